@@ -3,10 +3,15 @@ package main
 import (
 	"fmt"
 
+	"github.com/SantiagoBobrik/spec-viewer/pkg/ui"
+
 	"github.com/charmbracelet/lipgloss"
 )
 
-func PrintBanner(port, folder string) {
+// PrintBanner shows where the server can be opened: local on this machine
+// and network from others, with a QR code of the first network URL for
+// phones.
+func PrintBanner(local string, network []string, folder string) {
 	primary := lipgloss.NewStyle().Foreground(lipgloss.Color("63"))    // Indigo
 	secondary := lipgloss.NewStyle().Foreground(lipgloss.Color("250")) // Light Grey
 	accent := lipgloss.NewStyle().Foreground(lipgloss.Color("255"))    // White
@@ -18,26 +23,35 @@ func PrintBanner(port, folder string) {
  \__ \  _/  __/ (__    \ V /| | _| \ \/\/ /| _||   /
  |___/_|  \___|\___|    \_/ |_|___| \_/\_/ |___|_|_\
 `
-	fmt.Println(primary.Render(asciiArt))
+	out := ui.Output()
+	_, _ = fmt.Fprintln(out, primary.Render(asciiArt))
+
+	// row aligns the values of the labelled lines.
+	row := func(key, value string) string {
+		return fmt.Sprintf(" %s %s\n", bold.Render(fmt.Sprintf("%-12s", key)), value)
+	}
 
 	statusKey := secondary.Render("●  Server Running")
-	urlKey := bold.Render("➜  Local:")
-	urlValue := primary.Render(fmt.Sprintf("http://localhost:%s", port))
-	folderKey := bold.Render("➜  Folder:")
-	folderValue := secondary.Render(folder)
+	urls := row("➜  Local:", primary.Render(local))
+	for i, u := range network {
+		key := ""
+		if i == 0 {
+			key = "➜  Network:"
+		}
+		urls += row(key, primary.Render(u))
+	}
+	folderRow := row("➜  Folder:", secondary.Render(folder))
 	quitMsg := secondary.Render("Press Ctrl+C to stop")
 
 	content := fmt.Sprintf(`
  %s
 
- %s   %s
- %s   %s
-
+%s%s
  %s
 `,
 		accent.Render(statusKey),
-		urlKey, urlValue,
-		folderKey, folderValue,
+		urls,
+		folderRow,
 		quitMsg,
 	)
 
@@ -48,6 +62,13 @@ func PrintBanner(port, folder string) {
 		Width(60).
 		Render(content)
 
-	fmt.Println(box)
-	fmt.Println("")
+	_, _ = fmt.Fprintln(out, box)
+	_, _ = fmt.Fprintln(out, "")
+
+	if len(network) > 0 {
+		if code, err := ui.QRCode(network[0]); err == nil {
+			_, _ = fmt.Fprintln(out, secondary.Render("Scan to open "+network[0]+":"))
+			_, _ = fmt.Fprintln(out, code)
+		}
+	}
 }
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+	"github.com/SantiagoBobrik/spec-viewer/internal/export"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var exportOutput string
+
+var exportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export a spec to another format",
+	Long:  `Converts a markdown spec into a standalone document for sharing.`,
+}
+
+var exportPDFCmd = &cobra.Command{
+	Use:   "pdf <file>",
+	Short: "Export a spec to PDF",
+	Long: `Renders a markdown spec as a paginated PDF with a table of contents.
+The PDF is produced without a browser, so it also works on CI machines.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		src := args[0]
+		loadSpecConfig(filepath.Dir(src))
+		content, err := os.ReadFile(src)
+		if err != nil {
+			logger.Fatal("Failed to read file", "file", src, "error", err)
+		}
+
+		var buf bytes.Buffer
+		if err := export.PDF(&buf, filepath.ToSlash(filepath.Clean(src)), parseSpec(src, content)); err != nil {
+			logger.Fatal("Failed to export PDF", "file", src, "error", err)
+		}
+
+		writeExport(src, ".pdf", buf.Bytes())
+	},
+}
+
+var exportHTMLCmd = &cobra.Command{
+	Use:   "html <file>",
+	Short: "Export a spec to a standalone HTML file",
+	Long: `Renders a markdown spec as a single HTML file with its styles, theme toggle,
+diagrams and local images inlined, so it can be shared and opened offline.`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		src := args[0]
+		loadSpecConfig(filepath.Dir(src))
+		content, err := os.ReadFile(src)
+		if err != nil {
+			logger.Fatal("Failed to read file", "file", src, "error", err)
+		}
+
+		var buf bytes.Buffer
+		if err := export.HTML(&buf, filepath.ToSlash(filepath.Clean(src)), parseSpec(src, content), filepath.Dir(src)); err != nil {
+			logger.Fatal("Failed to export HTML", "file", src, "error", err)
+		}
+
+		writeExport(src, ".html", buf.Bytes())
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+	exportCmd.AddCommand(exportPDFCmd)
+	exportCmd.AddCommand(exportHTMLCmd)
+
+	exportCmd.PersistentFlags().StringVarP(&exportOutput, "output", "o", "", `Output file, or "-" for stdout (default: <file> with the new extension)`)
+}
+
+// loadSpecConfig applies the markdown settings of the optional config file
+// in dir, the folder of the exported or linted specs.
+func loadSpecConfig(dir string) {
+	cfg, err := config.Load(filepath.Join(dir, config.FileName), false)
+	if err != nil {
+		logger.Fatal("Failed to load config", "error", err)
+	}
+	configureMarkdown(cfg, "")
+}
+
+// parseSpec parses the spec at src, resolving its includes against the
+// working directory, or against the directory of src when it lies outside.
+func parseSpec(src string, content []byte) *markdown.Document {
+	clean := filepath.Clean(src)
+	if filepath.IsLocal(clean) {
+		return markdown.ParseFile(".", filepath.ToSlash(clean), content)
+	}
+	return markdown.ParseFile(filepath.Dir(clean), filepath.Base(clean), content)
+}
+
+// writeExport writes data to --output, defaulting to src with its extension
+// replaced by ext.
+func writeExport(src, ext string, data []byte) {
+	out := exportOutput
+	if out == "-" {
+		_, _ = os.Stdout.Write(data)
+		return
+	}
+	if out == "" {
+		out = strings.TrimSuffix(src, filepath.Ext(src)) + ext
+	}
+
+	if err := os.WriteFile(out, data, 0644); err != nil {
+		logger.Fatal("Failed to write export", "file", out, "error", err)
+	}
+	ui.PrintSuccess("Exported " + out)
+}
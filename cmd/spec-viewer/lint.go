@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/lint"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/ui"
+
+	"github.com/spf13/cobra"
+)
+
+var lintCmd = &cobra.Command{
+	Use:   "lint [folder]",
+	Short: "Check specs for problems",
+	Long: `Checks every markdown spec in a folder (default ./specs) and prints the
+problems found, such as diagram or formula syntax errors, as file:line: message.
+Exits with status 1 when there are problems, so it can run in CI.`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		dir := "./specs"
+		if len(args) == 1 {
+			dir = args[0]
+		}
+		loadSpecConfig(dir)
+
+		issues, err := lint.Folder(dir)
+		if err != nil {
+			logger.Fatal("Failed to lint specs", "folder", dir, "error", err)
+		}
+		if len(issues) == 0 {
+			ui.PrintSuccess("No problems found")
+			return
+		}
+
+		for _, issue := range issues {
+			issue.File = filepath.Join(dir, issue.File)
+			fmt.Println(issue)
+		}
+		fmt.Printf("\n%d problem(s) found\n", len(issues))
+		os.Exit(1)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(lintCmd)
+}
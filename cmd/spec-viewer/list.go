@@ -0,0 +1,43 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/instance"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List the running spec viewers",
+	Long: `Lists the spec viewers started by the current user that are still running,
+with the URL they serve and the folder they watch.`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		instances, err := instance.List()
+		if err != nil {
+			logger.Fatal("Failed to list running viewers", "error", err)
+		}
+		if len(instances) == 0 {
+			fmt.Println("No spec viewer is running")
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		_, _ = fmt.Fprintln(w, "PID\tURL\tFOLDER\tUPTIME")
+		for _, inst := range instances {
+			uptime := time.Since(inst.Started).Round(time.Second)
+			_, _ = fmt.Fprintf(w, "%d\t%s\t%s\t%s\n", inst.PID, inst.URL, inst.Folder, uptime)
+		}
+		_ = w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+}
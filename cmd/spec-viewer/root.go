@@ -4,12 +4,28 @@ import (
 	"fmt"
 	"os"
 
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/ui"
+
 	"github.com/spf13/cobra"
 )
 
 var (
-	port   string
-	folder string
+	port        string
+	host        string
+	folder      string
+	configPath  string
+	useTLS      bool
+	tlsCert     string
+	tlsKey      string
+	token       string
+	basicAuth   string
+	basePath    string
+	trustProxy  []string
+	openBrowser bool
+	logLevel    string
+	logFormat   string
+	logFile     string
 )
 
 var rootCmd = &cobra.Command{
@@ -18,6 +34,38 @@ var rootCmd = &cobra.Command{
 	Long: `Spec Viewer is a CLI tool that serves your local markdown specifications
 as a live-reloading website. It watches for changes in your folder
 and updates the browser automatically.`,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return configureLogger()
+	},
+}
+
+func init() {
+	rootCmd.PersistentFlags().StringVar(&logLevel, "log-level", "info", "Lowest level logged: debug, info, warn or error")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", logger.FormatText, "Log format: text or json")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Append the logs to this file instead of printing them")
+}
+
+// configureLogger sets up the logger from the --log-* flags. The log file
+// stays open until the process exits.
+func configureLogger() error {
+	level, err := logger.ParseLevel(logLevel)
+	if err != nil {
+		return err
+	}
+	opts := logger.Options{Level: level, Format: logFormat}
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return fmt.Errorf("opening log file: %w", err)
+		}
+		opts.Output = f
+	}
+	if logFormat == logger.FormatJSON {
+		// Keep stdout parseable: the banner and other messages for people
+		// go to stderr.
+		ui.SetOutput(os.Stderr)
+	}
+	return logger.Configure(opts)
 }
 
 func Execute() {
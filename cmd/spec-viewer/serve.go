@@ -2,12 +2,21 @@ package main
 
 import (
 	"context"
+	"crypto/tls"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
+	"github.com/SantiagoBobrik/spec-viewer/internal/activity"
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+	"github.com/SantiagoBobrik/spec-viewer/internal/instance"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/internal/private"
 	"github.com/SantiagoBobrik/spec-viewer/internal/server"
 	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
 	"github.com/SantiagoBobrik/spec-viewer/internal/templates"
@@ -28,25 +37,107 @@ var serveCmd = &cobra.Command{
 			logger.Fatal("Folder does not exist", "folder", folder, "error", err)
 		}
 
-		PrintBanner(port, folder)
+		settings, err := loadConfig()
+		if err != nil {
+			logger.Fatal("Failed to load config", "error", err)
+		}
+		hideConfig()
+		base := server.BasePath(basePath)
+		proxies, err := server.ParseProxies(trustProxy)
+		if err != nil {
+			logger.Fatal("Invalid --trust-proxy", "error", err)
+		}
+		configureMarkdown(settings, base)
+		templates.SetBasePath(base)
+
+		ln, port, err := server.Listen(host, port)
+		if err != nil {
+			logger.Fatal("Failed to listen", "error", err)
+		}
+
+		secure := useTLS || tlsCert != "" || tlsKey != ""
+		local, network := server.URLs(host, port, secure)
+		var tlsConfig *tls.Config
+		if secure {
+			// Without a state directory, the certificate is not kept.
+			stateDir, _ := instance.Dir()
+			tlsConfig, err = server.TLSConfig(tlsCert, tlsKey, append([]string{local}, network...), stateDir)
+			if err != nil {
+				logger.Fatal("Failed to set up TLS", "error", err)
+			}
+		}
+
+		auth, err := server.NewAuth(settings.Auth)
+		if err != nil {
+			logger.Fatal("Invalid auth settings", "error", err)
+		}
+		if token != "" {
+			auth.AddToken(token, server.ReadWrite)
+		}
+		if basicAuth != "" {
+			name, password, ok := strings.Cut(basicAuth, ":")
+			if !ok || name == "" || password == "" {
+				logger.Fatal("Invalid --basic-auth, expected user:password")
+			}
+			auth.AddUser(name, password, server.ReadWrite)
+		}
+		if len(network) > 0 && !auth.Enabled() {
+			logger.Warn("Anyone on the network can read the specs; use --token or --basic-auth to restrict access")
+		}
+
+		// Links in the banner log in with the token given on the command line,
+		// which the instance list, readable by other programs, leaves out.
+		viewURL := local + base
+		local = server.LoginURL(viewURL, token)
+		for i, u := range network {
+			network[i] = server.LoginURL(u+base, token)
+		}
+		PrintBanner(local, network, folder)
+
+		if abs, err := filepath.Abs(folder); err == nil {
+			if err := instance.Register(instance.Instance{PID: os.Getpid(), URL: viewURL, Folder: abs, Started: time.Now()}); err != nil {
+				logger.Warn("Failed to record the running instance", "error", err)
+			}
+			defer func() { _ = instance.Unregister(os.Getpid()) }()
+		}
+		if openBrowser {
+			if err := ui.OpenBrowser(local); err != nil {
+				logger.Warn("Failed to open the browser", "url", local, "error", err)
+			}
+		}
 
 		// Create context that listens for the interrupt signal from the OS.
 		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
 		defer stop()
 
 		hub := socket.NewHub()
+		recent := activity.NewLog(activity.DefaultLimit)
 
-		go watcher.Watch(ctx, folder, hub)
+		go watcher.Watch(ctx, folder, hub, recent)
 
 		srv := server.New(hub, server.Config{
-			Port:   port,
-			Folder: folder,
+			Host:           host,
+			Port:           port,
+			Folder:         folder,
+			Settings:       settings,
+			Activity:       recent,
+			TLS:            tlsConfig,
+			Auth:           auth,
+			BasePath:       base,
+			TrustedProxies: proxies,
 		})
 
 		templates.Init(folder)
 
 		go func() {
-			if err := srv.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			var err error
+			if secure {
+				// The certificate is already in srv.TLSConfig.
+				err = srv.ServeTLS(ln, "", "")
+			} else {
+				err = srv.Serve(ln)
+			}
+			if err != nil && err != http.ErrServerClosed {
 				logger.Fatal("Server failed", "error", err)
 			}
 		}()
@@ -72,6 +163,59 @@ var serveCmd = &cobra.Command{
 func init() {
 	rootCmd.AddCommand(serveCmd)
 
-	serveCmd.Flags().StringVarP(&port, "port", "p", "9091", "Port to run the server on")
+	serveCmd.Flags().StringVarP(&port, "port", "p", server.DefaultPort, "Port to run the server on, 0 for any free port or auto for the first free one from "+server.DefaultPort)
+	serveCmd.Flags().BoolVar(&openBrowser, "open", false, "Open the viewer in the default browser")
+	serveCmd.Flags().StringVar(&host, "host", server.DefaultHost, "Interface to listen on, 0.0.0.0 to share on the network")
+	serveCmd.Flags().BoolVar(&useTLS, "tls", false, "Serve HTTPS with a self-signed certificate, unless --tls-cert and --tls-key are given")
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file, enables HTTPS")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS private key file, enables HTTPS")
+	serveCmd.Flags().StringVar(&basePath, "base-path", "", "Path to serve under, such as /specs, behind a reverse proxy")
+	serveCmd.Flags().StringSliceVar(&trustProxy, "trust-proxy", nil, "Addresses or CIDR ranges of reverse proxies whose X-Forwarded-Host and X-Forwarded-Proto headers are trusted")
+	serveCmd.Flags().StringVar(&token, "token", "", "Require this token, given once as ?token= in the link, for read-write access")
+	serveCmd.Flags().StringVar(&basicAuth, "basic-auth", "", "Require this user:password with basic auth for read-write access")
 	serveCmd.Flags().StringVarP(&folder, "folder", "f", "./specs", "Folder to watch for specs")
+	serveCmd.Flags().StringVarP(&configPath, "config", "c", "", "Config file (default: <folder>/"+config.FileName+")")
+}
+
+// loadConfig reads the config file given by --config, falling back to the
+// optional config file in the spec folder.
+func loadConfig() (*config.Config, error) {
+	if configPath != "" {
+		return config.Load(configPath, true)
+	}
+	return config.Load(filepath.Join(folder, config.FileName), false)
+}
+
+// hideConfig keeps the config file given by --config private when it is in
+// the spec folder, as its credentials would otherwise be served like any
+// other artifact. The default config file is hidden by its name.
+func hideConfig() {
+	if configPath == "" {
+		return
+	}
+	abs, err := filepath.Abs(configPath)
+	if err != nil {
+		return
+	}
+	root, err := filepath.Abs(folder)
+	if err != nil {
+		return
+	}
+	if rel, err := filepath.Rel(root, abs); err == nil && !strings.HasPrefix(rel, "..") {
+		private.Hide(rel)
+	}
+}
+
+// configureMarkdown enables the optional markdown syntax selected in cfg
+// and the artifacts it lists, and prefixes links to other specs with
+// basePath.
+func configureMarkdown(cfg *config.Config, basePath string) {
+	artifact.Configure(cfg.Artifacts)
+	markdown.Configure(markdown.Options{
+		Alerts:          cfg.Markdown.Alerts,
+		Footnotes:       cfg.Markdown.Footnotes,
+		DefinitionLists: cfg.Markdown.DefinitionLists,
+		Typographer:     cfg.Markdown.Typographer,
+		BasePath:        basePath,
+	})
 }
@@ -0,0 +1,83 @@
+// Package activity keeps the specs recently viewed in the viewer and the
+// ones recently changed on disk, most recent first.
+package activity
+
+import (
+	"slices"
+	"sync"
+	"time"
+)
+
+// DefaultLimit is the number of specs each list keeps.
+const DefaultLimit = 10
+
+// Entry is a spec, by its slash-separated path relative to the spec folder,
+// and when it was last viewed or changed.
+type Entry struct {
+	File string
+	Time time.Time
+}
+
+// Log records recently viewed and changed specs. It is safe for concurrent
+// use.
+type Log struct {
+	mu      sync.Mutex
+	limit   int
+	viewed  []Entry
+	changed []Entry
+}
+
+// NewLog returns a Log keeping the last limit specs of each list.
+func NewLog(limit int) *Log {
+	return &Log{limit: limit}
+}
+
+// Viewed records that file was opened in the viewer.
+func (l *Log) Viewed(file string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.viewed = l.push(l.viewed, file)
+}
+
+// Changed records that file was written or created.
+func (l *Log) Changed(file string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.changed = l.push(l.changed, file)
+}
+
+// Removed forgets file, which no longer exists.
+func (l *Log) Removed(file string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.viewed = without(l.viewed, file)
+	l.changed = without(l.changed, file)
+}
+
+// RecentlyViewed returns the specs last viewed, most recent first.
+func (l *Log) RecentlyViewed() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.viewed)
+}
+
+// RecentlyChanged returns the specs last changed, most recent first.
+func (l *Log) RecentlyChanged() []Entry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return slices.Clone(l.changed)
+}
+
+// push moves file to the front of entries, dropping the oldest entries
+// past the limit.
+func (l *Log) push(entries []Entry, file string) []Entry {
+	entries = append([]Entry{{File: file, Time: time.Now()}}, without(entries, file)...)
+	if len(entries) > l.limit {
+		entries = entries[:l.limit]
+	}
+	return entries
+}
+
+func without(entries []Entry, file string) []Entry {
+	return slices.DeleteFunc(entries, func(e Entry) bool { return e.File == file })
+}
@@ -0,0 +1,64 @@
+package activity
+
+import (
+	"slices"
+	"sync"
+	"testing"
+)
+
+func files(entries []Entry) []string {
+	var files []string
+	for _, e := range entries {
+		files = append(files, e.File)
+	}
+	return files
+}
+
+func TestLog(t *testing.T) {
+	log := NewLog(3)
+	for _, file := range []string{"a.md", "b.md", "c.md", "a.md", "d.md"} {
+		log.Viewed(file)
+	}
+	if got, want := files(log.RecentlyViewed()), []string{"d.md", "a.md", "c.md"}; !slices.Equal(got, want) {
+		t.Errorf("expected viewed %v, got %v", want, got)
+	}
+
+	log.Changed("c.md")
+	log.Changed("e.md")
+	if got, want := files(log.RecentlyChanged()), []string{"e.md", "c.md"}; !slices.Equal(got, want) {
+		t.Errorf("expected changed %v, got %v", want, got)
+	}
+
+	log.Removed("c.md")
+	if got := append(files(log.RecentlyViewed()), files(log.RecentlyChanged())...); slices.Contains(got, "c.md") {
+		t.Errorf("expected the removed spec to be forgotten, got %v", got)
+	}
+
+	entries := log.RecentlyChanged()
+	if entries[0].Time.IsZero() {
+		t.Error("expected entries to be timestamped")
+	}
+	entries[0].File = "changed.md"
+	if log.RecentlyChanged()[0].File != "e.md" {
+		t.Error("expected RecentlyChanged to return a copy")
+	}
+}
+
+func TestLog_Concurrent(t *testing.T) {
+	log := NewLog(DefaultLimit)
+	var wg sync.WaitGroup
+	for i := range 20 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			file := string(rune('a'+i)) + ".md"
+			log.Viewed(file)
+			log.Changed(file)
+			_ = log.RecentlyViewed()
+		}()
+	}
+	wg.Wait()
+	if n := len(log.RecentlyViewed()); n != DefaultLimit {
+		t.Errorf("expected %d viewed specs, got %d", DefaultLimit, n)
+	}
+}
@@ -0,0 +1,117 @@
+// Package artifact displays the files kept next to the specs that are not
+// markdown, such as sample payloads, CSV fixtures and SQL migrations.
+//
+// An artifact is rendered as a markdown document holding a single fenced
+// block, so it shares the viewer's layout, comments and exports with the
+// specs. JSON and YAML fences marked "tree" are shown as collapsible trees
+// and CSV fences marked "table" as sortable tables.
+package artifact
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"strings"
+	"sync"
+)
+
+// DefaultExtensions are the extensions of the files listed as artifacts
+// when none are configured.
+var DefaultExtensions = []string{".json", ".yaml", ".yml", ".csv", ".sql"}
+
+var (
+	mu         sync.RWMutex
+	extensions = DefaultExtensions
+)
+
+// Configure sets the extensions of the files listed as artifacts. Leading
+// dots are optional and case is ignored.
+func Configure(exts []string) {
+	normalized := make([]string, 0, len(exts))
+	for _, ext := range exts {
+		ext = strings.ToLower(strings.TrimSpace(ext))
+		if ext == "" {
+			continue
+		}
+		if !strings.HasPrefix(ext, ".") {
+			ext = "." + ext
+		}
+		normalized = append(normalized, ext)
+	}
+	mu.Lock()
+	extensions = normalized
+	mu.Unlock()
+}
+
+// Supported reports whether name has one of the configured extensions.
+func Supported(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	if ext == "" {
+		return false
+	}
+	mu.RLock()
+	defer mu.RUnlock()
+	for _, e := range extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Language returns the fence language of the file name: its extension,
+// with YAML spelled out.
+func Language(name string) string {
+	lang := strings.TrimPrefix(strings.ToLower(path.Ext(name)), ".")
+	if lang == "yml" {
+		return "yaml"
+	}
+	return lang
+}
+
+// View returns the fence flag that shows code in lang as data rather than
+// as source: "tree" for JSON and YAML, "table" for CSV, or "".
+func View(lang string) string {
+	switch strings.ToLower(lang) {
+	case "json", "yaml", "yml":
+		return "tree"
+	case "csv", "tsv":
+		return "table"
+	}
+	return ""
+}
+
+// Markdown returns the artifact name, with the given content, as a
+// markdown document titled after the file.
+func Markdown(name string, content []byte) []byte {
+	return fmt.Appendf(nil, "# %s\n\n%s", escape(path.Base(name)), Block(name, content))
+}
+
+// Block returns the content of the artifact name as the fenced block that
+// displays it, to be embedded in a spec.
+func Block(name string, content []byte) []byte {
+	lang := Language(name)
+	return Fence(strings.TrimSpace(lang+" "+View(lang)), content)
+}
+
+// Fence returns content as a fenced code block with the given info string,
+// using a fence longer than any backtick run in content.
+func Fence(info string, content []byte) []byte {
+	fence := "```"
+	for bytes.Contains(content, []byte(fence)) {
+		fence += "`"
+	}
+	return fmt.Appendf(nil, "%s%s\n%s\n%s\n", fence, info, bytes.TrimRight(content, "\n"), fence)
+}
+
+// escape escapes the markdown punctuation file names may contain.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>#", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
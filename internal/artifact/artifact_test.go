@@ -0,0 +1,89 @@
+package artifact
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConfigure(t *testing.T) {
+	defer Configure(DefaultExtensions)
+	for name, want := range map[string]bool{"a.json": true, "b.YML": true, "c.sql": true, "d.txt": false, "e": false} {
+		if got := Supported(name); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", name, got, want)
+		}
+	}
+
+	Configure([]string{"TSV", ".Json", " "})
+	for name, want := range map[string]bool{"a.json": true, "b.tsv": true, "c.sql": false} {
+		if got := Supported(name); got != want {
+			t.Errorf("Supported(%q) = %v with the configured extensions, want %v", name, got, want)
+		}
+	}
+}
+
+func TestMarkdown(t *testing.T) {
+	tests := []struct {
+		name, content, want string
+	}{
+		{"samples/payload.json", "{}\n", "# payload.json\n\n```json tree\n{}\n```\n"},
+		{"config.yml", "a: 1", "# config.yml\n\n```yaml tree\na: 1\n```\n"},
+		{"fixtures/users_v2.csv", "id\n1\n", "# users\\_v2.csv\n\n```csv table\nid\n1\n```\n"},
+		{"001.sql", "-- ```\nSELECT 1;\n", "# 001.sql\n\n````sql\n-- ```\nSELECT 1;\n````\n"},
+	}
+	for _, tt := range tests {
+		if got := string(Markdown(tt.name, []byte(tt.content))); got != tt.want {
+			t.Errorf("Markdown(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestTree(t *testing.T) {
+	src := "# Payment\nid: 7\nowner:\n  name: Jane\n  tags: [a, b]\n  address:\n    city: Lima\nnotes: ~\nitems: []\n"
+	tree, err := Tree([]byte(src))
+	if err != nil {
+		t.Fatalf("Tree returned error: %v", err)
+	}
+	out := string(tree)
+	for _, want := range []string{
+		`<ul class="data-tree"><li><details open><summary><span class="data-tree-meta">{ 4 keys }</span></summary><ul>`,
+		`<li><span class="tok-key">id</span>: <span class="tok-number">7</span></li>`,
+		`<li><details open><summary><span class="tok-key">owner</span>: <span class="data-tree-meta">{ 3 keys }</span></summary>`,
+		`<li><span class="data-tree-index">0</span>: <span class="tok-string">&#34;a&#34;</span></li>`,
+		`<li><details><summary><span class="tok-key">address</span>: <span class="data-tree-meta">{ 1 key }</span></summary>`,
+		`<li><span class="tok-key">notes</span>: <span class="tok-literal">null</span></li>`,
+		`<li><span class="tok-key">items</span>: <span class="data-tree-meta">[]</span></li>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected tree to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	if tree, err := Tree([]byte("a: 1\n---\nb: 2\n")); err != nil || !strings.Contains(string(tree), "Document 2") {
+		t.Errorf("expected a tree per YAML document, got %s, %v", tree, err)
+	}
+	if _, err := Tree([]byte(`{"a": [1,}`)); err == nil {
+		t.Error("expected error for invalid JSON")
+	}
+}
+
+func TestTable(t *testing.T) {
+	table, err := Table([]byte("name,amount\nJane,12.5\n\"Doe, J\",3\nshort\n"), ',')
+	if err != nil {
+		t.Fatalf("Table returned error: %v", err)
+	}
+	want := `<table class="data-table" data-sortable><thead><tr>` +
+		`<th scope="col" aria-sort="none"><button type="button">name</button></th>` +
+		`<th scope="col" class="numeric" aria-sort="none"><button type="button">amount</button></th>` +
+		`</tr></thead><tbody>` +
+		`<tr><td>Jane</td><td class="numeric">12.5</td></tr>` +
+		`<tr><td>Doe, J</td><td class="numeric">3</td></tr>` +
+		`<tr><td>short</td><td class="numeric"></td></tr>` +
+		`</tbody></table>`
+	if string(table) != want {
+		t.Errorf("got\n%s\nwant\n%s", table, want)
+	}
+
+	if table, err := Table([]byte("a\tb\n1\t2\n"), Comma("tsv")); err != nil || !strings.Contains(string(table), `<td class="numeric">1</td><td class="numeric">2</td>`) {
+		t.Errorf("expected a tab separated table, got %s, %v", table, err)
+	}
+}
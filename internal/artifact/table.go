@@ -0,0 +1,90 @@
+package artifact
+
+import (
+	"bytes"
+	"encoding/csv"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// Table returns CSV content as an HTML table whose first row is the
+// header. Rows shorter than the header are padded. The table is marked
+// sortable so the viewer sorts it by the column header clicked.
+func Table(content []byte, comma rune) ([]byte, error) {
+	r := csv.NewReader(bytes.NewReader(content))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+	records, err := r.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<table class="data-table" data-sortable>`)
+	if len(records) == 0 {
+		b.WriteString("</table>")
+		return b.Bytes(), nil
+	}
+	header, rows := records[0], records[1:]
+	width := len(header)
+	for _, row := range rows {
+		width = max(width, len(row))
+	}
+
+	// Numeric columns are right-aligned and sort by value.
+	align := make([]string, width)
+	for i := range width {
+		if numeric(rows, i) {
+			align[i] = ` class="numeric"`
+		}
+	}
+
+	b.WriteString("<thead><tr>")
+	for i := range width {
+		name := ""
+		if i < len(header) {
+			name = header[i]
+		}
+		b.WriteString(`<th scope="col"` + align[i] + ` aria-sort="none"><button type="button">` + html.EscapeString(name) + "</button></th>")
+	}
+	b.WriteString("</tr></thead><tbody>")
+	for _, row := range rows {
+		b.WriteString("<tr>")
+		for i := range width {
+			cell := ""
+			if i < len(row) {
+				cell = row[i]
+			}
+			b.WriteString("<td" + align[i] + ">" + html.EscapeString(cell) + "</td>")
+		}
+		b.WriteString("</tr>")
+	}
+	b.WriteString("</tbody></table>")
+	return b.Bytes(), nil
+}
+
+// numeric reports whether every non-empty cell of column i is a number.
+func numeric(rows [][]string, i int) bool {
+	seen := false
+	for _, row := range rows {
+		if i >= len(row) || strings.TrimSpace(row[i]) == "" {
+			continue
+		}
+		if _, err := strconv.ParseFloat(strings.TrimSpace(row[i]), 64); err != nil {
+			return false
+		}
+		seen = true
+	}
+	return seen
+}
+
+// Comma returns the field separator of a CSV fence language: a tab for
+// "tsv", a comma otherwise.
+func Comma(lang string) rune {
+	if strings.EqualFold(lang, "tsv") {
+		return '\t'
+	}
+	return ','
+}
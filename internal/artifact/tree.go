@@ -0,0 +1,131 @@
+package artifact
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"strconv"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/highlight"
+	"gopkg.in/yaml.v3"
+)
+
+// openDepth is how many levels of a tree start expanded.
+const openDepth = 2
+
+// Tree returns JSON or YAML content as nested lists of collapsible
+// <details> elements, one per object and array, with keys and values
+// highlighted. Documents of a YAML stream become siblings.
+func Tree(content []byte) ([]byte, error) {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	for {
+		var doc yaml.Node
+		err := dec.Decode(&doc)
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(doc.Content) > 0 {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+
+	var b bytes.Buffer
+	b.WriteString(`<ul class="data-tree">`)
+	for i, doc := range docs {
+		label := ""
+		if len(docs) > 1 {
+			label = fmt.Sprintf("Document %d", i+1)
+		}
+		writeNode(&b, label, false, doc, 0)
+	}
+	b.WriteString("</ul>")
+	return b.Bytes(), nil
+}
+
+// writeNode writes a node as an item labelled with its key, or its index
+// when index is set.
+func writeNode(b *bytes.Buffer, label string, index bool, n *yaml.Node, depth int) {
+	if n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	b.WriteString("<li>")
+	writeLabel := func() {
+		switch {
+		case label == "":
+		case index:
+			b.WriteString(`<span class="data-tree-index">` + html.EscapeString(label) + "</span>: ")
+		default:
+			b.WriteString(`<span class="` + highlight.Key + `">` + html.EscapeString(label) + "</span>: ")
+		}
+	}
+
+	switch n.Kind {
+	case yaml.MappingNode, yaml.SequenceNode:
+		count, open, close := len(n.Content), "[", "]"
+		if n.Kind == yaml.MappingNode {
+			count, open, close = len(n.Content)/2, "{", "}"
+		}
+		if count == 0 {
+			writeLabel()
+			b.WriteString(`<span class="data-tree-meta">` + open + close + "</span></li>")
+			return
+		}
+		b.WriteString("<details")
+		if depth < openDepth {
+			b.WriteString(" open")
+		}
+		b.WriteString("><summary>")
+		writeLabel()
+		noun := "items"
+		if n.Kind == yaml.MappingNode {
+			noun = "keys"
+		}
+		if count == 1 {
+			noun = noun[:len(noun)-1]
+		}
+		fmt.Fprintf(b, `<span class="data-tree-meta">%s %d %s %s</span></summary><ul>`, open, count, noun, close)
+		if n.Kind == yaml.MappingNode {
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				writeNode(b, n.Content[i].Value, false, n.Content[i+1], depth+1)
+			}
+		} else {
+			for i, item := range n.Content {
+				writeNode(b, strconv.Itoa(i), true, item, depth+1)
+			}
+		}
+		b.WriteString("</ul></details></li>")
+	default:
+		writeLabel()
+		b.WriteString(`<span class="` + scalarClass(n) + `">` + html.EscapeString(scalarText(n)) + "</span></li>")
+	}
+}
+
+func scalarClass(n *yaml.Node) string {
+	switch n.ShortTag() {
+	case "!!int", "!!float":
+		return highlight.Number
+	case "!!bool", "!!null":
+		return highlight.Literal
+	}
+	return highlight.String
+}
+
+// scalarText shows strings quoted, as JSON would, and other scalars as
+// written.
+func scalarText(n *yaml.Node) string {
+	switch n.ShortTag() {
+	case "!!str", "!!binary", "!!timestamp":
+		return strconv.Quote(n.Value)
+	case "!!null":
+		if n.Value == "" || n.Value == "~" {
+			return "null"
+		}
+	}
+	return n.Value
+}
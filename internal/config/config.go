@@ -0,0 +1,130 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FileName is the config file looked up in the spec folder when no explicit
+// path is given.
+const FileName = ".spec-viewer.yaml"
+
+// Config holds the user-level settings read from the config file.
+type Config struct {
+	Export   ExportConfig   `yaml:"export"`
+	Review   ReviewConfig   `yaml:"review"`
+	Markdown MarkdownConfig `yaml:"markdown"`
+	Auth     AuthConfig     `yaml:"auth"`
+	// Artifacts lists the extensions of the files other than markdown
+	// listed and displayed with the specs, such as ".json" or ".csv".
+	Artifacts []string `yaml:"artifacts"`
+
+	// dir is the directory the config file was loaded from. Relative paths
+	// in the file are resolved against it.
+	dir string
+}
+
+// ExportConfig configures how review comments are exported as prompts.
+type ExportConfig struct {
+	// DefaultTemplate is the template used when a request names none.
+	DefaultTemplate string `yaml:"default_template"`
+	// Templates maps template names to text/template files.
+	Templates map[string]string `yaml:"templates"`
+}
+
+// ReviewConfig configures review comments.
+type ReviewConfig struct {
+	// Author is the default name comments are attributed to. When empty,
+	// git's user.name for the spec folder is used.
+	Author string `yaml:"author"`
+}
+
+// MarkdownConfig toggles the optional markdown syntax. Settings left out of
+// the file keep their defaults.
+type MarkdownConfig struct {
+	// Alerts renders blockquotes starting with [!NOTE], [!TIP],
+	// [!IMPORTANT], [!WARNING] or [!CAUTION] as callouts.
+	Alerts bool `yaml:"alerts"`
+	// Footnotes enables [^label] footnotes.
+	Footnotes bool `yaml:"footnotes"`
+	// DefinitionLists enables definition lists.
+	DefinitionLists bool `yaml:"definition_lists"`
+	// Typographer turns straight quotes, dashes and ellipses into curly
+	// quotes, en and em dashes and ellipsis characters.
+	Typographer bool `yaml:"typographer"`
+}
+
+// AuthConfig restricts who can open the viewer. Without tokens or users,
+// anyone who can reach the server can.
+type AuthConfig struct {
+	// Tokens are shared secrets, given once as ?token= in a link.
+	Tokens []TokenConfig `yaml:"tokens"`
+	// Users sign in with basic auth.
+	Users []UserConfig `yaml:"users"`
+}
+
+// TokenConfig is a shared token and the role of its holders, "read-only"
+// or "read-write". Tokens without a role are read-only.
+type TokenConfig struct {
+	Token string `yaml:"token"`
+	// TokenSHA256 is the hex SHA-256 of the token, to keep the token itself
+	// out of the file.
+	TokenSHA256 string `yaml:"token_sha256"`
+	Role        string `yaml:"role"`
+}
+
+// UserConfig is a basic auth user and its role, as for tokens.
+type UserConfig struct {
+	Name     string `yaml:"name"`
+	Password string `yaml:"password"`
+	// PasswordSHA256 is the hex SHA-256 of the password, to keep the
+	// password itself out of the file.
+	PasswordSHA256 string `yaml:"password_sha256"`
+	Role           string `yaml:"role"`
+}
+
+// Default returns the configuration used when no config file exists.
+func Default() *Config {
+	return &Config{
+		Markdown: MarkdownConfig{
+			Alerts:          true,
+			Footnotes:       true,
+			DefinitionLists: true,
+		},
+		Artifacts: []string{".json", ".yaml", ".yml", ".csv", ".sql"},
+		dir:       ".",
+	}
+}
+
+// Load reads the config file at path. When required is false a missing file
+// is not an error and the defaults are returned instead.
+func Load(path string, required bool) (*Config, error) {
+	cfg := Default()
+	cfg.dir = filepath.Dir(path)
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && !required {
+			return cfg, nil
+		}
+		return nil, err
+	}
+
+	if err := yaml.Unmarshal(data, cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return cfg, nil
+}
+
+// Resolve returns p relative to the directory of the config file, leaving
+// absolute paths untouched.
+func (c *Config) Resolve(p string) string {
+	if filepath.IsAbs(p) {
+		return p
+	}
+	return filepath.Join(c.dir, p)
+}
@@ -0,0 +1,381 @@
+// Package contract documents the API contracts of a spec: OpenAPI and
+// JSON Schema files, written in YAML or JSON.
+//
+// Contracts are rendered as markdown, so they share the viewer's layout,
+// table of contents, comments and exports with the specs themselves.
+package contract
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+// Extensions lists the file extensions contracts are read from.
+var Extensions = []string{".yaml", ".yml", ".json"}
+
+// Folder is the folder of a feature that holds its contracts, as in Spec
+// Kit.
+const Folder = "contracts"
+
+// IsContract reports whether the file at the slash-separated path name is
+// one of the contracts listed with the specs: a YAML or JSON file inside a
+// contracts folder.
+func IsContract(name string) bool {
+	if !Supported(name) {
+		return false
+	}
+	for _, dir := range strings.Split(path.Dir(name), "/") {
+		if dir == Folder {
+			return true
+		}
+	}
+	return false
+}
+
+// Supported reports whether name has the extension of a contract file.
+func Supported(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range Extensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// Kind is the format of a contract.
+type Kind int
+
+const (
+	OpenAPI Kind = iota + 1
+	JSONSchema
+)
+
+// ErrUnknownFormat is returned for files that are neither OpenAPI
+// documents nor JSON Schemas.
+var ErrUnknownFormat = errors.New("not an OpenAPI document or a JSON Schema")
+
+// methods lists the HTTP methods of OpenAPI path items, in the order they
+// are documented.
+var methods = []string{"get", "put", "post", "delete", "options", "head", "patch", "trace"}
+
+// Contract is a parsed OpenAPI document or JSON Schema.
+type Contract struct {
+	Kind        Kind
+	Title       string
+	Version     string
+	Description string
+	Servers     []string
+	Operations  []Operation
+	// Schemas are the named schemas: components.schemas and definitions
+	// for OpenAPI, $defs and definitions for JSON Schema.
+	Schemas []Schema
+
+	// root is the whole document, which $refs point into.
+	root *object
+	// schema is the root schema of a JSON Schema.
+	schema *object
+}
+
+// Schema is a named schema.
+type Schema struct {
+	Name string
+	def  *object
+}
+
+// Operation is an OpenAPI operation, a method on a path.
+type Operation struct {
+	ID          string
+	Method      string
+	Path        string
+	Summary     string
+	Description string
+	Tags        []string
+	Deprecated  bool
+	Parameters  []Parameter
+	RequestBody *Body
+	Responses   []Response
+}
+
+// Parameter is a path, query, header or cookie parameter of an operation.
+type Parameter struct {
+	Name        string
+	In          string
+	Description string
+	Required    bool
+	Deprecated  bool
+	schema      *object
+}
+
+// Body is the request body of an operation.
+type Body struct {
+	Description string
+	Required    bool
+	Content     []Media
+}
+
+// Response is a response of an operation, by status code.
+type Response struct {
+	Status      string
+	Description string
+	Content     []Media
+}
+
+// Media is the schema and example of a body for one media type.
+type Media struct {
+	Type    string
+	schema  *object
+	example any
+}
+
+// Parse parses the contract in data, the content of the file name. The
+// title of a JSON Schema without one is the base name of the file.
+func Parse(name string, data []byte) (*Contract, error) {
+	v, err := decode(data)
+	if err != nil {
+		return nil, err
+	}
+	root, ok := v.(*object)
+	if !ok {
+		return nil, ErrUnknownFormat
+	}
+
+	switch {
+	case root.get("openapi") != nil || root.get("swagger") != nil:
+		return parseOpenAPI(root), nil
+	case root.get("$schema") != nil || root.get("properties") != nil || root.get("$defs") != nil ||
+		root.get("type") != nil && root.get("paths") == nil:
+		return parseSchema(name, root), nil
+	}
+	return nil, ErrUnknownFormat
+}
+
+func parseOpenAPI(root *object) *Contract {
+	info := root.obj("info")
+	c := &Contract{
+		Kind:        OpenAPI,
+		Title:       info.str("title"),
+		Version:     info.str("version"),
+		Description: info.str("description"),
+		root:        root,
+	}
+
+	for _, s := range root.list("servers") {
+		if s, ok := s.(*object); ok && s.str("url") != "" {
+			c.Servers = append(c.Servers, s.str("url"))
+		}
+	}
+	// Swagger 2.0 describes a single server.
+	if host := root.str("host"); host != "" {
+		scheme := "https"
+		if schemes := root.list("schemes"); len(schemes) > 0 {
+			scheme = fmt.Sprint(schemes[0])
+		}
+		c.Servers = append(c.Servers, scheme+"://"+host+root.str("basePath"))
+	}
+
+	paths := root.obj("paths")
+	for _, p := range paths.keysOrNil() {
+		item, _ := c.resolve(paths.obj(p))
+		for _, m := range methods {
+			op := item.obj(m)
+			if op == nil {
+				continue
+			}
+			c.Operations = append(c.Operations, c.operation(strings.ToUpper(m), p, item, op))
+		}
+	}
+
+	c.Schemas = namedSchemas(root.obj("components").obj("schemas"), root.obj("definitions"))
+	return c
+}
+
+func parseSchema(name string, root *object) *Contract {
+	title := root.str("title")
+	if title == "" {
+		base := path.Base(name)
+		title = strings.TrimSuffix(base, path.Ext(base))
+	}
+	return &Contract{
+		Kind:        JSONSchema,
+		Title:       title,
+		Description: root.str("description"),
+		Schemas:     namedSchemas(root.obj("$defs"), root.obj("definitions")),
+		root:        root,
+		schema:      root,
+	}
+}
+
+// keysOrNil returns the keys of o, which may be nil.
+func (o *object) keysOrNil() []string {
+	if o == nil {
+		return nil
+	}
+	return o.keys
+}
+
+func namedSchemas(sets ...*object) []Schema {
+	var schemas []Schema
+	for _, set := range sets {
+		for _, name := range set.keysOrNil() {
+			if def := set.obj(name); def != nil {
+				schemas = append(schemas, Schema{Name: name, def: def})
+			}
+		}
+	}
+	return schemas
+}
+
+// operation collects an operation with the parameters of its path item.
+func (c *Contract) operation(method, p string, item, op *object) Operation {
+	o := Operation{
+		ID:          op.str("operationId"),
+		Method:      method,
+		Path:        p,
+		Summary:     op.str("summary"),
+		Description: op.str("description"),
+		Deprecated:  op.flag("deprecated"),
+	}
+	for _, t := range op.list("tags") {
+		o.Tags = append(o.Tags, fmt.Sprint(t))
+	}
+
+	// Operation parameters override path item ones with the same name and
+	// location.
+	params := make(map[string]int)
+	for _, list := range [][]any{item.list("parameters"), op.list("parameters")} {
+		for _, raw := range list {
+			def, _ := c.resolve(asObject(raw))
+			if def == nil {
+				continue
+			}
+			if def.str("in") == "body" {
+				// Swagger 2.0 request body.
+				o.RequestBody = &Body{
+					Description: def.str("description"),
+					Required:    def.flag("required"),
+					Content:     []Media{{Type: "application/json", schema: def.obj("schema")}},
+				}
+				continue
+			}
+			param := Parameter{
+				Name:        def.str("name"),
+				In:          def.str("in"),
+				Description: def.str("description"),
+				Required:    def.flag("required"),
+				Deprecated:  def.flag("deprecated"),
+				schema:      def.obj("schema"),
+			}
+			if param.schema == nil && def.get("type") != nil {
+				// Swagger 2.0 parameters carry their type themselves.
+				param.schema = def
+			}
+			key := param.In + ":" + param.Name
+			if i, ok := params[key]; ok {
+				o.Parameters[i] = param
+				continue
+			}
+			params[key] = len(o.Parameters)
+			o.Parameters = append(o.Parameters, param)
+		}
+	}
+
+	if body, _ := c.resolve(op.obj("requestBody")); body != nil {
+		o.RequestBody = &Body{
+			Description: body.str("description"),
+			Required:    body.flag("required"),
+			Content:     c.media(body.obj("content")),
+		}
+	}
+
+	responses := op.obj("responses")
+	for _, status := range responses.keysOrNil() {
+		def, _ := c.resolve(responses.obj(status))
+		r := Response{Status: status, Description: def.str("description"), Content: c.media(def.obj("content"))}
+		if s := def.obj("schema"); s != nil {
+			// Swagger 2.0 response.
+			r.Content = []Media{{Type: "application/json", schema: s, example: def.obj("examples").get("application/json")}}
+		}
+		o.Responses = append(o.Responses, r)
+	}
+	return o
+}
+
+// media collects the media types of a content map with their example: the
+// example, the first of the examples, or none.
+func (c *Contract) media(content *object) []Media {
+	var media []Media
+	for _, typ := range content.keysOrNil() {
+		def := content.obj(typ)
+		m := Media{Type: typ, schema: def.obj("schema"), example: def.get("example")}
+		if m.example == nil {
+			if examples := def.obj("examples"); examples != nil && len(examples.keys) > 0 {
+				ex, _ := c.resolve(examples.obj(examples.keys[0]))
+				m.example = ex.get("value")
+			}
+		}
+		media = append(media, m)
+	}
+	return media
+}
+
+func asObject(v any) *object {
+	o, _ := v.(*object)
+	return o
+}
+
+// resolve follows the $ref of def, if any, within the document, returning
+// the definition it points to and the name of the referenced schema. A
+// $ref to another file resolves to nil.
+func (c *Contract) resolve(def *object) (*object, string) {
+	name := ""
+	for range 16 {
+		ref := def.str("$ref")
+		if ref == "" {
+			return def, name
+		}
+		target, ok := strings.CutPrefix(ref, "#")
+		if !ok {
+			return nil, path.Base(ref)
+		}
+		var v any = c.root
+		for _, part := range strings.Split(strings.TrimPrefix(target, "/"), "/") {
+			if part == "" {
+				continue
+			}
+			part = strings.ReplaceAll(strings.ReplaceAll(part, "~1", "/"), "~0", "~")
+			v = asObject(v).get(part)
+		}
+		def = asObject(v)
+		name = path.Base(target)
+		if def == nil {
+			return nil, name
+		}
+	}
+	return nil, name
+}
+
+// Find returns the operation whose operationId is ref or that ref names
+// as "METHOD /path".
+func (c *Contract) Find(ref string) (Operation, bool) {
+	method, p, _ := strings.Cut(strings.TrimSpace(ref), " ")
+	for _, op := range c.Operations {
+		if op.ID != "" && op.ID == ref ||
+			strings.EqualFold(op.Method, method) && op.Path == strings.TrimSpace(p) {
+			return op, true
+		}
+	}
+	return Operation{}, false
+}
+
+// schemaNamed returns the named schema called name.
+func (c *Contract) schemaNamed(name string) (Schema, bool) {
+	for _, s := range c.Schemas {
+		if s.Name == name {
+			return s, true
+		}
+	}
+	return Schema{}, false
+}
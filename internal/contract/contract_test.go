@@ -0,0 +1,281 @@
+package contract
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+const openAPI = `openapi: 3.0.3
+info:
+  title: Payments API
+  version: 1.2.0
+  description: Takes payments.
+servers:
+  - url: https://api.example.com/v1
+paths:
+  /payments:
+    get:
+      operationId: listPayments
+      tags: [payments]
+      summary: List payments
+      parameters:
+        - name: limit
+          in: query
+          schema: {type: integer, minimum: 1, maximum: 100}
+      responses:
+        "200":
+          description: The payments.
+          content:
+            application/json:
+              schema:
+                type: array
+                items: {$ref: "#/components/schemas/Payment"}
+    post:
+      operationId: createPayment
+      tags: [payments]
+      summary: Create a payment
+      requestBody:
+        required: true
+        content:
+          application/json:
+            schema: {$ref: "#/components/schemas/NewPayment"}
+      responses:
+        "201":
+          description: Created.
+          content:
+            application/json:
+              schema: {$ref: "#/components/schemas/Payment"}
+  /payments/{id}:
+    parameters:
+      - name: id
+        in: path
+        required: true
+        schema: {type: string, format: uuid}
+    delete:
+      deprecated: true
+      responses:
+        "204":
+          description: Deleted.
+components:
+  schemas:
+    NewPayment:
+      type: object
+      required: [amount]
+      properties:
+        amount: {type: integer, description: In cents., example: 1250}
+        currency: {type: string, enum: [EUR, USD]}
+    Payment:
+      allOf:
+        - $ref: "#/components/schemas/NewPayment"
+        - type: object
+          required: [id]
+          properties:
+            id: {type: string, format: uuid, readOnly: true}
+            refunds:
+              type: array
+              items: {$ref: "#/components/schemas/Payment"}
+`
+
+func TestParse_OpenAPI(t *testing.T) {
+	c, err := Parse("contracts/payments.yaml", []byte(openAPI))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if c.Kind != OpenAPI || c.Title != "Payments API" || c.Version != "1.2.0" {
+		t.Errorf("unexpected contract %+v", c)
+	}
+	if len(c.Servers) != 1 || c.Servers[0] != "https://api.example.com/v1" {
+		t.Errorf("unexpected servers %v", c.Servers)
+	}
+
+	var ops []string
+	for _, op := range c.Operations {
+		ops = append(ops, op.Method+" "+op.Path)
+	}
+	if got := strings.Join(ops, ", "); got != "GET /payments, POST /payments, DELETE /payments/{id}" {
+		t.Errorf("unexpected operations %s", got)
+	}
+	del := c.Operations[2]
+	if !del.Deprecated || len(del.Parameters) != 1 || del.Parameters[0].Name != "id" || !del.Parameters[0].Required {
+		t.Errorf("expected the path item parameter on the operation, got %+v", del)
+	}
+	if c.Operations[1].RequestBody == nil || !c.Operations[1].RequestBody.Required {
+		t.Errorf("expected a required request body, got %+v", c.Operations[1].RequestBody)
+	}
+	if len(c.Schemas) != 2 || c.Schemas[0].Name != "NewPayment" || c.Schemas[1].Name != "Payment" {
+		t.Errorf("unexpected schemas %+v", c.Schemas)
+	}
+}
+
+func TestFind(t *testing.T) {
+	c, err := Parse("api.yaml", []byte(openAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, ref := range []string{"createPayment", "POST /payments", "post /payments"} {
+		if op, ok := c.Find(ref); !ok || op.ID != "createPayment" {
+			t.Errorf("Find(%q) = %+v, %v", ref, op, ok)
+		}
+	}
+	if _, ok := c.Find("PUT /payments"); ok {
+		t.Error("expected no operation for PUT /payments")
+	}
+}
+
+func TestMarkdown_OpenAPI(t *testing.T) {
+	c, err := Parse("api.yaml", []byte(openAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	md := string(c.Markdown())
+	for _, want := range []string{
+		"# Payments API\n\nVersion `1.2.0`\n\nTakes payments.\n\n**Servers:** `https://api.example.com/v1`",
+		"## payments\n\n### `GET` `/payments`\n\n**List payments**\n\nOperation ID `listPayments`",
+		"| `limit` | query | integer | No | Minimum 1. Maximum 100. |",
+		"**`200`** The payments.\n\n`application/json`: array of [Payment](#payment)",
+		"**Request body** (required)",
+		"## Operations\n\n### `DELETE` `/payments/{id}`\n\n**Deprecated.**",
+		"| `id` | path | string (uuid) | Yes |  |",
+		"## Schemas\n\n### NewPayment",
+		"| `amount` | integer | Yes | In cents. |",
+		"| `currency` | string | No | One of `\"EUR\"`, `\"USD\"`. |",
+		"| `refunds` | array of [Payment](#payment) | No |  |",
+		"\"amount\": 1250,",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, md)
+		}
+	}
+	if strings.Contains(md, "refunds[].") {
+		t.Errorf("expected the recursive schema to be linked rather than expanded, got:\n%s", md)
+	}
+}
+
+func TestSection(t *testing.T) {
+	c, err := Parse("api.yaml", []byte(openAPI))
+	if err != nil {
+		t.Fatal(err)
+	}
+	md, err := c.Section("createPayment")
+	if err != nil {
+		t.Fatalf("Section returned error: %v", err)
+	}
+	for _, want := range []string{
+		"### `POST` `/payments`",
+		"| `amount` | integer | Yes | In cents. |",
+		"| `refunds` | array of Payment | No |  |",
+	} {
+		if !strings.Contains(string(md), want) {
+			t.Errorf("expected section to contain %q, got:\n%s", want, md)
+		}
+	}
+	if strings.Contains(string(md), "GET") || strings.Contains(string(md), "](#") || strings.Contains(string(md), "refunds[].") {
+		t.Errorf("expected only the operation with its schemas expanded once, got:\n%s", md)
+	}
+
+	if md, err := c.Section("Payment"); err != nil || !strings.HasPrefix(string(md), "### Payment\n") {
+		t.Errorf("expected the Payment schema, got %q, %v", md, err)
+	}
+	if _, err := c.Section("refund"); err == nil || err.Error() != `operation or schema "refund" not found` {
+		t.Errorf("unexpected error %v", err)
+	}
+}
+
+func TestParse_JSONSchema(t *testing.T) {
+	src := `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "type": "object",
+  "required": ["name"],
+  "properties": {
+    "name": {"type": "string", "maxLength": 40},
+    "address": {"$ref": "#/$defs/Address"},
+    "tags": {"type": "array", "items": {"type": "object", "properties": {"key": {"type": "string"}}}}
+  },
+  "$defs": {
+    "Address": {"type": "object", "properties": {"city": {"type": "string"}}}
+  }
+}`
+	c, err := Parse("contracts/customer.schema.json", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if c.Kind != JSONSchema || c.Title != "customer.schema" {
+		t.Errorf("unexpected contract %+v", c)
+	}
+	md := string(c.Markdown())
+	for _, want := range []string{
+		"# customer.schema",
+		"| `name` | string | Yes | Max length 40. |",
+		"| `address` | [Address](#address) | No |  |",
+		"| `tags[].key` | string | No |  |",
+		"## Example",
+		"## Definitions\n\n### Address",
+		"| `city` | string | No |  |",
+	} {
+		if !strings.Contains(md, want) {
+			t.Errorf("expected markdown to contain %q, got:\n%s", want, md)
+		}
+	}
+}
+
+func TestParse_Swagger2(t *testing.T) {
+	src := `swagger: "2.0"
+info: {title: Legacy, version: "1"}
+host: legacy.example.com
+basePath: /api
+schemes: [http]
+paths:
+  /users:
+    post:
+      parameters:
+        - {name: body, in: body, required: true, schema: {$ref: "#/definitions/User"}}
+        - {name: dry_run, in: query, type: boolean}
+      responses:
+        "200": {description: OK, schema: {$ref: "#/definitions/User"}}
+definitions:
+  User: {type: object, properties: {name: {type: string}}}
+`
+	c, err := Parse("legacy.yaml", []byte(src))
+	if err != nil {
+		t.Fatalf("Parse returned error: %v", err)
+	}
+	if len(c.Servers) != 1 || c.Servers[0] != "http://legacy.example.com/api" {
+		t.Errorf("unexpected servers %v", c.Servers)
+	}
+	op := c.Operations[0]
+	if op.RequestBody == nil || !op.RequestBody.Required || len(op.Parameters) != 1 || op.Parameters[0].Name != "dry_run" {
+		t.Errorf("expected the body parameter as the request body, got %+v", op)
+	}
+	if md := string(c.Markdown()); !strings.Contains(md, "| `dry_run` | query | boolean | No |  |") {
+		t.Errorf("expected the typed parameter, got:\n%s", md)
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	if _, err := Parse("list.yaml", []byte("- a\n- b\n")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+	if _, err := Parse("config.yaml", []byte("port: 8080\n")); !errors.Is(err, ErrUnknownFormat) {
+		t.Errorf("expected ErrUnknownFormat, got %v", err)
+	}
+	if _, err := Parse("broken.yaml", []byte("openapi: [\n")); err == nil {
+		t.Error("expected error for invalid YAML")
+	}
+}
+
+func TestIsContract(t *testing.T) {
+	for name, want := range map[string]bool{
+		"001-payments/contracts/api.yaml":    true,
+		"contracts/schema.json":              true,
+		"001-payments/contracts/v1/api.YML":  true,
+		"001-payments/api.yaml":              false,
+		"001-payments/contracts/notes.md":    false,
+		"001-payments/my-contracts/api.yaml": false,
+		"001-payments/contracts/diagram.d2":  false,
+	} {
+		if got := IsContract(name); got != want {
+			t.Errorf("IsContract(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
@@ -0,0 +1,357 @@
+package contract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Markdown returns the contract documented as markdown: for OpenAPI, its
+// operations grouped by tag followed by its schemas, and for JSON Schema,
+// its fields, an example and its definitions. Types naming a schema link
+// to its section.
+func (c *Contract) Markdown() []byte {
+	// Links need the heading IDs of the schema sections, which depend on
+	// every heading before them, so a first pass collects them.
+	first := &writer{c: c, ids: make(map[string]bool), anchors: make(map[string]string), link: true}
+	first.document()
+	w := &writer{c: c, ids: make(map[string]bool), anchors: first.anchors, link: true}
+	w.document()
+	return []byte(w.b.String())
+}
+
+// Section returns the markdown of a single operation, named by its
+// operationId or as "METHOD /path", or of a named schema, to embed in a
+// spec. Schemas are expanded in place rather than linked.
+func (c *Contract) Section(ref string) ([]byte, error) {
+	w := &writer{c: c, ids: make(map[string]bool), anchors: make(map[string]string)}
+	if op, ok := c.Find(ref); ok {
+		w.operation(op, 3)
+	} else if s, ok := c.schemaNamed(ref); ok {
+		w.schemaSection(s, 3)
+	} else {
+		return nil, fmt.Errorf(`operation or schema "%s" not found`, ref)
+	}
+	return []byte(w.b.String()), nil
+}
+
+// writer builds the markdown of a contract.
+type writer struct {
+	c *Contract
+	b strings.Builder
+
+	// ids are the heading IDs written so far, generated like goldmark's
+	// automatic heading IDs, and anchors maps schema names to theirs.
+	ids     map[string]bool
+	anchors map[string]string
+	// link makes types naming a schema link to it instead of expanding it.
+	link bool
+}
+
+func (w *writer) line(format string, args ...any) {
+	fmt.Fprintf(&w.b, format, args...)
+	w.b.WriteString("\n\n")
+}
+
+func (w *writer) text(s string) {
+	if s = strings.TrimSpace(s); s != "" {
+		w.line("%s", s)
+	}
+}
+
+// heading writes a heading and returns its ID.
+func (w *writer) heading(level int, text string) string {
+	w.line("%s %s", strings.Repeat("#", level), text)
+	return w.id(text)
+}
+
+// id generates the ID goldmark gives a heading with the given source text.
+func (w *writer) id(text string) string {
+	var b strings.Builder
+	for _, r := range strings.TrimSpace(text) {
+		switch {
+		case r >= 'A' && r <= 'Z':
+			b.WriteRune(r + 'a' - 'A')
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			b.WriteRune(r)
+		case r == ' ', r == '\t', r == '-', r == '_':
+			b.WriteByte('-')
+		}
+	}
+	id := b.String()
+	if id == "" {
+		id = "heading"
+	}
+	if w.ids[id] {
+		for i := 1; ; i++ {
+			if next := fmt.Sprintf("%s-%d", id, i); !w.ids[next] {
+				id = next
+				break
+			}
+		}
+	}
+	w.ids[id] = true
+	return id
+}
+
+func (w *writer) document() {
+	c := w.c
+	w.heading(1, escape(c.Title))
+	if c.Version != "" {
+		w.line("Version `%s`", c.Version)
+	}
+	w.text(c.Description)
+	if len(c.Servers) > 0 {
+		servers := make([]string, len(c.Servers))
+		for i, s := range c.Servers {
+			servers[i] = "`" + s + "`"
+		}
+		w.line("**Servers:** %s", strings.Join(servers, ", "))
+	}
+
+	if c.Kind == JSONSchema {
+		w.schemaBody(c.schema, 2)
+		if len(c.Schemas) > 0 {
+			w.heading(2, "Definitions")
+			for _, s := range c.Schemas {
+				w.schemaSection(s, 3)
+			}
+		}
+		return
+	}
+
+	for _, group := range c.groups() {
+		w.heading(2, escape(group.name))
+		for _, op := range group.ops {
+			w.operation(op, 3)
+		}
+	}
+	if len(c.Schemas) > 0 {
+		w.heading(2, "Schemas")
+		for _, s := range c.Schemas {
+			w.schemaSection(s, 3)
+		}
+	}
+}
+
+type group struct {
+	name string
+	ops  []Operation
+}
+
+// groups groups operations by their first tag, in the order the tags first
+// appear. Untagged operations come last, under "Operations".
+func (c *Contract) groups() []group {
+	var groups []group
+	index := make(map[string]int)
+	var untagged []Operation
+	for _, op := range c.Operations {
+		if len(op.Tags) == 0 {
+			untagged = append(untagged, op)
+			continue
+		}
+		i, ok := index[op.Tags[0]]
+		if !ok {
+			i = len(groups)
+			index[op.Tags[0]] = i
+			groups = append(groups, group{name: op.Tags[0]})
+		}
+		groups[i].ops = append(groups[i].ops, op)
+	}
+	if len(untagged) > 0 {
+		groups = append(groups, group{name: "Operations", ops: untagged})
+	}
+	return groups
+}
+
+func (w *writer) operation(op Operation, level int) {
+	w.heading(level, fmt.Sprintf("`%s` `%s`", op.Method, op.Path))
+	if op.Summary != "" {
+		w.line("**%s**", escape(op.Summary))
+	}
+	if op.Deprecated {
+		w.line("**Deprecated.**")
+	}
+	w.text(op.Description)
+	if op.ID != "" {
+		w.line("Operation ID `%s`", op.ID)
+	}
+
+	if len(op.Parameters) > 0 {
+		w.line("**Parameters**")
+		w.b.WriteString("| Name | In | Type | Required | Description |\n|---|---|---|---|---|\n")
+		for _, p := range op.Parameters {
+			desc := p.Description
+			if resolved, _ := w.c.resolve(p.schema); resolved != nil {
+				desc = joinText(desc, constraints(resolved))
+			}
+			if p.Deprecated {
+				desc = joinText("Deprecated.", desc)
+			}
+			fmt.Fprintf(&w.b, "| `%s` | %s | %s | %s | %s |\n", p.Name, p.In, cell(w.typeName(p.schema)), yes(p.Required), cell(desc))
+		}
+		w.b.WriteString("\n")
+	}
+
+	if body := op.RequestBody; body != nil {
+		label := "**Request body**"
+		if body.Required {
+			label += " (required)"
+		}
+		w.line("%s", label)
+		w.text(body.Description)
+		for _, m := range body.Content {
+			w.media(m)
+		}
+	}
+
+	if len(op.Responses) > 0 {
+		w.line("**Responses**")
+		for _, r := range op.Responses {
+			w.line("**`%s`** %s", r.Status, strings.TrimSpace(r.Description))
+			for _, m := range r.Content {
+				w.media(m)
+			}
+		}
+	}
+}
+
+// media writes the schema and example of a body.
+func (w *writer) media(m Media) {
+	if m.schema != nil {
+		w.line("`%s`: %s", m.Type, w.typeName(m.schema))
+		w.fields(m.schema, false)
+	} else {
+		w.line("`%s`", m.Type)
+	}
+	example := m.example
+	if example == nil && m.schema != nil {
+		example = w.c.sample(m.schema)
+	}
+	if example != nil {
+		w.example(example)
+	}
+}
+
+// schemaSection writes a named schema under a heading.
+func (w *writer) schemaSection(s Schema, level int) {
+	w.anchors[s.Name] = w.heading(level, escape(s.Name))
+	w.schemaBody(s.def, level+1)
+}
+
+// schemaBody writes the description, type, fields and example of a
+// schema. Examples get a heading of the given level in JSON Schema
+// documents.
+func (w *writer) schemaBody(def *object, level int) {
+	resolved, _ := w.c.resolve(def)
+	if resolved == nil {
+		return
+	}
+	if resolved != w.c.schema {
+		w.text(resolved.str("description"))
+	}
+	typ := w.typeName(def)
+	if typ != "object" {
+		w.line("Type: %s", typ)
+	}
+	if desc := constraints(resolved); desc != "" {
+		w.line("%s", desc)
+	}
+	// The schema itself is written here, so only the schemas it refers to
+	// are linked.
+	w.fields(resolved, true)
+
+	if example := w.c.sample(resolved); example != nil {
+		if w.c.Kind == JSONSchema && resolved == w.c.schema {
+			w.heading(level, "Example")
+		}
+		w.example(example)
+	}
+}
+
+// fields writes the table of the properties of a schema, or of the items
+// of an array schema. Named schemas are linked rather than expanded when
+// the writer links, unless expand is set for the schema itself.
+func (w *writer) fields(def *object, expand bool) {
+	resolved, name := w.c.resolve(def)
+	if resolved == nil || !expand && w.linked(name) {
+		return
+	}
+	prefix := ""
+	if items, itemName := w.c.resolve(resolved.obj("items")); items != nil {
+		if w.linked(itemName) {
+			return
+		}
+		resolved, prefix = items, "[]."
+	}
+	rows := w.c.fields(resolved, w.link)
+	if len(rows) == 0 {
+		return
+	}
+	w.b.WriteString("| Field | Type | Required | Description |\n|---|---|---|---|\n")
+	for _, r := range rows {
+		fmt.Fprintf(&w.b, "| `%s` | %s | %s | %s |\n", prefix+r.Path, cell(w.linkTypes(r.Type)), yes(r.Required), cell(r.Description))
+	}
+	w.b.WriteString("\n")
+}
+
+// linked reports whether the schema name is linked to its own section.
+func (w *writer) linked(name string) bool {
+	return w.link && name != "" && w.anchors[name] != ""
+}
+
+func (w *writer) example(v any) {
+	w.line("```json\n%s\n```", jsonText(v))
+}
+
+// typeName returns the type of a schema with the schemas it names linked.
+func (w *writer) typeName(def *object) string {
+	return w.linkTypes(w.c.typeName(def))
+}
+
+// linkTypes links the schema names in a type description, such as
+// "array of Payment", to their sections.
+func (w *writer) linkTypes(typ string) string {
+	if !w.link {
+		return escape(typ)
+	}
+	alts := strings.Split(typ, " or ")
+	for i, alt := range alts {
+		prefix := ""
+		for strings.HasPrefix(alt, "array of ") {
+			prefix += "array of "
+			alt = strings.TrimPrefix(alt, "array of ")
+		}
+		if anchor, ok := w.anchors[alt]; ok {
+			alts[i] = prefix + "[" + escape(alt) + "](#" + anchor + ")"
+		} else {
+			alts[i] = prefix + escape(alt)
+		}
+	}
+	return strings.Join(alts, " or ")
+}
+
+// escape escapes the markdown punctuation in s that would change how plain
+// names and titles render.
+func escape(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		if strings.ContainsRune("\\`*_[]<>#$", r) {
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// cell makes s fit in a table cell.
+func cell(s string) string {
+	s = strings.Join(strings.Fields(s), " ")
+	return strings.ReplaceAll(s, "|", `\|`)
+}
+
+func yes(b bool) string {
+	if b {
+		return "Yes"
+	}
+	return "No"
+}
@@ -0,0 +1,297 @@
+package contract
+
+import (
+	"fmt"
+	"strings"
+)
+
+// maxDepth bounds how deep nested and recursive schemas are expanded.
+const maxDepth = 6
+
+// field is a row of a schema table: a property, possibly nested, with its
+// type and constraints.
+type field struct {
+	Path        string
+	Type        string
+	Required    bool
+	Description string
+}
+
+// typeName describes the type of a schema, such as "string (uuid)",
+// "array of Payment" or "Card or BankAccount".
+func (c *Contract) typeName(def *object) string {
+	resolved, name := c.resolve(def)
+	if name != "" && (resolved == nil || resolved.obj("properties") != nil || resolved.list("allOf") != nil) {
+		return name
+	}
+	def = resolved
+	if def == nil {
+		return "any"
+	}
+
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alts := def.list(key); len(alts) > 0 {
+			names := make([]string, 0, len(alts))
+			for _, alt := range alts {
+				names = append(names, c.typeName(asObject(alt)))
+			}
+			return strings.Join(names, " or ")
+		}
+	}
+
+	var types []string
+	switch t := def.get("type").(type) {
+	case string:
+		types = []string{t}
+	case []any:
+		for _, v := range t {
+			types = append(types, fmt.Sprint(v))
+		}
+	}
+	if len(types) == 0 {
+		switch {
+		case def.obj("properties") != nil || def.list("allOf") != nil:
+			types = []string{"object"}
+		case def.get("items") != nil:
+			types = []string{"array"}
+		default:
+			types = []string{"any"}
+		}
+	}
+
+	for i, t := range types {
+		switch t {
+		case "array":
+			types[i] = "array of " + c.typeName(def.obj("items"))
+		default:
+			if format := def.str("format"); format != "" && t != "null" {
+				types[i] = t + " (" + format + ")"
+			}
+		}
+	}
+	if def.flag("nullable") {
+		types = append(types, "null")
+	}
+	return strings.Join(types, " or ")
+}
+
+// constraints describes the enum, default, bounds and pattern of a schema.
+func constraints(def *object) string {
+	var parts []string
+	if enum := def.list("enum"); len(enum) > 0 {
+		values := make([]string, 0, len(enum))
+		for _, v := range enum {
+			values = append(values, "`"+jsonText(v)+"`")
+		}
+		parts = append(parts, "One of "+strings.Join(values, ", ")+".")
+	}
+	if v := def.get("const"); v != nil {
+		parts = append(parts, "Always `"+jsonText(v)+"`.")
+	}
+	if v := def.get("default"); v != nil {
+		parts = append(parts, "Default `"+jsonText(v)+"`.")
+	}
+	for _, b := range []struct{ key, label string }{
+		{"minimum", "Minimum"}, {"exclusiveMinimum", "Above"}, {"maximum", "Maximum"}, {"exclusiveMaximum", "Below"},
+		{"minLength", "Min length"}, {"maxLength", "Max length"}, {"minItems", "Min items"}, {"maxItems", "Max items"},
+	} {
+		if v := def.get(b.key); v != nil {
+			if _, isBool := v.(bool); !isBool {
+				parts = append(parts, fmt.Sprintf("%s %v.", b.label, v))
+			}
+		}
+	}
+	if p := def.str("pattern"); p != "" {
+		parts = append(parts, "Pattern `"+p+"`.")
+	}
+	if def.flag("readOnly") {
+		parts = append(parts, "Read only.")
+	}
+	if def.flag("writeOnly") {
+		parts = append(parts, "Write only.")
+	}
+	if def.flag("deprecated") {
+		parts = append(parts, "Deprecated.")
+	}
+	return strings.Join(parts, " ")
+}
+
+// properties returns the properties and required names of an object
+// schema, merging the members of allOf.
+func (c *Contract) properties(def *object, seen map[*object]bool) (*object, map[string]bool) {
+	props := &object{vals: make(map[string]any)}
+	required := make(map[string]bool)
+
+	def, _ = c.resolve(def)
+	if def == nil || seen[def] {
+		return props, required
+	}
+	seen[def] = true
+	defer delete(seen, def)
+
+	for _, part := range def.list("allOf") {
+		p, r := c.properties(asObject(part), seen)
+		for _, k := range p.keys {
+			props.set(k, p.vals[k])
+		}
+		for k := range r {
+			required[k] = true
+		}
+	}
+	own := def.obj("properties")
+	for _, k := range own.keysOrNil() {
+		props.set(k, own.vals[k])
+	}
+	for _, r := range def.list("required") {
+		required[fmt.Sprint(r)] = true
+	}
+	return props, required
+}
+
+// fields flattens an object schema into rows, nesting the properties of
+// inline objects and of arrays of them under dotted paths. Named schemas
+// are expanded too unless link is set, in which case they are left to
+// their own section.
+func (c *Contract) fields(def *object, link bool) []field {
+	var rows []field
+	// seen holds the schemas being expanded, so recursive ones stop.
+	seen := make(map[*object]bool)
+	var walk func(def *object, prefix string, depth int)
+	walk = func(def *object, prefix string, depth int) {
+		def, _ = c.resolve(def)
+		if def == nil || seen[def] || depth > maxDepth {
+			return
+		}
+		seen[def] = true
+		defer delete(seen, def)
+
+		props, required := c.properties(def, make(map[*object]bool))
+		for _, name := range props.keys {
+			prop := asObject(props.vals[name])
+			resolved, ref := c.resolve(prop)
+			row := field{
+				Path:     prefix + name,
+				Type:     c.typeName(prop),
+				Required: required[name],
+			}
+			if resolved != nil {
+				row.Description = joinText(resolved.str("description"), constraints(resolved))
+			}
+			rows = append(rows, row)
+
+			if resolved == nil || link && ref != "" {
+				continue
+			}
+			// Nest objects and arrays of objects.
+			if items, itemRef := c.resolve(resolved.obj("items")); items != nil {
+				if !link || itemRef == "" {
+					walk(items, row.Path+"[].", depth+1)
+				}
+				continue
+			}
+			walk(resolved, row.Path+".", depth+1)
+		}
+	}
+	walk(def, "", 0)
+	return rows
+}
+
+func joinText(parts ...string) string {
+	var kept []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			kept = append(kept, p)
+		}
+	}
+	return strings.Join(kept, " ")
+}
+
+// sampleValues are the example values of string formats.
+var sampleValues = map[string]string{
+	"uuid":      "3fa85f64-5717-4562-b3fc-2c963f66afa6",
+	"date-time": "2024-01-01T12:00:00Z",
+	"date":      "2024-01-01",
+	"time":      "12:00:00",
+	"email":     "user@example.com",
+	"uri":       "https://example.com",
+	"url":       "https://example.com",
+	"hostname":  "example.com",
+	"ipv4":      "192.0.2.1",
+	"ipv6":      "2001:db8::1",
+}
+
+// sample returns an example value of a schema: its example, default, first
+// enum value, or one made up from its type. Properties of recursive schemas
+// are left out where they recur.
+func (c *Contract) sample(def *object) any {
+	return c.sampleOf(def, make(map[*object]bool))
+}
+
+func (c *Contract) sampleOf(def *object, seen map[*object]bool) any {
+	def, _ = c.resolve(def)
+	if def == nil || seen[def] || len(seen) > maxDepth {
+		return nil
+	}
+	seen[def] = true
+	defer delete(seen, def)
+
+	for _, key := range []string{"example", "const", "default"} {
+		if v := def.get(key); v != nil {
+			return v
+		}
+	}
+	if examples := def.list("examples"); len(examples) > 0 {
+		return examples[0]
+	}
+	if enum := def.list("enum"); len(enum) > 0 {
+		return enum[0]
+	}
+	for _, key := range []string{"oneOf", "anyOf"} {
+		if alts := def.list(key); len(alts) > 0 {
+			return c.sampleOf(asObject(alts[0]), seen)
+		}
+	}
+
+	typ := def.str("type")
+	if t, ok := def.get("type").([]any); ok && len(t) > 0 {
+		typ = fmt.Sprint(t[0])
+	}
+	switch {
+	case typ == "object" || typ == "" && (def.obj("properties") != nil || def.list("allOf") != nil):
+		props, _ := c.properties(def, map[*object]bool{})
+		o := &object{vals: make(map[string]any)}
+		for _, name := range props.keys {
+			prop, _ := c.resolve(asObject(props.vals[name]))
+			if prop != nil && prop.flag("writeOnly") {
+				continue
+			}
+			if v := c.sampleOf(asObject(props.vals[name]), seen); v != nil {
+				o.set(name, v)
+			}
+		}
+		return o
+	case typ == "array" || def.get("items") != nil:
+		if item := c.sampleOf(def.obj("items"), seen); item != nil {
+			return []any{item}
+		}
+		return []any{}
+	case typ == "string":
+		if v, ok := sampleValues[def.str("format")]; ok {
+			return v
+		}
+		return "string"
+	case typ == "integer":
+		if v := def.get("minimum"); v != nil {
+			return v
+		}
+		return 0
+	case typ == "number":
+		if v := def.get("minimum"); v != nil {
+			return v
+		}
+		return 0.0
+	case typ == "boolean":
+		return true
+	}
+	return nil
+}
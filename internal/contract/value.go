@@ -0,0 +1,178 @@
+package contract
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// object is a YAML or JSON mapping that keeps the order of its keys, so
+// operations and properties are documented in the order they were written.
+type object struct {
+	keys []string
+	vals map[string]any
+}
+
+func (o *object) get(key string) any {
+	if o == nil {
+		return nil
+	}
+	return o.vals[key]
+}
+
+func (o *object) set(key string, v any) {
+	if _, ok := o.vals[key]; !ok {
+		o.keys = append(o.keys, key)
+	}
+	o.vals[key] = v
+}
+
+// str returns the string at key, or "" if there is none.
+func (o *object) str(key string) string {
+	switch v := o.get(key).(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// obj returns the mapping at key, or nil if there is none.
+func (o *object) obj(key string) *object {
+	v, _ := o.get(key).(*object)
+	return v
+}
+
+// list returns the sequence at key, or nil if there is none.
+func (o *object) list(key string) []any {
+	v, _ := o.get(key).([]any)
+	return v
+}
+
+// flag returns the boolean at key.
+func (o *object) flag(key string) bool {
+	v, _ := o.get(key).(bool)
+	return v
+}
+
+// decode parses YAML, or JSON as a subset of it, into objects, slices and
+// scalars.
+func decode(data []byte) (any, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return convert(doc.Content[0])
+}
+
+func convert(n *yaml.Node) (any, error) {
+	switch n.Kind {
+	case yaml.AliasNode:
+		return convert(n.Alias)
+	case yaml.SequenceNode:
+		items := make([]any, 0, len(n.Content))
+		for _, c := range n.Content {
+			v, err := convert(c)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, v)
+		}
+		return items, nil
+	case yaml.MappingNode:
+		o := &object{vals: make(map[string]any)}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			k, vn := n.Content[i], n.Content[i+1]
+			v, err := convert(vn)
+			if err != nil {
+				return nil, err
+			}
+			if k.Value == "<<" && k.Tag == "!!merge" {
+				if m, ok := v.(*object); ok {
+					for _, mk := range m.keys {
+						if _, exists := o.vals[mk]; !exists {
+							o.set(mk, m.vals[mk])
+						}
+					}
+				}
+				continue
+			}
+			o.set(k.Value, v)
+		}
+		return o, nil
+	default:
+		var v any
+		if err := n.Decode(&v); err != nil {
+			return nil, err
+		}
+		return v, nil
+	}
+}
+
+// writeJSON writes v as indented JSON, keeping the key order of objects.
+func writeJSON(b *strings.Builder, v any, indent string) {
+	switch v := v.(type) {
+	case *object:
+		if len(v.keys) == 0 {
+			b.WriteString("{}")
+			return
+		}
+		b.WriteString("{\n")
+		for i, k := range v.keys {
+			b.WriteString(indent + "  ")
+			writeJSON(b, k, "")
+			b.WriteString(": ")
+			writeJSON(b, v.vals[k], indent+"  ")
+			if i < len(v.keys)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "}")
+	case []any:
+		if len(v) == 0 {
+			b.WriteString("[]")
+			return
+		}
+		b.WriteString("[\n")
+		for i, item := range v {
+			b.WriteString(indent + "  ")
+			writeJSON(b, item, indent+"  ")
+			if i < len(v)-1 {
+				b.WriteByte(',')
+			}
+			b.WriteByte('\n')
+		}
+		b.WriteString(indent + "]")
+	case map[string]any:
+		// Maps only come from YAML keys that are not strings.
+		o := &object{vals: make(map[string]any)}
+		for k, val := range v {
+			o.set(k, val)
+		}
+		writeJSON(b, o, indent)
+	case float64:
+		b.WriteString(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		data, err := json.Marshal(v)
+		if err != nil {
+			data, _ = json.Marshal(fmt.Sprint(v))
+		}
+		b.Write(data)
+	}
+}
+
+// jsonText returns v as indented JSON.
+func jsonText(v any) string {
+	var b strings.Builder
+	writeJSON(&b, v, "")
+	return b.String()
+}
@@ -0,0 +1,53 @@
+package diagram
+
+import (
+	"strconv"
+	"strings"
+)
+
+// colorNames are the named colors understood outside the browser, for
+// outputs such as PDF. They are common to CSS and Graphviz.
+var colorNames = map[string][3]int{
+	"black": {0, 0, 0}, "white": {255, 255, 255}, "gray": {128, 128, 128},
+	"grey": {128, 128, 128}, "silver": {192, 192, 192}, "lightgray": {211, 211, 211},
+	"lightgrey": {211, 211, 211}, "darkgray": {169, 169, 169}, "darkgrey": {169, 169, 169},
+	"gainsboro": {220, 220, 220}, "whitesmoke": {245, 245, 245},
+	"red": {255, 0, 0}, "darkred": {139, 0, 0}, "maroon": {128, 0, 0},
+	"crimson": {220, 20, 60}, "tomato": {255, 99, 71}, "coral": {255, 127, 80},
+	"salmon": {250, 128, 114}, "pink": {255, 192, 203}, "lightpink": {255, 182, 193},
+	"orange": {255, 165, 0}, "gold": {255, 215, 0}, "yellow": {255, 255, 0},
+	"lightyellow": {255, 255, 224}, "khaki": {240, 230, 140}, "beige": {245, 245, 220},
+	"ivory": {255, 255, 240}, "wheat": {245, 222, 179}, "tan": {210, 180, 140},
+	"brown": {165, 42, 42}, "olive": {128, 128, 0}, "green": {0, 128, 0},
+	"darkgreen": {0, 100, 0}, "lime": {0, 255, 0}, "lightgreen": {144, 238, 144},
+	"teal": {0, 128, 128}, "turquoise": {64, 224, 208}, "cyan": {0, 255, 255},
+	"aqua": {0, 255, 255}, "azure": {240, 255, 255}, "lightblue": {173, 216, 230},
+	"skyblue": {135, 206, 235}, "steelblue": {70, 130, 180}, "blue": {0, 0, 255},
+	"darkblue": {0, 0, 139}, "navy": {0, 0, 128}, "indigo": {75, 0, 130},
+	"purple": {128, 0, 128}, "violet": {238, 130, 238}, "orchid": {218, 112, 214},
+	"magenta": {255, 0, 255}, "fuchsia": {255, 0, 255}, "lavender": {230, 230, 250},
+}
+
+// RGB returns the components of a hex (#rgb, #rrggbb) or named color.
+func RGB(color string) (r, g, b int, ok bool) {
+	color = strings.ToLower(strings.TrimSpace(color))
+	if c, found := colorNames[color]; found {
+		return c[0], c[1], c[2], true
+	}
+
+	hex, found := strings.CutPrefix(color, "#")
+	if !found {
+		return 0, 0, 0, false
+	}
+	if len(hex) == 3 {
+		hex = string([]byte{hex[0], hex[0], hex[1], hex[1], hex[2], hex[2]})
+	}
+	if len(hex) != 6 {
+		return 0, 0, 0, false
+	}
+	v, err := strconv.ParseUint(hex, 16, 32)
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return int(v >> 16), int(v >> 8 & 0xff), int(v & 0xff), true
+}
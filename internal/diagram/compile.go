@@ -0,0 +1,68 @@
+package diagram
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sync"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+)
+
+// cacheSize is the number of compiled diagrams kept in memory.
+const cacheSize = 256
+
+// Result is a compiled diagram: the laid out graph and its SVG, or the
+// errors that prevented compiling it. Results are shared and must not be
+// modified.
+type Result struct {
+	Graph  *Graph
+	SVG    []byte
+	Errors []Error
+}
+
+var cache = struct {
+	sync.Mutex
+	results map[string]*Result
+}{results: make(map[string]*Result)}
+
+// Compile parses src, a diagram in lang, lays it out and draws it as SVG.
+// Results are cached by content hash, so unchanged diagrams are not laid
+// out again each time a spec is rendered.
+func Compile(lang, src string) *Result {
+	parse, ok := Languages[lang]
+	if !ok {
+		return &Result{Errors: []Error{{Line: 1, Message: "unsupported diagram language " + lang}}}
+	}
+
+	sum := sha256.Sum256([]byte(lang + "\x00" + src))
+	key := hex.EncodeToString(sum[:])
+
+	cache.Lock()
+	r, ok := cache.results[key]
+	cache.Unlock()
+	metrics.CacheLookup("diagram", ok)
+	if ok {
+		return r
+	}
+
+	r = &Result{}
+	g, errs := parse(src)
+	switch {
+	case len(errs) > 0:
+		r.Errors = errs
+	case len(g.Nodes) == 0:
+		r.Errors = []Error{{Line: 1, Message: "diagram is empty"}}
+	default:
+		Layout(g)
+		r.Graph = g
+		r.SVG = g.SVG("diagram-" + key[:12])
+	}
+
+	cache.Lock()
+	if len(cache.results) >= cacheSize {
+		clear(cache.results)
+	}
+	cache.results[key] = r
+	cache.Unlock()
+	return r
+}
@@ -0,0 +1,621 @@
+package diagram
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// d2Shapes maps D2 shapes to the closest shape that is drawn.
+var d2Shapes = map[string]Shape{
+	"rectangle": ShapeBox, "square": ShapeBox, "page": ShapeBox, "document": ShapeBox,
+	"queue": ShapeBox, "package": ShapeBox, "step": ShapeBox, "callout": ShapeBox,
+	"stored_data": ShapeBox, "person": ShapeBox, "c4-person": ShapeBox, "cloud": ShapeBox,
+	"code": ShapeBox, "image": ShapeBox,
+	"parallelogram": ShapeParallelogram,
+	"cylinder":      ShapeCylinder,
+	"diamond":       ShapeDiamond,
+	"oval":          ShapeEllipse,
+	"circle":        ShapeCircle,
+	"hexagon":       ShapeHexagon,
+	"text":          ShapeText,
+}
+
+// d2UnsupportedShapes are the shapes whose contents mean something else
+// than nested boxes, such as the columns of a table or the messages of a
+// sequence diagram. They are reported rather than drawn wrong.
+var d2UnsupportedShapes = map[string]bool{
+	"sequence_diagram": true, "sql_table": true, "class": true,
+}
+
+// d2UnsupportedKeywords are the keywords that change how a diagram is
+// laid out or read, reported rather than ignored.
+var d2UnsupportedKeywords = map[string]bool{
+	"grid-rows": true, "grid-columns": true, "vars": true,
+}
+
+// d2Reserved are the keywords that name properties rather than objects.
+var d2Reserved = map[string]bool{
+	"shape": true, "label": true, "style": true, "icon": true, "tooltip": true,
+	"link": true, "near": true, "width": true, "height": true, "direction": true,
+	"constraint": true, "class": true, "grid-rows": true, "grid-columns": true,
+	"grid-gap": true, "vertical-gap": true, "horizontal-gap": true, "top": true,
+	"left": true, "source-arrowhead": true, "target-arrowhead": true, "vars": true,
+	"classes": true, "layers": true, "scenarios": true, "steps": true,
+}
+
+var d2Directions = map[string]Direction{
+	"down": TopToBottom, "up": BottomToTop, "right": LeftToRight, "left": RightToLeft,
+}
+
+// d2Edge matches edge operators: any run of dashes with an arrow on
+// either end, or at least two dashes.
+var d2Edge = regexp.MustCompile(`<-+>?|-+>|--+`)
+
+// d2Object is a shape or container declared in a D2 diagram, keyed by its
+// dotted path.
+type d2Object struct {
+	id       string
+	parent   string
+	label    string
+	shape    Shape
+	style    Style
+	children int
+}
+
+type d2ScopeKind int
+
+const (
+	d2Container d2ScopeKind = iota
+	d2EdgeBlock
+	d2StyleBlock
+	d2Ignored
+)
+
+// d2Scope is an open block: the body of a container, an edge or a style
+// map, or a block whose contents are not drawn.
+type d2Scope struct {
+	line   int
+	kind   d2ScopeKind
+	path   []string
+	target *d2Object
+	edges  []*Edge
+}
+
+type d2Parser struct {
+	objects map[string]*d2Object
+	order   []*d2Object
+	edges   []*Edge
+	dir     Direction
+	scopes  []d2Scope
+	errs    []Error
+}
+
+func parseD2(src string) (*Graph, []Error) {
+	p := &d2Parser{
+		objects: make(map[string]*d2Object),
+		scopes:  []d2Scope{{kind: d2Container}},
+	}
+
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	inComment := false
+	for i := 0; i < len(lines); i++ {
+		text := strings.TrimSpace(lines[i])
+		if text == `"""` || (strings.HasPrefix(text, `"""`) && !inComment && !strings.HasSuffix(text[3:], `"""`)) {
+			inComment = !inComment
+			continue
+		}
+		if inComment {
+			inComment = !strings.HasSuffix(text, `"""`)
+			continue
+		}
+		i = p.line(lines, i)
+	}
+
+	for _, s := range p.scopes[1:] {
+		p.errorf(s.line, "block is never closed, missing \"}\"")
+	}
+	if len(p.errs) > 0 {
+		return nil, p.errs
+	}
+	return p.graph(), nil
+}
+
+func (p *d2Parser) errorf(line int, format string, args ...any) {
+	p.errs = append(p.errs, Error{Line: line, Message: fmt.Sprintf(format, args...)})
+}
+
+// line parses lines[i], which may start a block string spanning further
+// lines, and returns the index of the last line it consumed.
+func (p *d2Parser) line(lines []string, i int) int {
+	n := i + 1
+	text := stripComment(lines[i])
+
+	if key, value, ok := cutTopLevel(text, ':'); ok {
+		if delim, rest, ok := blockString(value); ok {
+			// A block string such as |md ... | runs until its delimiter.
+			var body []string
+			for j := i; ; j++ {
+				line := rest
+				if j > i {
+					line = lines[j]
+				}
+				if before, found := strings.CutSuffix(strings.TrimSpace(line), delim); found {
+					body = append(body, before)
+					p.apply(n, strings.TrimSpace(key), dedent(body), true, false)
+					return j
+				}
+				body = append(body, line)
+				if j == len(lines)-1 {
+					p.errorf(n, "block string is never closed, missing %q", delim)
+					return j
+				}
+			}
+		}
+	}
+
+	var cur strings.Builder
+	quote, escaped := rune(0), false
+	for _, r := range text {
+		switch {
+		case quote != 0:
+			cur.WriteRune(r)
+			switch {
+			case escaped:
+				escaped = false
+			case r == '\\':
+				escaped = true
+			case r == quote:
+				quote = 0
+			}
+			continue
+		case (r == '"' || r == '\'') && quoteStart(cur.String()):
+			quote = r
+			cur.WriteRune(r)
+		case r == ';':
+			p.statement(n, cur.String(), false)
+			cur.Reset()
+		case r == '{':
+			p.statement(n, cur.String(), true)
+			cur.Reset()
+		case r == '}':
+			p.statement(n, cur.String(), false)
+			cur.Reset()
+			p.close(n)
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if quote != 0 {
+		p.errorf(n, "unterminated string")
+		return i
+	}
+	p.statement(n, cur.String(), false)
+	return i
+}
+
+// quoteStart reports whether a quote following text opens a string: only
+// whole keys, values and path segments can be quoted.
+func quoteStart(text string) bool {
+	text = strings.TrimSpace(text)
+	return text == "" || strings.ContainsAny(text[len(text)-1:], ":.<->(")
+}
+
+// stripComment removes a # comment from a line.
+func stripComment(line string) string {
+	quote := rune(0)
+	for i, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && quoteStart(line[:i]):
+			quote = r
+		case r == '#' && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t'):
+			return line[:i]
+		}
+	}
+	return line
+}
+
+// cutTopLevel cuts text around the first sep outside quotes.
+func cutTopLevel(text string, sep rune) (string, string, bool) {
+	quote := rune(0)
+	for i, r := range text {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+		case (r == '"' || r == '\'') && quoteStart(text[:i]):
+			quote = r
+		case r == sep:
+			return text[:i], text[i+1:], true
+		}
+	}
+	return text, "", false
+}
+
+// blockString reports whether value opens a block string, returning its
+// closing delimiter and the text after the opening one.
+func blockString(value string) (string, string, bool) {
+	value = strings.TrimSpace(value)
+	if !strings.HasPrefix(value, "|") {
+		return "", "", false
+	}
+	delim := value[:len(value)-len(strings.TrimLeft(value, "|`"))]
+	rest := value[len(delim):]
+	// Skip the language tag, as in |md or |`go.
+	if tag := strings.IndexAny(rest, " \t"); tag >= 0 {
+		rest = rest[tag:]
+	} else if !strings.HasSuffix(rest, reverse(delim)) {
+		rest = ""
+	}
+	return reverse(delim), rest, true
+}
+
+func reverse(s string) string {
+	b := []byte(s)
+	for i, j := 0, len(b)-1; i < j; i, j = i+1, j-1 {
+		b[i], b[j] = b[j], b[i]
+	}
+	return string(b)
+}
+
+// dedent trims the lines of a block string and their common indentation.
+func dedent(lines []string) string {
+	indent := -1
+	for _, l := range lines {
+		if strings.TrimSpace(l) == "" {
+			continue
+		}
+		n := len(l) - len(strings.TrimLeft(l, " \t"))
+		if indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	for i, l := range lines {
+		if len(l) >= indent && indent > 0 {
+			lines[i] = l[indent:]
+		}
+		lines[i] = strings.TrimRight(lines[i], " \t")
+	}
+	return strings.Trim(strings.Join(lines, "\n"), "\n")
+}
+
+func (p *d2Parser) close(line int) {
+	if len(p.scopes) == 1 {
+		p.errorf(line, "unexpected \"}\"")
+		return
+	}
+	p.scopes = p.scopes[:len(p.scopes)-1]
+}
+
+func (p *d2Parser) push(s d2Scope) {
+	p.scopes = append(p.scopes, s)
+}
+
+// statement parses a key, an edge or a property, with an optional value,
+// that opens a block when open is set.
+func (p *d2Parser) statement(line int, text string, open bool) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		if open {
+			p.errorf(line, "block has no key")
+			p.push(d2Scope{line: line, kind: d2Ignored})
+		}
+		return
+	}
+
+	key, value, hasValue := cutTopLevel(text, ':')
+	p.apply(line, strings.TrimSpace(key), d2Unquote(value), hasValue && strings.TrimSpace(value) != "", open)
+}
+
+func (p *d2Parser) apply(line int, key, value string, hasValue, open bool) {
+	scope := p.scopes[len(p.scopes)-1]
+	ignore := func() {
+		if open {
+			p.push(d2Scope{line: line, kind: d2Ignored})
+		}
+	}
+
+	switch scope.kind {
+	case d2Ignored:
+		ignore()
+		return
+	case d2StyleBlock:
+		p.style(line, scope.target, scope.edges, key, value)
+		ignore()
+		return
+	case d2EdgeBlock:
+		p.edgeProperty(line, scope.edges, strings.Split(key, "."), value, hasValue, open)
+		return
+	}
+
+	if strings.HasPrefix(key, "(") {
+		// References to existing edges, such as (a -> b)[0].style.
+		ignore()
+		return
+	}
+	if ops := d2Edge.FindAllStringIndex(maskQuotes(key), -1); ops != nil {
+		p.edge(line, scope.path, key, ops, value, hasValue, open)
+		return
+	}
+
+	parts, ok := keyParts(key)
+	if !ok {
+		p.errorf(line, "invalid key %q", key)
+		ignore()
+		return
+	}
+	reserved := -1
+	for i, part := range parts {
+		if d2Reserved[part] {
+			reserved = i
+			break
+		}
+	}
+
+	if reserved < 0 {
+		obj := p.object(append(append([]string(nil), scope.path...), parts...))
+		if hasValue {
+			obj.label = value
+		}
+		if open {
+			p.push(d2Scope{line: line, kind: d2Container, path: strings.Split(obj.id, "\x00"), target: obj})
+		}
+		return
+	}
+
+	path := append(append([]string(nil), scope.path...), parts[:reserved]...)
+	prop := parts[reserved:]
+	if d2UnsupportedKeywords[prop[0]] {
+		p.errorf(line, "unsupported D2 feature: %s", prop[0])
+		ignore()
+		return
+	}
+	if len(path) == 0 {
+		switch prop[0] {
+		case "direction":
+			dir, ok := d2Directions[value]
+			if !ok {
+				p.errorf(line, "unknown direction %q, expected up, down, left or right", value)
+			}
+			p.dir = dir
+		}
+		ignore()
+		return
+	}
+	p.property(line, p.object(path), prop, value, open)
+}
+
+// property applies a reserved property such as shape or style.fill to an
+// object.
+func (p *d2Parser) property(line int, obj *d2Object, prop []string, value string, open bool) {
+	switch {
+	case prop[0] == "shape" && d2UnsupportedShapes[value]:
+		p.errorf(line, "unsupported D2 feature: shape %s", value)
+	case prop[0] == "shape":
+		shape, ok := d2Shapes[value]
+		if !ok {
+			p.errorf(line, "unknown shape %q", value)
+		}
+		obj.shape = shape
+	case prop[0] == "label":
+		obj.label = value
+	case prop[0] == "style" && len(prop) == 1 && open:
+		p.push(d2Scope{line: line, kind: d2StyleBlock, target: obj})
+		return
+	case prop[0] == "style" && len(prop) == 2:
+		p.style(line, obj, nil, prop[1], value)
+	}
+	if open {
+		p.push(d2Scope{line: line, kind: d2Ignored})
+	}
+}
+
+// style applies a style property to an object or to edges.
+func (p *d2Parser) style(line int, obj *d2Object, edges []*Edge, name, value string) {
+	set := func(fn func(s *Style)) {
+		if obj != nil {
+			fn(&obj.style)
+		}
+		for _, e := range edges {
+			fn(&e.Style)
+		}
+	}
+	number := func() float64 {
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			p.errorf(line, "style.%s must be a number, got %q", name, value)
+		}
+		return f
+	}
+
+	switch name {
+	case "fill":
+		set(func(s *Style) { s.Fill = value })
+	case "stroke":
+		set(func(s *Style) { s.Stroke = value })
+	case "font-color":
+		set(func(s *Style) { s.FontColor = value })
+	case "stroke-dash":
+		dashed := number() > 0
+		set(func(s *Style) { s.Dashed = dashed })
+	case "border-radius":
+		rounded := number() > 0
+		set(func(s *Style) { s.Rounded = rounded })
+	}
+}
+
+// edge adds the edges of a chain such as a -> b <- c.
+func (p *d2Parser) edge(line int, scope []string, key string, ops [][]int, value string, hasValue, open bool) {
+	var ends [][]string
+	start := 0
+	for i := 0; i <= len(ops); i++ {
+		end := len(key)
+		if i < len(ops) {
+			end = ops[i][0]
+		}
+		part := strings.TrimSpace(key[start:end])
+		if part == "" {
+			if i == 0 {
+				p.errorf(line, "edge has no source")
+			} else {
+				p.errorf(line, "edge has no target")
+			}
+			if open {
+				p.push(d2Scope{line: line, kind: d2Ignored})
+			}
+			return
+		}
+		parts, ok := keyParts(part)
+		if !ok {
+			p.errorf(line, "invalid key %q", part)
+			return
+		}
+		ends = append(ends, append(append([]string(nil), scope...), parts...))
+		if i < len(ops) {
+			start = ops[i][1]
+		}
+	}
+
+	var edges []*Edge
+	for i, op := range ops {
+		from, to := p.object(ends[i]), p.object(ends[i+1])
+		arrow := key[op[0]:op[1]]
+		e := &Edge{
+			From:      from.id,
+			To:        to.id,
+			ArrowHead: strings.HasSuffix(arrow, ">"),
+			ArrowTail: strings.HasPrefix(arrow, "<"),
+		}
+		if hasValue {
+			e.Label = value
+		}
+		edges = append(edges, e)
+	}
+	p.edges = append(p.edges, edges...)
+	if open {
+		p.push(d2Scope{line: line, kind: d2EdgeBlock, edges: edges})
+	}
+}
+
+// edgeProperty applies a property inside an edge's block.
+func (p *d2Parser) edgeProperty(line int, edges []*Edge, prop []string, value string, hasValue, open bool) {
+	switch {
+	case prop[0] == "label" && hasValue:
+		for _, e := range edges {
+			e.Label = value
+		}
+	case prop[0] == "style" && len(prop) == 1 && open:
+		p.push(d2Scope{line: line, kind: d2StyleBlock, edges: edges})
+		return
+	case prop[0] == "style" && len(prop) == 2:
+		p.style(line, nil, edges, prop[1], value)
+	}
+	if open {
+		p.push(d2Scope{line: line, kind: d2Ignored})
+	}
+}
+
+// object returns the object at path, declaring it and its containers.
+func (p *d2Parser) object(path []string) *d2Object {
+	id := strings.Join(path, "\x00")
+	if obj, ok := p.objects[id]; ok {
+		return obj
+	}
+	obj := &d2Object{id: id, label: path[len(path)-1]}
+	if len(path) > 1 {
+		parent := p.object(path[:len(path)-1])
+		parent.children++
+		obj.parent = parent.id
+	}
+	p.objects[id] = obj
+	p.order = append(p.order, obj)
+	return obj
+}
+
+// graph converts the declared objects into a Graph: objects with children
+// become clusters, the others nodes.
+func (p *d2Parser) graph() *Graph {
+	g := &Graph{Direction: p.dir, Directed: true}
+	clusters := make(map[string]*Cluster)
+	for _, obj := range p.order {
+		if obj.children == 0 {
+			continue
+		}
+		c := &Cluster{ID: publicID(obj.id), Label: obj.label, Style: obj.style, Parent: clusters[obj.parent]}
+		clusters[obj.id] = c
+		g.Clusters = append(g.Clusters, c)
+	}
+	for _, obj := range p.order {
+		if obj.children > 0 {
+			continue
+		}
+		n := g.addNode(publicID(obj.id), clusters[obj.parent])
+		n.Label, n.Shape, n.Style = obj.label, obj.shape, obj.style
+	}
+	for _, e := range p.edges {
+		e.From, e.To = publicID(e.From), publicID(e.To)
+		g.Edges = append(g.Edges, e)
+	}
+	return g
+}
+
+// publicID joins the segments of an object path with dots, as D2 writes
+// them.
+func publicID(id string) string {
+	return strings.ReplaceAll(id, "\x00", ".")
+}
+
+// keyParts splits a key into its dotted path segments.
+func keyParts(key string) ([]string, bool) {
+	var parts []string
+	masked := maskQuotes(key)
+	start := 0
+	for i := 0; i <= len(key); i++ {
+		if i < len(key) && masked[i] != '.' {
+			continue
+		}
+		part := d2Unquote(key[start:i])
+		if part == "" {
+			return nil, false
+		}
+		parts = append(parts, part)
+		start = i + 1
+	}
+	return parts, true
+}
+
+// maskQuotes replaces the contents of quoted strings with underscores, so
+// operators inside them are not matched.
+func maskQuotes(text string) string {
+	b := []byte(text)
+	quote := byte(0)
+	for i := 0; i < len(b); i++ {
+		switch {
+		case quote != 0:
+			if b[i] == quote {
+				quote = 0
+			} else {
+				b[i] = '_'
+			}
+		case (b[i] == '"' || b[i] == '\'') && quoteStart(text[:i]):
+			quote = b[i]
+		}
+	}
+	return string(b)
+}
+
+// d2Unquote returns the value of a possibly quoted string.
+func d2Unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) >= 2 && (s[0] == '"' || s[0] == '\'') && s[len(s)-1] == s[0] {
+		s = s[1 : len(s)-1]
+		return strings.NewReplacer(`\"`, `"`, `\'`, `'`, `\n`, "\n", `\\`, `\`).Replace(s)
+	}
+	return s
+}
@@ -0,0 +1,192 @@
+// Package diagram compiles D2 and Graphviz DOT sources to SVG without any
+// external tools.
+//
+// Both languages are parsed into the same Graph, which is laid out in
+// layers (the approach of Graphviz's dot) and drawn as SVG. The layout is
+// kept on the graph so other outputs, such as PDF exports, can draw the
+// same picture.
+//
+// The parsers cover the subset of D2 and DOT listed in the README rather
+// than the whole languages. The official D2 library lays diagrams out by
+// running dagre or ELK in an embedded JavaScript engine, and the Go
+// Graphviz bindings run Graphviz compiled to WebAssembly; either would
+// multiply the dependencies and size of the binary for the few diagrams a
+// spec has. Syntax outside the subset must fail with an Error naming it,
+// never be drawn as something else, and every construct supported is
+// covered by the tests, the README examples included.
+package diagram
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Languages maps the fence languages that are compiled to their parsers.
+var Languages = map[string]func(src string) (*Graph, []Error){
+	"d2":  parseD2,
+	"dot": parseDOT,
+}
+
+// Supported reports whether fences of lang are compiled to diagrams.
+func Supported(lang string) bool {
+	_, ok := Languages[lang]
+	return ok
+}
+
+// Error is a compile error at a 1-based line of a diagram's source.
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Direction is the direction in which a graph's layers run.
+type Direction int
+
+const (
+	TopToBottom Direction = iota
+	BottomToTop
+	LeftToRight
+	RightToLeft
+)
+
+// Shape is the outline of a node.
+type Shape int
+
+const (
+	ShapeBox Shape = iota
+	ShapeEllipse
+	ShapeCircle
+	ShapeDiamond
+	ShapeCylinder
+	ShapeHexagon
+	ShapeParallelogram
+	ShapeText
+	ShapePoint
+)
+
+// Style holds the presentation attributes of a node, edge or cluster.
+// Colors are CSS colors; empty values use the theme's colors.
+type Style struct {
+	Fill      string
+	Stroke    string
+	FontColor string
+	Dashed    bool
+	Rounded   bool
+	Hidden    bool
+}
+
+// Graph is a diagram: nodes, the edges between them and clusters grouping
+// them. Layout fills in the positions and sizes, in pixels.
+type Graph struct {
+	Direction Direction
+	Directed  bool
+	Nodes     []*Node
+	Edges     []*Edge
+	Clusters  []*Cluster
+
+	Width, Height float64
+}
+
+// Node is a box, ellipse or other shape with a label. X and Y are its
+// center.
+type Node struct {
+	ID      string
+	Label   string
+	Shape   Shape
+	Style   Style
+	Cluster *Cluster
+
+	X, Y, Width, Height float64
+}
+
+// Edge connects two nodes or clusters, referenced by ID. Points is the
+// path of the laid out edge and LabelX, LabelY the center of its label.
+type Edge struct {
+	From, To   string
+	Label      string
+	ArrowHead  bool
+	ArrowTail  bool
+	Style      Style
+	Points     []Point
+	LabelX     float64
+	LabelY     float64
+	LabelWidth float64
+}
+
+// Cluster is a labelled group of nodes, possibly nested in another
+// cluster. X and Y are its top left corner.
+type Cluster struct {
+	ID     string
+	Label  string
+	Style  Style
+	Parent *Cluster
+
+	X, Y, Width, Height float64
+}
+
+// Point is a position in pixels.
+type Point struct {
+	X, Y float64
+}
+
+// node returns the node with id, or nil.
+func (g *Graph) node(id string) *Node {
+	for _, n := range g.Nodes {
+		if n.ID == id {
+			return n
+		}
+	}
+	return nil
+}
+
+// cluster returns the cluster with id, or nil.
+func (g *Graph) cluster(id string) *Cluster {
+	for _, c := range g.Clusters {
+		if c.ID == id {
+			return c
+		}
+	}
+	return nil
+}
+
+// addNode returns the node with id, adding it in cluster if it is new.
+func (g *Graph) addNode(id string, cluster *Cluster) *Node {
+	if n := g.node(id); n != nil {
+		return n
+	}
+	n := &Node{ID: id, Label: id, Cluster: cluster}
+	g.Nodes = append(g.Nodes, n)
+	return n
+}
+
+// path returns the clusters containing c, outermost first, ending with c.
+func (c *Cluster) path() []*Cluster {
+	if c == nil {
+		return nil
+	}
+	return append(c.Parent.path(), c)
+}
+
+// depth returns the number of clusters containing c, including itself.
+func (c *Cluster) depth() int {
+	return len(c.path())
+}
+
+// contains reports whether c is other or one of its ancestors.
+func (c *Cluster) contains(other *Cluster) bool {
+	for ; other != nil; other = other.Parent {
+		if other == c {
+			return true
+		}
+	}
+	return false
+}
+
+// lines splits a label into its lines.
+func lines(label string) []string {
+	return strings.Split(label, "\n")
+}
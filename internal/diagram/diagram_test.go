@@ -0,0 +1,247 @@
+package diagram
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestParseDOT(t *testing.T) {
+	g, errs := parseDOT(`digraph G {
+  rankdir=LR
+  node [shape=box]
+  a [label="Start\nhere"]
+  a -> b -> {c d} [label="next" style=dashed]
+  subgraph cluster_store { label="Store"; d [shape=cylinder style=filled fillcolor="#eef"] }
+  e -> a [dir=back]
+}`)
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if g.Direction != LeftToRight {
+		t.Errorf("expected left to right, got %v", g.Direction)
+	}
+	if len(g.Nodes) != 5 || len(g.Edges) != 4 || len(g.Clusters) != 1 {
+		t.Fatalf("expected 5 nodes, 4 edges and 1 cluster, got %d, %d and %d", len(g.Nodes), len(g.Edges), len(g.Clusters))
+	}
+
+	a, d := g.node("a"), g.node("d")
+	if a.Label != "Start\nhere" || a.Shape != ShapeBox {
+		t.Errorf("expected box labelled %q, got %v labelled %q", "Start\nhere", a.Shape, a.Label)
+	}
+	if d.Shape != ShapeCylinder || d.Style.Fill != "#eef" || d.Cluster != g.Clusters[0] {
+		t.Errorf("expected filled cylinder in the cluster, got %+v", d)
+	}
+	if e := g.Edges[1]; e.From != "b" || e.To != "c" || e.Label != "next" || !e.Style.Dashed || !e.ArrowHead {
+		t.Errorf("expected dashed labelled edge b -> c, got %+v", e)
+	}
+	if e := g.Edges[3]; e.ArrowHead || !e.ArrowTail {
+		t.Errorf("expected edge with a tail arrow only, got %+v", e)
+	}
+}
+
+func TestParseDOT_Errors(t *testing.T) {
+	_, errs := parseDOT("digraph {\n  a -> b\n  c [label=\n}")
+	if len(errs) != 1 || errs[0].Line != 4 {
+		t.Fatalf("expected 1 error on line 4, got %v", errs)
+	}
+
+	_, errs = parseDOT("digraph {\n  rankdir=XY\n  a\n}")
+	if len(errs) != 1 || !strings.Contains(errs[0].Message, `unknown rankdir "XY"`) {
+		t.Errorf("expected unknown rankdir error, got %v", errs)
+	}
+}
+
+func TestParseD2(t *testing.T) {
+	g, errs := parseD2(`# Services
+direction: right
+users: Users {shape: person}
+api: "API: v2"
+db: {
+  label: Database
+  pg: Postgres {shape: cylinder}
+  cache.shape: oval
+}
+users -> api: HTTPS
+api -> db.pg <- db.cache: SQL
+api -- db {style.stroke-dash: 3}
+api.style.fill: "#e0f0ff"
+note: |md
+  # Deploy
+  Rolling
+|
+`)
+	if len(errs) != 0 {
+		t.Fatalf("expected no errors, got %v", errs)
+	}
+	if g.Direction != LeftToRight {
+		t.Errorf("expected left to right, got %v", g.Direction)
+	}
+	if len(g.Clusters) != 1 || g.Clusters[0].ID != "db" || g.Clusters[0].Label != "Database" {
+		t.Fatalf("expected cluster db labelled Database, got %+v", g.Clusters)
+	}
+
+	api, pg, cache := g.node("api"), g.node("db.pg"), g.node("db.cache")
+	if api.Label != "API: v2" || api.Style.Fill != "#e0f0ff" {
+		t.Errorf("expected quoted label and fill, got %+v", api)
+	}
+	if pg.Shape != ShapeCylinder || pg.Cluster != g.Clusters[0] || cache.Shape != ShapeEllipse {
+		t.Errorf("expected cylinder and oval in db, got %+v and %+v", pg, cache)
+	}
+	if note := g.node("note"); note == nil || note.Label != "# Deploy\nRolling" {
+		t.Errorf("expected block string label, got %+v", note)
+	}
+
+	want := []Edge{
+		{From: "users", To: "api", Label: "HTTPS", ArrowHead: true},
+		{From: "api", To: "db.pg", Label: "SQL", ArrowHead: true},
+		{From: "db.pg", To: "db.cache", Label: "SQL", ArrowTail: true},
+		{From: "api", To: "db", Style: Style{Dashed: true}},
+	}
+	if len(g.Edges) != len(want) {
+		t.Fatalf("expected %d edges, got %d", len(want), len(g.Edges))
+	}
+	for i, w := range want {
+		e := g.Edges[i]
+		if e.From != w.From || e.To != w.To || e.Label != w.Label || e.ArrowHead != w.ArrowHead || e.ArrowTail != w.ArrowTail || e.Style != w.Style {
+			t.Errorf("expected edge %+v, got %+v", w, *e)
+		}
+	}
+}
+
+func TestParseD2_Errors(t *testing.T) {
+	tests := []struct {
+		src     string
+		line    int
+		message string
+	}{
+		{"a -> b\nc: {\n  d", 2, `block is never closed, missing "}"`},
+		{"a\n}", 2, `unexpected "}"`},
+		{"a -> \nb", 1, "edge has no target"},
+		{"x.shape: blob", 1, `unknown shape "blob"`},
+		{"direction: sideways", 1, `unknown direction "sideways", expected up, down, left or right`},
+		{`a: "open`, 1, "unterminated string"},
+		{"flow: {\n  shape: sequence_diagram\n  a -> b\n}", 2, "unsupported D2 feature: shape sequence_diagram"},
+		{"users: {\n  shape: sql_table\n  id: int\n  name: string\n}", 2, "unsupported D2 feature: shape sql_table"},
+		{"a.shape: class", 1, "unsupported D2 feature: shape class"},
+		{"grid-rows: 2\na\nb", 1, "unsupported D2 feature: grid-rows"},
+		{"board: {\n  grid-columns: 3\n  a\n}", 2, "unsupported D2 feature: grid-columns"},
+		{"vars: {\n  name: api\n}\na: ${name}", 1, "unsupported D2 feature: vars"},
+	}
+	for _, tt := range tests {
+		_, errs := parseD2(tt.src)
+		if len(errs) != 1 || errs[0].Line != tt.line || errs[0].Message != tt.message {
+			t.Errorf("%q: expected line %d %q, got %v", tt.src, tt.line, tt.message, errs)
+		}
+	}
+}
+
+func TestLayout(t *testing.T) {
+	r := Compile("dot", `digraph {
+  a -> b -> d
+  a -> c -> d
+  d -> a
+  subgraph cluster_x { b; c }
+  a -> e
+}`)
+	if len(r.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", r.Errors)
+	}
+	g := r.Graph
+
+	for i, n := range g.Nodes {
+		if n.X-n.Width/2 < 0 || n.Y-n.Height/2 < 0 || n.X+n.Width/2 > g.Width || n.Y+n.Height/2 > g.Height {
+			t.Errorf("node %s lies outside the graph", n.ID)
+		}
+		for _, m := range g.Nodes[i+1:] {
+			if overlap(n, m) {
+				t.Errorf("nodes %s and %s overlap", n.ID, m.ID)
+			}
+		}
+	}
+	if a, b := g.node("a"), g.node("b"); a.Y >= b.Y {
+		t.Errorf("expected a above b, got y %v and %v", a.Y, b.Y)
+	}
+
+	c := g.Clusters[0]
+	for _, n := range g.Nodes {
+		inside := n.X > c.X && n.X < c.X+c.Width && n.Y > c.Y && n.Y < c.Y+c.Height
+		if inside != (n.Cluster == c) {
+			t.Errorf("node %s: expected inside cluster %v, got %v", n.ID, n.Cluster == c, inside)
+		}
+	}
+
+	// Edges end on the outline of their nodes, not at their centers.
+	for _, e := range g.Edges {
+		end := e.Points[len(e.Points)-1]
+		to := g.node(e.To)
+		if end.X == to.X && end.Y == to.Y {
+			t.Errorf("edge %s -> %s ends at the center of %s", e.From, e.To, e.To)
+		}
+	}
+}
+
+func overlap(a, b *Node) bool {
+	return a.X-a.Width/2 < b.X+b.Width/2 && b.X-b.Width/2 < a.X+a.Width/2 &&
+		a.Y-a.Height/2 < b.Y+b.Height/2 && b.Y-b.Height/2 < a.Y+a.Height/2
+}
+
+func TestCompile(t *testing.T) {
+	r := Compile("d2", "a: <b>&</b> -> c\na -> c: \"x\" {style.stroke: red}")
+	if len(r.Errors) != 0 {
+		t.Fatalf("expected no errors, got %v", r.Errors)
+	}
+	svg := string(r.SVG)
+	for _, want := range []string{
+		`<svg xmlns="http://www.w3.org/2000/svg" class="diagram-svg"`,
+		`<tspan`,
+		`marker-end="url(#diagram-`,
+		`style="stroke:red"`,
+	} {
+		if !strings.Contains(svg, want) {
+			t.Errorf("expected SVG to contain %q, got:\n%s", want, svg)
+		}
+	}
+	if strings.Contains(svg, "<b>") {
+		t.Errorf("expected labels to be escaped, got:\n%s", svg)
+	}
+
+	if again := Compile("d2", "a: <b>&</b> -> c\na -> c: \"x\" {style.stroke: red}"); again != r {
+		t.Error("expected the cached result for the same source")
+	}
+	if r := Compile("dot", "digraph {}"); len(r.Errors) != 1 || r.Errors[0].Message != "diagram is empty" {
+		t.Errorf("expected empty diagram error, got %v", r.Errors)
+	}
+}
+
+// TestREADMEExamples compiles the diagrams of the README, so that the
+// supported subset it documents stays supported.
+func TestREADMEExamples(t *testing.T) {
+	readme, err := os.ReadFile("../../README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	examples := 0
+	var lang string
+	var src strings.Builder
+	for _, line := range strings.Split(string(readme), "\n") {
+		switch {
+		case lang == "":
+			if l, ok := strings.CutPrefix(line, "```"); ok && Supported(l) {
+				lang = l
+				src.Reset()
+			}
+		case line == "```":
+			if r := Compile(lang, src.String()); len(r.Errors) > 0 {
+				t.Errorf("README %s example: %v\n%s", lang, r.Errors, src.String())
+			}
+			lang = ""
+			examples++
+		default:
+			src.WriteString(line + "\n")
+		}
+	}
+	if examples < 2 {
+		t.Errorf("expected the README to show D2 and DOT examples, found %d", examples)
+	}
+}
@@ -0,0 +1,374 @@
+package diagram
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gonum.org/v1/gonum/graph/formats/dot"
+	dotast "gonum.org/v1/gonum/graph/formats/dot/ast"
+)
+
+// dotError matches the position prefix of gonum's DOT parse errors.
+var dotError = regexp.MustCompile(`^(\d+):(\d+): (?:error: )?(.*)$`)
+
+// dotShapes maps Graphviz shapes to the closest shape that is drawn.
+// Shapes that are not listed are drawn as boxes.
+var dotShapes = map[string]Shape{
+	"ellipse": ShapeEllipse, "oval": ShapeEllipse, "egg": ShapeEllipse,
+	"circle": ShapeCircle, "doublecircle": ShapeCircle, "Mcircle": ShapeCircle,
+	"diamond": ShapeDiamond, "Mdiamond": ShapeDiamond,
+	"cylinder":      ShapeCylinder,
+	"hexagon":       ShapeHexagon,
+	"parallelogram": ShapeParallelogram,
+	"plaintext":     ShapeText, "plain": ShapeText, "none": ShapeText, "underline": ShapeText,
+	"point": ShapePoint,
+}
+
+// dotAttrs are the attributes in scope: graph attributes and node and
+// edge defaults.
+type dotAttrs struct {
+	node, edge map[string]string
+}
+
+func (a dotAttrs) clone() dotAttrs {
+	c := dotAttrs{node: map[string]string{}, edge: map[string]string{}}
+	for k, v := range a.node {
+		c.node[k] = v
+	}
+	for k, v := range a.edge {
+		c.edge[k] = v
+	}
+	return c
+}
+
+type dotParser struct {
+	g      *Graph
+	strict bool
+	seen   map[[2]string]bool
+}
+
+func parseDOT(src string) (*Graph, []Error) {
+	file, err := dot.ParseString(src)
+	if err != nil {
+		return nil, []Error{dotParseError(err)}
+	}
+	if len(file.Graphs) == 0 {
+		return nil, []Error{{Line: 1, Message: "no graph defined"}}
+	}
+
+	ag := file.Graphs[0]
+	p := &dotParser{
+		g:      &Graph{Directed: ag.Directed},
+		strict: ag.Strict,
+		seen:   make(map[[2]string]bool),
+	}
+	scope := dotAttrs{node: map[string]string{}, edge: map[string]string{}}
+	var errs []Error
+	for _, stmt := range ag.Stmts {
+		if err := p.graphAttr(stmt); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	p.stmts(ag.Stmts, scope, nil)
+	return p.g, errs
+}
+
+// dotParseError converts a gonum parse error, which starts with a
+// line:column position.
+func dotParseError(err error) Error {
+	m := dotError.FindStringSubmatch(err.Error())
+	if m == nil {
+		return Error{Line: 1, Message: err.Error()}
+	}
+	line, _ := strconv.Atoi(m[1])
+	return Error{Line: line, Message: m[3]}
+}
+
+// graphAttr applies top-level graph attributes such as rankdir.
+func (p *dotParser) graphAttr(stmt dotast.Stmt) *Error {
+	var attrs []*dotast.Attr
+	switch s := stmt.(type) {
+	case *dotast.Attr:
+		attrs = []*dotast.Attr{s}
+	case *dotast.AttrStmt:
+		if s.Kind == dotast.GraphKind {
+			attrs = s.Attrs
+		}
+	}
+
+	for _, a := range attrs {
+		if a.Key != "rankdir" {
+			continue
+		}
+		switch dotUnquote(a.Val) {
+		case "TB":
+			p.g.Direction = TopToBottom
+		case "BT":
+			p.g.Direction = BottomToTop
+		case "LR":
+			p.g.Direction = LeftToRight
+		case "RL":
+			p.g.Direction = RightToLeft
+		default:
+			// gonum's AST keeps no positions, so the error points at the
+			// first line.
+			return &Error{Line: 1, Message: fmt.Sprintf("unknown rankdir %q, expected TB, BT, LR or RL", dotUnquote(a.Val))}
+		}
+	}
+	return nil
+}
+
+// stmts adds the nodes, edges and clusters of a statement list, returning
+// the IDs of the nodes it mentions.
+func (p *dotParser) stmts(stmts []dotast.Stmt, scope dotAttrs, cluster *Cluster) []string {
+	var ids []string
+	for _, stmt := range stmts {
+		switch s := stmt.(type) {
+		case *dotast.AttrStmt:
+			switch s.Kind {
+			case dotast.NodeKind:
+				setAttrs(scope.node, s.Attrs)
+			case dotast.EdgeKind:
+				setAttrs(scope.edge, s.Attrs)
+			case dotast.GraphKind:
+				p.clusterAttrs(cluster, s.Attrs)
+			}
+		case *dotast.Attr:
+			p.clusterAttrs(cluster, []*dotast.Attr{s})
+		case *dotast.NodeStmt:
+			id := dotUnquote(s.Node.ID)
+			p.node(id, scope, cluster, s.Attrs)
+			ids = append(ids, id)
+		case *dotast.Subgraph:
+			ids = append(ids, p.subgraph(s, scope, cluster)...)
+		case *dotast.EdgeStmt:
+			ids = append(ids, p.edges(s, scope, cluster)...)
+		}
+	}
+	return ids
+}
+
+func (p *dotParser) subgraph(s *dotast.Subgraph, scope dotAttrs, cluster *Cluster) []string {
+	id := dotUnquote(s.ID)
+	if strings.HasPrefix(id, "cluster") {
+		c := &Cluster{ID: id, Parent: cluster}
+		p.g.Clusters = append(p.g.Clusters, c)
+		cluster = c
+	}
+	return p.stmts(s.Stmts, scope.clone(), cluster)
+}
+
+func (p *dotParser) clusterAttrs(cluster *Cluster, attrs []*dotast.Attr) {
+	if cluster == nil {
+		return
+	}
+	values := map[string]string{}
+	setAttrs(values, attrs)
+	if label, ok := values["label"]; ok {
+		cluster.Label = dotLabel(label, cluster.ID)
+	}
+	cluster.Style = dotStyle(values, cluster.Style)
+}
+
+// node declares a node, applying the defaults in scope the first time it
+// is seen.
+func (p *dotParser) node(id string, scope dotAttrs, cluster *Cluster, attrs []*dotast.Attr) {
+	n := p.g.node(id)
+	values := map[string]string{}
+	if n == nil {
+		n = p.g.addNode(id, cluster)
+		n.Shape = ShapeEllipse
+		for k, v := range scope.node {
+			values[k] = v
+		}
+	} else if n.Cluster == nil || (cluster != nil && n.Cluster.contains(cluster)) {
+		// A node belongs to the innermost cluster it appears in.
+		n.Cluster = cluster
+	}
+	setAttrs(values, attrs)
+
+	if label, ok := values["label"]; ok {
+		n.Label = dotLabel(label, id)
+	}
+	if shape, ok := values["shape"]; ok {
+		s, known := dotShapes[shape]
+		if !known {
+			s = ShapeBox
+		}
+		n.Shape = s
+		if shape == "Mrecord" {
+			n.Style.Rounded = true
+		}
+		if shape == "record" || shape == "Mrecord" {
+			n.Label = recordLabel(n.Label)
+		}
+	}
+	n.Style = dotStyle(values, n.Style)
+}
+
+// edges adds an edge for each pair of consecutive vertices of an edge
+// statement, which may be subgraphs.
+func (p *dotParser) edges(s *dotast.EdgeStmt, scope dotAttrs, cluster *Cluster) []string {
+	values := map[string]string{}
+	for k, v := range scope.edge {
+		values[k] = v
+	}
+	setAttrs(values, s.Attrs)
+
+	from := p.vertex(s.From, scope, cluster)
+	ids := append([]string(nil), from...)
+	for e := s.To; e != nil; e = e.To {
+		to := p.vertex(e.Vertex, scope, cluster)
+		for _, a := range from {
+			for _, b := range to {
+				p.edge(a, b, values)
+			}
+		}
+		ids = append(ids, to...)
+		from = to
+	}
+	return ids
+}
+
+func (p *dotParser) vertex(v dotast.Vertex, scope dotAttrs, cluster *Cluster) []string {
+	switch v := v.(type) {
+	case *dotast.Node:
+		id := dotUnquote(v.ID)
+		p.node(id, scope, cluster, nil)
+		return []string{id}
+	case *dotast.Subgraph:
+		return p.subgraph(v, scope, cluster)
+	}
+	return nil
+}
+
+func (p *dotParser) edge(from, to string, values map[string]string) {
+	key := [2]string{from, to}
+	if !p.g.Directed && from > to {
+		key = [2]string{to, from}
+	}
+	if p.strict && p.seen[key] {
+		return
+	}
+	p.seen[key] = true
+
+	e := &Edge{From: from, To: to, ArrowHead: p.g.Directed}
+	if label, ok := values["label"]; ok {
+		e.Label = dotLabel(label, "")
+	}
+	switch values["dir"] {
+	case "back":
+		e.ArrowHead, e.ArrowTail = false, true
+	case "both":
+		e.ArrowHead, e.ArrowTail = true, true
+	case "none":
+		e.ArrowHead = false
+	case "forward":
+		e.ArrowHead = true
+	}
+	if values["arrowhead"] == "none" {
+		e.ArrowHead = false
+	}
+	e.Style = dotStyle(values, e.Style)
+	p.g.Edges = append(p.g.Edges, e)
+}
+
+func setAttrs(values map[string]string, attrs []*dotast.Attr) {
+	for _, a := range attrs {
+		values[a.Key] = dotUnquote(a.Val)
+	}
+}
+
+// dotStyle applies the style and color attributes in values to s.
+func dotStyle(values map[string]string, s Style) Style {
+	styles := strings.Split(values["style"], ",")
+	has := func(name string) bool {
+		for _, st := range styles {
+			if strings.TrimSpace(st) == name {
+				return true
+			}
+		}
+		return false
+	}
+
+	if has("dashed") || has("dotted") {
+		s.Dashed = true
+	}
+	if has("rounded") {
+		s.Rounded = true
+	}
+	if has("invis") {
+		s.Hidden = true
+	}
+	if c := firstColor(values["color"]); c != "" {
+		s.Stroke = c
+	}
+	if has("filled") {
+		if c := firstColor(values["fillcolor"]); c != "" {
+			s.Fill = c
+		} else if c := firstColor(values["color"]); c != "" {
+			s.Fill = c
+		}
+	} else if c := firstColor(values["bgcolor"]); c != "" {
+		s.Fill = c
+	}
+	if c := firstColor(values["fontcolor"]); c != "" {
+		s.FontColor = c
+	}
+	return s
+}
+
+// firstColor returns the first color of a Graphviz color list.
+func firstColor(value string) string {
+	c, _, _ := strings.Cut(value, ":")
+	c, _, _ = strings.Cut(c, ";")
+	return strings.TrimSpace(c)
+}
+
+// dotUnquote returns the value of a DOT ID, which may be quoted or an
+// HTML string.
+func dotUnquote(id string) string {
+	switch {
+	case len(id) >= 2 && id[0] == '"' && id[len(id)-1] == '"':
+		id = id[1 : len(id)-1]
+		id = strings.ReplaceAll(id, "\\\n", "")
+		return strings.ReplaceAll(id, `\"`, `"`)
+	case len(id) >= 2 && id[0] == '<' && id[len(id)-1] == '>':
+		return htmlText(id[1 : len(id)-1])
+	}
+	return id
+}
+
+var (
+	htmlBreak  = regexp.MustCompile(`(?i)<br\s*/?>`)
+	htmlTag    = regexp.MustCompile(`<[^>]*>`)
+	recordPort = regexp.MustCompile(`^<[^>]*>\s*`)
+)
+
+// htmlText returns the text of a Graphviz HTML-like label, keeping line
+// breaks.
+func htmlText(s string) string {
+	s = htmlBreak.ReplaceAllString(s, "\n")
+	s = htmlTag.ReplaceAllString(s, "")
+	r := strings.NewReplacer("&lt;", "<", "&gt;", ">", "&quot;", `"`, "&amp;", "&")
+	return strings.TrimSpace(r.Replace(s))
+}
+
+// dotLabel expands the escapes of a Graphviz label.
+func dotLabel(label, id string) string {
+	r := strings.NewReplacer(`\n`, "\n", `\l`, "\n", `\r`, "\n", `\N`, id, `\G`, "", `\\`, `\`)
+	return strings.TrimRight(r.Replace(label), "\n")
+}
+
+// recordLabel lays the fields of a record label out one per line.
+func recordLabel(label string) string {
+	var fields []string
+	for _, f := range strings.FieldsFunc(label, func(r rune) bool { return r == '|' || r == '{' || r == '}' }) {
+		if f = recordPort.ReplaceAllString(strings.TrimSpace(f), ""); f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return strings.Join(fields, "\n")
+}
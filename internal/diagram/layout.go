@@ -0,0 +1,878 @@
+package diagram
+
+import (
+	"math"
+	"sort"
+)
+
+// Layout spacing, in pixels.
+const (
+	nodePadX     = 16.0
+	nodePadY     = 10.0
+	minNodeWidth = 60.0
+	rankGap      = 50.0
+	nodeGap      = 30.0
+	virtualGap   = 10.0
+	clusterPad   = 14.0
+	clusterLabel = LineHeight + 4
+	labelPad     = 4.0
+	loopSize     = 24.0
+	margin       = 10.0
+
+	// orderPasses is the number of sweeps that reorder layers to reduce
+	// crossings and placePasses the number that straighten edges.
+	orderPasses = 12
+	placePasses = 8
+)
+
+// lnode is a node in a layer: a graph node, a virtual node routing a long
+// edge across a layer, which may carry the edge's label, or a filler
+// holding a cluster's place in a layer where it has no nodes.
+type lnode struct {
+	node    *Node
+	label   *Edge
+	filler  bool
+	cluster *Cluster
+	rank    int
+	order   int
+	pos     float64
+	breadth float64
+	depth   float64
+	x, y    float64
+	in, out []*lnode
+}
+
+func (v *lnode) virtual() bool {
+	return v.node == nil
+}
+
+// lchain is the path of an edge through the layers, from the layer node of
+// its first end to the layer node of its last.
+type lchain struct {
+	edge     *Edge
+	from, to *lnode
+	minLen   int
+	reversed bool
+	nodes    []*lnode
+}
+
+type layout struct {
+	g        *Graph
+	nodes    map[*Node]*lnode
+	chains   []*lchain
+	loops    []*Edge
+	ranks    [][]*lnode
+	centers  []float64
+	vertical bool
+}
+
+// Layout computes the size and position of every node, cluster and edge
+// of g, and the size of the whole graph.
+func Layout(g *Graph) {
+	l := &layout{
+		g:        g,
+		nodes:    make(map[*Node]*lnode),
+		vertical: g.Direction == TopToBottom || g.Direction == BottomToTop,
+	}
+	for _, n := range g.Nodes {
+		sizeNode(n)
+		v := &lnode{node: n, cluster: n.Cluster, breadth: n.Width, depth: n.Height}
+		if !l.vertical {
+			v.breadth, v.depth = n.Height, n.Width
+		}
+		l.nodes[n] = v
+	}
+
+	l.rank()
+	l.buildLayers()
+	l.order()
+	l.place()
+	l.separateClusters()
+	l.coordinates()
+	l.clusters()
+	l.route()
+	l.fit()
+}
+
+// sizeNode sets the size of n from its label and shape.
+func sizeNode(n *Node) {
+	tw, th := labelSize(n.Label)
+	w, h := max(tw+2*nodePadX, minNodeWidth), th+2*nodePadY
+	switch n.Shape {
+	case ShapeEllipse:
+		w, h = w*1.25, h*1.3
+	case ShapeCircle:
+		d := math.Hypot(tw, th) + 2*nodePadY
+		w, h = d, d
+	case ShapeDiamond:
+		w, h = 2*tw+2*nodePadX, 2*th+2*nodePadY
+	case ShapeHexagon, ShapeParallelogram:
+		w += h / 2
+	case ShapeCylinder:
+		h += 16
+	case ShapeText:
+		w, h = tw+8, th+4
+	case ShapePoint:
+		w, h = 10, 10
+	}
+	n.Width, n.Height = w, h
+}
+
+// endpoint resolves an edge end to the node or cluster it names, and the
+// layer node that stands for it when ranking: a cluster is represented by
+// its first node.
+func (l *layout) endpoint(id string) (*Node, *Cluster, *lnode) {
+	if n := l.g.node(id); n != nil {
+		return n, nil, l.nodes[n]
+	}
+	c := l.g.cluster(id)
+	if c == nil {
+		return nil, nil, nil
+	}
+	for _, n := range l.g.Nodes {
+		if c.contains(n.Cluster) {
+			return nil, c, l.nodes[n]
+		}
+	}
+	return nil, nil, nil
+}
+
+// rank assigns layers so that every edge points down, reversing edges
+// that close cycles. Labelled edges span two layers to make room for the
+// label.
+func (l *layout) rank() {
+	out := make(map[*lnode][]*lchain)
+	for _, e := range l.g.Edges {
+		_, _, from := l.endpoint(e.From)
+		_, _, to := l.endpoint(e.To)
+		switch {
+		case from == nil || to == nil:
+			continue
+		case from == to:
+			l.loops = append(l.loops, e)
+			continue
+		}
+		c := &lchain{edge: e, from: from, to: to, minLen: 1}
+		if e.Label != "" {
+			c.minLen = 2
+		}
+		l.chains = append(l.chains, c)
+		out[from] = append(out[from], c)
+	}
+
+	// Reverse the edges that lead back to a node on the DFS stack.
+	state := make(map[*lnode]int)
+	var visit func(v *lnode)
+	visit = func(v *lnode) {
+		state[v] = 1
+		for _, c := range out[v] {
+			switch state[c.to] {
+			case 0:
+				visit(c.to)
+			case 1:
+				c.reversed = true
+			}
+		}
+		state[v] = 2
+	}
+	for _, n := range l.g.Nodes {
+		if state[l.nodes[n]] == 0 {
+			visit(l.nodes[n])
+		}
+	}
+
+	succ := make(map[*lnode][]*lchain)
+	indeg := make(map[*lnode]int)
+	for _, c := range l.chains {
+		if c.reversed {
+			c.from, c.to = c.to, c.from
+		}
+		succ[c.from] = append(succ[c.from], c)
+		indeg[c.to]++
+	}
+
+	// Longest path ranking, in topological order.
+	var topo, queue []*lnode
+	sources := make(map[*lnode]bool)
+	for _, n := range l.g.Nodes {
+		if v := l.nodes[n]; indeg[v] == 0 {
+			queue = append(queue, v)
+			sources[v] = true
+		}
+	}
+	for len(queue) > 0 {
+		v := queue[0]
+		queue = queue[1:]
+		topo = append(topo, v)
+		for _, c := range succ[v] {
+			c.to.rank = max(c.to.rank, v.rank+c.minLen)
+			if indeg[c.to]--; indeg[c.to] == 0 {
+				queue = append(queue, c.to)
+			}
+		}
+	}
+
+	// Pull sources down next to the nodes they point at.
+	for i := len(topo) - 1; i >= 0; i-- {
+		v := topo[i]
+		if !sources[v] || len(succ[v]) == 0 {
+			continue
+		}
+		r := math.MaxInt
+		for _, c := range succ[v] {
+			r = min(r, c.to.rank-c.minLen)
+		}
+		v.rank = r
+	}
+
+	lowest := math.MaxInt
+	for _, v := range l.nodes {
+		lowest = min(lowest, v.rank)
+	}
+	for _, v := range l.nodes {
+		v.rank -= lowest
+	}
+}
+
+// buildLayers places the nodes in their layers, in declaration order,
+// and routes every edge through virtual nodes in the layers it crosses.
+func (l *layout) buildLayers() {
+	add := func(v *lnode) {
+		for len(l.ranks) <= v.rank {
+			l.ranks = append(l.ranks, nil)
+		}
+		l.ranks[v.rank] = append(l.ranks[v.rank], v)
+	}
+	for _, n := range l.g.Nodes {
+		add(l.nodes[n])
+	}
+
+	for _, c := range l.chains {
+		cluster := commonCluster(l.endCluster(c.edge.From), l.endCluster(c.edge.To))
+		span := c.to.rank - c.from.rank
+		c.nodes = []*lnode{c.from}
+		for r := c.from.rank + 1; r < c.to.rank; r++ {
+			v := &lnode{rank: r, cluster: cluster}
+			if c.edge.Label != "" && r == c.from.rank+span/2 {
+				w, h := labelSize(c.edge.Label)
+				v.label = c.edge
+				v.breadth, v.depth = w+2*labelPad, h
+				if !l.vertical {
+					v.breadth, v.depth = h+2*labelPad, w
+				}
+			}
+			c.nodes = append(c.nodes, v)
+			add(v)
+		}
+		c.nodes = append(c.nodes, c.to)
+
+		for i := 1; i < len(c.nodes); i++ {
+			link(c.nodes[i-1], c.nodes[i])
+		}
+	}
+	l.fillClusters()
+
+	for _, rank := range l.ranks {
+		for i, v := range rank {
+			v.order = i
+		}
+	}
+}
+
+func link(from, to *lnode) {
+	from.out = append(from.out, to)
+	to.in = append(to.in, from)
+}
+
+// fillClusters adds fillers to the layers a cluster spans without having
+// nodes in them, linked to the cluster's nodes in the adjacent layers, so
+// that ordering and placement keep other nodes out of the cluster.
+func (l *layout) fillClusters() {
+	for _, c := range l.g.Clusters {
+		first, last := -1, -1
+		for r, rank := range l.ranks {
+			for _, v := range rank {
+				if c.contains(v.cluster) {
+					if first < 0 {
+						first = r
+					}
+					last = r
+				}
+			}
+		}
+		if first < 0 {
+			continue
+		}
+
+		var prev *lnode
+		for r := first; r <= last; r++ {
+			var member *lnode
+			for _, v := range l.ranks[r] {
+				if c.contains(v.cluster) {
+					member = v
+					break
+				}
+			}
+			if member == nil {
+				member = &lnode{rank: r, cluster: c, filler: true}
+				l.ranks[r] = append(l.ranks[r], member)
+			}
+			if prev != nil && (prev.filler || member.filler) {
+				link(prev, member)
+			}
+			prev = member
+		}
+	}
+}
+
+// endCluster returns the cluster an edge end lies in: the cluster of a
+// node, or the parent of a cluster.
+func (l *layout) endCluster(id string) *Cluster {
+	n, c, _ := l.endpoint(id)
+	if n != nil {
+		return n.Cluster
+	}
+	if c != nil {
+		return c.Parent
+	}
+	return nil
+}
+
+// commonCluster returns the innermost cluster containing both a and b.
+func commonCluster(a, b *Cluster) *Cluster {
+	for c := a; c != nil; c = c.Parent {
+		if c.contains(b) {
+			return c
+		}
+	}
+	return nil
+}
+
+// order reorders the layers to reduce edge crossings, sweeping down and up
+// and sorting nodes by the mean position of their neighbours, while
+// keeping the members of each cluster together.
+func (l *layout) order() {
+	for r, rank := range l.ranks {
+		key := make(map[*lnode]float64)
+		for _, v := range rank {
+			key[v] = float64(v.order)
+		}
+		l.ranks[r] = groupSort(rank, key, 0)
+		for i, v := range l.ranks[r] {
+			v.order = i
+		}
+	}
+
+	best, fewest := l.snapshot(), l.crossings()
+	for pass := 0; pass < orderPasses && fewest > 0; pass++ {
+		if pass%2 == 0 {
+			for r := 1; r < len(l.ranks); r++ {
+				l.sortRank(r, func(v *lnode) []*lnode { return v.in })
+			}
+		} else {
+			for r := len(l.ranks) - 2; r >= 0; r-- {
+				l.sortRank(r, func(v *lnode) []*lnode { return v.out })
+			}
+		}
+		if c := l.crossings(); c < fewest {
+			best, fewest = l.snapshot(), c
+		}
+	}
+	l.ranks = best
+	for _, rank := range l.ranks {
+		for i, v := range rank {
+			v.order = i
+		}
+	}
+}
+
+func (l *layout) snapshot() [][]*lnode {
+	s := make([][]*lnode, len(l.ranks))
+	for i, rank := range l.ranks {
+		s[i] = append([]*lnode(nil), rank...)
+	}
+	return s
+}
+
+func (l *layout) sortRank(r int, neighbours func(*lnode) []*lnode) {
+	key := make(map[*lnode]float64)
+	for _, v := range l.ranks[r] {
+		ns := neighbours(v)
+		if len(ns) == 0 {
+			key[v] = float64(v.order)
+			continue
+		}
+		sum := 0.0
+		for _, n := range ns {
+			sum += float64(n.order)
+		}
+		key[v] = sum / float64(len(ns))
+	}
+
+	l.ranks[r] = groupSort(l.ranks[r], key, 0)
+	for i, v := range l.ranks[r] {
+		v.order = i
+	}
+}
+
+// groupSort sorts nodes by key, keeping the members of each cluster at the
+// given nesting depth together, ordered by their mean key.
+func groupSort(nodes []*lnode, key map[*lnode]float64, depth int) []*lnode {
+	type group struct {
+		cluster *Cluster
+		nodes   []*lnode
+		key     float64
+	}
+	var groups []*group
+	byCluster := make(map[*Cluster]*group)
+	for _, v := range nodes {
+		path := v.cluster.path()
+		if len(path) <= depth {
+			groups = append(groups, &group{nodes: []*lnode{v}})
+			continue
+		}
+		g, ok := byCluster[path[depth]]
+		if !ok {
+			g = &group{cluster: path[depth]}
+			byCluster[path[depth]] = g
+			groups = append(groups, g)
+		}
+		g.nodes = append(g.nodes, v)
+	}
+	for _, g := range groups {
+		for _, v := range g.nodes {
+			g.key += key[v]
+		}
+		g.key /= float64(len(g.nodes))
+	}
+	sort.SliceStable(groups, func(i, j int) bool { return groups[i].key < groups[j].key })
+
+	var sorted []*lnode
+	for _, g := range groups {
+		if g.cluster != nil {
+			sorted = append(sorted, groupSort(g.nodes, key, depth+1)...)
+		} else {
+			sorted = append(sorted, g.nodes...)
+		}
+	}
+	return sorted
+}
+
+// crossings counts the edges crossing between consecutive layers.
+func (l *layout) crossings() int {
+	count := 0
+	for _, rank := range l.ranks {
+		var segs [][2]int
+		for _, v := range rank {
+			for _, w := range v.out {
+				segs = append(segs, [2]int{v.order, w.order})
+			}
+		}
+		for i := range segs {
+			for j := i + 1; j < len(segs); j++ {
+				a, b := segs[i], segs[j]
+				if (a[0] < b[0] && a[1] > b[1]) || (a[0] > b[0] && a[1] < b[1]) {
+					count++
+				}
+			}
+		}
+	}
+	return count
+}
+
+// separation returns the minimum distance between the centers of two
+// neighbours in a layer, leaving room for the borders of the clusters
+// between them.
+func (l *layout) separation(a, b *lnode) float64 {
+	gap := nodeGap
+	if a.virtual() && b.virtual() && a.label == nil && b.label == nil {
+		gap = virtualGap
+	}
+	for c := a.cluster; c != nil; c = c.Parent {
+		if !c.contains(b.cluster) {
+			gap += clusterPad
+		}
+	}
+	for c := b.cluster; c != nil; c = c.Parent {
+		if !c.contains(a.cluster) {
+			gap += clusterPad
+			if !l.vertical {
+				gap += clusterLabel
+			}
+		}
+	}
+	return a.breadth/2 + gap + b.breadth/2
+}
+
+// place assigns positions within layers, moving nodes towards their
+// neighbours to straighten edges.
+func (l *layout) place() {
+	for _, rank := range l.ranks {
+		for i, v := range rank {
+			if i == 0 {
+				v.pos = v.breadth / 2
+			} else {
+				v.pos = rank[i-1].pos + l.separation(rank[i-1], v)
+			}
+		}
+	}
+
+	for pass := 0; pass < placePasses; pass++ {
+		down := pass%2 == 0
+		last := pass == placePasses-1
+		for i := range l.ranks {
+			r := i
+			if !down {
+				r = len(l.ranks) - 1 - i
+			}
+			desired := make([]float64, len(l.ranks[r]))
+			for j, v := range l.ranks[r] {
+				var ns []*lnode
+				switch {
+				case last:
+					ns = append(append(ns, v.in...), v.out...)
+				case down:
+					ns = v.in
+				default:
+					ns = v.out
+				}
+				desired[j] = v.pos
+				if len(ns) > 0 {
+					sum := 0.0
+					for _, n := range ns {
+						sum += n.pos
+					}
+					desired[j] = sum / float64(len(ns))
+				}
+			}
+			l.settle(l.ranks[r], desired)
+		}
+	}
+}
+
+// settle moves the nodes of a layer as close to their desired positions
+// as their order and separation allow.
+func (l *layout) settle(rank []*lnode, desired []float64) {
+	n := len(rank)
+	if n == 0 {
+		return
+	}
+	left := make([]float64, n)
+	right := make([]float64, n)
+	for i := range rank {
+		left[i] = desired[i]
+		if i > 0 {
+			left[i] = max(left[i], left[i-1]+l.separation(rank[i-1], rank[i]))
+		}
+	}
+	for i := n - 1; i >= 0; i-- {
+		right[i] = desired[i]
+		if i < n-1 {
+			right[i] = min(right[i], right[i+1]-l.separation(rank[i], rank[i+1]))
+		}
+	}
+	for i, v := range rank {
+		v.pos = (left[i] + right[i]) / 2
+		if i > 0 {
+			v.pos = max(v.pos, rank[i-1].pos+l.separation(rank[i-1], v))
+		}
+	}
+}
+
+// separateClusters moves nodes that are not members of a cluster out of
+// the span of its members, which its box will cover in every layer.
+func (l *layout) separateClusters() {
+	sorted := append([]*Cluster(nil), l.g.Clusters...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].depth() > sorted[j].depth() })
+
+	for pass := 0; pass < 3; pass++ {
+		for _, c := range sorted {
+			lo, hi := math.Inf(1), math.Inf(-1)
+			for _, rank := range l.ranks {
+				for _, v := range rank {
+					if c.contains(v.cluster) {
+						lo, hi = min(lo, v.pos-v.breadth/2), max(hi, v.pos+v.breadth/2)
+					}
+				}
+			}
+			if math.IsInf(lo, 1) {
+				continue
+			}
+			for _, rank := range l.ranks {
+				l.clearSpan(rank, c, lo-clusterPad-nodeGap, hi+clusterPad+nodeGap)
+			}
+		}
+	}
+}
+
+// clearSpan moves the nodes of a layer on either side of a cluster's
+// members outside lo and hi, pushing their neighbours along.
+func (l *layout) clearSpan(rank []*lnode, c *Cluster, lo, hi float64) {
+	i0, i1 := -1, -1
+	for i, v := range rank {
+		if c.contains(v.cluster) {
+			if i0 < 0 {
+				i0 = i
+			}
+			i1 = i
+		}
+	}
+	if i0 < 0 {
+		return
+	}
+	for i := i0 - 1; i >= 0; i-- {
+		v := rank[i]
+		limit := rank[i+1].pos - l.separation(v, rank[i+1])
+		if i == i0-1 {
+			limit = min(limit, lo-v.breadth/2)
+		}
+		v.pos = min(v.pos, limit)
+	}
+	for i := i1 + 1; i < len(rank); i++ {
+		v := rank[i]
+		limit := rank[i-1].pos + l.separation(rank[i-1], v)
+		if i == i1+1 {
+			limit = max(limit, hi+v.breadth/2)
+		}
+		v.pos = max(v.pos, limit)
+	}
+}
+
+// coordinates stacks the layers, leaving room for cluster borders, and
+// sets the center of every node.
+func (l *layout) coordinates() {
+	first := make(map[*Cluster]int)
+	last := make(map[*Cluster]int)
+	for _, rank := range l.ranks {
+		for _, v := range rank {
+			for _, c := range v.cluster.path() {
+				if r, ok := first[c]; !ok || v.rank < r {
+					first[c] = v.rank
+				}
+				last[c] = max(last[c], v.rank)
+			}
+		}
+	}
+
+	labelBefore := l.g.Direction == TopToBottom
+	labelAfter := l.g.Direction == BottomToTop
+	along := margin
+	l.centers = make([]float64, len(l.ranks))
+	for r, rank := range l.ranks {
+		before, after, thickness := 0.0, 0.0, 0.0
+		for _, v := range rank {
+			b, a := 0.0, 0.0
+			for _, c := range v.cluster.path() {
+				if first[c] == r {
+					b += clusterPad
+					if labelBefore {
+						b += clusterLabel
+					}
+				}
+				if last[c] == r {
+					a += clusterPad
+					if labelAfter {
+						a += clusterLabel
+					}
+				}
+			}
+			before, after = max(before, b), max(after, a)
+			thickness = max(thickness, v.depth)
+		}
+		along += before
+		l.centers[r] = along + thickness/2
+		along += thickness + after + rankGap
+	}
+	length := along - rankGap + margin
+
+	for _, rank := range l.ranks {
+		for _, v := range rank {
+			v.x, v.y = l.point(v, length)
+			if v.node != nil {
+				v.node.X, v.node.Y = v.x, v.y
+			}
+		}
+	}
+}
+
+// point maps a layer node to screen coordinates.
+func (l *layout) point(v *lnode, length float64) (float64, float64) {
+	along := l.centers[v.rank]
+	switch l.g.Direction {
+	case BottomToTop:
+		return v.pos, length - along
+	case LeftToRight:
+		return along, v.pos
+	case RightToLeft:
+		return length - along, v.pos
+	}
+	return v.pos, along
+}
+
+// clusters sizes every cluster around its nodes and nested clusters,
+// innermost first.
+func (l *layout) clusters() {
+	sorted := append([]*Cluster(nil), l.g.Clusters...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].depth() > sorted[j].depth() })
+
+	for _, c := range sorted {
+		x0, y0, x1, y1 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+		extend := func(ax, ay, bx, by float64) {
+			x0, y0, x1, y1 = min(x0, ax), min(y0, ay), max(x1, bx), max(y1, by)
+		}
+		for _, rank := range l.ranks {
+			for _, v := range rank {
+				switch {
+				case v.cluster != c:
+				case v.node != nil:
+					n := v.node
+					extend(n.X-n.Width/2, n.Y-n.Height/2, n.X+n.Width/2, n.Y+n.Height/2)
+				default:
+					extend(v.x, v.y, v.x, v.y)
+				}
+			}
+		}
+		for _, child := range l.g.Clusters {
+			if child.Parent == c && child.Width > 0 {
+				extend(child.X, child.Y, child.X+child.Width, child.Y+child.Height)
+			}
+		}
+		if math.IsInf(x0, 1) {
+			continue
+		}
+
+		top := clusterPad
+		if c.Label != "" {
+			top += clusterLabel
+		}
+		c.X, c.Y = x0-clusterPad, y0-top
+		c.Width, c.Height = x1-x0+2*clusterPad, y1-y0+clusterPad+top
+		if w, _ := labelSize(c.Label); w+2*clusterPad > c.Width {
+			c.X -= (w + 2*clusterPad - c.Width) / 2
+			c.Width = w + 2*clusterPad
+		}
+	}
+}
+
+// route sets the points of every edge, clipped to the outlines of the
+// shapes it connects, and the positions of edge labels.
+func (l *layout) route() {
+	for _, c := range l.chains {
+		e := c.edge
+		var pts []Point
+		for _, v := range c.nodes {
+			pts = append(pts, Point{v.x, v.y})
+			if v.label != nil {
+				e.LabelX, e.LabelY = v.x, v.y
+			}
+		}
+		if c.reversed {
+			for i, j := 0, len(pts)-1; i < j; i, j = i+1, j-1 {
+				pts[i], pts[j] = pts[j], pts[i]
+			}
+		}
+
+		// Edges to clusters start and end at the cluster's border.
+		if _, cl, _ := l.endpoint(e.From); cl != nil {
+			pts[0] = Point{cl.X + cl.Width/2, cl.Y + cl.Height/2}
+		}
+		if _, cl, _ := l.endpoint(e.To); cl != nil {
+			pts[len(pts)-1] = Point{cl.X + cl.Width/2, cl.Y + cl.Height/2}
+		}
+		pts[0] = l.clip(e.From, pts[0], pts[1])
+		pts[len(pts)-1] = l.clip(e.To, pts[len(pts)-1], pts[len(pts)-2])
+		e.Points = pts
+		e.LabelWidth, _ = labelSize(e.Label)
+	}
+
+	for _, e := range l.loops {
+		n, c, _ := l.endpoint(e.From)
+		var x, y, h float64
+		if n != nil {
+			x, y, h = n.X+n.Width/2, n.Y, n.Height/2
+		} else {
+			x, y, h = c.X+c.Width, c.Y+c.Height/2, c.Height/2
+		}
+		dy := min(h/2, loopSize/2)
+		e.Points = []Point{{x, y - dy}, {x + loopSize, y - dy}, {x + loopSize, y + dy}, {x, y + dy}}
+		e.LabelWidth, _ = labelSize(e.Label)
+		e.LabelX, e.LabelY = x+loopSize+labelPad+e.LabelWidth/2, y
+	}
+}
+
+// clip moves the end of an edge at p, the center of the shape named id,
+// to the shape's outline in the direction of toward.
+func (l *layout) clip(id string, p, toward Point) Point {
+	n, c, _ := l.endpoint(id)
+	hw, hh, shape := 0.0, 0.0, ShapeBox
+	switch {
+	case n != nil:
+		hw, hh, shape = n.Width/2, n.Height/2, n.Shape
+	case c != nil:
+		hw, hh = c.Width/2, c.Height/2
+	}
+	dx, dy := toward.X-p.X, toward.Y-p.Y
+	if (dx == 0 && dy == 0) || hw == 0 || hh == 0 {
+		return p
+	}
+
+	var t float64
+	switch shape {
+	case ShapeEllipse, ShapeCircle, ShapePoint:
+		t = 1 / math.Hypot(dx/hw, dy/hh)
+	case ShapeDiamond:
+		t = 1 / (math.Abs(dx)/hw + math.Abs(dy)/hh)
+	default:
+		t = math.Min(hw/math.Abs(dx), hh/math.Abs(dy))
+	}
+	t = min(t, 1)
+	return Point{p.X + dx*t, p.Y + dy*t}
+}
+
+// fit moves the drawing to the margin and sets the size of the graph.
+func (l *layout) fit() {
+	x0, y0, x1, y1 := math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)
+	extend := func(ax, ay, bx, by float64) {
+		x0, y0, x1, y1 = min(x0, ax), min(y0, ay), max(x1, bx), max(y1, by)
+	}
+	for _, n := range l.g.Nodes {
+		extend(n.X-n.Width/2, n.Y-n.Height/2, n.X+n.Width/2, n.Y+n.Height/2)
+	}
+	for _, c := range l.g.Clusters {
+		if c.Width > 0 {
+			extend(c.X, c.Y, c.X+c.Width, c.Y+c.Height)
+		}
+	}
+	for _, e := range l.g.Edges {
+		for _, p := range e.Points {
+			extend(p.X, p.Y, p.X, p.Y)
+		}
+		if e.Label != "" {
+			_, h := labelSize(e.Label)
+			extend(e.LabelX-e.LabelWidth/2-labelPad, e.LabelY-h/2, e.LabelX+e.LabelWidth/2+labelPad, e.LabelY+h/2)
+		}
+	}
+	if math.IsInf(x0, 1) {
+		l.g.Width, l.g.Height = 2*margin, 2*margin
+		return
+	}
+
+	dx, dy := margin-x0, margin-y0
+	for _, n := range l.g.Nodes {
+		n.X += dx
+		n.Y += dy
+	}
+	for _, c := range l.g.Clusters {
+		c.X += dx
+		c.Y += dy
+	}
+	for _, e := range l.g.Edges {
+		for i := range e.Points {
+			e.Points[i].X += dx
+			e.Points[i].Y += dy
+		}
+		e.LabelX += dx
+		e.LabelY += dy
+	}
+	l.g.Width, l.g.Height = x1-x0+2*margin, y1-y0+2*margin
+}
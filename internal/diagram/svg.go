@@ -0,0 +1,223 @@
+package diagram
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default colors, used when the page's stylesheet does not theme the
+// diagram.
+const (
+	defaultFill    = "#ffffff"
+	defaultStroke  = "#555555"
+	defaultText    = "#1a1a1a"
+	defaultCluster = "#f6f6f6"
+)
+
+// SVG draws the laid out graph as an SVG document. id prefixes the IDs of
+// the arrow markers, which must be unique within a page.
+func (g *Graph) SVG(id string) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, `<svg xmlns="http://www.w3.org/2000/svg" class="diagram-svg" width="%s" height="%s" viewBox="0 0 %s %s" role="img" font-family="system-ui, -apple-system, sans-serif" font-size="%s">`,
+		num(g.Width), num(g.Height), num(g.Width), num(g.Height), num(FontSize))
+
+	markers := g.markers(id)
+	b.WriteString("<defs>")
+	for _, stroke := range sortedKeys(markers) {
+		fill := stroke
+		class := ""
+		if stroke == "" {
+			fill, class = defaultStroke, ` class="diagram-arrow"`
+		}
+		fmt.Fprintf(&b, `<marker id="%s" viewBox="0 0 10 10" refX="9" refY="5" markerWidth="8" markerHeight="8" orient="auto-start-reverse"><path d="M0,0L10,5L0,10z"%s fill="%s"/></marker>`,
+			markers[stroke], class, attr(fill))
+	}
+	b.WriteString("</defs>")
+
+	for _, c := range g.Clusters {
+		if c.Width == 0 || c.Style.Hidden {
+			continue
+		}
+		b.WriteString(`<g class="diagram-cluster">`)
+		fmt.Fprintf(&b, `<rect x="%s" y="%s" width="%s" height="%s" rx="6" fill="%s" stroke="%s"%s/>`,
+			num(c.X), num(c.Y), num(c.Width), num(c.Height), defaultCluster, defaultStroke, styleAttr(c.Style))
+		if c.Label != "" {
+			writeText(&b, c.Label, c.X+c.Width/2, c.Y+clusterPad/2+LineHeight/2, c.Style.FontColor)
+		}
+		b.WriteString("</g>")
+	}
+
+	for _, e := range g.Edges {
+		if e.Style.Hidden || len(e.Points) < 2 {
+			continue
+		}
+		b.WriteString(`<g class="diagram-edge">`)
+		fmt.Fprintf(&b, `<path d="%s" fill="none" stroke="%s"`, edgePath(e.Points), defaultStroke)
+		if e.Style.Stroke != "" {
+			fmt.Fprintf(&b, ` style="stroke:%s"`, attr(e.Style.Stroke))
+		}
+		if e.Style.Dashed {
+			b.WriteString(` stroke-dasharray="5,4"`)
+		}
+		if e.ArrowHead {
+			fmt.Fprintf(&b, ` marker-end="url(#%s)"`, markers[e.Style.Stroke])
+		}
+		if e.ArrowTail {
+			fmt.Fprintf(&b, ` marker-start="url(#%s)"`, markers[e.Style.Stroke])
+		}
+		b.WriteString("/>")
+		if e.Label != "" {
+			_, h := labelSize(e.Label)
+			fmt.Fprintf(&b, `<rect class="diagram-label" x="%s" y="%s" width="%s" height="%s" rx="3" fill="%s"/>`,
+				num(e.LabelX-e.LabelWidth/2-labelPad), num(e.LabelY-h/2), num(e.LabelWidth+2*labelPad), num(h), defaultFill)
+			writeText(&b, e.Label, e.LabelX, e.LabelY, e.Style.FontColor)
+		}
+		b.WriteString("</g>")
+	}
+
+	for _, n := range g.Nodes {
+		if n.Style.Hidden {
+			continue
+		}
+		b.WriteString(`<g class="diagram-node">`)
+		writeShape(&b, n)
+		if n.Shape != ShapePoint {
+			writeText(&b, n.Label, n.X, n.Y, n.Style.FontColor)
+		}
+		b.WriteString("</g>")
+	}
+
+	b.WriteString("</svg>")
+	return b.Bytes()
+}
+
+// markers returns the arrow marker ID for each edge color, "" being the
+// theme's color.
+func (g *Graph) markers(id string) map[string]string {
+	markers := make(map[string]string)
+	for _, e := range g.Edges {
+		if !e.ArrowHead && !e.ArrowTail {
+			continue
+		}
+		if _, ok := markers[e.Style.Stroke]; !ok {
+			markers[e.Style.Stroke] = fmt.Sprintf("%s-arrow-%d", id, len(markers))
+		}
+	}
+	return markers
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func writeShape(b *bytes.Buffer, n *Node) {
+	x0, y0 := n.X-n.Width/2, n.Y-n.Height/2
+	x1, y1 := n.X+n.Width/2, n.Y+n.Height/2
+	style := styleAttr(n.Style)
+	paint := fmt.Sprintf(`fill="%s" stroke="%s"%s`, defaultFill, defaultStroke, style)
+	if n.Style.Dashed {
+		paint += ` stroke-dasharray="5,4"`
+	}
+
+	switch n.Shape {
+	case ShapeEllipse, ShapeCircle:
+		fmt.Fprintf(b, `<ellipse cx="%s" cy="%s" rx="%s" ry="%s" %s/>`, num(n.X), num(n.Y), num(n.Width/2), num(n.Height/2), paint)
+	case ShapeDiamond:
+		writePolygon(b, paint, []Point{{n.X, y0}, {x1, n.Y}, {n.X, y1}, {x0, n.Y}})
+	case ShapeHexagon:
+		d := n.Height / 4
+		writePolygon(b, paint, []Point{{x0 + d, y0}, {x1 - d, y0}, {x1, n.Y}, {x1 - d, y1}, {x0 + d, y1}, {x0, n.Y}})
+	case ShapeParallelogram:
+		d := n.Height / 4
+		writePolygon(b, paint, []Point{{x0 + d, y0}, {x1, y0}, {x1 - d, y1}, {x0, y1}})
+	case ShapeCylinder:
+		ry := 8.0
+		fmt.Fprintf(b, `<path d="M%s,%s a%s,%s 0 0,0 %s,0 a%s,%s 0 0,0 -%s,0 v%s a%s,%s 0 0,0 %s,0 v-%s" %s/>`,
+			num(x0), num(y0+ry), num(n.Width/2), num(ry), num(n.Width), num(n.Width/2), num(ry), num(n.Width),
+			num(n.Height-2*ry), num(n.Width/2), num(ry), num(n.Width), num(n.Height-2*ry), paint)
+	case ShapeText:
+	case ShapePoint:
+		fmt.Fprintf(b, `<circle class="diagram-point" cx="%s" cy="%s" r="%s" fill="%s"%s/>`, num(n.X), num(n.Y), num(n.Width/2), defaultStroke, style)
+	default:
+		rx := 2.0
+		if n.Style.Rounded {
+			rx = 10
+		}
+		fmt.Fprintf(b, `<rect x="%s" y="%s" width="%s" height="%s" rx="%s" %s/>`, num(x0), num(y0), num(n.Width), num(n.Height), num(rx), paint)
+	}
+}
+
+func writePolygon(b *bytes.Buffer, paint string, pts []Point) {
+	var s []string
+	for _, p := range pts {
+		s = append(s, num(p.X)+","+num(p.Y))
+	}
+	fmt.Fprintf(b, `<polygon points="%s" %s/>`, strings.Join(s, " "), paint)
+}
+
+// writeText writes a label centered on x, y, one tspan per line.
+func writeText(b *bytes.Buffer, label string, x, y float64, color string) {
+	if label == "" {
+		return
+	}
+	ls := lines(label)
+	fmt.Fprintf(b, `<text class="diagram-text" x="%s" y="%s" text-anchor="middle" dominant-baseline="central" fill="%s"`, num(x), num(y), defaultText)
+	if color != "" {
+		fmt.Fprintf(b, ` style="fill:%s"`, attr(color))
+	}
+	b.WriteString(">")
+	top := y - float64(len(ls)-1)*LineHeight/2
+	for i, l := range ls {
+		fmt.Fprintf(b, `<tspan x="%s" y="%s">%s</tspan>`, num(x), num(top+float64(i)*LineHeight), html.EscapeString(l))
+	}
+	b.WriteString("</text>")
+}
+
+// edgePath returns an SVG path through pts.
+func edgePath(pts []Point) string {
+	var b strings.Builder
+	for i, p := range pts {
+		cmd := " L"
+		if i == 0 {
+			cmd = "M"
+		}
+		fmt.Fprintf(&b, "%s%s,%s", cmd, num(p.X), num(p.Y))
+	}
+	return b.String()
+}
+
+// styleAttr returns a style attribute overriding the theme's colors with
+// the explicit colors of s.
+func styleAttr(s Style) string {
+	var props []string
+	if s.Fill != "" {
+		props = append(props, "fill:"+attr(s.Fill))
+	}
+	if s.Stroke != "" {
+		props = append(props, "stroke:"+attr(s.Stroke))
+	}
+	if len(props) == 0 {
+		return ""
+	}
+	return ` style="` + strings.Join(props, ";") + `"`
+}
+
+// attr escapes a user supplied value for an attribute. Semicolons would
+// let a color inject further style properties, so they are dropped.
+func attr(s string) string {
+	return html.EscapeString(strings.ReplaceAll(s, ";", ""))
+}
+
+func num(f float64) string {
+	return strconv.FormatFloat(math.Round(f*100)/100, 'f', -1, 64)
+}
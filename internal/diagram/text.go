@@ -0,0 +1,50 @@
+package diagram
+
+import (
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/opentype"
+)
+
+// Label metrics, in pixels.
+const (
+	FontSize   = 14.0
+	LineHeight = 18.0
+)
+
+var (
+	faceOnce sync.Once
+	face     font.Face
+)
+
+// textWidth returns the width of s set in Go Regular at FontSize, which is
+// close to the width of the sans-serif fonts the viewer uses and exact for
+// PDF exports.
+func textWidth(s string) float64 {
+	faceOnce.Do(func() {
+		f, err := opentype.Parse(goregular.TTF)
+		if err != nil {
+			panic(err)
+		}
+		face, err = opentype.NewFace(f, &opentype.FaceOptions{Size: FontSize, DPI: 72})
+		if err != nil {
+			panic(err)
+		}
+	})
+	return float64(font.MeasureString(face, s)) / 64
+}
+
+// labelSize returns the width and height of a possibly multi-line label.
+func labelSize(label string) (float64, float64) {
+	if label == "" {
+		return 0, 0
+	}
+	w := 0.0
+	ls := lines(label)
+	for _, l := range ls {
+		w = max(w, textWidth(l))
+	}
+	return w, float64(len(ls)) * LineHeight
+}
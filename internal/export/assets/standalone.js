@@ -0,0 +1,48 @@
+// Standalone export script — theme toggle and Mermaid rendering without
+// network access.
+(function () {
+  "use strict";
+
+  function isDark() {
+    return document.documentElement.classList.contains("dark");
+  }
+
+  // Replace ```mermaid blocks with containers that keep their source, so
+  // diagrams can be drawn again when the theme changes.
+  function prepareDiagrams() {
+    document.querySelectorAll("pre > code.language-mermaid").forEach(function (code) {
+      var pre = code.parentElement;
+      var div = document.createElement("div");
+      div.className = "mermaid";
+      div.setAttribute("data-original-source", code.textContent);
+      div.textContent = code.textContent;
+      pre.parentNode.replaceChild(div, pre);
+    });
+  }
+
+  function renderDiagrams() {
+    if (typeof mermaid === "undefined") return;
+
+    var nodes = Array.prototype.slice.call(document.querySelectorAll("div.mermaid"));
+    if (nodes.length === 0) return;
+
+    nodes.forEach(function (div) {
+      div.removeAttribute("data-processed");
+      div.textContent = div.getAttribute("data-original-source");
+    });
+    mermaid.initialize({ startOnLoad: false, theme: isDark() ? "dark" : "default" });
+    mermaid.run({ nodes: nodes });
+  }
+
+  document.getElementById("theme-toggle").addEventListener("click", function () {
+    var dark = !isDark();
+    document.documentElement.classList.toggle("dark", dark);
+    try {
+      localStorage.setItem("themeMode", dark ? "dark" : "light");
+    } catch (e) {}
+    renderDiagrams();
+  });
+
+  prepareDiagrams();
+  renderDiagrams();
+})();
@@ -0,0 +1,196 @@
+package export
+
+import (
+	"bytes"
+	"embed"
+	"encoding/base64"
+	"html/template"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/web"
+	"github.com/yuin/goldmark/ast"
+)
+
+// assets holds the standalone page template, its stylesheet and script, and
+// Mermaid, which is inlined into exports that contain diagrams along with
+// its MIT license, in mermaid.LICENSE.
+//
+// mermaid.min.js is the unmodified Mermaid 10.6.0 build from
+// https://cdn.jsdelivr.net/npm/mermaid@10.6.0/dist/mermaid.min.js, with
+// SHA-256 bda42a045be085a58c5f485b5e51ec1100a8f42d579fc42c0467a5ea44f75fb1.
+// The viewer loads the same version from the CDN in
+// web/templates/layouts/base.html; upgrade both together.
+//
+//go:embed assets
+var assets embed.FS
+
+// mermaidVersion is the version of the bundled Mermaid.
+const mermaidVersion = "10.6.0"
+
+var standalone = template.Must(template.ParseFS(assets, "assets/standalone.html"))
+
+// scriptEnd matches closing script tags, which must not appear verbatim in
+// inlined scripts.
+var scriptEnd = regexp.MustCompile(`(?i)</script`)
+
+// standalonePage is the data of the standalone page template.
+type standalonePage struct {
+	Title   string
+	Content template.HTML
+	TOC     []markdown.TOCEntry
+	CSS     template.CSS
+	Script  template.JS
+	Mermaid template.JS
+}
+
+// HTML writes doc as a single self-contained HTML page titled title, with
+// the stylesheet, theme toggle, table sorting and, when the spec has diagrams, Mermaid
+// inlined so it opens offline. Images referenced by relative paths are
+// resolved against dir and embedded as data URIs, which modifies doc.
+func HTML(w io.Writer, title string, doc *markdown.Document, dir string) error {
+	inlineImages(doc, dir)
+
+	var content bytes.Buffer
+	if err := doc.Render(&content); err != nil {
+		return err
+	}
+
+	css, err := stylesheet()
+	if err != nil {
+		return err
+	}
+	script, err := inlineScript(assets, "assets/standalone.js")
+	if err != nil {
+		return err
+	}
+	// CSV tables sort as they do in the viewer.
+	tables, err := inlineScript(web.Files, "public/js/data-table.js")
+	if err != nil {
+		return err
+	}
+	script += "\n" + tables
+
+	page := standalonePage{
+		Title:   title,
+		Content: template.HTML(content.String()),
+		TOC:     doc.TOC(),
+		CSS:     css,
+		Script:  script,
+	}
+	if hasMermaid(doc) {
+		if page.Mermaid, err = mermaid(); err != nil {
+			return err
+		}
+	}
+
+	return standalone.Execute(w, page)
+}
+
+// stylesheet returns the viewer's theme stylesheet followed by the
+// standalone layout, so exports share the viewer's colours.
+func stylesheet() (template.CSS, error) {
+	theme, err := web.Files.ReadFile("public/css/main.css")
+	if err != nil {
+		return "", err
+	}
+	layout, err := assets.ReadFile("assets/standalone.css")
+	if err != nil {
+		return "", err
+	}
+	return template.CSS(string(theme) + "\n" + string(layout)), nil
+}
+
+// mermaid returns the bundled Mermaid preceded by its license, which must
+// go along with every copy.
+func mermaid() (template.JS, error) {
+	license, err := assets.ReadFile("assets/mermaid.LICENSE")
+	if err != nil {
+		return "", err
+	}
+	script, err := inlineScript(assets, "assets/mermaid.min.js")
+	if err != nil {
+		return "", err
+	}
+	return template.JS("/*! Mermaid "+mermaidVersion+"\n\n"+string(license)+"*/\n") + script, nil
+}
+
+func inlineScript(fsys embed.FS, name string) (template.JS, error) {
+	data, err := fsys.ReadFile(name)
+	if err != nil {
+		return "", err
+	}
+	return template.JS(scriptEnd.ReplaceAll(data, []byte(`<\/script`))), nil
+}
+
+// hasMermaid reports whether doc, or a document it includes, has a
+// Mermaid diagram.
+func hasMermaid(doc *markdown.Document) bool {
+	found := false
+	_ = ast.Walk(doc.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.FencedCodeBlock:
+			found = string(n.Language(doc.Source)) == "mermaid"
+		case *markdown.Include:
+			found = n.Doc != nil && hasMermaid(n.Doc)
+		}
+		if found {
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+// imageTypes are the image formats that can be inlined. Goldmark drops
+// data URIs of any other type as unsafe.
+var imageTypes = map[string]string{
+	".png":  "image/png",
+	".gif":  "image/gif",
+	".jpg":  "image/jpeg",
+	".jpeg": "image/jpeg",
+	".webp": "image/webp",
+}
+
+// inlineImages replaces relative image destinations with data URIs of the
+// files they point to, those of included documents being relative to the
+// included file. Images that cannot be read keep their destination.
+func inlineImages(doc *markdown.Document, dir string) {
+	_ = ast.Walk(doc.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if inc, ok := n.(*markdown.Include); ok && entering && inc.Doc != nil {
+			// The target is relative to the including file, as dir is.
+			file, _, _ := strings.Cut(inc.Target, "#")
+			inlineImages(inc.Doc, filepath.Join(dir, filepath.FromSlash(path.Dir(file))))
+			return ast.WalkContinue, nil
+		}
+		img, ok := n.(*ast.Image)
+		if !ok || !entering {
+			return ast.WalkContinue, nil
+		}
+
+		u, err := url.Parse(string(img.Destination))
+		if err != nil || u.Scheme != "" || u.Host != "" || !filepath.IsLocal(filepath.FromSlash(u.Path)) {
+			return ast.WalkContinue, nil
+		}
+		contentType, ok := imageTypes[strings.ToLower(path.Ext(u.Path))]
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(u.Path)))
+		if err != nil {
+			return ast.WalkContinue, nil
+		}
+
+		img.Destination = []byte("data:" + contentType + ";base64," + base64.StdEncoding.EncodeToString(data))
+		return ast.WalkContinue, nil
+	})
+}
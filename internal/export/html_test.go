@@ -0,0 +1,100 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+)
+
+func TestHTML_Standalone(t *testing.T) {
+	var buf bytes.Buffer
+	if err := HTML(&buf, "spec.md", markdown.Parse([]byte(sampleSpec)), t.TempDir()); err != nil {
+		t.Fatalf("HTML returned error: %v", err)
+	}
+	got := buf.String()
+
+	for _, want := range []string{
+		"<title>spec.md</title>",
+		`<h2 id="data-model"`,
+		`<a href="#data-model">Data Model</a>`,
+		"--background:",
+		`id="theme-toggle"`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q", want)
+		}
+	}
+	if strings.Contains(got, "<link") || strings.Contains(got, "<script src") {
+		t.Error("expected no external stylesheets or scripts")
+	}
+	if buf.Len() > 1<<20 {
+		t.Errorf("expected Mermaid to be left out of specs without diagrams, got %d bytes", buf.Len())
+	}
+}
+
+func TestHTML_InlinesMermaid(t *testing.T) {
+	var buf bytes.Buffer
+	src := "# Flow\n\n```mermaid\ngraph TD; A-->B\n```\n"
+	if err := HTML(&buf, "flow.md", markdown.Parse([]byte(src)), t.TempDir()); err != nil {
+		t.Fatalf("HTML returned error: %v", err)
+	}
+	if buf.Len() < 1<<20 {
+		t.Errorf("expected the Mermaid library to be inlined, got %d bytes", buf.Len())
+	}
+	if !strings.Contains(buf.String(), "/*! Mermaid 10.6.0\n\nThe MIT License (MIT)\n\nCopyright (c) 2014 - 2022 Knut Sveidqvist") {
+		t.Error("expected the Mermaid license to be inlined with it")
+	}
+}
+
+func TestHTML_InlinesLocalImages(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "pixel.png"), []byte("png-bytes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	src := "![local](pixel.png) ![remote](https://example.com/x.png) ![outside](../x.png)\n"
+	if err := HTML(&buf, "img.md", markdown.Parse([]byte(src)), dir); err != nil {
+		t.Fatalf("HTML returned error: %v", err)
+	}
+	got := buf.String()
+
+	if !strings.Contains(got, `src="data:image/png;base64,cG5nLWJ5dGVz"`) {
+		t.Error("expected the local image to be inlined")
+	}
+	if !strings.Contains(got, `src="https://example.com/x.png"`) || !strings.Contains(got, `src="../x.png"`) {
+		t.Error("expected remote and outside images to keep their destination")
+	}
+}
+
+func TestHTML_Includes(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "shared", "img"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"shared/flow.md":       "```mermaid\ngraph TD; A-->B\n```\n\n![pixel](img/pixel.png)\n",
+		"shared/img/pixel.png": "png-bytes",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(root, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	doc := markdown.ParseFile(root, "001-auth/spec.md", []byte("# Auth\n\n![[../shared/flow.md]]\n"))
+	if err := HTML(&buf, "spec.md", doc, filepath.Join(root, "001-auth")); err != nil {
+		t.Fatalf("HTML returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `src="data:image/png;base64,cG5nLWJ5dGVz"`) {
+		t.Error("expected the image of the included file to be inlined")
+	}
+	if buf.Len() < 1<<20 {
+		t.Errorf("expected the Mermaid library to be inlined for the included diagram, got %d bytes", buf.Len())
+	}
+}
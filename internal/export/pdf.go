@@ -0,0 +1,781 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/diagram"
+	"github.com/SantiagoBobrik/spec-viewer/internal/latex"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/go-pdf/fpdf"
+	"github.com/yuin/goldmark/ast"
+	east "github.com/yuin/goldmark/extension/ast"
+	"golang.org/x/image/font/gofont/gobold"
+	"golang.org/x/image/font/gofont/gobolditalic"
+	"golang.org/x/image/font/gofont/goitalic"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/gofont/gomonobold"
+	"golang.org/x/image/font/gofont/goregular"
+)
+
+// Page layout, in millimetres and points.
+const (
+	margin      = 20.0
+	lineHeight  = 5.5
+	blockGap    = 3.0
+	listIndent  = 6.0
+	quoteIndent = 5.0
+	cellPadding = 1.5
+	tocLine     = 6.5
+	tocNumber   = 12.0
+
+	bodySize = 10.5
+	codeSize = 9.0
+
+	fontSans = "go"
+	fontMono = "gomono"
+)
+
+// headingSizes holds the font size of each heading level.
+var headingSizes = [...]float64{1: 20, 2: 16, 3: 13.5, 4: 12, 5: 11, 6: 10.5}
+
+// PDF writes doc as an A4 PDF document titled title. Headings are listed
+// in a table of contents on the first page, linked to their pages.
+func PDF(w io.Writer, title string, doc *markdown.Document) error {
+	// The table of contents comes before the headings it lists. Its layout
+	// does not depend on the page numbers, so a first pass finds the page
+	// of every heading and a second pass fills them in.
+	pages := make(map[string]int)
+	if _, err := renderPDF(title, doc, pages); err != nil {
+		return err
+	}
+	pdf, err := renderPDF(title, doc, pages)
+	if err != nil {
+		return err
+	}
+	return pdf.Output(w)
+}
+
+// renderPDF lays out the document, recording the page of each heading ID
+// in pages. Page numbers already in pages are printed in the contents.
+func renderPDF(title string, doc *markdown.Document, pages map[string]int) (*fpdf.Fpdf, error) {
+	pdf := fpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(margin, margin, margin)
+	pdf.SetAutoPageBreak(true, margin)
+	pdf.SetTitle(title, true)
+	pdf.SetCreator("spec-viewer", true)
+
+	pdf.AddUTF8FontFromBytes(fontSans, "", goregular.TTF)
+	pdf.AddUTF8FontFromBytes(fontSans, "B", gobold.TTF)
+	pdf.AddUTF8FontFromBytes(fontSans, "I", goitalic.TTF)
+	pdf.AddUTF8FontFromBytes(fontSans, "BI", gobolditalic.TTF)
+	pdf.AddUTF8FontFromBytes(fontMono, "", gomono.TTF)
+	pdf.AddUTF8FontFromBytes(fontMono, "B", gomonobold.TTF)
+
+	pdf.SetFooterFunc(func() {
+		pdf.SetY(-margin + 5)
+		pdf.SetFont(fontSans, "", 8)
+		pdf.SetTextColor(130, 130, 130)
+		pdf.CellFormat(0, 5, title, "", 0, "L", false, 0, "")
+		pdf.SetX(margin)
+		pdf.CellFormat(0, 5, strconv.Itoa(pdf.PageNo()), "", 0, "R", false, 0, "")
+	})
+
+	r := &pdfRenderer{
+		pdf:   pdf,
+		doc:   doc,
+		links: make(map[string]int),
+		pages: pages,
+		size:  bodySize,
+	}
+	r.contents(title, doc.TOC())
+	r.blocks(doc.Root)
+
+	return pdf, pdf.Error()
+}
+
+// pdfRenderer walks a goldmark AST and draws it with fpdf. Inline styles
+// nest, so they are tracked as counters rather than flags.
+type pdfRenderer struct {
+	pdf *fpdf.Fpdf
+	doc *markdown.Document
+
+	// links maps heading IDs to PDF link targets and pages maps them to
+	// the page they were drawn on.
+	links map[string]int
+	pages map[string]int
+
+	size                       float64
+	bold, italic, strike, mono int
+	quote                      int
+	link                       string
+}
+
+// contents draws the title and the table of contents, then starts the
+// body on a new page. Documents without headings start right below the
+// title.
+func (r *pdfRenderer) contents(title string, toc []markdown.TOCEntry) {
+	p := r.pdf
+	p.AddPage()
+	p.SetFont(fontSans, "B", 22)
+	p.MultiCell(0, 10, title, "", "L", false)
+	p.Ln(blockGap)
+	if len(toc) == 0 {
+		return
+	}
+
+	p.SetFont(fontSans, "B", 13)
+	p.CellFormat(0, 8, "Contents", "", 1, "L", false, 0, "")
+	p.Ln(1)
+
+	top := toc[0].Level
+	for _, e := range toc {
+		top = min(top, e.Level)
+	}
+
+	width := r.contentWidth()
+	for _, e := range toc {
+		link := p.AddLink()
+		r.links[e.ID] = link
+
+		indent := float64(e.Level-top) * listIndent
+		style := ""
+		if e.Level == top {
+			style = "B"
+		}
+		p.SetFont(fontSans, style, bodySize)
+
+		page := ""
+		if n := r.pages[e.ID]; n > 0 {
+			page = strconv.Itoa(n)
+		}
+		textWidth := width - indent - tocNumber
+		p.SetX(margin + indent)
+		p.CellFormat(textWidth, tocLine, r.fit(e.Text, textWidth), "", 0, "L", false, link, "")
+		p.CellFormat(tocNumber, tocLine, page, "", 1, "R", false, link, "")
+	}
+	p.AddPage()
+}
+
+// fit shortens s with an ellipsis until it fits in width with the
+// current font.
+func (r *pdfRenderer) fit(s string, width float64) string {
+	width -= 2 * r.pdf.GetCellMargin()
+	if r.pdf.GetStringWidth(s) <= width {
+		return s
+	}
+	runes := []rune(s)
+	for len(runes) > 0 && r.pdf.GetStringWidth(string(runes)+"…") > width {
+		runes = runes[:len(runes)-1]
+	}
+	return string(runes) + "…"
+}
+
+func (r *pdfRenderer) contentWidth() float64 {
+	pageWidth, _ := r.pdf.GetPageSize()
+	left, _, right, _ := r.pdf.GetMargins()
+	return pageWidth - left - right
+}
+
+// pageBottom returns the y position that triggers a page break.
+func (r *pdfRenderer) pageBottom() float64 {
+	_, pageHeight := r.pdf.GetPageSize()
+	_, bottom := r.pdf.GetAutoPageBreak()
+	return pageHeight - bottom
+}
+
+// ensureSpace starts a new page unless h millimetres fit on this one.
+func (r *pdfRenderer) ensureSpace(h float64) {
+	if r.pdf.GetY()+h > r.pageBottom() {
+		r.pdf.AddPage()
+	}
+}
+
+// indent runs fn with the left margin moved right by dx.
+func (r *pdfRenderer) indent(dx float64, fn func()) {
+	left, _, _, _ := r.pdf.GetMargins()
+	r.pdf.SetLeftMargin(left + dx)
+	fn()
+	r.pdf.SetLeftMargin(left)
+	r.pdf.SetX(left)
+}
+
+// setFont applies the current inline style.
+func (r *pdfRenderer) setFont() {
+	family, style, size := fontSans, "", r.size
+	if r.bold > 0 {
+		style += "B"
+	}
+	if r.mono > 0 {
+		family = fontMono
+		size *= 0.9
+	} else if r.italic > 0 {
+		style += "I"
+	}
+	if r.strike > 0 {
+		style += "S"
+	}
+	r.pdf.SetFont(family, style, size)
+
+	switch {
+	case r.link != "":
+		r.pdf.SetTextColor(37, 99, 235)
+	case r.quote > 0:
+		r.pdf.SetTextColor(100, 100, 100)
+	default:
+		r.pdf.SetTextColor(20, 20, 20)
+	}
+}
+
+func (r *pdfRenderer) blocks(parent ast.Node) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		r.block(n)
+	}
+}
+
+func (r *pdfRenderer) block(n ast.Node) {
+	switch n := n.(type) {
+	case *ast.Heading:
+		r.heading(n)
+	case *ast.Paragraph:
+		r.paragraph(n)
+		r.pdf.Ln(blockGap)
+	case *ast.TextBlock:
+		r.paragraph(n)
+	case *ast.List:
+		r.list(n)
+	case *ast.Blockquote:
+		r.blockquote(n)
+	case *markdown.Alert:
+		r.alert(n)
+	case *east.DefinitionList:
+		r.definitionList(n)
+	case *east.FootnoteList:
+		r.footnotes(n)
+	case *markdown.Include:
+		r.include(n)
+	case *ast.FencedCodeBlock:
+		if g := r.compiledDiagram(n); g != nil {
+			r.diagram(g)
+		} else if f := r.fenceFormula(n); f != nil {
+			r.displayMath(f)
+		} else {
+			r.code(n)
+		}
+	case *markdown.MathBlock:
+		if f, err := latex.Parse(n.Formula(r.doc.Source)); err == nil {
+			r.displayMath(f)
+		} else {
+			r.code(n)
+		}
+	case *ast.CodeBlock:
+		r.code(n)
+	case *east.Table:
+		r.table(n)
+	case *ast.ThematicBreak:
+		r.rule()
+	case *ast.HTMLBlock:
+		// Raw HTML has no PDF equivalent.
+	case *markdown.FrontMatter:
+		// Front matter is not rendered.
+	default:
+		r.blocks(n)
+	}
+}
+
+func (r *pdfRenderer) heading(n *ast.Heading) {
+	size := headingSizes[min(max(n.Level, 1), len(headingSizes)-1)]
+	h := size * 0.5
+
+	if r.pdf.GetY() > margin+1 {
+		r.pdf.Ln(h * 0.6)
+	}
+	// Keep the heading together with the first lines that follow it.
+	r.ensureSpace(h + 2*lineHeight)
+
+	if id, ok := n.AttributeString("id"); ok {
+		key := fmt.Sprint(id)
+		if b, ok := id.([]byte); ok {
+			key = string(b)
+		}
+		r.pages[key] = r.pdf.PageNo()
+		if link, ok := r.links[key]; ok {
+			r.pdf.SetLink(link, r.pdf.GetY(), -1)
+		}
+	}
+
+	prev := r.size
+	r.size = size
+	r.bold++
+	r.setFont()
+	r.inlines(n, h)
+	r.bold--
+	r.size = prev
+	r.pdf.Ln(h)
+
+	if n.Level <= 2 {
+		y := r.pdf.GetY() + 0.5
+		left, _, _, _ := r.pdf.GetMargins()
+		r.pdf.SetDrawColor(220, 220, 220)
+		r.pdf.Line(left, y, left+r.contentWidth(), y)
+		r.pdf.Ln(1.5)
+	}
+	r.pdf.Ln(1)
+	r.setFont()
+}
+
+func (r *pdfRenderer) paragraph(n ast.Node) {
+	r.setFont()
+	r.inlines(n, lineHeight)
+	r.pdf.Ln(lineHeight)
+}
+
+func (r *pdfRenderer) list(n *ast.List) {
+	number := n.Start
+	for item := n.FirstChild(); item != nil; item = item.NextSibling() {
+		r.ensureSpace(lineHeight)
+		left, _, _, _ := r.pdf.GetMargins()
+		r.pdf.SetX(left)
+
+		marker := "•"
+		if n.IsOrdered() {
+			marker = fmt.Sprintf("%d%c", number, n.Marker)
+			number++
+		}
+		// Task items show their checkbox instead of a bullet.
+		if isTask(item) {
+			marker = ""
+		}
+		r.setFont()
+		r.pdf.CellFormat(listIndent, lineHeight, marker, "", 0, "L", false, 0, "")
+
+		r.indent(listIndent, func() {
+			r.pdf.SetX(left + listIndent)
+			r.blocks(item)
+		})
+	}
+	if _, nested := n.Parent().(*ast.ListItem); !nested {
+		r.pdf.Ln(blockGap)
+	}
+}
+
+// isTask reports whether a list item starts with a task checkbox.
+func isTask(item ast.Node) bool {
+	first := item.FirstChild()
+	if first == nil {
+		return false
+	}
+	_, ok := first.FirstChild().(*east.TaskCheckBox)
+	return ok
+}
+
+func (r *pdfRenderer) blockquote(n *ast.Blockquote) {
+	r.quoted(n, [3]int{200, 200, 200}, func() {
+		r.quote++
+		r.blocks(n)
+		r.quote--
+	})
+}
+
+// alertColors holds the bar and title color of each alert type.
+var alertColors = map[string][3]int{
+	"note":      {9, 105, 218},
+	"tip":       {26, 127, 55},
+	"important": {130, 80, 223},
+	"warning":   {154, 103, 0},
+	"caution":   {209, 36, 47},
+}
+
+// alert draws an alert like a blockquote, with a bar and a title in the
+// color of its type.
+func (r *pdfRenderer) alert(n *markdown.Alert) {
+	color := alertColors[n.AlertType]
+	r.quoted(n, color, func() {
+		r.ensureSpace(2 * lineHeight)
+		r.bold++
+		r.setFont()
+		r.pdf.SetTextColor(color[0], color[1], color[2])
+		r.pdf.Write(lineHeight, n.Title())
+		r.bold--
+		r.pdf.Ln(lineHeight + 1)
+		r.setFont()
+		r.blocks(n)
+	})
+}
+
+// quoted runs fn indented, with a bar of the given color drawn on its left
+// on every page the content spans.
+func (r *pdfRenderer) quoted(n ast.Node, bar [3]int, fn func()) {
+	left, _, _, _ := r.pdf.GetMargins()
+	startPage, startY := r.pdf.PageNo(), r.pdf.GetY()
+
+	r.indent(quoteIndent, func() {
+		r.pdf.SetX(left + quoteIndent)
+		fn()
+	})
+	r.setFont()
+	if !n.HasChildren() {
+		r.pdf.Ln(blockGap)
+	}
+
+	endPage, endY := r.pdf.PageNo(), r.pdf.GetY()-blockGap
+	r.pdf.SetDrawColor(bar[0], bar[1], bar[2])
+	r.pdf.SetLineWidth(0.8)
+	for page := startPage; page <= endPage; page++ {
+		top, bottom := margin, r.pageBottom()
+		if page == startPage {
+			top = startY
+		}
+		if page == endPage {
+			bottom = endY
+		}
+		r.pdf.SetPage(page)
+		r.pdf.Line(left+1, top, left+1, bottom)
+	}
+	r.pdf.SetPage(endPage)
+	r.pdf.SetLineWidth(0.2)
+}
+
+// include draws the blocks of an included spec, or the directive and the
+// reason it failed.
+func (r *pdfRenderer) include(n *markdown.Include) {
+	if n.Doc == nil {
+		r.setFont()
+		r.styled(&r.mono, func() { r.write(lineHeight, strings.TrimSpace(r.codeText(n))) })
+		r.write(lineHeight, " "+n.Err)
+		r.pdf.Ln(lineHeight + blockGap)
+		return
+	}
+	prev := r.doc
+	r.doc = n.Doc
+	r.blocks(n.Doc.Root)
+	r.doc = prev
+}
+
+// definitionList draws terms in bold with their definitions indented below.
+func (r *pdfRenderer) definitionList(n *east.DefinitionList) {
+	left, _, _, _ := r.pdf.GetMargins()
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		switch c := c.(type) {
+		case *east.DefinitionTerm:
+			r.ensureSpace(2 * lineHeight)
+			r.styled(&r.bold, func() { r.paragraph(c) })
+		case *east.DefinitionDescription:
+			r.indent(listIndent, func() {
+				r.pdf.SetX(left + listIndent)
+				r.blocks(c)
+			})
+			if c.IsTight {
+				r.pdf.Ln(blockGap)
+			}
+		}
+	}
+}
+
+// footnotes draws the footnotes after a rule, numbered like their
+// references.
+func (r *pdfRenderer) footnotes(n *east.FootnoteList) {
+	r.rule()
+	prev := r.size
+	r.size = bodySize * 0.9
+	for c := n.FirstChild(); c != nil; c = c.NextSibling() {
+		note, ok := c.(*east.Footnote)
+		if !ok {
+			continue
+		}
+		r.ensureSpace(lineHeight)
+		left, _, _, _ := r.pdf.GetMargins()
+		r.pdf.SetX(left)
+		r.setFont()
+		r.pdf.CellFormat(listIndent, lineHeight, fmt.Sprintf("%d.", note.Index), "", 0, "L", false, 0, "")
+		r.indent(listIndent, func() {
+			r.pdf.SetX(left + listIndent)
+			r.blocks(note)
+		})
+	}
+	r.size = prev
+	r.setFont()
+}
+
+// compiledDiagram returns the laid out diagram of a D2 or Graphviz fence,
+// or nil if n is another fence or does not compile.
+func (r *pdfRenderer) compiledDiagram(n *ast.FencedCodeBlock) *diagram.Graph {
+	lang := string(n.Language(r.doc.Source))
+	if !diagram.Supported(lang) {
+		return nil
+	}
+	return diagram.Compile(lang, r.codeText(n)).Graph
+}
+
+// fenceFormula returns the parsed formula of a math fence, or nil if n is
+// another fence or does not parse.
+func (r *pdfRenderer) fenceFormula(n *ast.FencedCodeBlock) *latex.Formula {
+	if string(n.Language(r.doc.Source)) != "math" {
+		return nil
+	}
+	f, _ := latex.Parse(r.codeText(n))
+	return f
+}
+
+func (r *pdfRenderer) codeText(n ast.Node) string {
+	var b strings.Builder
+	lines := n.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		b.Write(line.Value(r.doc.Source))
+	}
+	return b.String()
+}
+
+func (r *pdfRenderer) code(n ast.Node) {
+	text := strings.ReplaceAll(strings.TrimRight(r.codeText(n), "\n"), "\t", "    ")
+
+	r.pdf.SetFont(fontMono, "", codeSize)
+	r.pdf.SetTextColor(20, 20, 20)
+	r.pdf.SetFillColor(245, 245, 245)
+	r.ensureSpace(2 * lineHeight)
+	r.pdf.MultiCell(0, codeSize*0.5, text, "", "L", true)
+	r.pdf.Ln(blockGap)
+	r.setFont()
+}
+
+func (r *pdfRenderer) rule() {
+	left, _, _, _ := r.pdf.GetMargins()
+	y := r.pdf.GetY() + blockGap
+	r.pdf.SetDrawColor(200, 200, 200)
+	r.pdf.Line(left, y, left+r.contentWidth(), y)
+	r.pdf.Ln(2 * blockGap)
+}
+
+// tableCell is the plain text of a table cell. Tables are drawn cell by
+// cell, so inline styles inside cells are dropped.
+type tableCell struct {
+	text  string
+	align string
+}
+
+func (r *pdfRenderer) table(t *east.Table) {
+	var header []tableCell
+	var rows [][]tableCell
+	for row := t.FirstChild(); row != nil; row = row.NextSibling() {
+		var cells []tableCell
+		for c := row.FirstChild(); c != nil; c = c.NextSibling() {
+			cell := tableCell{text: r.doc.Text(c), align: "L"}
+			if tc, ok := c.(*east.TableCell); ok {
+				switch tc.Alignment {
+				case east.AlignCenter:
+					cell.align = "C"
+				case east.AlignRight:
+					cell.align = "R"
+				}
+			}
+			cells = append(cells, cell)
+		}
+		if _, ok := row.(*east.TableHeader); ok {
+			header = cells
+		} else {
+			rows = append(rows, cells)
+		}
+	}
+
+	widths := r.columnWidths(append([][]tableCell{header}, rows...))
+	r.pdf.SetDrawColor(210, 210, 210)
+	r.tableRow(header, widths, true)
+	for _, row := range rows {
+		if r.rowHeight(row, widths) > r.pageBottom()-r.pdf.GetY() {
+			r.pdf.AddPage()
+			r.tableRow(header, widths, true)
+		}
+		r.tableRow(row, widths, false)
+	}
+	r.pdf.Ln(blockGap)
+	r.setFont()
+}
+
+// columnWidths splits the content width between columns in proportion to
+// their widest cell, giving every column a minimum share.
+func (r *pdfRenderer) columnWidths(rows [][]tableCell) []float64 {
+	cols := 0
+	for _, row := range rows {
+		cols = max(cols, len(row))
+	}
+	if cols == 0 {
+		return nil
+	}
+
+	r.pdf.SetFont(fontSans, "B", bodySize)
+	natural := make([]float64, cols)
+	for _, row := range rows {
+		for i, c := range row {
+			natural[i] = max(natural[i], r.pdf.GetStringWidth(c.text)+2*cellPadding)
+		}
+	}
+
+	total := r.contentWidth()
+	floor := total / float64(cols) / 3
+	sum := 0.0
+	for i := range natural {
+		natural[i] = max(natural[i], floor)
+		sum += natural[i]
+	}
+	if sum < total {
+		return natural
+	}
+	for i := range natural {
+		natural[i] *= total / sum
+	}
+	return natural
+}
+
+func (r *pdfRenderer) rowHeight(row []tableCell, widths []float64) float64 {
+	r.pdf.SetFont(fontSans, "", bodySize)
+	lines := 1
+	for i, c := range row {
+		if i < len(widths) {
+			lines = max(lines, len(r.pdf.SplitText(c.text, widths[i]-2*cellPadding)))
+		}
+	}
+	return float64(lines)*lineHeight + 2*cellPadding
+}
+
+func (r *pdfRenderer) tableRow(row []tableCell, widths []float64, header bool) {
+	if row == nil {
+		return
+	}
+	h := r.rowHeight(row, widths)
+	left, _, _, _ := r.pdf.GetMargins()
+	x, y := left, r.pdf.GetY()
+
+	style := ""
+	if header {
+		style = "B"
+		r.pdf.SetFillColor(240, 240, 240)
+	}
+	r.pdf.SetFont(fontSans, style, bodySize)
+	r.pdf.SetTextColor(20, 20, 20)
+
+	for i, w := range widths {
+		fill := "D"
+		if header {
+			fill = "FD"
+		}
+		r.pdf.Rect(x, y, w, h, fill)
+		if i < len(row) {
+			r.pdf.SetXY(x+cellPadding, y+cellPadding)
+			for _, line := range r.pdf.SplitText(row[i].text, w-2*cellPadding) {
+				r.pdf.CellFormat(w-2*cellPadding, lineHeight, line, "", 2, row[i].align, false, 0, "")
+			}
+		}
+		x += w
+	}
+	r.pdf.SetXY(left, y+h)
+}
+
+func (r *pdfRenderer) inlines(parent ast.Node, h float64) {
+	for n := parent.FirstChild(); n != nil; n = n.NextSibling() {
+		r.inline(n, h)
+	}
+}
+
+func (r *pdfRenderer) inline(n ast.Node, h float64) {
+	switch n := n.(type) {
+	case *ast.Text:
+		r.write(h, string(n.Segment.Value(r.doc.Source)))
+		if n.HardLineBreak() {
+			r.pdf.Ln(h)
+		} else if n.SoftLineBreak() {
+			r.write(h, " ")
+		}
+	case *ast.String:
+		r.write(h, string(n.Value))
+	case *ast.CodeSpan:
+		r.styled(&r.mono, func() { r.inlines(n, h) })
+	case *ast.Emphasis:
+		counter := &r.italic
+		if n.Level >= 2 {
+			counter = &r.bold
+		}
+		r.styled(counter, func() { r.inlines(n, h) })
+	case *east.Strikethrough:
+		r.styled(&r.strike, func() { r.inlines(n, h) })
+	case *ast.Link:
+		r.linked(string(n.Destination), func() { r.inlines(n, h) })
+	case *markdown.WikiLink:
+		// Only links within the spec lead anywhere in the PDF.
+		if href := n.Href(); n.Err == "" && strings.HasPrefix(href, "#") {
+			r.linked(href, func() { r.inlines(n, h) })
+		} else {
+			r.inlines(n, h)
+		}
+	case *ast.AutoLink:
+		r.linked(string(n.URL(r.doc.Source)), func() {
+			r.write(h, string(n.Label(r.doc.Source)))
+		})
+	case *ast.Image:
+		r.styled(&r.italic, func() { r.write(h, "["+r.doc.Text(n)+"]") })
+	case *east.TaskCheckBox:
+		r.checkbox(n.IsChecked, h)
+	case *markdown.Math:
+		r.inlineMath(n, h)
+	case *east.FootnoteLink:
+		r.write(h, fmt.Sprintf("[%d]", n.Index))
+	case *east.FootnoteBacklink:
+		// The PDF has no links back to the references.
+	case *ast.RawHTML:
+		// Inline HTML has no PDF equivalent.
+	default:
+		r.inlines(n, h)
+	}
+}
+
+// styled runs fn with an inline style counter incremented.
+func (r *pdfRenderer) styled(counter *int, fn func()) {
+	*counter++
+	r.setFont()
+	fn()
+	*counter--
+	r.setFont()
+}
+
+func (r *pdfRenderer) linked(dest string, fn func()) {
+	prev := r.link
+	r.link = dest
+	r.setFont()
+	fn()
+	r.link = prev
+	r.setFont()
+}
+
+// write draws inline text at the current position, wrapping at the right
+// margin. Links to headings of the document jump to their page.
+func (r *pdfRenderer) write(h float64, s string) {
+	switch {
+	case r.link == "":
+		r.pdf.Write(h, s)
+	case strings.HasPrefix(r.link, "#"):
+		if link, ok := r.links[r.link[1:]]; ok {
+			r.pdf.WriteLinkID(h, s, link)
+		} else {
+			r.pdf.Write(h, s)
+		}
+	default:
+		r.pdf.WriteLinkString(h, s, r.link)
+	}
+}
+
+func (r *pdfRenderer) checkbox(checked bool, h float64) {
+	const size = 3.2
+	x, y := r.pdf.GetXY()
+	top := y + (h-size)/2
+	r.pdf.SetDrawColor(120, 120, 120)
+	r.pdf.SetLineWidth(0.3)
+	r.pdf.Rect(x, top, size, size, "D")
+	if checked {
+		r.pdf.Line(x+0.7, top+1.7, x+1.4, top+2.5)
+		r.pdf.Line(x+1.4, top+2.5, x+2.6, top+0.7)
+	}
+	r.pdf.SetLineWidth(0.2)
+	r.pdf.SetX(x + size + 1.5)
+}
@@ -0,0 +1,198 @@
+package export
+
+import (
+	"math"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/diagram"
+	"github.com/go-pdf/fpdf"
+)
+
+// Diagram drawing, in millimetres per CSS pixel and points per millimetre.
+const (
+	mmPerPixel = 0.2646
+	ptPerMM    = 72 / 25.4
+)
+
+// diagramCanvas maps diagram pixels to page positions.
+type diagramCanvas struct {
+	pdf          *fpdf.Fpdf
+	x0, y0, unit float64
+}
+
+func (c diagramCanvas) x(px float64) float64 { return c.x0 + px*c.unit }
+func (c diagramCanvas) y(px float64) float64 { return c.y0 + px*c.unit }
+func (c diagramCanvas) d(px float64) float64 { return px * c.unit }
+
+// diagram draws a laid out D2 or Graphviz diagram, scaled down to fit the
+// page when needed.
+func (r *pdfRenderer) diagram(g *diagram.Graph) {
+	unit := min(mmPerPixel, r.contentWidth()/g.Width, (r.pageBottom()-margin)/g.Height)
+	r.ensureSpace(g.Height * unit)
+
+	left, _, _, _ := r.pdf.GetMargins()
+	c := diagramCanvas{
+		pdf:  r.pdf,
+		x0:   left + (r.contentWidth()-g.Width*unit)/2,
+		y0:   r.pdf.GetY(),
+		unit: unit,
+	}
+	p := r.pdf
+	p.SetLineWidth(0.25)
+	p.SetFont(fontSans, "", diagram.FontSize*unit*ptPerMM)
+
+	for _, cl := range g.Clusters {
+		if cl.Width == 0 || cl.Style.Hidden {
+			continue
+		}
+		setColors(p, cl.Style, [3]int{246, 246, 246})
+		p.RoundedRect(c.x(cl.X), c.y(cl.Y), c.d(cl.Width), c.d(cl.Height), c.d(6), "1234", "FD")
+		c.text(cl.Label, cl.X+cl.Width/2, cl.Y+7+diagram.LineHeight/2, cl.Style.FontColor)
+	}
+
+	for _, e := range g.Edges {
+		if e.Style.Hidden || len(e.Points) < 2 {
+			continue
+		}
+		setColors(p, e.Style, [3]int{255, 255, 255})
+		if e.Style.Dashed {
+			p.SetDashPattern([]float64{1.2, 1}, 0)
+		}
+		for i := 1; i < len(e.Points); i++ {
+			a, b := e.Points[i-1], e.Points[i]
+			p.Line(c.x(a.X), c.y(a.Y), c.x(b.X), c.y(b.Y))
+		}
+		p.SetDashPattern(nil, 0)
+
+		n := len(e.Points)
+		if e.ArrowHead {
+			c.arrow(e.Points[n-2], e.Points[n-1])
+		}
+		if e.ArrowTail {
+			c.arrow(e.Points[1], e.Points[0])
+		}
+		if e.Label != "" {
+			lines := float64(len(strings.Split(e.Label, "\n")))
+			h := lines * diagram.LineHeight
+			p.SetFillColor(255, 255, 255)
+			p.Rect(c.x(e.LabelX-e.LabelWidth/2-4), c.y(e.LabelY-h/2), c.d(e.LabelWidth+8), c.d(h), "F")
+			c.text(e.Label, e.LabelX, e.LabelY, e.Style.FontColor)
+		}
+	}
+
+	for _, n := range g.Nodes {
+		if n.Style.Hidden {
+			continue
+		}
+		setColors(p, n.Style, [3]int{255, 255, 255})
+		if n.Style.Dashed {
+			p.SetDashPattern([]float64{1.2, 1}, 0)
+		}
+		c.shape(n)
+		p.SetDashPattern(nil, 0)
+		if n.Shape != diagram.ShapePoint {
+			c.text(n.Label, n.X, n.Y, n.Style.FontColor)
+		}
+	}
+
+	p.SetLineWidth(0.2)
+	p.SetDrawColor(0, 0, 0)
+	p.SetY(c.y0 + g.Height*unit)
+	p.Ln(blockGap)
+	r.setFont()
+}
+
+func (c diagramCanvas) shape(n *diagram.Node) {
+	p := c.pdf
+	x0, y0 := n.X-n.Width/2, n.Y-n.Height/2
+	x1, y1 := n.X+n.Width/2, n.Y+n.Height/2
+	polygon := func(pts ...diagram.Point) {
+		var ps []fpdf.PointType
+		for _, pt := range pts {
+			ps = append(ps, fpdf.PointType{X: c.x(pt.X), Y: c.y(pt.Y)})
+		}
+		p.Polygon(ps, "FD")
+	}
+
+	switch n.Shape {
+	case diagram.ShapeEllipse, diagram.ShapeCircle:
+		p.Ellipse(c.x(n.X), c.y(n.Y), c.d(n.Width/2), c.d(n.Height/2), 0, "FD")
+	case diagram.ShapeDiamond:
+		polygon(diagram.Point{X: n.X, Y: y0}, diagram.Point{X: x1, Y: n.Y}, diagram.Point{X: n.X, Y: y1}, diagram.Point{X: x0, Y: n.Y})
+	case diagram.ShapeHexagon:
+		d := n.Height / 4
+		polygon(diagram.Point{X: x0 + d, Y: y0}, diagram.Point{X: x1 - d, Y: y0}, diagram.Point{X: x1, Y: n.Y},
+			diagram.Point{X: x1 - d, Y: y1}, diagram.Point{X: x0 + d, Y: y1}, diagram.Point{X: x0, Y: n.Y})
+	case diagram.ShapeParallelogram:
+		d := n.Height / 4
+		polygon(diagram.Point{X: x0 + d, Y: y0}, diagram.Point{X: x1, Y: y0}, diagram.Point{X: x1 - d, Y: y1}, diagram.Point{X: x0, Y: y1})
+	case diagram.ShapeCylinder:
+		ry := 8.0
+		p.Ellipse(c.x(n.X), c.y(y1-ry), c.d(n.Width/2), c.d(ry), 0, "FD")
+		p.Rect(c.x(x0), c.y(y0+ry), c.d(n.Width), c.d(n.Height-2*ry), "F")
+		p.Line(c.x(x0), c.y(y0+ry), c.x(x0), c.y(y1-ry))
+		p.Line(c.x(x1), c.y(y0+ry), c.x(x1), c.y(y1-ry))
+		p.Ellipse(c.x(n.X), c.y(y0+ry), c.d(n.Width/2), c.d(ry), 0, "FD")
+	case diagram.ShapeText:
+	case diagram.ShapePoint:
+		p.SetFillColor(85, 85, 85)
+		p.Circle(c.x(n.X), c.y(n.Y), c.d(n.Width/2), "F")
+	default:
+		radius := 2.0
+		if n.Style.Rounded {
+			radius = 10
+		}
+		p.RoundedRect(c.x(x0), c.y(y0), c.d(n.Width), c.d(n.Height), c.d(radius), "1234", "FD")
+	}
+}
+
+// arrow draws an arrowhead at to, pointing away from from.
+func (c diagramCanvas) arrow(from, to diagram.Point) {
+	angle := math.Atan2(to.Y-from.Y, to.X-from.X)
+	const length, width = 8.0, 3.5
+	bx, by := to.X-length*math.Cos(angle), to.Y-length*math.Sin(angle)
+	dx, dy := width*math.Sin(angle), -width*math.Cos(angle)
+
+	r, g, b := c.pdf.GetDrawColor()
+	c.pdf.SetFillColor(r, g, b)
+	c.pdf.Polygon([]fpdf.PointType{
+		{X: c.x(to.X), Y: c.y(to.Y)},
+		{X: c.x(bx + dx), Y: c.y(by + dy)},
+		{X: c.x(bx - dx), Y: c.y(by - dy)},
+	}, "F")
+}
+
+// text draws a label centered on x, y.
+func (c diagramCanvas) text(label string, x, y float64, color string) {
+	if label == "" {
+		return
+	}
+	r, g, b, ok := diagram.RGB(color)
+	if !ok {
+		r, g, b = 26, 26, 26
+	}
+	c.pdf.SetTextColor(r, g, b)
+
+	lines := strings.Split(label, "\n")
+	_, size := c.pdf.GetFontSize()
+	top := y - float64(len(lines)-1)*diagram.LineHeight/2
+	for i, l := range lines {
+		w := c.pdf.GetStringWidth(l)
+		baseline := c.y(top+float64(i)*diagram.LineHeight) + size*0.35
+		c.pdf.Text(c.x(x)-w/2, baseline, l)
+	}
+}
+
+// setColors sets the fill and stroke colors of s, with fill as the
+// default fill.
+func setColors(p *fpdf.Fpdf, s diagram.Style, fill [3]int) {
+	if r, g, b, ok := diagram.RGB(s.Fill); ok {
+		fill = [3]int{r, g, b}
+	}
+	p.SetFillColor(fill[0], fill[1], fill[2])
+	if r, g, b, ok := diagram.RGB(s.Stroke); ok {
+		p.SetDrawColor(r, g, b)
+	} else {
+		p.SetDrawColor(85, 85, 85)
+	}
+}
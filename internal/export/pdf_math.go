@@ -0,0 +1,75 @@
+package export
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/latex"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"golang.org/x/image/font/gofont/goregular"
+	"golang.org/x/image/font/sfnt"
+)
+
+// mathSubstitutes replace the math symbols the Go fonts cannot draw.
+var mathSubstitutes = map[rune]string{
+	'∈': "in", '∉': "not in", '∋': "ni", '⊂': "subset", '⊆': "subseteq",
+	'⊃': "supset", '⊇': "supseteq", '⇒': "=>", '⇐': "<=", '⇔': "<=>",
+	'⟹': "=>", '⟸': "<=", '⟺': "<=>", '⟶': "→", '⟵': "←", '↦': "|->",
+	'⋅': "·", '∓': "-+", '∗': "*", '⋆': "*", '∘': "o", '∖': "\\",
+	'⋯': "...", '⋮': "...", '⋱': "...", '∀': "for all ", '∃': "exists ",
+	'∄': "not exists ", '∧': "and", '∨': "or", '⊕': "(+)", '⊗': "(x)",
+	'∅': "{}", '∼': "~", '≃': "~=", '≅': "~=", '∝': "~", '≪': "<<",
+	'≫': ">>", '≔': ":=", '∣': "|", '‖': "||", '∥': "||", '⊥': "_|_",
+	'⟨': "<", '⟩': ">", '⌊': "[", '⌋': "]", '⌈': "[", '⌉': "]",
+	'ℝ': "R", 'ℕ': "N", 'ℤ': "Z", 'ℂ': "C", 'ℚ': "Q", 'ℏ': "h", 'ℓ': "l",
+	'∇': "nabla ", '∴': "therefore ", '∵': "because ", '∠': "angle ",
+}
+
+var goRegular = sync.OnceValue(func() *sfnt.Font {
+	f, _ := sfnt.Parse(goregular.TTF)
+	return f
+})
+
+// mathText returns the plain text of a formula with the symbols missing
+// from the PDF fonts replaced, and accents they lack dropped.
+func mathText(s string) string {
+	f := goRegular()
+	var buf sfnt.Buffer
+	var b strings.Builder
+	for _, r := range s {
+		if i, err := f.GlyphIndex(&buf, r); err == nil && i != 0 || unicode.IsSpace(r) {
+			b.WriteRune(r)
+			continue
+		}
+		if sub, ok := mathSubstitutes[r]; ok {
+			b.WriteString(sub)
+		} else if !unicode.Is(unicode.Mn, r) {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// inlineMath writes an inline formula in italics, or its source in the
+// code font when it does not parse.
+func (r *pdfRenderer) inlineMath(n *markdown.Math, h float64) {
+	src := n.Formula(r.doc.Source)
+	f, err := latex.Parse(src)
+	if err != nil {
+		r.styled(&r.mono, func() { r.write(h, "$"+src+"$") })
+		return
+	}
+	r.styled(&r.italic, func() { r.write(h, mathText(f.Text())) })
+}
+
+// displayMath writes a display formula centered on lines of its own.
+func (r *pdfRenderer) displayMath(f *latex.Formula) {
+	r.italic++
+	r.setFont()
+	r.ensureSpace(lineHeight)
+	r.pdf.MultiCell(0, lineHeight, mathText(f.Text()), "", "C", false)
+	r.italic--
+	r.setFont()
+	r.pdf.Ln(blockGap)
+}
@@ -0,0 +1,112 @@
+package export
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+)
+
+const sampleSpec = "# Payments\n\n" +
+	"Intro with **bold**, `code` and a [link](#data-model).\n\n" +
+	"## Tasks\n\n- [x] Done\n- [ ] Open\n  - Nested\n\n" +
+	"> A quote\n\n" +
+	"## Data Model\n\n| Field | Type |\n|:--|--:|\n| id | uuid |\n\n" +
+	"```go\nfunc main() {}\n```\n\n---\n"
+
+func TestPDF_Output(t *testing.T) {
+	var buf bytes.Buffer
+	if err := PDF(&buf, "spec.md", markdown.Parse([]byte(sampleSpec))); err != nil {
+		t.Fatalf("PDF returned error: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("expected PDF header, got %q", buf.Bytes()[:min(buf.Len(), 8)])
+	}
+}
+
+func TestPDF_ContentsPageNumbers(t *testing.T) {
+	src := sampleSpec + strings.Repeat("Filler paragraph that takes up a line or two of the page.\n\n", 80) + "## Appendix\n"
+	doc := markdown.Parse([]byte(src))
+
+	pages := make(map[string]int)
+	if _, err := renderPDF("spec.md", doc, pages); err != nil {
+		t.Fatalf("renderPDF returned error: %v", err)
+	}
+	pdf, err := renderPDF("spec.md", doc, pages)
+	if err != nil {
+		t.Fatalf("renderPDF returned error: %v", err)
+	}
+
+	// The contents take the first page.
+	if pages["payments"] != 2 || pages["data-model"] != 2 {
+		t.Errorf("expected first headings on page 2, got %v", pages)
+	}
+	if pages["appendix"] != pdf.PageCount() || pages["appendix"] <= 2 {
+		t.Errorf("expected appendix on the last page %d, got %v", pdf.PageCount(), pages)
+	}
+}
+
+func TestPDF_NoHeadings(t *testing.T) {
+	pages := make(map[string]int)
+	pdf, err := renderPDF("notes.md", markdown.Parse([]byte("Just a paragraph.\n")), pages)
+	if err != nil {
+		t.Fatalf("renderPDF returned error: %v", err)
+	}
+	if pdf.PageCount() != 1 {
+		t.Errorf("expected a single page without contents, got %d", pdf.PageCount())
+	}
+}
+
+func TestPDF_Diagrams(t *testing.T) {
+	src := "# Flow\n\n```dot\ndigraph { rankdir=LR; a -> b [label=next]; b [shape=cylinder] }\n```\n\n" +
+		"```d2\nusers -> api: calls\napi: {shape: hexagon}\n```\n"
+	var buf bytes.Buffer
+	if err := PDF(&buf, "spec.md", markdown.Parse([]byte(src))); err != nil {
+		t.Fatalf("PDF returned error: %v", err)
+	}
+	if !bytes.HasPrefix(buf.Bytes(), []byte("%PDF-")) {
+		t.Errorf("expected PDF header, got %q", buf.Bytes()[:min(buf.Len(), 8)])
+	}
+}
+
+func TestPDF_Math(t *testing.T) {
+	src := "# Fees\n\nThe fee is $f = r \\cdot a$.\n\n$$\n\\forall x \\in S: x \\leq \\frac{n}{2}\n$$\n\n```math\n\\frac{1\n```\n"
+	var buf bytes.Buffer
+	if err := PDF(&buf, "spec.md", markdown.Parse([]byte(src))); err != nil {
+		t.Fatalf("PDF returned error: %v", err)
+	}
+}
+
+func TestMathText(t *testing.T) {
+	if got, want := mathText("∀x ∈ S: x̂ ≤ a ⋅ b²"), "for all x in S: x ≤ a · b²"; got != want {
+		t.Errorf("expected %q, got %q", want, got)
+	}
+}
+
+func TestPDF_AlertsFootnotesAndDefinitions(t *testing.T) {
+	src := "# Keys\n\n> [!CAUTION]\n> Rotation logs everyone out[^1].\n\n> [!NOTE]\n\n" +
+		"Idempotency key\n: A client-chosen request ID.\n\n[^1]: Including service accounts.\n"
+	var buf bytes.Buffer
+	if err := PDF(&buf, "spec.md", markdown.Parse([]byte(src))); err != nil {
+		t.Fatalf("PDF returned error: %v", err)
+	}
+}
+
+func TestPDF_Include(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "spec.md"), []byte("# Spec\n\n## Data Model\n\n- id\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	doc := markdown.ParseFile(root, "plan.md", []byte("# Plan\n\n![[spec.md#Data Model]]\n\n![[missing.md]]\n"))
+
+	pages := make(map[string]int)
+	if _, err := renderPDF("plan.md", doc, pages); err != nil {
+		t.Fatalf("renderPDF returned error: %v", err)
+	}
+	if pages["data-model"] == 0 {
+		t.Errorf("expected the included heading to be drawn, got %v", pages)
+	}
+}
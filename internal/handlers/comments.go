@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/netip"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/review"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+)
+
+// maxExportBody caps the size of an export request body.
+const maxExportBody = 1 << 20
+
+// CommentsExportHandler renders the posted review comments as an LLM prompt,
+// JSON or a patch request, with source line ranges resolved on the server.
+func CommentsExportHandler(exporter *review.Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req review.Request
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxExportBody)).Decode(&req); err != nil {
+			http.Error(w, "Invalid export request", http.StatusBadRequest)
+			return
+		}
+
+		out, contentType, err := exporter.Export(req)
+		if err != nil {
+			if errors.Is(err, review.ErrUnknownFormat) ||
+				errors.Is(err, review.ErrUnknownTemplate) ||
+				errors.Is(err, review.ErrInvalidPath) {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			logger.Error("Failed to export comments", "error", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		_, _ = w.Write(out)
+	}
+}
+
+// CommentTemplatesHandler lists the prompt templates available for export.
+func CommentTemplatesHandler(exporter *review.Exporter) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(exporter.Templates())
+	}
+}
+
+// IdentityHandler serves the name review comments are attributed to by
+// default. It is the host user's, so only browsers on the same machine are
+// offered it; reviewers on other machines pick their own in the review
+// panel.
+func IdentityHandler(author string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		name := author
+		if !isLocal(r) {
+			name = ""
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]string{"name": name})
+	}
+}
+
+// isLocal reports whether r comes straight from the machine the server
+// runs on, not through a reverse proxy on it.
+func isLocal(r *http.Request) bool {
+	if r.Header.Get("X-Forwarded-For") != "" || r.Header.Get("Forwarded") != "" {
+		return false
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip, err := netip.ParseAddr(host)
+	return err == nil && ip.Unmap().IsLoopback()
+}
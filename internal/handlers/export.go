@@ -0,0 +1,71 @@
+package handlers
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/export"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+)
+
+// ExportPDFHandler serves the requested spec as a PDF download.
+func ExportPDFHandler(folder string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cleanPath, content, ok := readSpec(folder, w, r)
+		if !ok {
+			return
+		}
+
+		var buf bytes.Buffer
+		start := time.Now()
+		if err := export.PDF(&buf, filepath.ToSlash(cleanPath), markdown.ParseFile(folder, filepath.ToSlash(cleanPath), content)); err != nil {
+			logger.Error("Failed to export PDF", "file", cleanPath, "error", err)
+			http.Error(w, "Failed to export PDF", http.StatusInternalServerError)
+			return
+		}
+		metrics.RenderDuration.Since(start, "pdf")
+
+		w.Header().Set("Content-Type", "application/pdf")
+		w.Header().Set("Content-Disposition", attachment(cleanPath, ".pdf"))
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// ExportHTMLHandler serves the requested spec as a standalone HTML page
+// download.
+func ExportHTMLHandler(folder string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cleanPath, content, ok := readSpec(folder, w, r)
+		if !ok {
+			return
+		}
+
+		var buf bytes.Buffer
+		dir := filepath.Dir(filepath.Join(folder, cleanPath))
+		start := time.Now()
+		if err := export.HTML(&buf, filepath.ToSlash(cleanPath), markdown.ParseFile(folder, filepath.ToSlash(cleanPath), content), dir); err != nil {
+			logger.Error("Failed to export HTML", "file", cleanPath, "error", err)
+			http.Error(w, "Failed to export HTML", http.StatusInternalServerError)
+			return
+		}
+		metrics.RenderDuration.Since(start, "html")
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Disposition", attachment(cleanPath, ".html"))
+		_, _ = w.Write(buf.Bytes())
+	}
+}
+
+// attachment returns a Content-Disposition header that downloads the spec
+// at path under its base name with the extension replaced by ext.
+func attachment(path, ext string) string {
+	base := filepath.Base(path)
+	name := strings.TrimSuffix(base, filepath.Ext(base)) + ext
+	return mime.FormatMediaType("attachment", map[string]string{"filename": name})
+}
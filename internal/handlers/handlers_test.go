@@ -6,10 +6,16 @@ import (
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
+	"github.com/SantiagoBobrik/spec-viewer/internal/activity"
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+	"github.com/SantiagoBobrik/spec-viewer/internal/review"
+	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
 	"github.com/SantiagoBobrik/spec-viewer/internal/templates"
 	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+	"github.com/gorilla/websocket"
 )
 
 // testSpecDir is a temporary directory used as the spec folder for templates
@@ -45,7 +51,7 @@ func TestMain(m *testing.M) {
 // --- HomeHandler tests ---
 
 func TestHomeHandler_ReturnsOK(t *testing.T) {
-	handler := HomeHandler()
+	handler := HomeHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 	req := httptest.NewRequest(http.MethodGet, "/", nil)
 	rr := httptest.NewRecorder()
 
@@ -66,6 +72,26 @@ func TestHomeHandler_ReturnsOK(t *testing.T) {
 	}
 }
 
+func TestHomeHandler_RecentSpecs(t *testing.T) {
+	recent := activity.NewLog(activity.DefaultLimit)
+	recent.Viewed("sample.md")
+	recent.Changed("sample.md")
+	recent.Changed("deleted.md")
+
+	rr := httptest.NewRecorder()
+	HomeHandler(testSpecDir, recent).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	body := rr.Body.String()
+	for _, want := range []string{"Recently viewed", "Recently changed", `<a href="/view?file=sample.md" title="sample.md">`, "just now"} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the home page to contain %q", want)
+		}
+	}
+	if strings.Contains(body, "deleted.md") {
+		t.Error("expected specs that no longer exist to be left out")
+	}
+}
+
 // --- NotFoundHandler tests ---
 
 func TestNotFoundHandler_Returns404(t *testing.T) {
@@ -96,7 +122,7 @@ func TestNotFoundHandler_ReturnsHTML(t *testing.T) {
 // --- ViewSpecHandler tests ---
 
 func TestViewSpecHandler_NoFileParam_Redirects(t *testing.T) {
-	handler := ViewSpecHandler(testSpecDir)
+	handler := ViewSpecHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 	req := httptest.NewRequest(http.MethodGet, "/view", nil)
 	rr := httptest.NewRecorder()
 
@@ -112,7 +138,7 @@ func TestViewSpecHandler_NoFileParam_Redirects(t *testing.T) {
 }
 
 func TestViewSpecHandler_EmptyFileParam_Redirects(t *testing.T) {
-	handler := ViewSpecHandler(testSpecDir)
+	handler := ViewSpecHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 	req := httptest.NewRequest(http.MethodGet, "/view?file=", nil)
 	rr := httptest.NewRecorder()
 
@@ -136,7 +162,7 @@ func TestViewSpecHandler_DirectoryTraversal_Redirects(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			handler := ViewSpecHandler(testSpecDir)
+			handler := ViewSpecHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 			req := httptest.NewRequest(http.MethodGet, "/view?file="+tt.fileParam, nil)
 			rr := httptest.NewRecorder()
 
@@ -153,8 +179,30 @@ func TestViewSpecHandler_DirectoryTraversal_Redirects(t *testing.T) {
 	}
 }
 
+func TestViewSpecHandler_HiddenFile_Redirects(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, ".spec-viewer.yaml"), []byte("auth: {tokens: [{token: ADMINSECRET}]}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, handler := range []http.Handler{
+		ViewSpecHandler(dir, activity.NewLog(activity.DefaultLimit)),
+		ViewContentHandler(dir),
+		ExportHTMLHandler(dir),
+	} {
+		req := httptest.NewRequest(http.MethodGet, "/view?file=.spec-viewer.yaml", nil)
+		rr := httptest.NewRecorder()
+
+		handler.ServeHTTP(rr, req)
+
+		if rr.Code != http.StatusSeeOther || strings.Contains(rr.Body.String(), "ADMINSECRET") {
+			t.Errorf("expected a redirect for a hidden file, got %d %q", rr.Code, rr.Body.String())
+		}
+	}
+}
+
 func TestViewSpecHandler_MissingFile_Redirects(t *testing.T) {
-	handler := ViewSpecHandler(testSpecDir)
+	handler := ViewSpecHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 	req := httptest.NewRequest(http.MethodGet, "/view?file=nonexistent.md", nil)
 	rr := httptest.NewRecorder()
 
@@ -166,7 +214,7 @@ func TestViewSpecHandler_MissingFile_Redirects(t *testing.T) {
 }
 
 func TestViewSpecHandler_ValidFile_ReturnsOK(t *testing.T) {
-	handler := ViewSpecHandler(testSpecDir)
+	handler := ViewSpecHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 	req := httptest.NewRequest(http.MethodGet, "/view?file=sample.md", nil)
 	rr := httptest.NewRecorder()
 
@@ -188,16 +236,17 @@ func TestViewSpecHandler_ValidFile_ReturnsOK(t *testing.T) {
 }
 
 func TestViewSpecHandler_ValidFile_RendersMarkdown(t *testing.T) {
-	handler := ViewSpecHandler(testSpecDir)
+	handler := ViewSpecHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 	req := httptest.NewRequest(http.MethodGet, "/view?file=sample.md", nil)
 	rr := httptest.NewRecorder()
 
 	handler.ServeHTTP(rr, req)
 
 	body := rr.Body.String()
-	// The markdown "# Sample" should be rendered as an <h1> tag with an auto-generated id.
-	if !containsSubstring(body, "<h1 id=\"sample\">Sample</h1>") {
-		t.Error("expected rendered HTML to contain '<h1 id=\"sample\">Sample</h1>'")
+	// The markdown "# Sample" should be rendered as an <h1> tag with an auto-generated id
+	// and its source line range.
+	if !containsSubstring(body, "<h1 id=\"sample\" data-source-line=\"1\" data-source-line-end=\"1\"") {
+		t.Error("expected rendered HTML to contain an <h1> with id 'sample' and source lines 1-1")
 	}
 	// The paragraph text should appear.
 	if !containsSubstring(body, "Hello world") {
@@ -216,7 +265,7 @@ func TestViewSpecHandler_NestedFile_ReturnsOK(t *testing.T) {
 	}
 	defer func() { _ = os.RemoveAll(subdir) }()
 
-	handler := ViewSpecHandler(testSpecDir)
+	handler := ViewSpecHandler(testSpecDir, activity.NewLog(activity.DefaultLimit))
 	req := httptest.NewRequest(http.MethodGet, "/view?file=nested/deep.md", nil)
 	rr := httptest.NewRecorder()
 
@@ -227,6 +276,65 @@ func TestViewSpecHandler_NestedFile_ReturnsOK(t *testing.T) {
 	}
 }
 
+func TestViewSpecHandler_WalksFolderOnce(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.md", "b.md", "c.md"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lookups := func() float64 {
+		return metrics.CacheRequests.Value("summary", "hit") + metrics.CacheRequests.Value("summary", "miss")
+	}
+
+	before := lookups()
+	rr := httptest.NewRecorder()
+	ViewSpecHandler(dir, activity.NewLog(activity.DefaultLimit)).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/view?file=b.md", nil))
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	// One summary per spec, for the sidebar and the navigation alike.
+	if got := lookups() - before; got != 3 {
+		t.Errorf("expected 3 summary lookups, got %v", got)
+	}
+	if !strings.Contains(rr.Body.String(), "a.md") || !strings.Contains(rr.Body.String(), `rel="next"`) {
+		t.Error("expected the sidebar and the navigation of the spec folder")
+	}
+}
+
+func TestViewSpecHandler_Navigation(t *testing.T) {
+	subdir := filepath.Join(testSpecDir, "003-billing")
+	if err := os.MkdirAll(subdir, 0755); err != nil {
+		t.Fatalf("failed to create subdir: %v", err)
+	}
+	defer func() { _ = os.RemoveAll(subdir) }()
+	for name, content := range map[string]string{"spec.md": "# Billing Spec", "plan.md": "# Billing Plan", "tasks.md": "# Tasks"} {
+		if err := os.WriteFile(filepath.Join(subdir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("failed to write %s: %v", name, err)
+		}
+	}
+
+	recent := activity.NewLog(activity.DefaultLimit)
+	rr := httptest.NewRecorder()
+	ViewSpecHandler(testSpecDir, recent).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/view?file=003-billing/plan.md", nil))
+
+	body := rr.Body.String()
+	for _, want := range []string{
+		`<span>003-billing</span>`,
+		`<span class="font-medium text-foreground" aria-current="page">Billing Plan</span>`,
+		`<a href="/view?file=003-billing%2fspec.md" rel="prev" class="spec-pager-link">`,
+		`<a href="/view?file=003-billing%2ftasks.md" rel="next" class="spec-pager-link is-next">`,
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected the viewer to contain %q", want)
+		}
+	}
+	if viewed := recent.RecentlyViewed(); len(viewed) != 1 || viewed[0].File != "003-billing/plan.md" {
+		t.Errorf("expected the spec to be recorded as viewed, got %v", viewed)
+	}
+}
+
 func containsSubstring(s, substr string) bool {
 	return len(s) >= len(substr) && (s == substr || len(s) > 0 && contains(s, substr))
 }
@@ -239,3 +347,170 @@ func contains(s, substr string) bool {
 	}
 	return false
 }
+
+// --- IdentityHandler tests ---
+
+func TestIdentityHandler_ReturnsName(t *testing.T) {
+	handler := IdentityHandler("Ana")
+	for _, tt := range []struct {
+		name       string
+		remoteAddr string
+		header     string
+		want       string
+	}{
+		{"local", "127.0.0.1:5000", "", "Ana"},
+		{"local IPv6", "[::1]:5000", "", "Ana"},
+		{"remote", "192.168.1.20:5000", "", ""},
+		{"local proxy", "127.0.0.1:5000", "192.168.1.20", ""},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/identity", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set("X-Forwarded-For", tt.header)
+			}
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if want := "{\"name\":\"" + tt.want + "\"}\n"; rr.Body.String() != want {
+				t.Errorf("expected %q, got %q", want, rr.Body.String())
+			}
+		})
+	}
+}
+
+// --- CommentsExportHandler tests ---
+
+func TestCommentsExportHandler_Markdown(t *testing.T) {
+	handler := CommentsExportHandler(review.NewExporter(testSpecDir, nil))
+	body := `{"format":"markdown","files":[{"file":"sample.md","threads":[{"id":"a","startLine":3,"endLine":3,"comments":[{"id":"a1","author":"Ana","text":"Say more"}]}]}]}`
+	req := httptest.NewRequest(http.MethodPost, "/api/comments/export", strings.NewReader(body))
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, rr.Code, rr.Body.String())
+	}
+	if !strings.Contains(rr.Body.String(), `Lines 3-3, section starting with: "Hello world"`+"\n- Ana: Say more") {
+		t.Errorf("expected line range in prompt, got %q", rr.Body.String())
+	}
+}
+
+func TestCommentsExportHandler_BadRequests(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+	}{
+		{"malformed JSON", `{`},
+		{"unknown format", `{"format":"docx"}`},
+		{"directory traversal", `{"files":[{"file":"../secret.md","threads":[{"comments":[{"text":"x"}]}]}]}`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := CommentsExportHandler(review.NewExporter(testSpecDir, nil))
+			req := httptest.NewRequest(http.MethodPost, "/api/comments/export", strings.NewReader(tt.body))
+			rr := httptest.NewRecorder()
+
+			handler.ServeHTTP(rr, req)
+
+			if rr.Code != http.StatusBadRequest {
+				t.Errorf("expected status %d, got %d", http.StatusBadRequest, rr.Code)
+			}
+		})
+	}
+}
+
+// --- Export handler tests ---
+
+func TestExportPDFHandler_ReturnsPDF(t *testing.T) {
+	handler := ExportPDFHandler(testSpecDir)
+	req := httptest.NewRequest(http.MethodGet, "/api/export/pdf?file=sample.md", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if ct := rr.Header().Get("Content-Type"); ct != "application/pdf" {
+		t.Errorf("expected Content-Type 'application/pdf', got %q", ct)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename=sample.pdf` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if !strings.HasPrefix(rr.Body.String(), "%PDF-") {
+		t.Error("expected body to be a PDF document")
+	}
+}
+
+func TestExportPDFHandler_DirectoryTraversal_Redirects(t *testing.T) {
+	handler := ExportPDFHandler(testSpecDir)
+	req := httptest.NewRequest(http.MethodGet, "/api/export/pdf?file=../../etc/passwd", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusSeeOther {
+		t.Errorf("expected status %d, got %d", http.StatusSeeOther, rr.Code)
+	}
+}
+
+func TestExportHTMLHandler_ReturnsStandalonePage(t *testing.T) {
+	handler := ExportHTMLHandler(testSpecDir)
+	req := httptest.NewRequest(http.MethodGet, "/api/export/html?file=sample.md", nil)
+	rr := httptest.NewRecorder()
+
+	handler.ServeHTTP(rr, req)
+
+	if rr.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, rr.Code)
+	}
+	if cd := rr.Header().Get("Content-Disposition"); cd != `attachment; filename=sample.html` {
+		t.Errorf("unexpected Content-Disposition %q", cd)
+	}
+	if !containsSubstring(rr.Body.String(), "<title>sample.md</title>") {
+		t.Error("expected the page title to be the spec path")
+	}
+}
+
+// --- WebSocketHandler tests ---
+
+func TestWebSocketHandler_RefusesOtherOrigins(t *testing.T) {
+	server := httptest.NewServer(WebSocketHandler(socket.NewHub()))
+	defer server.Close()
+	wsURL := "ws" + strings.TrimPrefix(server.URL, "http")
+
+	header := http.Header{"Origin": {"http://evil.example"}}
+	if _, resp, err := websocket.DefaultDialer.Dial(wsURL, header); err == nil || resp == nil || resp.StatusCode != http.StatusForbidden {
+		t.Errorf("expected a cross-origin connection to be refused, got %v", err)
+	}
+
+	header = http.Header{"Origin": {server.URL}}
+	conn, _, err := websocket.DefaultDialer.Dial(wsURL, header)
+	if err != nil {
+		t.Fatalf("expected a same-origin connection to be accepted: %v", err)
+	}
+	_ = conn.Close()
+}
+
+// --- Health and readiness tests ---
+
+func TestReadyHandler(t *testing.T) {
+	up := Check{Name: "templates", Ready: func() bool { return true }}
+	down := Check{Name: "watcher", Ready: func() bool { return false }}
+
+	rr := httptest.NewRecorder()
+	ReadyHandler(up).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusOK || !strings.Contains(rr.Body.String(), `"status":"ok"`) {
+		t.Errorf("expected ready, got %d %s", rr.Code, rr.Body.String())
+	}
+
+	rr = httptest.NewRecorder()
+	ReadyHandler(up, down).ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	if rr.Code != http.StatusServiceUnavailable || !strings.Contains(rr.Body.String(), `"watcher":false`) {
+		t.Errorf("expected the failing check to be reported, got %d %s", rr.Code, rr.Body.String())
+	}
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// HealthHandler reports that the server is up, for liveness probes.
+func HealthHandler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		_, _ = w.Write([]byte("ok\n"))
+	}
+}
+
+// Check is a condition the server needs to serve specs, such as its
+// templates being parsed.
+type Check struct {
+	Name  string
+	Ready func() bool
+}
+
+// ReadyHandler reports whether every check holds, for readiness probes:
+// 200 when they all do and 503 otherwise, with the state of each check.
+func ReadyHandler(checks ...Check) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		status := "ok"
+		states := make(map[string]bool, len(checks))
+		for _, c := range checks {
+			states[c.Name] = c.Ready()
+			if !states[c.Name] {
+				status = "unavailable"
+			}
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		if status != "ok" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+		_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": states})
+	}
+}
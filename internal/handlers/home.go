@@ -2,12 +2,45 @@ package handlers
 
 import (
 	"net/http"
+	"os"
+	"path/filepath"
+	"time"
 
+	"github.com/SantiagoBobrik/spec-viewer/internal/activity"
+	"github.com/SantiagoBobrik/spec-viewer/internal/spec"
 	"github.com/SantiagoBobrik/spec-viewer/internal/templates"
 )
 
-func HomeHandler() http.HandlerFunc {
+type HomeData struct {
+	Viewed  []RecentSpec
+	Changed []RecentSpec
+}
+
+// RecentSpec is a spec of the recently viewed or changed lists.
+type RecentSpec struct {
+	Path  string
+	Title string
+	Time  time.Time
+}
+
+func HomeHandler(folder string, recent *activity.Log) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		templates.Render(w, "home", nil)
+		templates.Render(w, "home", HomeData{
+			Viewed:  recentSpecs(folder, recent.RecentlyViewed()),
+			Changed: recentSpecs(folder, recent.RecentlyChanged()),
+		})
+	}
+}
+
+// recentSpecs titles the entries that still exist in folder.
+func recentSpecs(folder string, entries []activity.Entry) []RecentSpec {
+	var specs []RecentSpec
+	for _, e := range entries {
+		path := filepath.FromSlash(e.File)
+		if _, err := os.Stat(filepath.Join(folder, path)); err != nil {
+			continue
+		}
+		specs = append(specs, RecentSpec{Path: e.File, Title: spec.Title(folder, path), Time: e.Time})
 	}
+	return specs
 }
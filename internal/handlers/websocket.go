@@ -9,12 +9,12 @@ import (
 	"github.com/gorilla/websocket"
 )
 
+// upgrader only accepts connections from pages served by this server: the
+// default origin check refuses browsers whose Origin header names another
+// host, so that other sites cannot read specs through a visitor's cookie.
 var upgrader = websocket.Upgrader{
 	ReadBufferSize:  1024,
 	WriteBufferSize: 1024,
-	CheckOrigin: func(r *http.Request) bool {
-		return true // Allow all origins for local development
-	},
 }
 
 func WebSocketHandler(hub *socket.Hub) http.HandlerFunc {
@@ -24,20 +24,7 @@ func WebSocketHandler(hub *socket.Hub) http.HandlerFunc {
 			logger.Error("Failed to upgrade to websocket", "error", err)
 			return
 		}
+		// The hub reads and writes to the connection until it closes.
 		hub.Add(conn)
-
-		// Start a goroutine to keep the connection open and detect disconnects
-		go func() {
-			defer hub.Remove(conn)
-			for {
-				// Although we don't expect messages from the client, reading is necessary
-				// to process control frames (ping/pong) and detect when the client closes the connection.
-				_, _, err := conn.ReadMessage()
-				if err != nil {
-					// This is expected when the client disconnects
-					break
-				}
-			}
-		}()
 	}
 }
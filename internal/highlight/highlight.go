@@ -0,0 +1,369 @@
+// Package highlight renders source code as HTML with its tokens wrapped in
+// classed spans, so code blocks are highlighted without client-side
+// scripts and in exports.
+//
+// Tokens are classed tok-key, tok-string, tok-number, tok-literal,
+// tok-keyword and tok-comment. Text of other tokens is only escaped.
+package highlight
+
+import (
+	"bytes"
+	"html"
+	"strings"
+)
+
+// Token classes.
+const (
+	Key     = "tok-key"
+	String  = "tok-string"
+	Number  = "tok-number"
+	Literal = "tok-literal"
+	Keyword = "tok-keyword"
+	Comment = "tok-comment"
+)
+
+type lexer func(code string, emit func(class, text string))
+
+var lexers = map[string]lexer{
+	"json": lexJSON,
+	"yaml": lexYAML,
+	"yml":  lexYAML,
+	"sql":  lexSQL,
+}
+
+// Supported reports whether lang can be highlighted.
+func Supported(lang string) bool {
+	_, ok := lexers[strings.ToLower(lang)]
+	return ok
+}
+
+// HTML returns code in lang as escaped HTML with its tokens highlighted, or
+// only escaped if lang is not supported.
+func HTML(lang string, code []byte) []byte {
+	var b bytes.Buffer
+	lex, ok := lexers[strings.ToLower(lang)]
+	if !ok {
+		b.WriteString(html.EscapeString(string(code)))
+		return b.Bytes()
+	}
+	lex(string(code), func(class, text string) {
+		if text == "" {
+			return
+		}
+		if class == "" {
+			b.WriteString(html.EscapeString(text))
+			return
+		}
+		b.WriteString(`<span class="` + class + `">`)
+		b.WriteString(html.EscapeString(text))
+		b.WriteString("</span>")
+	})
+	return b.Bytes()
+}
+
+// quoted returns the length of the string starting with the quote at
+// code[i], up to the closing quote or the end of the line. Backslashes
+// escape the next character when escapes is set; otherwise a doubled quote
+// stands for one.
+func quoted(code string, i int, escapes bool) int {
+	q := code[i]
+	j := i + 1
+	for j < len(code) && code[j] != '\n' {
+		switch {
+		case escapes && code[j] == '\\' && j+1 < len(code):
+			j += 2
+			continue
+		case code[j] == q:
+			if !escapes && j+1 < len(code) && code[j+1] == q {
+				j += 2
+				continue
+			}
+			return j + 1 - i
+		}
+		j++
+	}
+	return j - i
+}
+
+// number returns the length of the number starting at code[i], or 0.
+func number(code string, i int) int {
+	j := i
+	if j < len(code) && (code[j] == '-' || code[j] == '+') {
+		j++
+	}
+	start := j
+	for j < len(code) && (isDigit(code[j]) || code[j] == '.' || code[j] == '_') {
+		j++
+	}
+	if j == start || !isDigit(code[start]) && !(code[start] == '.' && j > start+1) {
+		return 0
+	}
+	if j < len(code) && (code[j] == 'e' || code[j] == 'E') {
+		k := j + 1
+		if k < len(code) && (code[k] == '-' || code[k] == '+') {
+			k++
+		}
+		if k < len(code) && isDigit(code[k]) {
+			for j = k; j < len(code) && isDigit(code[j]); j++ {
+			}
+		}
+	}
+	return j - i
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+func isWord(c byte) bool {
+	return c == '_' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || isDigit(c) || c >= 0x80
+}
+
+func lexJSON(code string, emit func(class, text string)) {
+	plain := 0
+	flush := func(i int) {
+		emit("", code[plain:i])
+	}
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case c == '"':
+			n := quoted(code, i, true)
+			class := String
+			// Strings followed by a colon are keys.
+			rest := strings.TrimLeft(code[i+n:], " \t\r\n")
+			if strings.HasPrefix(rest, ":") {
+				class = Key
+			}
+			flush(i)
+			emit(class, code[i:i+n])
+			i += n
+			plain = i
+		case c == '-' || isDigit(c):
+			n := number(code, i)
+			if n == 0 {
+				i++
+				continue
+			}
+			flush(i)
+			emit(Number, code[i:i+n])
+			i += n
+			plain = i
+		case isWord(c):
+			j := i
+			for j < len(code) && isWord(code[j]) {
+				j++
+			}
+			switch word := code[i:j]; word {
+			case "true", "false", "null":
+				flush(i)
+				emit(Literal, word)
+				plain = j
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	flush(len(code))
+}
+
+// yamlLiterals are the plain scalars YAML reads as booleans and nulls.
+var yamlLiterals = map[string]bool{
+	"true": true, "false": true, "True": true, "False": true, "TRUE": true, "FALSE": true,
+	"null": true, "Null": true, "NULL": true, "~": true, "yes": true, "no": true,
+}
+
+func lexYAML(code string, emit func(class, text string)) {
+	for len(code) > 0 {
+		line, rest, found := strings.Cut(code, "\n")
+		lexYAMLLine(line, emit)
+		if found {
+			emit("", "\n")
+		}
+		code = rest
+	}
+}
+
+func lexYAMLLine(line string, emit func(class, text string)) {
+	i := 0
+	// Indentation and sequence markers.
+	for i < len(line) && (line[i] == ' ' || line[i] == '\t' || line[i] == '-' && (i+1 == len(line) || line[i+1] == ' ')) {
+		i++
+	}
+	emit("", line[:i])
+	line = line[i:]
+	if strings.HasPrefix(line, "#") {
+		emit(Comment, line)
+		return
+	}
+	if line == "---" || line == "..." {
+		emit("", line)
+		return
+	}
+
+	// A key is the text up to the first ": " or a trailing colon, outside
+	// quotes.
+	if k := yamlKey(line); k > 0 {
+		emit(Key, line[:k])
+		j := k + 1
+		emit("", line[k:j])
+		line = line[j:]
+	}
+	yamlValue(line, emit)
+}
+
+// yamlKey returns the length of the key at the start of line, or 0.
+func yamlKey(line string) int {
+	end := 0
+	if line != "" && (line[0] == '"' || line[0] == '\'') {
+		end = quoted(line, 0, line[0] == '"')
+	}
+	for j := end; j < len(line); j++ {
+		switch line[j] {
+		case ':':
+			if j+1 == len(line) || line[j+1] == ' ' || line[j+1] == '\t' {
+				return j
+			}
+		case '#':
+			if j > 0 && line[j-1] == ' ' {
+				return 0
+			}
+		case '{', '[', '"', '\'':
+			if j == 0 {
+				return 0
+			}
+		}
+	}
+	return 0
+}
+
+// yamlValue highlights the value of a YAML line, a plain, quoted or flow
+// scalar followed by an optional comment.
+func yamlValue(line string, emit func(class, text string)) {
+	lead := len(line) - len(strings.TrimLeft(line, " \t"))
+	emit("", line[:lead])
+	line = line[lead:]
+	if line == "" {
+		return
+	}
+
+	comment := ""
+	switch line[0] {
+	case '"', '\'':
+		n := quoted(line, 0, line[0] == '"')
+		emit(String, line[:n])
+		line = line[n:]
+		if k := strings.Index(line, "#"); k >= 0 {
+			line, comment = line[:k], line[k:]
+		}
+		emit("", line)
+	case '{', '[':
+		// Flow collections read like JSON.
+		if k := strings.Index(line, " #"); k >= 0 {
+			line, comment = line[:k+1], line[k+1:]
+		}
+		lexJSON(line, emit)
+	default:
+		if k := strings.Index(line, " #"); k >= 0 {
+			line, comment = line[:k], line[k:]
+		}
+		value := strings.TrimRight(line, " \t")
+		switch {
+		case yamlLiterals[value]:
+			emit(Literal, value)
+		case number(value, 0) == len(value):
+			emit(Number, value)
+		case value == "|" || value == ">" || strings.HasPrefix(value, "|") || strings.HasPrefix(value, ">") ||
+			strings.HasPrefix(value, "&") || strings.HasPrefix(value, "*") || strings.HasPrefix(value, "!"):
+			// Block scalar indicators, anchors, aliases and tags.
+			emit("", value)
+		default:
+			emit(String, value)
+		}
+		emit("", line[len(value):])
+	}
+	emit(Comment, comment)
+}
+
+// sqlKeywords are the SQL keywords highlighted, in upper case.
+var sqlKeywords = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(`
+		ADD ALL ALTER AND ANY AS ASC AUTOINCREMENT AUTO_INCREMENT BEGIN BETWEEN BIGINT BIGSERIAL BOOLEAN BOOL BY
+		BYTEA CASCADE CASE CHAR CHECK COLUMN COMMIT CONSTRAINT CONFLICT CREATE CROSS CURRENT_TIMESTAMP DATABASE
+		DATE DECIMAL DEFAULT DEFERRABLE DELETE DESC DISTINCT DO DOUBLE DROP ELSE END ENUM EXCEPT EXISTS EXTENSION
+		FALSE FLOAT FOR FOREIGN FROM FULL FUNCTION GRANT GROUP HAVING IF IN INDEX INNER INSERT INT INTEGER
+		INTERSECT INTERVAL INTO IS JOIN JSON JSONB KEY LEFT LIKE LIMIT MATERIALIZED NOT NOTHING NULL NUMERIC
+		OFFSET ON OR ORDER OUTER PRECISION PRIMARY PROCEDURE REAL REFERENCES RENAME REPLACE RETURNING RETURNS
+		REVOKE RIGHT ROLLBACK SCHEMA SELECT SEQUENCE SERIAL SET SMALLINT TABLE TEMPORARY TEXT THEN TIME
+		TIMESTAMP TIMESTAMPTZ TO TRANSACTION TRIGGER TRUE TRUNCATE TYPE UNION UNIQUE UPDATE USING UUID VALUES
+		VARCHAR VIEW WHEN WHERE WITH WITHOUT ZONE`) {
+		sqlKeywords[k] = true
+	}
+}
+
+func lexSQL(code string, emit func(class, text string)) {
+	plain := 0
+	flush := func(i int) {
+		emit("", code[plain:i])
+	}
+	for i := 0; i < len(code); {
+		c := code[i]
+		switch {
+		case c == '-' && strings.HasPrefix(code[i:], "--"):
+			end := strings.IndexByte(code[i:], '\n')
+			if end < 0 {
+				end = len(code) - i
+			}
+			flush(i)
+			emit(Comment, code[i:i+end])
+			i += end
+			plain = i
+		case c == '/' && strings.HasPrefix(code[i:], "/*"):
+			end := strings.Index(code[i+2:], "*/")
+			n := len(code) - i
+			if end >= 0 {
+				n = end + 4
+			}
+			flush(i)
+			emit(Comment, code[i:i+n])
+			i += n
+			plain = i
+		case c == '\'':
+			n := quoted(code, i, false)
+			flush(i)
+			emit(String, code[i:i+n])
+			i += n
+			plain = i
+		case c == '"' || c == '`':
+			// Quoted identifiers.
+			i += quoted(code, i, false)
+		case isDigit(c) && (i == 0 || !isWord(code[i-1])):
+			n := number(code, i)
+			if n == 0 || i+n < len(code) && isWord(code[i+n]) {
+				i++
+				continue
+			}
+			flush(i)
+			emit(Number, code[i:i+n])
+			i += n
+			plain = i
+		case isWord(c):
+			j := i
+			for j < len(code) && isWord(code[j]) {
+				j++
+			}
+			if word := code[i:j]; sqlKeywords[strings.ToUpper(word)] {
+				flush(i)
+				emit(Keyword, word)
+				plain = j
+			}
+			i = j
+		default:
+			i++
+		}
+	}
+	flush(len(code))
+}
@@ -0,0 +1,53 @@
+package highlight
+
+import "testing"
+
+func TestHTML(t *testing.T) {
+	tests := []struct {
+		lang, code, want string
+	}{
+		{
+			"json",
+			`{"id": 7, "tags": ["a<b"], "ok": true, "x": null, "n": -1.5e3}`,
+			`{<span class="tok-key">&#34;id&#34;</span>: <span class="tok-number">7</span>, ` +
+				`<span class="tok-key">&#34;tags&#34;</span>: [<span class="tok-string">&#34;a&lt;b&#34;</span>], ` +
+				`<span class="tok-key">&#34;ok&#34;</span>: <span class="tok-literal">true</span>, ` +
+				`<span class="tok-key">&#34;x&#34;</span>: <span class="tok-literal">null</span>, ` +
+				`<span class="tok-key">&#34;n&#34;</span>: <span class="tok-number">-1.5e3</span>}`,
+		},
+		{
+			"yaml",
+			"# Payment\nid: 42\nname: Jane Doe # owner\n- enabled: true\nurl: \"http://x\"\nlist: [1, two]\nbody: |\n  text: here",
+			`<span class="tok-comment"># Payment</span>` + "\n" +
+				`<span class="tok-key">id</span>: <span class="tok-number">42</span>` + "\n" +
+				`<span class="tok-key">name</span>: <span class="tok-string">Jane Doe</span><span class="tok-comment"> # owner</span>` + "\n" +
+				`- <span class="tok-key">enabled</span>: <span class="tok-literal">true</span>` + "\n" +
+				`<span class="tok-key">url</span>: <span class="tok-string">&#34;http://x&#34;</span>` + "\n" +
+				`<span class="tok-key">list</span>: [<span class="tok-number">1</span>, two]` + "\n" +
+				`<span class="tok-key">body</span>: |` + "\n" +
+				`  <span class="tok-key">text</span>: <span class="tok-string">here</span>`,
+		},
+		{
+			"sql",
+			"-- users\nSELECT id, \"order\" FROM users WHERE name = 'O''Brien' AND age > 21 /* adults */;",
+			`<span class="tok-comment">-- users</span>` + "\n" +
+				`<span class="tok-keyword">SELECT</span> id, &#34;order&#34; <span class="tok-keyword">FROM</span> users ` +
+				`<span class="tok-keyword">WHERE</span> name = <span class="tok-string">&#39;O&#39;&#39;Brien&#39;</span> ` +
+				`<span class="tok-keyword">AND</span> age &gt; <span class="tok-number">21</span> <span class="tok-comment">/* adults */</span>;`,
+		},
+		{"go", "a < b", "a &lt; b"},
+	}
+	for _, tt := range tests {
+		if got := string(HTML(tt.lang, []byte(tt.code))); got != tt.want {
+			t.Errorf("HTML(%s) =\n%s\nwant\n%s", tt.lang, got, tt.want)
+		}
+	}
+}
+
+func TestSupported(t *testing.T) {
+	for lang, want := range map[string]bool{"json": true, "YAML": true, "yml": true, "sql": true, "csv": false, "": false} {
+		if got := Supported(lang); got != want {
+			t.Errorf("Supported(%q) = %v, want %v", lang, got, want)
+		}
+	}
+}
@@ -0,0 +1,14 @@
+package instance
+
+import "os"
+
+// alive reports whether process pid is running: on Windows, finding a
+// process opens it, which fails once it has exited.
+func alive(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	_ = p.Release()
+	return true
+}
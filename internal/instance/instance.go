@@ -0,0 +1,152 @@
+// Package instance records the running spec viewers of the current user in
+// a state file, so that they can be listed from any terminal.
+package instance
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Instance is a running spec viewer.
+type Instance struct {
+	PID     int       `json:"pid"`
+	URL     string    `json:"url"`
+	Folder  string    `json:"folder"`
+	Started time.Time `json:"started"`
+}
+
+// lockTimeout bounds the wait for another process updating the file.
+const lockTimeout = 2 * time.Second
+
+// Dir returns the per-user state directory of spec viewers:
+// $XDG_STATE_HOME/spec-viewer, or spec-viewer in the user cache directory
+// when XDG_STATE_HOME is not set.
+func Dir() (string, error) {
+	dir := os.Getenv("XDG_STATE_HOME")
+	if dir == "" {
+		var err error
+		if dir, err = os.UserCacheDir(); err != nil {
+			return "", err
+		}
+	}
+	return filepath.Join(dir, "spec-viewer"), nil
+}
+
+// Path returns the state file: instances.json in Dir.
+func Path() (string, error) {
+	dir, err := Dir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "instances.json"), nil
+}
+
+// Register records inst, replacing any instance with the same PID.
+func Register(inst Instance) error {
+	return update(func(list []Instance) []Instance {
+		list = slices.DeleteFunc(list, func(i Instance) bool { return i.PID == inst.PID })
+		return append(list, inst)
+	})
+}
+
+// Unregister forgets the instance of process pid.
+func Unregister(pid int) error {
+	return update(func(list []Instance) []Instance {
+		return slices.DeleteFunc(list, func(i Instance) bool { return i.PID == pid })
+	})
+}
+
+// List returns the running instances, oldest first. Instances whose
+// process is gone, because it was killed before unregistering, are left
+// out.
+func List() ([]Instance, error) {
+	path, err := Path()
+	if err != nil {
+		return nil, err
+	}
+	list, err := read(path)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(list, func(i Instance) bool { return !alive(i.PID) }), nil
+}
+
+// update applies change to the recorded instances, dropping those whose
+// process is gone, while holding a lock on the file so that viewers
+// starting together do not overwrite each other.
+func update(change func([]Instance) []Instance) error {
+	path, err := Path()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return err
+	}
+	unlock, err := lock(path + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	list, err := read(path)
+	if err != nil {
+		return err
+	}
+	list = change(slices.DeleteFunc(list, func(i Instance) bool { return !alive(i.PID) }))
+	slices.SortFunc(list, func(a, b Instance) int { return a.Started.Compare(b.Started) })
+
+	data, err := json.MarshalIndent(list, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func read(path string) ([]Instance, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var list []Instance
+	if err := json.Unmarshal(data, &list); err != nil {
+		return nil, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return list, nil
+}
+
+// lock creates the lock file at path, waiting for it to be removed by
+// another process. A lock older than lockTimeout is left over by a process
+// that died holding it and is taken over.
+func lock(path string) (unlock func(), err error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+		if err == nil {
+			_ = f.Close()
+			return func() { _ = os.Remove(path) }, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+		if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) > lockTimeout {
+			_ = os.Remove(path)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("timed out waiting for %s", path)
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+}
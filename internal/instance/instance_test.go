@@ -0,0 +1,61 @@
+package instance
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRegisterListUnregister(t *testing.T) {
+	t.Setenv("XDG_STATE_HOME", t.TempDir())
+
+	if list, err := List(); err != nil || len(list) != 0 {
+		t.Fatalf("expected no instances before any is registered, got %v, %v", list, err)
+	}
+
+	now := time.Now().Truncate(time.Second)
+	self := Instance{PID: os.Getpid(), URL: "http://localhost:9091", Folder: "/repo/specs", Started: now}
+	// A process ID that cannot be running.
+	gone := Instance{PID: 1 << 30, URL: "http://localhost:9092", Folder: "/old/specs", Started: now.Add(-time.Hour)}
+	for _, inst := range []Instance{gone, self} {
+		if err := Register(inst); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	list, err := List()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list) != 1 || list[0].URL != self.URL || !list[0].Started.Equal(now) {
+		t.Errorf("expected only the running instance, got %+v", list)
+	}
+
+	if err := Unregister(os.Getpid()); err != nil {
+		t.Fatal(err)
+	}
+	if list, _ := List(); len(list) != 0 {
+		t.Errorf("expected no instances after unregistering, got %+v", list)
+	}
+}
+
+func TestLockTakesOverStaleLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "instances.json.lock")
+	if err := os.WriteFile(path, nil, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(path, old, old); err != nil {
+		t.Fatal(err)
+	}
+
+	unlock, err := lock(path)
+	if err != nil {
+		t.Fatalf("expected a stale lock to be taken over: %v", err)
+	}
+	unlock()
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("expected unlock to remove the lock file, got %v", err)
+	}
+}
@@ -0,0 +1,96 @@
+package latex
+
+// alphabet is a Unicode math alphabet: the code points of its "A", "a" and
+// "0", zero when it has no such letters, and the letters encoded outside
+// of the contiguous range.
+type alphabet struct {
+	upper, lower, digit rune
+	holes               map[rune]rune
+}
+
+var alphabets = map[string]alphabet{
+	"bold":        {0x1D400, 0x1D41A, 0x1D7CE, nil},
+	"italic":      {0x1D434, 0x1D44E, 0, map[rune]rune{'h': 'ℎ'}},
+	"bold-italic": {0x1D468, 0x1D482, 0x1D7CE, nil},
+	"script": {0x1D49C, 0x1D4B6, 0, map[rune]rune{
+		'B': 'ℬ', 'E': 'ℰ', 'F': 'ℱ', 'H': 'ℋ', 'I': 'ℐ', 'L': 'ℒ', 'M': 'ℳ',
+		'R': 'ℛ', 'e': 'ℯ', 'g': 'ℊ', 'o': 'ℴ',
+	}},
+	"fraktur": {0x1D504, 0x1D51E, 0, map[rune]rune{
+		'C': 'ℭ', 'H': 'ℌ', 'I': 'ℑ', 'R': 'ℜ', 'Z': 'ℨ',
+	}},
+	"double-struck": {0x1D538, 0x1D552, 0x1D7D8, map[rune]rune{
+		'C': 'ℂ', 'H': 'ℍ', 'N': 'ℕ', 'P': 'ℙ', 'Q': 'ℚ', 'R': 'ℝ', 'Z': 'ℤ',
+	}},
+	"sans-serif": {0x1D5A0, 0x1D5BA, 0x1D7E2, nil},
+	"monospace":  {0x1D670, 0x1D68A, 0x1D7F6, nil},
+}
+
+// convert returns r in the alphabet, or r itself when the alphabet has no
+// such character.
+func (a alphabet) convert(r rune) rune {
+	if h, ok := a.holes[r]; ok {
+		return h
+	}
+	switch {
+	case r >= 'A' && r <= 'Z':
+		return a.upper + r - 'A'
+	case r >= 'a' && r <= 'z':
+		return a.lower + r - 'a'
+	case r >= '0' && r <= '9' && a.digit != 0:
+		return a.digit + r - '0'
+	}
+	return r
+}
+
+func (a alphabet) convertString(s string) string {
+	rs := []rune(s)
+	for i, r := range rs {
+		rs[i] = a.convert(r)
+	}
+	return string(rs)
+}
+
+// applyFont sets the font of the identifiers and numbers in n. Upright
+// letters in a row are joined into one identifier, so \mathrm{max} reads
+// as a single name.
+func applyFont(n node, font string) node {
+	switch n := n.(type) {
+	case row:
+		var out row
+		for _, c := range n {
+			c = applyFont(c, font)
+			if id, ok := c.(ident); ok && font == "normal" && len(out) > 0 {
+				if prev, ok := out[len(out)-1].(ident); ok {
+					out[len(out)-1] = ident{s: prev.s + id.s, plain: prev.plain + id.plain, upright: true}
+					continue
+				}
+			}
+			out = append(out, c)
+		}
+		return out
+	case ident:
+		if font == "normal" {
+			return ident{s: n.plain, plain: n.plain, upright: true}
+		}
+		return ident{s: alphabets[font].convertString(n.plain), plain: n.plain}
+	case number:
+		if font == "normal" {
+			return number{s: n.plain, plain: n.plain}
+		}
+		return number{s: alphabets[font].convertString(n.plain), plain: n.plain}
+	case *scripts:
+		s := *n
+		if s.base != nil {
+			s.base = applyFont(s.base, font)
+		}
+		return &s
+	case frac:
+		n.num, n.den = applyFont(n.num, font), applyFont(n.den, font)
+		return n
+	case fenced:
+		n.body = applyFont(n.body, font).(row)
+		return n
+	}
+	return n
+}
@@ -0,0 +1,69 @@
+// Package latex converts LaTeX formulas to MathML, so specs can show math
+// without a browser-side renderer.
+//
+// It understands the subset of LaTeX math used in technical writing:
+// scripts, fractions, roots, delimiters, Greek letters, operators and
+// relations, accents, font commands, text and the matrix, cases and aligned
+// environments. Formulas can also be reduced to plain Unicode text for
+// outputs that have no MathML, such as PDF.
+package latex
+
+import (
+	"fmt"
+	"html"
+	"strings"
+)
+
+// Error is a syntax error at a 1-based line of a formula.
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// Formula is a parsed formula.
+type Formula struct {
+	src  string
+	root node
+}
+
+// Parse parses the source of a formula, without its $ delimiters. The
+// error, if any, is an Error.
+func Parse(src string) (*Formula, error) {
+	p := &parser{src: src, line: 1}
+	root, err := p.formula()
+	if err != nil {
+		return nil, err
+	}
+	return &Formula{src: src, root: root}, nil
+}
+
+// MathML returns the formula as a MathML element, in display style if
+// display is set. The LaTeX source is kept as an annotation so copying the
+// formula yields its source.
+func (f *Formula) MathML(display bool) string {
+	var b strings.Builder
+	mode := "inline"
+	if display {
+		mode = "block"
+	}
+	fmt.Fprintf(&b, `<math xmlns="http://www.w3.org/1998/Math/MathML" display="%s"><semantics><mrow>`, mode)
+	f.root.mathml(&b)
+	b.WriteString(`</mrow><annotation encoding="application/x-tex">`)
+	b.WriteString(html.EscapeString(strings.TrimSpace(f.src)))
+	b.WriteString("</annotation></semantics></math>")
+	return b.String()
+}
+
+// Text returns the formula as plain Unicode text, such as "x² + 1 ≤ n/2".
+// The rows of a multi-line formula are separated by newlines.
+func (f *Formula) Text() string {
+	lines := strings.Split(f.root.text(), "\n")
+	for i, l := range lines {
+		lines[i] = strings.Join(strings.Fields(l), " ")
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,91 @@
+package latex
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestText(t *testing.T) {
+	tests := []struct {
+		src, want string
+	}{
+		{`x^2 + 1 \leq \frac{n}{2}`, "x² + 1 ≤ n/2"},
+		{`d = \min\left(b \cdot 2^{k}, d_{\max}\right)`, "d = min(b ⋅ 2^k, d_max)"},
+		{`\sum_{i=1}^{n} i = \frac{n(n+1)}{2}`, "∑_(i=1)ⁿ i = (n(n + 1))/2"},
+		{`f'(x) = -\sqrt[3]{x_0}`, "f′(x) = −³√(x₀)"},
+		{`\text{fee} = 2.5\% \times a`, "fee = 2.5% × a"},
+		{`\mathbb{R}^n \ni \vec{v}`, "Rⁿ ∋ v⃗"},
+		{`\begin{pmatrix} 1 & 2 \\ 3 & 4 \end{pmatrix}`, "(1, 2; 3, 4)"},
+		{"a &= b + c \\\\\n&= d", "a = b + c\n= d"},
+		{`\sin x \not= \cos x`, "sin x ≠ cos x"},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.src, err)
+			continue
+		}
+		if got := f.Text(); got != tt.want {
+			t.Errorf("%q: expected %q, got %q", tt.src, tt.want, got)
+		}
+	}
+}
+
+func TestMathML(t *testing.T) {
+	tests := []struct {
+		src     string
+		display bool
+		want    []string
+	}{
+		{`x^2`, false, []string{`display="inline"`, "<msup><mi>x</mi><mn>2</mn></msup>", `<annotation encoding="application/x-tex">x^2</annotation>`}},
+		{`\sum_{i=1}^n i`, true, []string{`display="block"`, "<munderover><mo>∑</mo><mrow><mi>i</mi><mo>=</mo><mn>1</mn></mrow><mi>n</mi></munderover>"}},
+		{`\int_0^1 x`, true, []string{"<msubsup><mo>∫</mo><mn>0</mn><mn>1</mn></msubsup>"}},
+		{`\frac{a}{b+c}`, false, []string{"<mfrac><mi>a</mi><mrow><mi>b</mi><mo>+</mo><mi>c</mi></mrow></mfrac>"}},
+		{`\left[ x \middle| y \right)`, false, []string{`<mo fence="true" stretchy="true" symmetric="true" form="prefix">[</mo>`, `<mo fence="true" stretchy="true" symmetric="true" form="postfix">)</mo>`}},
+		{`\log x`, false, []string{"<mi>log</mi><mo>&#x2061;</mo><mi>x</mi>"}},
+		{`\Delta \mathrm{d}t \mathbf{v}`, false, []string{`<mi mathvariant="normal">Δ</mi><mi mathvariant="normal">d</mi>`, "<mi>𝐯</mi>"}},
+		{`\text{if } a < b`, false, []string{"<mtext>if\u00a0</mtext>", "<mo>&lt;</mo>"}},
+		{`\begin{cases} 1 & x \\ 0 & y \end{cases}`, false, []string{`form="prefix">{</mo><mtable><mtr><mtd columnalign="left"`}},
+	}
+	for _, tt := range tests {
+		f, err := Parse(tt.src)
+		if err != nil {
+			t.Errorf("%q: unexpected error %v", tt.src, err)
+			continue
+		}
+		got := f.MathML(tt.display)
+		for _, want := range tt.want {
+			if !strings.Contains(got, want) {
+				t.Errorf("%q: expected MathML to contain %q, got:\n%s", tt.src, want, got)
+			}
+		}
+	}
+}
+
+func TestParse_Errors(t *testing.T) {
+	tests := []struct {
+		src     string
+		line    int
+		message string
+	}{
+		{`\frac{1`, 1, `group is never closed, missing "}"`},
+		{`\frac{1}`, 1, `"\frac" is missing an argument`},
+		{"a +\n\\foo", 2, `unknown command "\foo"`},
+		{`x}`, 1, `unexpected "}"`},
+		{`x^2^3`, 1, "double superscript"},
+		{`x_1_2`, 1, "double subscript"},
+		{"\\left(\nx", 1, `"\left" is never closed, missing "\right"`},
+		{`\left\foo x \right)`, 1, `"\foo" is not a delimiter`},
+		{`\begin{foo} x \end{foo}`, 1, `unknown environment "foo"`},
+		{"\\begin{matrix}\n1 \\\\\n2", 1, `environment "matrix" is never closed, missing "\end{matrix}"`},
+		{"\\begin{matrix}\n1\n\\end{pmatrix}", 3, `"\end{pmatrix}" does not match "\begin{matrix}"`},
+		{`x \right)`, 1, `unexpected "\right"`},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.src)
+		e, ok := err.(Error)
+		if !ok || e.Line != tt.line || e.Message != tt.message {
+			t.Errorf("%q: expected line %d %q, got %v", tt.src, tt.line, tt.message, err)
+		}
+	}
+}
@@ -0,0 +1,520 @@
+package latex
+
+import (
+	"html"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// node is an element of a parsed formula.
+type node interface {
+	mathml(b *strings.Builder)
+	text() string
+}
+
+// row is a sequence of nodes, such as the contents of a group.
+type row []node
+
+func (r row) mathml(b *strings.Builder) {
+	for i, n := range r {
+		n.mathml(b)
+		if isFunction(n) && i+1 < len(r) {
+			// Function application binds the function to its argument.
+			b.WriteString("<mo>&#x2061;</mo>")
+		}
+	}
+}
+
+func (r row) text() string {
+	var b strings.Builder
+	prev := opOpen
+	for i, n := range r {
+		s := n.text()
+		switch class := classOf(n); class {
+		case opRel:
+			s = " " + s + " "
+		case opBin:
+			if prev != opOpen && prev != opBin && prev != opRel && prev != opPunct {
+				s = " " + s + " "
+			}
+		case opPunct:
+			s += " "
+		}
+		if (isFunction(n) || classOf(n) == opLarge) && i+1 < len(r) && separate(r[i+1]) {
+			s += " "
+		}
+		b.WriteString(s)
+		prev = classOf(n)
+	}
+	return b.String()
+}
+
+// ident is an identifier. s is the character drawn, which differs from
+// plain for the math alphabets of font commands.
+type ident struct {
+	s, plain string
+	upright  bool
+}
+
+func (n ident) mathml(b *strings.Builder) {
+	b.WriteString("<mi")
+	if n.upright && utf8.RuneCountInString(n.s) == 1 {
+		b.WriteString(` mathvariant="normal"`)
+	}
+	b.WriteString(">" + html.EscapeString(n.s) + "</mi>")
+}
+
+func (n ident) text() string { return n.plain }
+
+type number struct {
+	s, plain string
+}
+
+func (n number) mathml(b *strings.Builder) {
+	b.WriteString("<mn>" + html.EscapeString(n.s) + "</mn>")
+}
+
+func (n number) text() string { return n.plain }
+
+// op is an operator, relation, punctuation or delimiter. Delimiters of
+// \left and \right stretch to the height of their contents, those of the
+// \big family take a fixed size, and others keep their natural size.
+type op struct {
+	s       string
+	class   opClass
+	stretch bool
+	size    string
+}
+
+func (n op) mathml(b *strings.Builder) {
+	b.WriteString("<mo")
+	switch {
+	case n.stretch:
+		b.WriteString(` fence="true" stretchy="true" symmetric="true"`)
+	case n.size != "":
+		b.WriteString(` fence="true" stretchy="true" symmetric="true" minsize="` + n.size + `" maxsize="` + n.size + `"`)
+	case n.class == opOpen || n.class == opClose || n.class == opOrd && isFence(n.s):
+		b.WriteString(` stretchy="false"`)
+	}
+	switch {
+	case n.class == opOpen && (n.stretch || n.size != ""):
+		b.WriteString(` form="prefix"`)
+	case n.class == opClose && (n.stretch || n.size != ""):
+		b.WriteString(` form="postfix"`)
+	}
+	b.WriteString(">" + html.EscapeString(n.s) + "</mo>")
+}
+
+func (n op) text() string { return n.s }
+
+func isFence(s string) bool {
+	return strings.ContainsAny(s, "|‖/")
+}
+
+// function is an upright operator name such as sin or lim. Functions with
+// limits take their scripts above and below in display style.
+type function struct {
+	name   string
+	limits bool
+}
+
+func (n function) mathml(b *strings.Builder) {
+	if n.limits {
+		b.WriteString(`<mo form="prefix" movablelimits="true">` + html.EscapeString(n.name) + "</mo>")
+		return
+	}
+	b.WriteString("<mi>" + html.EscapeString(n.name) + "</mi>")
+}
+
+func (n function) text() string { return n.name }
+
+func isFunction(n node) bool {
+	switch n := n.(type) {
+	case function:
+		return true
+	case *scripts:
+		return isFunction(n.base)
+	}
+	return false
+}
+
+// textNode is text inside a formula, such as the argument of \text.
+type textNode struct {
+	s string
+}
+
+func (n textNode) mathml(b *strings.Builder) {
+	// Spaces would collapse at the ends of the text.
+	b.WriteString("<mtext>" + html.EscapeString(strings.ReplaceAll(n.s, " ", "\u00a0")) + "</mtext>")
+}
+
+func (n textNode) text() string { return n.s }
+
+type space struct {
+	width string
+}
+
+func (n space) mathml(b *strings.Builder) {
+	if n.width != "0" {
+		b.WriteString(`<mspace width="` + n.width + `"/>`)
+	}
+}
+
+func (n space) text() string {
+	if n.width == "0" {
+		return ""
+	}
+	return " "
+}
+
+// frac is a fraction, or a binomial coefficient without a bar. display
+// forces display style for \dfrac ("true") or text style for \tfrac
+// ("false").
+type frac struct {
+	num, den node
+	binom    bool
+	display  string
+}
+
+func (n frac) mathml(b *strings.Builder) {
+	if n.display != "" {
+		b.WriteString(`<mstyle displaystyle="` + n.display + `" scriptlevel="0">`)
+	}
+	if n.binom {
+		b.WriteString(`<mrow><mo>(</mo><mfrac linethickness="0">`)
+	} else {
+		b.WriteString("<mfrac>")
+	}
+	child(b, n.num)
+	child(b, n.den)
+	b.WriteString("</mfrac>")
+	if n.binom {
+		b.WriteString("<mo>)</mo></mrow>")
+	}
+	if n.display != "" {
+		b.WriteString("</mstyle>")
+	}
+}
+
+func (n frac) text() string {
+	if n.binom {
+		return "C(" + n.num.text() + ", " + n.den.text() + ")"
+	}
+	return wrap(n.num.text()) + "/" + wrap(n.den.text())
+}
+
+// sqrt is a square root, or a root of another degree when index is set.
+type sqrt struct {
+	body, index node
+}
+
+func (n sqrt) mathml(b *strings.Builder) {
+	if n.index == nil {
+		b.WriteString("<msqrt>")
+		n.body.mathml(b)
+		b.WriteString("</msqrt>")
+		return
+	}
+	b.WriteString("<mroot>")
+	child(b, n.body)
+	child(b, n.index)
+	b.WriteString("</mroot>")
+}
+
+func (n sqrt) text() string {
+	s := "√" + wrap(n.body.text())
+	if n.index != nil {
+		s = superscript(n.index.text()) + s
+	}
+	return s
+}
+
+// scripts attaches a subscript, a superscript or both to base. With limits
+// they are drawn below and above it instead.
+type scripts struct {
+	base, sub, sup node
+	limits         bool
+}
+
+func (n *scripts) mathml(b *strings.Builder) {
+	var tag string
+	switch {
+	case n.limits && n.sub != nil && n.sup != nil:
+		tag = "munderover"
+	case n.limits && n.sub != nil:
+		tag = "munder"
+	case n.limits:
+		tag = "mover"
+	case n.sub != nil && n.sup != nil:
+		tag = "msubsup"
+	case n.sub != nil:
+		tag = "msub"
+	default:
+		tag = "msup"
+	}
+	b.WriteString("<" + tag + ">")
+	if n.base == nil {
+		b.WriteString("<mrow></mrow>")
+	} else {
+		child(b, n.base)
+	}
+	if n.sub != nil {
+		child(b, n.sub)
+	}
+	if n.sup != nil {
+		child(b, n.sup)
+	}
+	b.WriteString("</" + tag + ">")
+}
+
+func (n *scripts) text() string {
+	var s string
+	if n.base != nil {
+		s = n.base.text()
+	}
+	if n.sub != nil {
+		s += subscript(n.sub.text())
+	}
+	if n.sup != nil {
+		s += superscript(n.sup.text())
+	}
+	return s
+}
+
+// fenced is a group between delimiters that stretch to its height.
+type fenced struct {
+	open, close string
+	body        row
+}
+
+func (n fenced) mathml(b *strings.Builder) {
+	b.WriteString("<mrow>")
+	if n.open != "" {
+		op{s: n.open, class: opOpen, stretch: true}.mathml(b)
+	}
+	n.body.mathml(b)
+	if n.close != "" {
+		op{s: n.close, class: opClose, stretch: true}.mathml(b)
+	}
+	b.WriteString("</mrow>")
+}
+
+func (n fenced) text() string {
+	return n.open + n.body.text() + n.close
+}
+
+// accent draws a mark over, or under, base.
+type accent struct {
+	base            node
+	mark, combining string
+	stretchy, under bool
+}
+
+func (n accent) mathml(b *strings.Builder) {
+	tag, attr := "mover", "accent"
+	if n.under {
+		tag, attr = "munder", "accentunder"
+	}
+	stretchy := "false"
+	if n.stretchy {
+		stretchy = "true"
+	}
+	b.WriteString("<" + tag + " " + attr + `="true">`)
+	child(b, n.base)
+	b.WriteString(`<mo stretchy="` + stretchy + `">` + html.EscapeString(n.mark) + "</mo></" + tag + ">")
+}
+
+func (n accent) text() string {
+	s := n.base.text()
+	if n.combining == "" {
+		return s
+	}
+	if utf8.RuneCountInString(s) == 1 {
+		return s + n.combining
+	}
+	if n.stretchy {
+		// Lines span the whole base.
+		var b strings.Builder
+		for _, r := range s {
+			b.WriteRune(r)
+			b.WriteString(n.combining)
+		}
+		return b.String()
+	}
+	return wrap(s) + n.combining
+}
+
+// table is the grid of an environment, or of a formula whose lines are
+// separated by \\. align holds the alignment of each column, "l", "c" or
+// "r", repeating from the start when there are more columns.
+type table struct {
+	rows    [][]row
+	align   string
+	display bool
+	// sep joins the cells of a row in plain text.
+	sep string
+	top bool
+}
+
+func (n *table) mathml(b *strings.Builder) {
+	b.WriteString("<mtable")
+	if n.display {
+		b.WriteString(` displaystyle="true"`)
+	}
+	b.WriteString(">")
+	pairs := n.align == "rl"
+	for _, cells := range n.rows {
+		b.WriteString("<mtr>")
+		for i, cell := range cells {
+			b.WriteString("<mtd")
+			switch n.alignment(i) {
+			case 'l':
+				b.WriteString(` columnalign="left" style="text-align:left`)
+				if pairs {
+					b.WriteString(";padding-left:0")
+				}
+				b.WriteString(`"`)
+			case 'r':
+				b.WriteString(` columnalign="right" style="text-align:right`)
+				if pairs {
+					b.WriteString(";padding-right:0")
+				}
+				b.WriteString(`"`)
+			}
+			b.WriteString(">")
+			if pairs && i%2 == 1 {
+				// Keeps a leading relation spaced as an infix operator.
+				b.WriteString("<mi></mi>")
+			}
+			cell.mathml(b)
+			b.WriteString("</mtd>")
+		}
+		b.WriteString("</mtr>")
+	}
+	b.WriteString("</mtable>")
+}
+
+func (n *table) alignment(col int) byte {
+	if n.align == "" {
+		return 'c'
+	}
+	return n.align[col%len(n.align)]
+}
+
+func (n *table) text() string {
+	rows := make([]string, len(n.rows))
+	for i, cells := range n.rows {
+		parts := make([]string, len(cells))
+		for j, cell := range cells {
+			parts[j] = strings.TrimSpace(cell.text())
+		}
+		rows[i] = strings.Join(parts, n.sep)
+	}
+	if n.top {
+		return strings.Join(rows, "\n")
+	}
+	return strings.Join(rows, "; ")
+}
+
+// color draws body in a color of \textcolor.
+type color struct {
+	color string
+	body  node
+}
+
+func (n color) mathml(b *strings.Builder) {
+	b.WriteString(`<mstyle mathcolor="` + html.EscapeString(n.color) + `">`)
+	child(b, n.body)
+	b.WriteString("</mstyle>")
+}
+
+func (n color) text() string { return n.body.text() }
+
+// child writes n as a single element, the way script and fraction
+// elements expect their arguments.
+func child(b *strings.Builder, n node) {
+	if r, ok := n.(row); ok && len(r) != 1 {
+		b.WriteString("<mrow>")
+		r.mathml(b)
+		b.WriteString("</mrow>")
+		return
+	}
+	n.mathml(b)
+}
+
+// separate reports whether n is set apart from a preceding function name
+// in plain text, unlike a parenthesized argument.
+func separate(n node) bool {
+	if _, ok := n.(fenced); ok {
+		return false
+	}
+	return classOf(n) == opOrd
+}
+
+// classOf returns the class that decides the spacing around n in plain
+// text.
+func classOf(n node) opClass {
+	switch n := n.(type) {
+	case op:
+		return n.class
+	case *scripts:
+		if n.base != nil {
+			return classOf(n.base)
+		}
+	case row:
+		if len(n) == 1 {
+			return classOf(n[0])
+		}
+	}
+	return opOrd
+}
+
+// wrap parenthesizes s unless it reads as a single term.
+func wrap(s string) string {
+	if utf8.RuneCountInString(s) <= 1 {
+		return s
+	}
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '.' {
+			return "(" + s + ")"
+		}
+	}
+	return s
+}
+
+var (
+	superscripts = strings.NewReplacer("0", "⁰", "1", "¹", "2", "²", "3", "³", "4", "⁴",
+		"5", "⁵", "6", "⁶", "7", "⁷", "8", "⁸", "9", "⁹", "+", "⁺", "−", "⁻", "=", "⁼",
+		"(", "⁽", ")", "⁾", "n", "ⁿ")
+	subscripts = strings.NewReplacer("0", "₀", "1", "₁", "2", "₂", "3", "₃", "4", "₄",
+		"5", "₅", "6", "₆", "7", "₇", "8", "₈", "9", "₉", "+", "₊", "−", "₋")
+)
+
+// superscript returns s as Unicode superscript characters, or after a
+// caret when some have none.
+func superscript(s string) string {
+	s = strings.ReplaceAll(s, " ", "")
+	if t := superscripts.Replace(s); onlyOf(t, "⁰¹²³⁴⁵⁶⁷⁸⁹⁺⁻⁼⁽⁾ⁿ′") {
+		return t
+	}
+	return "^" + wrap(s)
+}
+
+// subscript is superscript for subscripts.
+func subscript(s string) string {
+	s = strings.ReplaceAll(s, " ", "")
+	if t := subscripts.Replace(s); onlyOf(t, "₀₁₂₃₄₅₆₇₈₉₊₋") {
+		return t
+	}
+	return "_" + wrap(s)
+}
+
+func onlyOf(s, chars string) bool {
+	for _, r := range s {
+		if !strings.ContainsRune(chars, r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,631 @@
+package latex
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// parser reads a formula token by token. A token is a command, such as
+// `\frac` or `\,`, or a single character.
+type parser struct {
+	src  string
+	pos  int
+	line int
+	// optional counts the optional arguments being read, which end at "]".
+	optional int
+}
+
+func (p *parser) errorf(line int, format string, args ...any) error {
+	return Error{Line: line, Message: fmt.Sprintf(format, args...)}
+}
+
+// skipSpace skips white space and comments, which have no meaning in math.
+func (p *parser) skipSpace() {
+	for p.pos < len(p.src) {
+		switch p.src[p.pos] {
+		case '\n':
+			p.line++
+			p.pos++
+		case ' ', '\t', '\r':
+			p.pos++
+		case '%':
+			for p.pos < len(p.src) && p.src[p.pos] != '\n' {
+				p.pos++
+			}
+		default:
+			return
+		}
+	}
+}
+
+// peek returns the next token without consuming it, or "" at the end.
+func (p *parser) peek() string {
+	p.skipSpace()
+	if p.pos >= len(p.src) {
+		return ""
+	}
+	if p.src[p.pos] != '\\' {
+		_, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		return p.src[p.pos : p.pos+size]
+	}
+	i := p.pos + 1
+	for i < len(p.src) && isASCIILetter(p.src[i]) {
+		i++
+	}
+	if i == p.pos+1 && i < len(p.src) {
+		_, size := utf8.DecodeRuneInString(p.src[i:])
+		i += size
+	} else if i < len(p.src) && p.src[i] == '*' && p.src[p.pos+1:i] == "operatorname" {
+		i++
+	}
+	return p.src[p.pos:i]
+}
+
+func (p *parser) next() string {
+	tok := p.peek()
+	p.pos += len(tok)
+	return tok
+}
+
+func isASCIILetter(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z'
+}
+
+// formula parses the whole source. Lines separated by \\, with columns
+// separated by &, form a table.
+func (p *parser) formula() (node, error) {
+	t, err := p.table()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok != "" {
+		return nil, p.errorf(p.line, `unexpected "%s"`, tok)
+	}
+	if len(t.rows) == 1 && len(t.rows[0]) == 1 {
+		return t.rows[0][0], nil
+	}
+	t.top, t.display, t.sep = true, true, " "
+	for _, cells := range t.rows {
+		if len(cells) > 1 {
+			t.align = "rl"
+		}
+	}
+	return t, nil
+}
+
+// table parses rows of cells up to a token that ends neither a cell nor a
+// row.
+func (p *parser) table() (*table, error) {
+	t := &table{}
+	var cells []row
+	for {
+		cell, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		cells = append(cells, cell)
+
+		switch p.peek() {
+		case "&":
+			p.next()
+		case `\\`:
+			p.next()
+			p.rowSpacing()
+			t.rows = append(t.rows, cells)
+			cells = nil
+		default:
+			// A trailing \\ does not start another row.
+			if len(t.rows) == 0 || len(cells) > 1 || len(cells[0]) > 0 {
+				t.rows = append(t.rows, cells)
+			}
+			return t, nil
+		}
+	}
+}
+
+// rowSpacing skips the optional extra space after \\, as in \\[2pt].
+func (p *parser) rowSpacing() {
+	if p.peek() != "[" {
+		return
+	}
+	if end := strings.IndexByte(p.src[p.pos:], ']'); end >= 0 {
+		p.pos += end + 1
+	}
+}
+
+// expression parses nodes up to the end of the current group, cell or row.
+func (p *parser) expression() (row, error) {
+	var r row
+	for {
+		switch tok := p.peek(); tok {
+		case "", "}", "&", `\\`, `\right`, `\middle`, `\end`:
+			return r, nil
+		case "]":
+			if p.optional > 0 {
+				return r, nil
+			}
+		}
+		n, err := p.scripted()
+		if err != nil {
+			return nil, err
+		}
+		if n != nil {
+			r = append(r, n)
+		}
+	}
+}
+
+// scripted parses a node together with its subscript and superscript.
+func (p *parser) scripted() (node, error) {
+	var base node
+	if tok := p.peek(); tok != "^" && tok != "_" && tok != "'" {
+		var err error
+		if base, err = p.atom(); err != nil {
+			return nil, err
+		}
+	}
+
+	s := &scripts{base: base, limits: hasLimits(base)}
+	var primes string
+	for {
+		line := p.line
+		switch tok := p.peek(); tok {
+		case "'":
+			p.next()
+			if s.sup != nil {
+				return nil, p.errorf(line, "double superscript")
+			}
+			primes += "′"
+		case "^", "_":
+			p.next()
+			arg, err := p.argument(tok)
+			if err != nil {
+				return nil, err
+			}
+			switch {
+			case tok == "_" && s.sub != nil:
+				return nil, p.errorf(line, "double subscript")
+			case tok == "_":
+				s.sub = arg
+			case s.sup != nil:
+				return nil, p.errorf(line, "double superscript")
+			case primes != "":
+				s.sup = row{op{s: primes}, arg}
+			default:
+				s.sup = arg
+			}
+		case `\limits`, `\nolimits`:
+			p.next()
+			s.limits = tok == `\limits`
+		default:
+			if primes != "" && s.sup == nil {
+				s.sup = op{s: primes}
+			}
+			if s.sub == nil && s.sup == nil {
+				return base, nil
+			}
+			return s, nil
+		}
+	}
+}
+
+// hasLimits reports whether the scripts of n go below and above it in
+// display style.
+func hasLimits(n node) bool {
+	switch n := n.(type) {
+	case op:
+		return n.class == opLarge && !integrals[n.s]
+	case function:
+		return n.limits
+	case accent:
+		return n.mark == "⏞" || n.mark == "⏟"
+	}
+	return false
+}
+
+// atom parses a single node without its scripts. Commands without any
+// effect yield nil.
+func (p *parser) atom() (node, error) {
+	line := p.line
+	tok := p.next()
+	switch {
+	case tok == "{":
+		r, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.closeGroup(line); err != nil {
+			return nil, err
+		}
+		return r, nil
+	case tok[0] == '\\':
+		return p.command(tok, line)
+	case tok[0] >= '0' && tok[0] <= '9':
+		start := p.pos - 1
+		for p.pos < len(p.src) && (isDigit(p.src[p.pos]) || p.src[p.pos] == '.' && p.pos+1 < len(p.src) && isDigit(p.src[p.pos+1])) {
+			p.pos++
+		}
+		s := p.src[start:p.pos]
+		return number{s: s, plain: s}, nil
+	}
+	return char(tok), nil
+}
+
+// closeGroup consumes the "}" ending a group opened on line.
+func (p *parser) closeGroup(line int) error {
+	switch tok := p.peek(); tok {
+	case "}":
+		p.next()
+		return nil
+	case "":
+		return p.errorf(line, `group is never closed, missing "}"`)
+	default:
+		return p.errorf(p.line, `unexpected "%s"`, tok)
+	}
+}
+
+func isDigit(c byte) bool {
+	return c >= '0' && c <= '9'
+}
+
+// char returns the node of a single character.
+func char(tok string) node {
+	switch tok {
+	case "+":
+		return op{s: "+", class: opBin}
+	case "-":
+		return op{s: "−", class: opBin}
+	case "*":
+		return op{s: "∗", class: opBin}
+	case "=", "<", ">", ":":
+		return op{s: tok, class: opRel}
+	case ",", ";":
+		return op{s: tok, class: opPunct}
+	case "(", "[":
+		return op{s: tok, class: opOpen}
+	case ")", "]":
+		return op{s: tok, class: opClose}
+	case "~":
+		return space{width: "0.25em"}
+	}
+	r, _ := utf8.DecodeRuneInString(tok)
+	switch {
+	case unicode.IsLetter(r):
+		return ident{s: tok, plain: tok}
+	case unicode.IsDigit(r):
+		return number{s: tok, plain: tok}
+	}
+	return op{s: tok}
+}
+
+// argument parses the required argument of cmd: a group, a command or a
+// single character.
+func (p *parser) argument(cmd string) (node, error) {
+	line := p.line
+	switch tok := p.peek(); tok {
+	case "", "}", "&", "^", "_", `\\`, `\right`, `\middle`, `\end`:
+		return nil, p.errorf(line, `"%s" is missing an argument`, cmd)
+	case "{":
+		return p.atom()
+	default:
+		if tok[0] != '\\' {
+			p.next()
+			return char(tok), nil
+		}
+		n, err := p.atom()
+		if err == nil && n == nil {
+			return nil, p.errorf(line, `"%s" is missing an argument`, cmd)
+		}
+		return n, err
+	}
+}
+
+// rawArgument returns the text of a group argument of cmd, with escaped
+// characters unescaped, for commands whose argument is not math.
+func (p *parser) rawArgument(cmd string) (string, error) {
+	line := p.line
+	if p.peek() != "{" {
+		return "", p.errorf(line, `"%s" is missing an argument`, cmd)
+	}
+	p.next()
+
+	var b strings.Builder
+	for depth := 0; p.pos < len(p.src); p.pos++ {
+		c := p.src[p.pos]
+		switch {
+		case c == '\n':
+			p.line++
+		case c == '{':
+			depth++
+		case c == '}' && depth == 0:
+			p.pos++
+			return b.String(), nil
+		case c == '}':
+			depth--
+		case c == '\\' && p.pos+1 < len(p.src) && strings.IndexByte(`{}$%&#_ \`, p.src[p.pos+1]) >= 0:
+			p.pos++
+			c = p.src[p.pos]
+		}
+		b.WriteByte(c)
+	}
+	return "", p.errorf(line, `group is never closed, missing "}"`)
+}
+
+// delimiter parses the delimiter after cmd, such as \left.
+func (p *parser) delimiter(cmd string) (string, opClass, error) {
+	line := p.line
+	tok := p.next()
+	if tok == "" {
+		return "", 0, p.errorf(line, `"%s" is missing a delimiter`, cmd)
+	}
+	s, ok := delimiters[tok]
+	if !ok {
+		return "", 0, p.errorf(line, `"%s" is not a delimiter`, tok)
+	}
+	return s, delimiterClass(tok), nil
+}
+
+// command parses the node of a command read on line.
+func (p *parser) command(tok string, line int) (node, error) {
+	name := tok[1:]
+	switch name {
+	case "frac", "dfrac", "tfrac", "cfrac", "binom", "dbinom", "tbinom":
+		num, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		den, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		f := frac{num: num, den: den, binom: strings.HasSuffix(name, "binom")}
+		switch name[0] {
+		case 'd', 'c':
+			f.display = "true"
+		case 't':
+			f.display = "false"
+		}
+		return f, nil
+
+	case "sqrt":
+		var index node
+		if p.peek() == "[" {
+			p.next()
+			p.optional++
+			r, err := p.expression()
+			p.optional--
+			if err != nil {
+				return nil, err
+			}
+			if p.next() != "]" {
+				return nil, p.errorf(line, `root index is never closed, missing "]"`)
+			}
+			index = r
+		}
+		body, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return sqrt{body: body, index: index}, nil
+
+	case "left":
+		return p.fenced(line)
+
+	case "begin":
+		return p.environment(line)
+
+	case "operatorname", "operatorname*":
+		s, err := p.rawArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return function{name: s, limits: name == "operatorname*"}, nil
+
+	case "textcolor":
+		c, err := p.rawArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		body, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return color{color: c, body: body}, nil
+
+	case "not":
+		n, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return negate(n), nil
+
+	case "pmod":
+		arg, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return row{space{width: "1em"}, op{s: "(", class: opOpen}, ident{s: "mod", plain: "mod"},
+			space{width: "0.3333em"}, arg, op{s: ")", class: opClose}}, nil
+
+	case "mod":
+		return row{space{width: "1em"}, ident{s: "mod", plain: "mod"}, space{width: "0.3333em"}}, nil
+	}
+
+	if size, ok := bigSize(name); ok {
+		s, class, err := p.delimiter(tok)
+		if err != nil {
+			return nil, err
+		}
+		return op{s: s, class: class, size: size}, nil
+	}
+	if texts[name] {
+		s, err := p.rawArgument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return textNode{s: s}, nil
+	}
+	if font, ok := fonts[name]; ok {
+		arg, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return applyFont(arg, font), nil
+	}
+	if a, ok := accents[name]; ok {
+		base, err := p.argument(tok)
+		if err != nil {
+			return nil, err
+		}
+		return accent{base: base, mark: a.mark, combining: a.combining, stretchy: a.stretchy, under: a.under}, nil
+	}
+	if s, ok := letters[name]; ok {
+		return ident{s: s, plain: s, upright: unicode.IsUpper([]rune(s)[0])}, nil
+	}
+	if s, ok := symbols[name]; ok {
+		return op{s: s.s, class: s.class}, nil
+	}
+	if limits, ok := functions[name]; ok {
+		return function{name: name, limits: limits}, nil
+	}
+	if w, ok := spaces[name]; ok {
+		return space{width: w}, nil
+	}
+	if s, ok := delimiters[tok]; ok {
+		return op{s: s, class: delimiterClass(tok)}, nil
+	}
+	if ignored[name] {
+		return nil, nil
+	}
+	switch name {
+	case "right", "middle", "end", "\\":
+		return nil, p.errorf(line, `unexpected "%s"`, tok)
+	}
+	return nil, p.errorf(line, `unknown command "%s"`, tok)
+}
+
+// bigSize returns the height of a \big delimiter command, including the
+// \bigl, \bigr and \bigm forms.
+func bigSize(name string) (string, bool) {
+	if size, ok := bigSizes[name]; ok {
+		return size, true
+	}
+	if n := len(name); n > 1 && strings.IndexByte("lrm", name[n-1]) >= 0 {
+		size, ok := bigSizes[name[:n-1]]
+		return size, ok
+	}
+	return "", false
+}
+
+// negated are the relations with a negated character of their own.
+var negated = map[string]string{
+	"=": "≠", "∈": "∉", "⊂": "⊄", "⊆": "⊈", "≡": "≢", "∼": "≁", "≈": "≉",
+	"<": "≮", ">": "≯", "≤": "≰", "≥": "≱", "∃": "∄",
+}
+
+// negate returns n struck through, for \not.
+func negate(n node) node {
+	o, ok := n.(op)
+	if !ok {
+		return n
+	}
+	if s, ok := negated[o.s]; ok {
+		o.s = s
+	} else {
+		o.s += "̸"
+	}
+	return o
+}
+
+// fenced parses the group of a \left delimiter read on line, up to its
+// \right delimiter.
+func (p *parser) fenced(line int) (node, error) {
+	open, _, err := p.delimiter(`\left`)
+	if err != nil {
+		return nil, err
+	}
+	var body row
+	for {
+		r, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		body = append(body, r...)
+
+		switch p.peek() {
+		case `\middle`:
+			p.next()
+			s, _, err := p.delimiter(`\middle`)
+			if err != nil {
+				return nil, err
+			}
+			body = append(body, op{s: s, class: opRel, stretch: true})
+		case `\right`:
+			p.next()
+			s, _, err := p.delimiter(`\right`)
+			if err != nil {
+				return nil, err
+			}
+			return fenced{open: open, close: s, body: body}, nil
+		default:
+			return nil, p.errorf(line, `"\left" is never closed, missing "\right"`)
+		}
+	}
+}
+
+// environment parses the body of a \begin read on line, up to its \end.
+func (p *parser) environment(line int) (node, error) {
+	name, err := p.rawArgument(`\begin`)
+	if err != nil {
+		return nil, err
+	}
+	env, ok := environments[name]
+	if !ok {
+		return nil, p.errorf(line, `unknown environment "%s"`, name)
+	}
+	align := env.align
+	if name == "array" {
+		spec, err := p.rawArgument(`\begin{array}`)
+		if err != nil {
+			return nil, err
+		}
+		align = strings.Map(func(r rune) rune {
+			if r == 'l' || r == 'c' || r == 'r' {
+				return r
+			}
+			return -1
+		}, spec)
+	}
+
+	t, err := p.table()
+	if err != nil {
+		return nil, err
+	}
+	if p.peek() != `\end` {
+		return nil, p.errorf(line, `environment "%s" is never closed, missing "\end{%s}"`, name, name)
+	}
+	p.next()
+	endLine := p.line
+	end, err := p.rawArgument(`\end`)
+	if err != nil {
+		return nil, err
+	}
+	if end != name {
+		return nil, p.errorf(endLine, `"\end{%s}" does not match "\begin{%s}"`, end, name)
+	}
+
+	t.align = align
+	switch name {
+	case "matrix", "pmatrix", "bmatrix", "Bmatrix", "vmatrix", "Vmatrix", "array":
+		t.sep = ", "
+	default:
+		t.sep = " "
+		t.display = name != "cases"
+	}
+	if env.open == "" && env.close == "" {
+		return t, nil
+	}
+	return fenced{open: env.open, close: env.close, body: row{t}}, nil
+}
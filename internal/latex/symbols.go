@@ -0,0 +1,210 @@
+package latex
+
+// opClass is the TeX class of an operator, which decides its spacing.
+type opClass int
+
+const (
+	opOrd opClass = iota
+	opBin
+	opRel
+	opOpen
+	opClose
+	opPunct
+	opLarge
+)
+
+type symbol struct {
+	s     string
+	class opClass
+}
+
+// letters are commands for letter-like symbols, rendered as identifiers.
+// Uppercase Greek letters are upright.
+var letters = map[string]string{
+	"alpha": "α", "beta": "β", "gamma": "γ", "delta": "δ", "epsilon": "ϵ",
+	"varepsilon": "ε", "zeta": "ζ", "eta": "η", "theta": "θ", "vartheta": "ϑ",
+	"iota": "ι", "kappa": "κ", "varkappa": "ϰ", "lambda": "λ", "mu": "μ",
+	"nu": "ν", "xi": "ξ", "omicron": "ο", "pi": "π", "varpi": "ϖ", "rho": "ρ",
+	"varrho": "ϱ", "sigma": "σ", "varsigma": "ς", "tau": "τ", "upsilon": "υ",
+	"phi": "ϕ", "varphi": "φ", "chi": "χ", "psi": "ψ", "omega": "ω",
+
+	"Gamma": "Γ", "Delta": "Δ", "Theta": "Θ", "Lambda": "Λ", "Xi": "Ξ",
+	"Pi": "Π", "Sigma": "Σ", "Upsilon": "Υ", "Phi": "Φ", "Psi": "Ψ",
+	"Omega": "Ω",
+
+	"infty": "∞", "partial": "∂", "nabla": "∇", "ell": "ℓ", "hbar": "ℏ",
+	"emptyset": "∅", "varnothing": "∅", "aleph": "ℵ", "Re": "ℜ", "Im": "ℑ",
+	"wp": "℘", "imath": "ı", "jmath": "ȷ",
+}
+
+// symbols are commands for operators, relations, punctuation and large
+// operators.
+var symbols = map[string]symbol{
+	"pm": {"±", opBin}, "mp": {"∓", opBin}, "times": {"×", opBin},
+	"div": {"÷", opBin}, "cdot": {"⋅", opBin}, "ast": {"∗", opBin},
+	"star": {"⋆", opBin}, "circ": {"∘", opBin}, "bullet": {"∙", opBin},
+	"cap": {"∩", opBin}, "cup": {"∪", opBin}, "setminus": {"∖", opBin},
+	"wedge": {"∧", opBin}, "land": {"∧", opBin}, "vee": {"∨", opBin},
+	"lor": {"∨", opBin}, "oplus": {"⊕", opBin}, "ominus": {"⊖", opBin},
+	"otimes": {"⊗", opBin}, "odot": {"⊙", opBin}, "bmod": {"mod", opBin},
+
+	"leq": {"≤", opRel}, "le": {"≤", opRel}, "geq": {"≥", opRel},
+	"ge": {"≥", opRel}, "neq": {"≠", opRel}, "ne": {"≠", opRel},
+	"approx": {"≈", opRel}, "equiv": {"≡", opRel}, "sim": {"∼", opRel},
+	"simeq": {"≃", opRel}, "cong": {"≅", opRel}, "propto": {"∝", opRel},
+	"ll": {"≪", opRel}, "gg": {"≫", opRel}, "prec": {"≺", opRel},
+	"succ": {"≻", opRel}, "preceq": {"⪯", opRel}, "succeq": {"⪰", opRel},
+	"in": {"∈", opRel}, "notin": {"∉", opRel}, "ni": {"∋", opRel},
+	"subset": {"⊂", opRel}, "subseteq": {"⊆", opRel}, "supset": {"⊃", opRel},
+	"supseteq": {"⊇", opRel}, "to": {"→", opRel}, "rightarrow": {"→", opRel},
+	"leftarrow": {"←", opRel}, "gets": {"←", opRel},
+	"leftrightarrow": {"↔", opRel}, "Rightarrow": {"⇒", opRel},
+	"Leftarrow": {"⇐", opRel}, "Leftrightarrow": {"⇔", opRel},
+	"implies": {"⟹", opRel}, "impliedby": {"⟸", opRel}, "iff": {"⟺", opRel},
+	"longrightarrow": {"⟶", opRel}, "longleftarrow": {"⟵", opRel},
+	"mapsto": {"↦", opRel}, "uparrow": {"↑", opRel}, "downarrow": {"↓", opRel},
+	"perp": {"⊥", opRel}, "parallel": {"∥", opRel}, "mid": {"∣", opRel},
+	"models": {"⊨", opRel}, "vdash": {"⊢", opRel}, "coloneqq": {"≔", opRel},
+	"doteq": {"≐", opRel},
+
+	"neg": {"¬", opOrd}, "lnot": {"¬", opOrd}, "forall": {"∀", opOrd},
+	"exists": {"∃", opOrd}, "nexists": {"∄", opOrd}, "therefore": {"∴", opOrd},
+	"because": {"∵", opOrd}, "prime": {"′", opOrd}, "angle": {"∠", opOrd},
+	"triangle": {"△", opOrd}, "top": {"⊤", opOrd}, "bot": {"⊥", opOrd},
+	"dots": {"…", opOrd}, "ldots": {"…", opOrd}, "cdots": {"⋯", opOrd},
+	"vdots": {"⋮", opOrd}, "ddots": {"⋱", opOrd}, "degree": {"°", opOrd},
+	"%": {"%", opOrd}, "$": {"$", opOrd}, "#": {"#", opOrd},
+	"&": {"&", opOrd}, "_": {"_", opOrd},
+
+	"colon": {":", opPunct},
+
+	"sum": {"∑", opLarge}, "prod": {"∏", opLarge}, "coprod": {"∐", opLarge},
+	"bigcup": {"⋃", opLarge}, "bigcap": {"⋂", opLarge},
+	"bigoplus": {"⨁", opLarge}, "bigotimes": {"⨂", opLarge},
+	"bigvee": {"⋁", opLarge}, "bigwedge": {"⋀", opLarge},
+	"int": {"∫", opLarge}, "iint": {"∬", opLarge}, "iiint": {"∭", opLarge},
+	"oint": {"∮", opLarge},
+}
+
+// integrals are large operators whose scripts stay at the side in display
+// style.
+var integrals = map[string]bool{"∫": true, "∬": true, "∭": true, "∮": true}
+
+// delimiters maps the source of the delimiters accepted after \left,
+// \right and the \big family to the character drawn. "." is the invisible
+// delimiter.
+var delimiters = map[string]string{
+	"(": "(", ")": ")", "[": "[", "]": "]", "|": "|", "/": "/", ".": "",
+	"<": "⟨", ">": "⟩",
+	`\{`: "{", `\}`: "}", `\lbrace`: "{", `\rbrace`: "}", `\|`: "‖",
+	`\langle`: "⟨", `\rangle`: "⟩", `\lfloor`: "⌊", `\rfloor`: "⌋",
+	`\lceil`: "⌈", `\rceil`: "⌉", `\vert`: "|", `\Vert`: "‖",
+	`\lvert`: "|", `\rvert`: "|", `\lVert`: "‖", `\rVert`: "‖",
+	`\backslash`: "∖", `\uparrow`: "↑", `\downarrow`: "↓",
+}
+
+// delimiterClass returns whether a delimiter opens or closes a group.
+func delimiterClass(src string) opClass {
+	switch src {
+	case "(", "[", "<", `\{`, `\lbrace`, `\langle`, `\lfloor`, `\lceil`, `\lvert`, `\lVert`:
+		return opOpen
+	case ")", "]", ">", `\}`, `\rbrace`, `\rangle`, `\rfloor`, `\rceil`, `\rvert`, `\rVert`:
+		return opClose
+	}
+	return opOrd
+}
+
+// bigSizes are the heights of the \big family of delimiters.
+var bigSizes = map[string]string{
+	"big": "1.2em", "Big": "1.8em", "bigg": "2.4em", "Bigg": "3em",
+}
+
+// functions are upright operator names. Those mapped to true take their
+// scripts above and below in display style, like \lim.
+var functions = map[string]bool{
+	"sin": false, "cos": false, "tan": false, "cot": false, "sec": false,
+	"csc": false, "arcsin": false, "arccos": false, "arctan": false,
+	"sinh": false, "cosh": false, "tanh": false, "coth": false, "log": false,
+	"ln": false, "lg": false, "exp": false, "deg": false, "dim": false,
+	"ker": false, "hom": false, "arg": false,
+	"lim": true, "liminf": true, "limsup": true, "max": true, "min": true,
+	"sup": true, "inf": true, "det": true, "gcd": true, "Pr": true,
+	"argmax": true, "argmin": true,
+}
+
+// accents maps accent commands to the mark drawn over, or under, their
+// argument and the combining character used in plain text.
+var accents = map[string]struct {
+	mark, combining string
+	stretchy, under bool
+}{
+	"hat":            {"^", "̂", false, false},
+	"widehat":        {"^", "̂", true, false},
+	"bar":            {"¯", "̄", false, false},
+	"overline":       {"¯", "̅", true, false},
+	"vec":            {"→", "⃗", false, false},
+	"overrightarrow": {"→", "⃗", true, false},
+	"overleftarrow":  {"←", "⃖", true, false},
+	"tilde":          {"~", "̃", false, false},
+	"widetilde":      {"~", "̃", true, false},
+	"dot":            {"˙", "̇", false, false},
+	"ddot":           {"¨", "̈", false, false},
+	"check":          {"ˇ", "̌", false, false},
+	"breve":          {"˘", "̆", false, false},
+	"acute":          {"´", "́", false, false},
+	"grave":          {"`", "̀", false, false},
+	"underline":      {"_", "̲", true, true},
+	"overbrace":      {"⏞", "", true, false},
+	"underbrace":     {"⏟", "", true, true},
+}
+
+// spaces maps spacing commands to their width.
+var spaces = map[string]string{
+	",": "0.1667em", "thinspace": "0.1667em", ":": "0.2222em",
+	">": "0.2222em", "medspace": "0.2222em", ";": "0.2778em",
+	"thickspace": "0.2778em", " ": "0.25em", "quad": "1em", "qquad": "2em",
+	"enspace": "0.5em", "!": "0", "negthinspace": "0",
+}
+
+// ignored are commands that have no effect on the rendered formula.
+var ignored = map[string]bool{
+	"hline": true, "nonumber": true, "notag": true, "limits": true,
+	"nolimits": true, "displaystyle": true, "textstyle": true,
+}
+
+// fonts are the commands that change the font of their argument.
+var fonts = map[string]string{
+	"mathrm": "normal", "mathit": "italic", "mathbf": "bold",
+	"boldsymbol": "bold-italic", "bm": "bold-italic",
+	"mathbb": "double-struck", "mathcal": "script", "mathscr": "script",
+	"mathfrak": "fraktur", "mathsf": "sans-serif", "mathtt": "monospace",
+}
+
+// texts are the commands whose argument is text rather than math.
+var texts = map[string]bool{
+	"text": true, "textrm": true, "textnormal": true, "textit": true,
+	"textbf": true, "textsf": true, "texttt": true, "mbox": true,
+	"textup": true,
+}
+
+// environments maps the supported environments to their delimiters and
+// column alignment, where "" repeats centered columns.
+var environments = map[string]struct {
+	open, close, align string
+}{
+	"matrix":   {"", "", ""},
+	"pmatrix":  {"(", ")", ""},
+	"bmatrix":  {"[", "]", ""},
+	"Bmatrix":  {"{", "}", ""},
+	"vmatrix":  {"|", "|", ""},
+	"Vmatrix":  {"‖", "‖", ""},
+	"cases":    {"{", "", "ll"},
+	"aligned":  {"", "", "rl"},
+	"align":    {"", "", "rl"},
+	"align*":   {"", "", "rl"},
+	"split":    {"", "", "rl"},
+	"gathered": {"", "", ""},
+	"gather":   {"", "", ""},
+	"gather*":  {"", "", ""},
+	"array":    {"", "", ""},
+}
@@ -0,0 +1,75 @@
+// Package lint reports problems in the specs of a folder.
+package lint
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/contract"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+)
+
+// Issue is a problem at a 1-based line of a spec.
+type Issue struct {
+	File    string
+	Line    int
+	Rule    string
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s:%d: %s (%s)", i.File, i.Line, i.Message, i.Rule)
+}
+
+// Folder lints every markdown file and contract under folder, skipping
+// hidden files and directories as the sidebar does. Issue paths are relative to folder.
+func Folder(folder string) ([]Issue, error) {
+	var issues []Issue
+	err := filepath.WalkDir(folder, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if path != folder && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(folder, path)
+		if err != nil {
+			return err
+		}
+		if !strings.HasSuffix(d.Name(), ".md") && !contract.IsContract(filepath.ToSlash(rel)) {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		issues = append(issues, File(folder, filepath.ToSlash(rel), content)...)
+		return nil
+	})
+	return issues, err
+}
+
+// File lints the content of the spec name, a slash-separated path relative
+// to folder. Includes are resolved against the other specs of folder.
+func File(folder, name string, content []byte) []Issue {
+	var issues []Issue
+	if contract.Supported(name) {
+		if _, err := contract.Parse(name, content); err != nil {
+			issues = append(issues, Issue{File: name, Line: 1, Rule: "contract", Message: err.Error()})
+		}
+	}
+	for _, d := range markdown.ParseFile(folder, name, content).Diagnostics() {
+		issues = append(issues, Issue{File: name, Line: d.Line, Rule: d.Language, Message: d.Message})
+	}
+	return issues
+}
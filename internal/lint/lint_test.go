@@ -0,0 +1,55 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFolder(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	broken := "# Flow\n\n```mermaid\nflowchart TD\n  A -->\n```\n"
+	write("ok.md", "# Fine\n\n```mermaid\ngraph LR\n  A --> B\n```\n")
+	write("nested/broken.md", broken)
+	write(".hidden/broken.md", broken)
+	write("notes.txt", broken)
+	write("plan.md", "# Plan\n\n![[ok.md#Fine]]\n\n{{< include \"missing.md\" >}}\n")
+	write("contracts/api.yaml", "openapi: 3.0.0\ninfo: {title: API}\npaths: {}\n")
+	write("contracts/config.yaml", "port: 8080\n")
+	write("config.yaml", "port: 8080\n")
+	write("links.md", "# Links\n\n[[ok]], [[Fine]] and [[Missing Spec]]\n")
+
+	issues, err := Folder(dir)
+	if err != nil {
+		t.Fatalf("Folder returned error: %v", err)
+	}
+	want := []string{
+		"contracts/config.yaml:1: not an OpenAPI document or a JSON Schema (contract)",
+		`links.md:3: no spec or heading named "Missing Spec" (link)`,
+		"nested/broken.md:5: link has no target node (mermaid)",
+		`plan.md:5: file "missing.md" not found (include)`,
+	}
+	if len(issues) != len(want) {
+		t.Fatalf("expected %d issues, got %v", len(want), issues)
+	}
+	for i := range want {
+		if issues[i].String() != want[i] {
+			t.Errorf("expected %q, got %q", want[i], issues[i].String())
+		}
+	}
+}
+
+func TestFolder_Missing(t *testing.T) {
+	if _, err := Folder(filepath.Join(t.TempDir(), "missing")); err == nil {
+		t.Error("expected error for missing folder")
+	}
+}
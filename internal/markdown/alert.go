@@ -0,0 +1,142 @@
+package markdown
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindAlert is the kind of Alert nodes.
+var KindAlert = ast.NewNodeKind("Alert")
+
+// alertTitles maps the alert types GitHub supports to their titles.
+var alertTitles = map[string]string{
+	"note":      "Note",
+	"tip":       "Tip",
+	"important": "Important",
+	"warning":   "Warning",
+	"caution":   "Caution",
+}
+
+// Alert is a blockquote whose first line is a marker such as [!NOTE] or
+// [!WARNING]. Its children are the blocks of the quote without the marker,
+// and its only line is the marker's.
+type Alert struct {
+	ast.BaseBlock
+	// AlertType is the lowercase alert type, such as "note" or "warning".
+	AlertType string
+}
+
+func (n *Alert) Kind() ast.NodeKind { return KindAlert }
+
+func (n *Alert) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"AlertType": n.AlertType}, nil)
+}
+
+// Title returns the title the alert is rendered with, such as "Warning".
+func (n *Alert) Title() string {
+	return alertTitles[n.AlertType]
+}
+
+// alertExtension turns blockquotes that start with an alert marker into
+// Alert nodes, rendered as callouts like GitHub does.
+type alertExtension struct{}
+
+func (alertExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(alertExtension{}, 500),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(alertRenderer{}, 100),
+	))
+}
+
+func (alertExtension) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var quotes []*ast.Blockquote
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if q, ok := n.(*ast.Blockquote); ok && entering {
+			quotes = append(quotes, q)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	source := reader.Source()
+	for _, q := range quotes {
+		p, ok := q.FirstChild().(*ast.Paragraph)
+		if !ok || p.Lines().Len() == 0 {
+			continue
+		}
+		marker := p.Lines().At(0)
+		typ := alertType(marker.Value(source))
+		if typ == "" {
+			continue
+		}
+
+		// Drop the marker from the paragraph, and the paragraph itself when
+		// the marker was all it held.
+		for c := p.FirstChild(); c != nil; {
+			t, ok := c.(*ast.Text)
+			if !ok || t.Segment.Start >= marker.Stop {
+				break
+			}
+			next := c.NextSibling()
+			p.RemoveChild(p, c)
+			c = next
+		}
+		rest := text.NewSegments()
+		rest.AppendAll(p.Lines().Sliced(1, p.Lines().Len()))
+		p.SetLines(rest)
+		if !p.HasChildren() {
+			q.RemoveChild(q, p)
+		}
+
+		alert := &Alert{AlertType: typ}
+		alert.Lines().Append(marker)
+		for c := q.FirstChild(); c != nil; {
+			next := c.NextSibling()
+			alert.AppendChild(alert, c)
+			c = next
+		}
+		q.Parent().ReplaceChild(q.Parent(), q, alert)
+	}
+}
+
+// alertType returns the type named by an alert marker line such as
+// "[!NOTE]", or "" if line is not one.
+func alertType(line []byte) string {
+	line = util.TrimRightSpace(line)
+	if !bytes.HasPrefix(line, []byte("[!")) || !bytes.HasSuffix(line, []byte("]")) {
+		return ""
+	}
+	typ := strings.ToLower(string(line[2 : len(line)-1]))
+	if _, ok := alertTitles[typ]; !ok {
+		return ""
+	}
+	return typ
+}
+
+type alertRenderer struct{}
+
+func (r alertRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindAlert, r.renderAlert)
+}
+
+func (r alertRenderer) renderAlert(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*Alert)
+	if !entering {
+		_, _ = w.WriteString("</div>\n")
+		return ast.WalkContinue, nil
+	}
+	_, _ = w.WriteString(`<div class="markdown-alert markdown-alert-` + n.AlertType + `"`)
+	html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+	_, _ = w.WriteString(">\n")
+	_, _ = w.WriteString(`<p class="markdown-alert-title">` + n.Title() + "</p>\n")
+	return ast.WalkContinue, nil
+}
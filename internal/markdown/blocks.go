@@ -0,0 +1,238 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+)
+
+// Block is a top-level block of a document together with the 1-based,
+// inclusive range of source lines it was parsed from.
+type Block struct {
+	Index     int
+	StartLine int
+	EndLine   int
+	Kind      string
+	Text      string
+	// Hash identifies the block's source independently of its position.
+	Hash string
+
+	node ast.Node
+}
+
+// Blocks returns the top-level blocks of the document in source order.
+func (d *Document) Blocks() []Block {
+	return blocksOf(d.Root, d.Source)
+}
+
+func blocksOf(root ast.Node, source []byte) []Block {
+	lines := newLineIndex(source)
+
+	var blocks []Block
+	for n := root.FirstChild(); n != nil; n = n.NextSibling() {
+		start, end := segmentLines(n, lines)
+		blocks = append(blocks, Block{
+			Index:     len(blocks),
+			StartLine: start,
+			EndLine:   end,
+			Kind:      n.Kind().String(),
+			Text:      strings.TrimSpace(nodeText(n, source)),
+			node:      n,
+		})
+	}
+
+	// Widen each block up to the line before the next block (minus trailing
+	// blank lines) so that syntax goldmark keeps no segments for, such as
+	// setext underlines, is attributed to the block it belongs to. Blocks
+	// without any segments (thematic breaks) start at the first non-blank
+	// line after their predecessor.
+	prevEnd := 0
+	for i := range blocks {
+		b := &blocks[i]
+		if b.StartLine == 0 {
+			b.StartLine = lines.nextNonBlank(prevEnd + 1)
+		}
+
+		limit := lines.count()
+		for j := i + 1; j < len(blocks); j++ {
+			if blocks[j].StartLine > 0 {
+				limit = blocks[j].StartLine - 1
+				break
+			}
+		}
+		if i+1 < len(blocks) && blocks[i+1].StartLine == 0 && b.EndLine > 0 {
+			// The next block has no segments of its own; leave the gap to it.
+			limit = b.EndLine
+		}
+		if end := lines.prevNonBlank(limit); end > b.EndLine {
+			b.EndLine = end
+		}
+		if b.EndLine < b.StartLine {
+			b.EndLine = b.StartLine
+		}
+		prevEnd = b.EndLine
+
+		src := lines.slice(b.StartLine, b.EndLine)
+		b.Hash = contentHash(src)
+		if b.Text == "" {
+			// Code blocks carry no inline text; fall back to their source.
+			b.Text = strings.TrimSpace(src)
+		}
+	}
+
+	return blocks
+}
+
+// SourceLines returns the source text for the 1-based, inclusive line range.
+func (d *Document) SourceLines(start, end int) string {
+	return newLineIndex(d.Source).slice(start, end)
+}
+
+// contentHash returns a short hash of s that ignores differences in
+// whitespace, so reflowing a paragraph keeps its identity.
+func contentHash(s string) string {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(strings.Join(strings.Fields(s), " ")))
+	return fmt.Sprintf("%08x", h.Sum32())
+}
+
+// segmentLines returns the smallest and largest source line covered by any
+// segment of n or its descendants, or zeros when n carries no segments.
+func segmentLines(n ast.Node, lines lineIndex) (int, int) {
+	start, end := 0, 0
+	add := func(from, to int) {
+		if to > from {
+			to-- // Segment stops are exclusive.
+		}
+		s, e := lines.lineOf(from), lines.lineOf(to)
+		if start == 0 || s < start {
+			start = s
+		}
+		if e > end {
+			end = e
+		}
+	}
+
+	_ = ast.Walk(n, func(cn ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		if cn.Type() == ast.TypeBlock {
+			segs := cn.Lines()
+			for i := 0; i < segs.Len(); i++ {
+				seg := segs.At(i)
+				add(seg.Start, seg.Stop)
+			}
+		}
+		if t, ok := cn.(*ast.Text); ok {
+			add(t.Segment.Start, t.Segment.Stop)
+		}
+		if fc, ok := cn.(*ast.FencedCodeBlock); ok && fc.Lines().Len() > 0 {
+			// Fences sit on the lines around the content.
+			segs := fc.Lines()
+			if l := lines.lineOf(segs.At(0).Start) - 1; l >= 1 && l < start {
+				start = l
+			}
+			last := lines.lineOf(segs.At(segs.Len() - 1).Start)
+			if l := last + 1; l <= lines.count() && lines.fence(l) && l > end {
+				end = l
+			}
+		} else if ok && fc.Info != nil {
+			add(fc.Info.Segment.Start, fc.Info.Segment.Stop)
+		}
+		if mb, ok := cn.(*MathBlock); ok {
+			// The opening delimiter may sit on a line of its own.
+			add(mb.Start, mb.Start+2)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	return start, end
+}
+
+// lineIndex maps byte offsets of a source to 1-based line numbers.
+type lineIndex struct {
+	source []byte
+	starts []int
+}
+
+func newLineIndex(source []byte) lineIndex {
+	starts := []int{0}
+	for i, c := range source {
+		if c == '\n' && i+1 < len(source) {
+			starts = append(starts, i+1)
+		}
+	}
+	return lineIndex{source: source, starts: starts}
+}
+
+func (l lineIndex) count() int {
+	if len(l.source) == 0 {
+		return 0
+	}
+	return len(l.starts)
+}
+
+func (l lineIndex) lineOf(offset int) int {
+	return sort.Search(len(l.starts), func(i int) bool { return l.starts[i] > offset })
+}
+
+// slice returns the source text for the 1-based, inclusive line range.
+func (l lineIndex) slice(start, end int) string {
+	if start < 1 || end < start || start > l.count() {
+		return ""
+	}
+	if end > l.count() {
+		end = l.count()
+	}
+	from := l.starts[start-1]
+	to := len(l.source)
+	if end < l.count() {
+		to = l.starts[end]
+	}
+	return strings.TrimRight(string(l.source[from:to]), "\n")
+}
+
+// text returns the trimmed content of a 1-based line.
+func (l lineIndex) text(line int) []byte {
+	from := l.starts[line-1]
+	to := len(l.source)
+	if line < len(l.starts) {
+		to = l.starts[line]
+	}
+	return bytes.TrimSpace(l.source[from:to])
+}
+
+func (l lineIndex) blank(line int) bool {
+	return len(l.text(line)) == 0
+}
+
+func (l lineIndex) fence(line int) bool {
+	t := l.text(line)
+	return bytes.HasPrefix(t, []byte("```")) || bytes.HasPrefix(t, []byte("~~~"))
+}
+
+func (l lineIndex) nextNonBlank(line int) int {
+	for ; line >= 1 && line <= l.count(); line++ {
+		if !l.blank(line) {
+			return line
+		}
+	}
+	return 0
+}
+
+func (l lineIndex) prevNonBlank(line int) int {
+	if line > l.count() {
+		line = l.count()
+	}
+	for ; line >= 1; line-- {
+		if !l.blank(line) {
+			return line
+		}
+	}
+	return 0
+}
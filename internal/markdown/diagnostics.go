@@ -0,0 +1,96 @@
+package markdown
+
+import (
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/diagram"
+	"github.com/SantiagoBobrik/spec-viewer/internal/mermaid"
+	"github.com/yuin/goldmark/ast"
+)
+
+// Diagnostic is a problem found in a document, at a 1-based source line.
+// Language is the language of the diagram it was found in, "math" for
+// formulas, "include" for includes that failed or "link" for wiki links
+// that could not be resolved.
+type Diagnostic struct {
+	Line     int
+	Language string
+	Message  string
+}
+
+// Diagnostics returns the syntax errors of the document's Mermaid, D2 and
+// Graphviz diagrams and of its formulas, its failed includes and its broken
+// wiki links, with lines
+// relative to the markdown source. Problems inside included specs are left
+// to the diagnostics of those specs.
+func (d *Document) Diagnostics() []Diagnostic {
+	var diags []Diagnostic
+	lines := newLineIndex(d.Source)
+
+	_ = ast.Walk(d.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.FencedCodeBlock:
+			diags = append(diags, fenceDiagnostics(n, d.Source, lines)...)
+		case *Math, *MathBlock:
+			diags = append(diags, mathDiagnostics(n, d.Source, lines)...)
+		case *Include:
+			diags = append(diags, includeDiagnostics(n, lines)...)
+		case *WikiLink:
+			diags = append(diags, wikiLinkDiagnostics(n, lines)...)
+		}
+		return ast.WalkContinue, nil
+	})
+	return diags
+}
+
+// fenceDiagnostics validates fc if it is a diagram or a formula.
+func fenceDiagnostics(fc *ast.FencedCodeBlock, source []byte, lines lineIndex) []Diagnostic {
+	lang := string(fc.Language(source))
+	if lang == "math" {
+		return mathDiagnostics(fc, source, lines)
+	}
+
+	var errs []Diagnostic
+	switch {
+	case lang == "mermaid":
+		for _, err := range mermaid.Validate(fenceText(fc, source)) {
+			errs = append(errs, Diagnostic{Line: err.Line, Language: lang, Message: err.Message})
+		}
+	case diagram.Supported(lang):
+		for _, err := range diagram.Compile(lang, fenceText(fc, source)).Errors {
+			errs = append(errs, Diagnostic{Line: err.Line, Language: lang, Message: err.Message})
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+
+	// Diagram lines count from the first line after the opening fence. An
+	// empty diagram reports its errors on the fence.
+	first := lines.lineOf(fc.Info.Segment.Start) + 1
+	if fc.Lines().Len() > 0 {
+		first = lines.lineOf(fc.Lines().At(0).Start)
+	}
+	for i := range errs {
+		if fc.Lines().Len() == 0 {
+			errs[i].Line = first - 1
+		} else {
+			errs[i].Line += first - 1
+		}
+	}
+	return errs
+}
+
+// fenceText returns the contents of a fenced code block.
+func fenceText(fc *ast.FencedCodeBlock, source []byte) string {
+	var b strings.Builder
+	for i := 0; i < fc.Lines().Len(); i++ {
+		line := fc.Lines().At(i)
+		b.Write(line.Value(source))
+	}
+	return b.String()
+}
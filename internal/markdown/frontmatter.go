@@ -0,0 +1,151 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"gopkg.in/yaml.v3"
+)
+
+// KindFrontMatter is the kind of FrontMatter nodes.
+var KindFrontMatter = ast.NewNodeKind("FrontMatter")
+
+// FrontMatter is the YAML block between --- lines that may open a spec. Its
+// lines hold the YAML without the delimiters. It is not rendered.
+type FrontMatter struct {
+	ast.BaseBlock
+	// Meta holds the fields the viewer reads; it is empty when they have
+	// the wrong type.
+	Meta Meta
+}
+
+func (n *FrontMatter) Kind() ast.NodeKind { return KindFrontMatter }
+
+func (n *FrontMatter) IsRaw() bool { return true }
+
+func (n *FrontMatter) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Title": n.Meta.Title}, nil)
+}
+
+// Meta is the front matter of a spec.
+type Meta struct {
+	// Title replaces the first H1 as the title of the spec in the sidebar.
+	Title string `yaml:"title"`
+	// Order places the spec among its siblings in the sidebar; specs
+	// without one follow those that have it.
+	Order *int `yaml:"order"`
+}
+
+// frontMatterExtension parses front matter and renders nothing for it.
+type frontMatterExtension struct{}
+
+func (frontMatterExtension) Extend(m goldmark.Markdown) {
+	// Before the thematic break and setext heading parsers, which also
+	// start at "-".
+	m.Parser().AddOptions(parser.WithBlockParsers(util.Prioritized(frontMatterParser{}, 50)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(frontMatterRenderer{}, 100)))
+}
+
+type frontMatterParser struct{}
+
+func (frontMatterParser) Trigger() []byte { return []byte{'-'} }
+
+// Open takes a --- line as the start of front matter only on the first line
+// of the document and when a closing --- or ... line follows a YAML
+// mapping, so documents opening with a thematic break are unchanged.
+func (frontMatterParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	if segment.Start != 0 || parent.Kind() != ast.KindDocument || !isDelimiter(line, false) {
+		return nil, parser.NoChildren
+	}
+	source := reader.Source()
+	var lines []text.Segment
+	for start := segment.Stop; start < len(source); {
+		end := bytes.IndexByte(source[start:], '\n') + 1
+		if end == 0 {
+			end = len(source) - start
+		}
+		if isDelimiter(source[start:start+end], true) {
+			if !isMapping(source[segment.Stop:start]) {
+				break
+			}
+			node := &FrontMatter{}
+			for _, l := range lines {
+				node.Lines().Append(l)
+			}
+			_ = yaml.Unmarshal(node.Lines().Value(source), &node.Meta)
+			reader.Advance(start - segment.Start)
+			reader.AdvanceToEOL()
+			return node, parser.NoChildren
+		}
+		lines = append(lines, text.NewSegment(start, start+end))
+		start += end
+	}
+	return nil, parser.NoChildren
+}
+
+func (frontMatterParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (frontMatterParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (frontMatterParser) CanInterruptParagraph() bool { return false }
+
+func (frontMatterParser) CanAcceptIndentedLine() bool { return false }
+
+// isMapping reports whether data is a YAML mapping, or blank, as front
+// matter is. Markdown between two thematic breaks, such as a heading, which
+// YAML reads as a comment, and a paragraph, is not.
+func isMapping(data []byte) bool {
+	if len(bytes.TrimSpace(data)) == 0 {
+		return true
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return false
+	}
+	return len(doc.Content) == 1 && doc.Content[0].Kind == yaml.MappingNode
+}
+
+// isDelimiter reports whether line opens front matter (---) or, if closing
+// is set, ends it (--- or ...).
+func isDelimiter(line []byte, closing bool) bool {
+	line = util.TrimRightSpace(line)
+	return bytes.Equal(line, []byte("---")) || closing && bytes.Equal(line, []byte("..."))
+}
+
+type frontMatterRenderer struct{}
+
+func (frontMatterRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindFrontMatter, func(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+		return ast.WalkSkipChildren, nil
+	})
+}
+
+// Meta returns the front matter of the document, if it has any.
+func (d *Document) Meta() Meta {
+	if fm, ok := d.Root.FirstChild().(*FrontMatter); ok {
+		return fm.Meta
+	}
+	return Meta{}
+}
+
+// Title returns the title of the document: the title of its front matter,
+// or else the text of its first H1.
+func (d *Document) Title() string {
+	if title := d.Meta().Title; title != "" {
+		return title
+	}
+	for _, h := range d.TOC() {
+		if h.Level == 1 {
+			return h.Text
+		}
+	}
+	return ""
+}
@@ -0,0 +1,394 @@
+package markdown
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/contract"
+	"github.com/SantiagoBobrik/spec-viewer/internal/private"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindInclude is the kind of Include nodes.
+var KindInclude = ast.NewNodeKind("Include")
+
+// includePatterns match the lines that include another spec, in Hugo
+// shortcode or Obsidian embed syntax.
+var includePatterns = []*regexp.Regexp{
+	regexp.MustCompile(`^\{\{<\s*include\s+"([^"]+)"\s*>\}\}$`),
+	regexp.MustCompile(`^!\[\[([^\[\]]+)\]\]$`),
+}
+
+// Include is a line that inlines another spec, or a section of it, such as
+// {{< include "spec.md#data-model" >}} or ![[spec.md#Data Model]]. Contracts
+// are included whole or by operation or schema, as in
+// ![[contracts/api.yaml#createPayment]], and other artifacts whole. Its
+// only line is the directive.
+type Include struct {
+	ast.BaseBlock
+	// Target is the included file and section as written.
+	Target string
+	// File is the included file, a slash-separated path relative to the
+	// spec folder. It is empty when the target is outside the folder.
+	File string
+	// Doc holds the included blocks, or is nil when the include failed.
+	Doc *Document
+	// Err says why the include failed.
+	Err string
+
+	// cycle is set when the include failed because it is part of a cycle.
+	cycle bool
+}
+
+func (n *Include) Kind() ast.NodeKind { return KindInclude }
+
+func (n *Include) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target, "Err": n.Err}, nil)
+}
+
+// includeTarget returns the target of an include directive line, or "" if
+// line is not one.
+func includeTarget(line []byte) string {
+	line = util.TrimRightSpace(util.TrimLeftSpace(line))
+	for _, re := range includePatterns {
+		if m := re.FindSubmatch(line); m != nil {
+			return strings.TrimSpace(string(m[1]))
+		}
+	}
+	return ""
+}
+
+// resolveInclude splits target into the file it names, relative to the
+// spec folder, and the section after "#". Files are relative to the
+// directory of the including spec name. The file is empty when it is
+// outside the folder.
+func resolveInclude(name, target string) (file, section string) {
+	file, section, _ = strings.Cut(target, "#")
+	if file == "" {
+		file = name
+	} else {
+		file = path.Join(path.Dir(name), file)
+	}
+	if !filepath.IsLocal(filepath.FromSlash(file)) {
+		file = ""
+	}
+	return file, strings.TrimSpace(section)
+}
+
+// Includes returns the files the spec name includes directly, as
+// slash-separated paths relative to the spec folder. It only scans source
+// for directives, so it is cheap enough to run on every file of a folder.
+func Includes(name string, source []byte) []string {
+	var files []string
+	seen := make(map[string]bool)
+	for _, line := range bytes.Split(source, []byte("\n")) {
+		target := includeTarget(line)
+		if target == "" {
+			continue
+		}
+		if file, _ := resolveInclude(name, target); file != "" && !seen[file] {
+			seen[file] = true
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// includeKey holds the includeState of a parse.
+var includeKey = parser.NewContextKey()
+
+// includeState locates the spec being parsed so its includes can be read.
+type includeState struct {
+	// root is the spec folder.
+	root string
+	// stack lists the specs being parsed, outermost first, to detect
+	// cycles.
+	stack []string
+}
+
+// includeExtension parses include directives and replaces them with the
+// blocks they include.
+type includeExtension struct{}
+
+func (includeExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(includeParser{}, 150)),
+		parser.WithASTTransformers(util.Prioritized(includeExtension{}, 600)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(includeRenderer{}, 100),
+	))
+}
+
+type includeParser struct{}
+
+func (includeParser) Trigger() []byte { return []byte{'{', '!'} }
+
+func (includeParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 {
+		return nil, parser.NoChildren
+	}
+	target := includeTarget(line[pos:])
+	if target == "" {
+		return nil, parser.NoChildren
+	}
+	node := &Include{Target: target}
+	node.Lines().Append(text.NewSegment(segment.Start+pos, segment.Stop))
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (includeParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	return parser.Close
+}
+
+func (includeParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (includeParser) CanInterruptParagraph() bool { return true }
+
+func (includeParser) CanAcceptIndentedLine() bool { return false }
+
+func (includeExtension) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	var includes []*Include
+	_ = ast.Walk(doc, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if inc, ok := n.(*Include); ok && entering {
+			includes = append(includes, inc)
+		}
+		return ast.WalkContinue, nil
+	})
+	if len(includes) == 0 {
+		return
+	}
+
+	state, _ := pc.Get(includeKey).(*includeState)
+	for _, inc := range includes {
+		if state == nil {
+			inc.Err = "includes are only resolved in files of the spec folder"
+			continue
+		}
+		resolve(inc, state, pc)
+	}
+}
+
+// resolve reads and parses the target of inc, keeping the section it
+// names. Heading IDs of the included blocks are made unique within the
+// including document.
+func resolve(inc *Include, state *includeState, pc parser.Context) {
+	name := state.stack[len(state.stack)-1]
+	file, section := resolveInclude(name, inc.Target)
+	if file == "" {
+		inc.Err = fmt.Sprintf(`"%s" is outside the spec folder`, inc.Target)
+		return
+	}
+	inc.File = file
+	for i, f := range state.stack {
+		if f == file {
+			inc.Err = "include cycle: " + strings.Join(append(state.stack[i:], file), " → ")
+			inc.cycle = true
+			return
+		}
+	}
+
+	source, err := private.ReadFile(state.root, filepath.FromSlash(file))
+	if errors.Is(err, private.ErrPrivate) {
+		inc.Err = fmt.Sprintf(`file "%s" is private`, file)
+		return
+	}
+	if err != nil {
+		inc.Err = fmt.Sprintf(`file "%s" not found`, file)
+		return
+	}
+	switch {
+	case contract.IsContract(file):
+		// Sections of contracts are operations and schemas.
+		if source, err = contractSection(file, source, section); err != nil {
+			inc.Err = err.Error()
+			return
+		}
+		section = ""
+	case artifact.Supported(file):
+		if section != "" {
+			inc.Err = fmt.Sprintf("%s has no sections", file)
+			return
+		}
+		source = artifact.Block(file, source)
+	}
+	included := parseFile(source, &includeState{root: state.root, stack: append(state.stack[:len(state.stack):len(state.stack)], file)})
+	// A cycle cannot be inlined, so every include along it fails.
+	if cycle := cycleOf(included); cycle != nil {
+		inc.Err, inc.cycle = cycle.Err, true
+		return
+	}
+
+	if section != "" {
+		root, ok := sectionOf(included, section)
+		if !ok {
+			inc.Err = fmt.Sprintf(`section "%s" not found in %s`, section, file)
+			return
+		}
+		included.Root = root
+	}
+
+	for n := included.Root.FirstChild(); n != nil; n = n.NextSibling() {
+		dropSourceAttributes(n)
+	}
+	eachHeading(included, func(h *ast.Heading) {
+		if id, ok := h.AttributeString("id"); ok {
+			if b, ok := id.([]byte); ok {
+				h.SetAttributeString("id", pc.IDs().Generate(b, ast.KindHeading))
+			}
+		}
+	})
+	inc.Doc = included
+}
+
+// contractSection documents the contract file, or the operation or schema
+// of it named by section, as markdown.
+func contractSection(file string, source []byte, section string) ([]byte, error) {
+	c, err := contract.Parse(file, source)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+	if section == "" {
+		return c.Markdown(), nil
+	}
+	md, err := c.Section(section)
+	if err != nil {
+		return nil, fmt.Errorf("%w in %s", err, file)
+	}
+	return md, nil
+}
+
+// cycleOf returns an include of doc that failed because of a cycle, if any.
+func cycleOf(doc *Document) *Include {
+	var cycle *Include
+	_ = ast.Walk(doc.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if inc, ok := n.(*Include); ok && entering && inc.cycle {
+			cycle = inc
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return cycle
+}
+
+// sectionOf moves the section of doc under the top-level heading whose ID
+// or text is section into a new document root: the heading and the blocks
+// up to the next heading of the same or a higher level.
+func sectionOf(doc *Document, section string) (ast.Node, bool) {
+	for n := doc.Root.FirstChild(); n != nil; n = n.NextSibling() {
+		h, ok := n.(*ast.Heading)
+		if !ok {
+			continue
+		}
+		id, _ := h.AttributeString("id")
+		b, _ := id.([]byte)
+		if string(b) != section && !strings.EqualFold(nodeText(h, doc.Source), section) {
+			continue
+		}
+
+		root := ast.NewDocument()
+		for c := ast.Node(h); c != nil; {
+			next := c.NextSibling()
+			if other, ok := c.(*ast.Heading); ok && c != h && other.Level <= h.Level {
+				break
+			}
+			root.AppendChild(root, c)
+			c = next
+		}
+		return root, true
+	}
+	return nil, false
+}
+
+// dropSourceAttributes removes the source position attributes of an
+// included block, which refer to the included file rather than to the
+// including one.
+func dropSourceAttributes(n ast.Node) {
+	attrs := n.Attributes()
+	n.RemoveAttributes()
+	for _, a := range attrs {
+		switch string(a.Name) {
+		case AttrSourceLine, AttrSourceLineEnd, AttrSourceHash:
+		default:
+			n.SetAttribute(a.Name, a.Value)
+		}
+	}
+}
+
+// eachHeading calls fn for every heading of doc, including those of the
+// documents it includes.
+func eachHeading(doc *Document, fn func(*ast.Heading)) {
+	_ = ast.Walk(doc.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch n := n.(type) {
+		case *ast.Heading:
+			fn(n)
+		case *Include:
+			if n.Doc != nil {
+				eachHeading(n.Doc, fn)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+type includeRenderer struct{}
+
+func (r includeRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindInclude, r.renderInclude)
+}
+
+// renderInclude writes the included blocks, or the directive followed by
+// the reason it failed.
+func (r includeRenderer) renderInclude(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*Include)
+	if n.Doc == nil {
+		_, _ = w.WriteString(`<p class="include-error" role="alert"`)
+		html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+		_, _ = w.WriteString("><code>")
+		_, _ = w.Write(util.EscapeHTML(util.TrimRightSpace(n.Lines().Value(source))))
+		_, _ = w.WriteString("</code> ")
+		_, _ = w.Write(util.EscapeHTML([]byte(n.Err)))
+		_, _ = w.WriteString("</p>\n")
+		return ast.WalkSkipChildren, nil
+	}
+
+	_, _ = w.WriteString(`<div class="include" data-include="`)
+	_, _ = w.Write(util.EscapeHTML([]byte(n.File)))
+	_ = w.WriteByte('"')
+	html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+	_, _ = w.WriteString(">\n")
+	if err := n.Doc.Render(w); err != nil {
+		return ast.WalkStop, err
+	}
+	_, _ = w.WriteString("</div>\n")
+	return ast.WalkSkipChildren, nil
+}
+
+// includeDiagnostics returns the reason an include failed, if it did.
+func includeDiagnostics(n *Include, lines lineIndex) []Diagnostic {
+	if n.Err == "" {
+		return nil
+	}
+	return []Diagnostic{{Line: lines.lineOf(n.Lines().At(0).Start), Language: "include", Message: n.Err}}
+}
@@ -0,0 +1,243 @@
+package markdown
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"path"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/contract"
+	"github.com/SantiagoBobrik/spec-viewer/internal/latex"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/text"
+)
+
+// TOCEntry represents a single heading in the table of contents.
+type TOCEntry struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	ID    string `json:"id"`
+}
+
+// Options toggles the optional markdown syntax.
+type Options struct {
+	// Alerts renders blockquotes starting with a marker such as [!NOTE] as
+	// GitHub-style callouts.
+	Alerts bool
+	// Footnotes enables [^label] references and their definitions.
+	Footnotes bool
+	// DefinitionLists enables terms followed by ": definition" lines.
+	DefinitionLists bool
+	// Typographer replaces straight quotes, dashes and ellipses with their
+	// typographic forms.
+	Typographer bool
+	// BasePath prefixes the links to other specs, for servers that do not
+	// live at the root of their host, such as "/specs".
+	BasePath string
+}
+
+// DefaultOptions returns the syntax enabled when the configuration does not
+// say otherwise. The typographer is off because it changes the text of the
+// source.
+func DefaultOptions() Options {
+	return Options{Alerts: true, Footnotes: true, DefinitionLists: true}
+}
+
+// typography maps the punctuation replaced by the typographer to Unicode
+// characters rather than HTML entities, so plain text outputs such as the
+// table of contents and PDF show them as is.
+var typography = map[extension.TypographicPunctuation]string{
+	extension.LeftSingleQuote:  "\u2018",
+	extension.RightSingleQuote: "\u2019",
+	extension.LeftDoubleQuote:  "\u201c",
+	extension.RightDoubleQuote: "\u201d",
+	extension.EnDash:           "\u2013",
+	extension.EmDash:           "\u2014",
+	extension.Ellipsis:         "\u2026",
+	extension.LeftAngleQuote:   "\u00ab",
+	extension.RightAngleQuote:  "\u00bb",
+	extension.Apostrophe:       "\u2019",
+}
+
+// md is the shared Goldmark instance configured with auto heading IDs for TOC generation.
+var md = newMarkdown(DefaultOptions())
+
+// Configure sets the optional syntax of the documents parsed from then on.
+// It is meant to be called once at startup, before any document is parsed.
+func Configure(opts Options) {
+	md = newMarkdown(opts)
+}
+
+func newMarkdown(opts Options) goldmark.Markdown {
+	extensions := []goldmark.Extender{
+		extension.Table,
+		extension.Strikethrough,
+		extension.Linkify,
+		extension.TaskList,
+		frontMatterExtension{},
+		mathExtension{},
+		includeExtension{},
+		wikiLinkExtension{base: opts.BasePath},
+		sourcePositions{},
+	}
+	if opts.Alerts {
+		extensions = append(extensions, alertExtension{})
+	}
+	if opts.Footnotes {
+		extensions = append(extensions, extension.Footnote)
+	}
+	if opts.DefinitionLists {
+		extensions = append(extensions, extension.DefinitionList)
+	}
+	if opts.Typographer {
+		extensions = append(extensions, extension.NewTypographer(
+			extension.WithTypographicSubstitutions(typography),
+		))
+	}
+
+	return goldmark.New(
+		goldmark.WithExtensions(extensions...),
+		goldmark.WithParserOptions(
+			parser.WithAutoHeadingID(),
+		),
+	)
+}
+
+// Document is a parsed markdown file together with its source.
+type Document struct {
+	Source []byte
+	Root   ast.Node
+}
+
+// Parse parses markdown source into a Document. Include directives are
+// not resolved, as the source has no place in a spec folder; use ParseFile
+// for specs.
+func Parse(source []byte) *Document {
+	return &Document{
+		Source: source,
+		Root:   md.Parser().Parse(text.NewReader(source)),
+	}
+}
+
+// ParseFile parses the source of the spec name, a slash-separated path
+// relative to the spec folder root, into a Document. Include directives are
+// replaced with the files or sections they name. Contracts and other
+// artifacts are documented as markdown first.
+func ParseFile(root, name string, source []byte) *Document {
+	switch {
+	case contract.IsContract(name):
+		source = contractMarkdown(name, source)
+	case artifact.Supported(name):
+		source = artifact.Markdown(name, source)
+	}
+	return parseFile(source, &includeState{root: root, stack: []string{name}})
+}
+
+// contractMarkdown documents the contract name as markdown, or shows its
+// source after the reason it cannot be read.
+func contractMarkdown(name string, source []byte) []byte {
+	c, err := contract.Parse(name, source)
+	if err == nil {
+		return c.Markdown()
+	}
+	return fmt.Appendf(nil, "# %s\n\nThis file cannot be read as a contract: %s.\n\n%s",
+		path.Base(name), err, artifact.Fence(artifact.Language(name), source))
+}
+
+func parseFile(source []byte, state *includeState) *Document {
+	pc := parser.NewContext()
+	pc.Set(includeKey, state)
+	return &Document{
+		Source: source,
+		Root:   md.Parser().Parse(text.NewReader(source), parser.WithContext(pc)),
+	}
+}
+
+// Render writes the document as HTML.
+func (d *Document) Render(w io.Writer) error {
+	return md.Renderer().Render(w, d.Source, d.Root)
+}
+
+// TOC walks the AST and collects heading entries for the table of contents,
+// including the headings of included specs.
+func (d *Document) TOC() []TOCEntry {
+	var entries []TOCEntry
+
+	_ = ast.Walk(d.Root, func(n ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+
+		if inc, ok := n.(*Include); ok && inc.Doc != nil {
+			entries = append(entries, inc.Doc.TOC()...)
+			return ast.WalkContinue, nil
+		}
+
+		heading, ok := n.(*ast.Heading)
+		if !ok {
+			return ast.WalkContinue, nil
+		}
+
+		// Get the auto-generated heading ID.
+		id, found := heading.AttributeString("id")
+		if !found {
+			return ast.WalkContinue, nil
+		}
+
+		idStr := ""
+		switch v := id.(type) {
+		case []byte:
+			idStr = string(v)
+		case string:
+			idStr = v
+		}
+
+		entries = append(entries, TOCEntry{
+			Level: heading.Level,
+			Text:  nodeText(heading, d.Source),
+			ID:    idStr,
+		})
+
+		return ast.WalkContinue, nil
+	})
+
+	return entries
+}
+
+// Text returns the plain text content of n, a node of the document.
+func (d *Document) Text(n ast.Node) string {
+	return nodeText(n, d.Source)
+}
+
+// nodeText concatenates the text content of all inline descendants of n,
+// including the contents of code spans, emphasis and links, and formulas as
+// plain text.
+func nodeText(n ast.Node, source []byte) string {
+	var buf bytes.Buffer
+	_ = ast.Walk(n, func(cn ast.Node, entering bool) (ast.WalkStatus, error) {
+		if !entering {
+			return ast.WalkContinue, nil
+		}
+		switch t := cn.(type) {
+		case *ast.Text:
+			buf.Write(t.Segment.Value(source))
+			if t.SoftLineBreak() || t.HardLineBreak() {
+				buf.WriteByte(' ')
+			}
+		case *ast.String:
+			buf.Write(t.Value)
+		case *Math:
+			if f, err := latex.Parse(t.Formula(source)); err == nil {
+				buf.WriteString(f.Text())
+			} else {
+				buf.WriteString(t.Formula(source))
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+	return buf.String()
+}
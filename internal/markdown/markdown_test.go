@@ -0,0 +1,693 @@
+package markdown
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestRender_HeadingIDs(t *testing.T) {
+	doc := Parse([]byte("# Sample\n\nHello world"))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if !strings.Contains(buf.String(), `<h1 id="sample" data-source-line="1" data-source-line-end="1"`) {
+		t.Errorf("expected heading with auto ID and source lines, got %q", buf.String())
+	}
+}
+
+func TestRender_SourceLineAttributes(t *testing.T) {
+	src := "Intro\n\n```go\nx := 1\n```\n\n- a\n  ```\n  nested\n  ```\n"
+	doc := Parse([]byte(src))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+
+	blocks := doc.Blocks()
+	for _, want := range []string{
+		`<p data-source-line="1" data-source-line-end="1" data-source-hash="` + blocks[0].Hash + `">Intro</p>`,
+		`<pre data-source-line="3" data-source-line-end="5" data-source-hash="` + blocks[1].Hash + `"><code class="language-go">x := 1`,
+		`<ul data-source-line="7" data-source-line-end="10"`,
+		"<pre><code>nested",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestBlocks_HashIgnoresPositionAndWhitespace(t *testing.T) {
+	a := Parse([]byte("Some text\nwrapped here.\n")).Blocks()
+	b := Parse([]byte("# New heading\n\nSome text wrapped\nhere.\n")).Blocks()
+
+	if a[0].Hash != b[1].Hash {
+		t.Errorf("expected equal hashes for reflowed text, got %s and %s", a[0].Hash, b[1].Hash)
+	}
+	if a[0].Hash == b[0].Hash {
+		t.Error("expected different hashes for different content")
+	}
+}
+
+func TestTOC(t *testing.T) {
+	doc := Parse([]byte("# Title\n\n## The `code` part\n\ntext\n\n### Deep *one*\n"))
+	toc := doc.TOC()
+
+	if len(toc) != 3 {
+		t.Fatalf("expected 3 TOC entries, got %d", len(toc))
+	}
+	want := []TOCEntry{
+		{Level: 1, Text: "Title", ID: "title"},
+		{Level: 2, Text: "The code part", ID: "the-code-part"},
+		{Level: 3, Text: "Deep one", ID: "deep-one"},
+	}
+	for i, w := range want {
+		if toc[i] != w {
+			t.Errorf("entry %d: expected %+v, got %+v", i, w, toc[i])
+		}
+	}
+}
+
+func TestBlocks_LineRanges(t *testing.T) {
+	src := strings.Join([]string{
+		"# Title",             // 1
+		"",                    // 2
+		"First paragraph",     // 3
+		"continues here.",     // 4
+		"",                    // 5
+		"```go",               // 6
+		"fmt.Println(\"hi\")", // 7
+		"```",                 // 8
+		"",                    // 9
+		"---",                 // 10
+		"",                    // 11
+		"- one",               // 12
+		"- two",               // 13
+		"",                    // 14
+		"| a | b |",           // 15
+		"|---|---|",           // 16
+		"| 1 | 2 |",           // 17
+		"",                    // 18
+		"Setext",              // 19
+		"======",              // 20
+	}, "\n")
+
+	blocks := Parse([]byte(src)).Blocks()
+
+	want := []struct {
+		start, end int
+		kind       string
+	}{
+		{1, 1, "Heading"},
+		{3, 4, "Paragraph"},
+		{6, 8, "FencedCodeBlock"},
+		{10, 10, "ThematicBreak"},
+		{12, 13, "List"},
+		{15, 17, "Table"},
+		{19, 20, "Heading"},
+	}
+
+	if len(blocks) != len(want) {
+		t.Fatalf("expected %d blocks, got %d: %+v", len(want), len(blocks), blocks)
+	}
+	for i, w := range want {
+		b := blocks[i]
+		if b.Index != i {
+			t.Errorf("block %d: expected index %d, got %d", i, i, b.Index)
+		}
+		if b.StartLine != w.start || b.EndLine != w.end {
+			t.Errorf("block %d (%s): expected lines %d-%d, got %d-%d", i, b.Kind, w.start, w.end, b.StartLine, b.EndLine)
+		}
+		if b.Kind != w.kind {
+			t.Errorf("block %d: expected kind %s, got %s", i, w.kind, b.Kind)
+		}
+	}
+
+	if blocks[1].Text != "First paragraph continues here." {
+		t.Errorf("unexpected paragraph text %q", blocks[1].Text)
+	}
+}
+
+func TestBlocks_Empty(t *testing.T) {
+	if blocks := Parse(nil).Blocks(); len(blocks) != 0 {
+		t.Errorf("expected no blocks, got %d", len(blocks))
+	}
+}
+
+func TestSourceLines(t *testing.T) {
+	doc := Parse([]byte("a\nb\nc\n"))
+
+	tests := []struct {
+		start, end int
+		want       string
+	}{
+		{1, 1, "a"},
+		{2, 3, "b\nc"},
+		{3, 9, "c"},
+		{0, 1, ""},
+		{4, 4, ""},
+	}
+	for _, tt := range tests {
+		if got := doc.SourceLines(tt.start, tt.end); got != tt.want {
+			t.Errorf("SourceLines(%d, %d) = %q, want %q", tt.start, tt.end, got, tt.want)
+		}
+	}
+}
+
+func TestDiagnostics_MermaidLines(t *testing.T) {
+	src := "# Flow\n\n```mermaid\nflowchart TD\n  A --> B\n  B -->\n```\n\n```mermaid\n```\n\n```go\nx -->\n```\n"
+	diags := Parse([]byte(src)).Diagnostics()
+
+	want := []Diagnostic{
+		{Line: 6, Language: "mermaid", Message: "link has no target node"},
+		{Line: 9, Language: "mermaid", Message: "diagram is empty"},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diags)
+	}
+	for i := range want {
+		if diags[i] != want[i] {
+			t.Errorf("expected %v, got %v", want[i], diags[i])
+		}
+	}
+}
+
+func TestRender_DiagramErrors(t *testing.T) {
+	doc := Parse([]byte("```mermaid\nsequenceDiagram\n  A->>B <x>\n```\n"))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	want := `</code></pre>
+<div class="diagram-errors" role="alert"><p>Diagram syntax errors</p><ul><li>Line 3: message must be followed by &quot;: text&quot;</li></ul></div>`
+	if !strings.Contains(buf.String(), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+	}
+}
+
+func TestRender_D2AndDOT(t *testing.T) {
+	doc := Parse([]byte("# Flow\n\n```dot\ndigraph { a -> b }\n```\n\n```d2\nx.shape: blob\n```\n"))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<figure class="diagram" data-source-line="3" data-source-line-end="5"`,
+		`<svg xmlns="http://www.w3.org/2000/svg" class="diagram-svg"`,
+		`<pre data-source-line="7" data-source-line-end="9"`,
+		`<li>Line 8: unknown shape &quot;blob&quot;</li>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	diags := doc.Diagnostics()
+	if len(diags) != 1 || diags[0] != (Diagnostic{Line: 8, Language: "d2", Message: `unknown shape "blob"`}) {
+		t.Errorf("expected the d2 diagnostic, got %v", diags)
+	}
+}
+
+func TestRender_Math(t *testing.T) {
+	src := "Backoff $d = b \\cdot 2^k$ costs $5 and $10, or \\$x\\$.\n\n" +
+		"$$\n\\sum_{i=1}^n i\n$$\n\n" +
+		"```math\nx^2\n```\n\n" +
+		"Broken $\\foo$ here.\n"
+	doc := Parse([]byte(src))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`Backoff <math xmlns="http://www.w3.org/1998/Math/MathML" display="inline">`,
+		`</math> costs $5 and $10, or $x$.</p>`,
+		`<div class="math-display" data-source-line="3" data-source-line-end="5" data-source-hash="`,
+		`<munderover><mo>∑</mo>`,
+		`<div class="math-display" data-source-line="7" data-source-line-end="9"`,
+		`<span class="math-error" role="alert"><code>$\foo$</code> unknown command &quot;\foo&quot;</span>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestDiagnostics_Math(t *testing.T) {
+	src := "# Fees\n\nRate $r^2^3$.\n\n$$\nx +\n\\frac{1\n$$\n\n```math\n\\left( x\n```\n"
+	diags := Parse([]byte(src)).Diagnostics()
+
+	want := []Diagnostic{
+		{Line: 3, Language: "math", Message: "double superscript"},
+		{Line: 7, Language: "math", Message: `group is never closed, missing "}"`},
+		{Line: 11, Language: "math", Message: `"\left" is never closed, missing "\right"`},
+	}
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diags)
+	}
+	for i := range want {
+		if diags[i] != want[i] {
+			t.Errorf("expected %v, got %v", want[i], diags[i])
+		}
+	}
+}
+
+func TestTOC_Math(t *testing.T) {
+	toc := Parse([]byte("# Fee $f = r \\cdot a$\n")).TOC()
+	if len(toc) != 1 || toc[0].Text != "Fee f = r ⋅ a" {
+		t.Errorf("expected formula as plain text, got %v", toc)
+	}
+}
+
+func TestRender_Alerts(t *testing.T) {
+	src := "> [!WARNING]\n> Rotating keys *logs out* every session.\n\n> [!tip]\n\n> [!UNKNOWN]\n> Stays a quote.\n"
+	doc := Parse([]byte(src))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<div class="markdown-alert markdown-alert-warning" data-source-line="1" data-source-line-end="2" data-source-hash="`,
+		"<p class=\"markdown-alert-title\">Warning</p>\n<p>Rotating keys <em>logs out</em> every session.</p>\n</div>",
+		"<div class=\"markdown-alert markdown-alert-tip\" data-source-line=\"4\" data-source-line-end=\"4\" data-source-hash=\"" + doc.Blocks()[1].Hash + "\">\n<p class=\"markdown-alert-title\">Tip</p>\n</div>",
+		"<blockquote data-source-line=\"6\" data-source-line-end=\"7\" data-source-hash=\"" + doc.Blocks()[2].Hash + "\"><p>[!UNKNOWN]",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if text := doc.Blocks()[0].Text; text != "Rotating keys logs out every session." {
+		t.Errorf("expected the marker to be dropped from the text, got %q", text)
+	}
+}
+
+func TestRender_FootnotesAndDefinitionLists(t *testing.T) {
+	doc := Parse([]byte("Retries back off[^1].\n\nIdempotency key\n: A client-chosen request ID.\n\n[^1]: Up to five times.\n"))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<a href="#fn:1" class="footnote-ref" role="doc-noteref">1</a>`,
+		"<dl data-source-line=\"3\" data-source-line-end=\"4\"",
+		"<dt>Idempotency key</dt>\n<dd>A client-chosen request ID.</dd>",
+		`<div class="footnotes" role="doc-endnotes" data-source-line="6"`,
+		`<li id="fn:1">`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestParse_FrontMatter(t *testing.T) {
+	doc := Parse([]byte("---\ntitle: Payment Processing\norder: 2\ntags: [payments]\n---\n# Spec: Payments\n\nBody.\n"))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if out := buf.String(); strings.Contains(out, "title:") || !strings.HasPrefix(out, `<h1 id="spec-payments" data-source-line="6"`) {
+		t.Errorf("expected the front matter to be hidden, got:\n%s", out)
+	}
+	if meta := doc.Meta(); meta.Title != "Payment Processing" || meta.Order == nil || *meta.Order != 2 {
+		t.Errorf("unexpected meta %+v", meta)
+	}
+	if title := doc.Title(); title != "Payment Processing" {
+		t.Errorf("expected the front matter title, got %q", title)
+	}
+
+	tests := []struct {
+		src, title string
+	}{
+		{"## Overview\n\n# Payments\n", "Payments"},
+		{"---\norder: 1\n...\n# Payments\n", "Payments"},
+		{"---\n\n# Payments\n", "Payments"},
+		{"Intro\n\n---\ntitle: Late\n---\n", ""},
+		{"No title.\n", ""},
+	}
+	for _, tt := range tests {
+		if title := Parse([]byte(tt.src)).Title(); title != tt.title {
+			t.Errorf("Title() of %q = %q, want %q", tt.src, title, tt.title)
+		}
+	}
+	// Markdown between two thematic breaks is not front matter.
+	for _, src := range []string{
+		"---\n# Title\n\nIntro text.\n\n---\n\nMore.\n",
+		"---\n# Title\n---\n\nMore.\n",
+		"---\n- one\n- two\n---\n",
+	} {
+		doc := Parse([]byte(src))
+		var buf bytes.Buffer
+		if err := doc.Render(&buf); err != nil {
+			t.Fatalf("Render returned error: %v", err)
+		}
+		if doc.Root.FirstChild().Kind().String() != "ThematicBreak" || !strings.Contains(buf.String(), "<hr") {
+			t.Errorf("expected %q to open with a thematic break, got:\n%s", src, buf.String())
+		}
+	}
+	if title := Parse([]byte("---\n# Title\n\nIntro text.\n\n---\n\nMore.\n")).Title(); title != "Title" {
+		t.Errorf("expected the H1 title, got %q", title)
+	}
+
+	// A thematic break followed by a setext heading is not front matter.
+	if doc := Parse([]byte("---\n\n# Payments\n")); doc.Root.FirstChild().Kind().String() != "ThematicBreak" {
+		t.Errorf("expected a thematic break, got %s", doc.Root.FirstChild().Kind())
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer Configure(DefaultOptions())
+	src := []byte("> [!NOTE]\n> Read this.\n\nSay \"hi\" -- or don't...\n\nTerm\n: Definition\n")
+
+	Configure(Options{Typographer: true})
+	doc := Parse(src)
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"<blockquote", "<p>[!NOTE]", "Say “hi” – or don’t…", ">Term\n: Definition</p>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if text := doc.Blocks()[1].Text; text != "Say “hi” – or don’t…" {
+		t.Errorf("expected typographic text, got %q", text)
+	}
+}
+
+// writeSpecs writes files to a temporary spec folder and returns it.
+func writeSpecs(t *testing.T, files map[string]string) string {
+	t.Helper()
+	root := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return root
+}
+
+func TestParseFile_Include(t *testing.T) {
+	root := writeSpecs(t, map[string]string{
+		"001/spec.md": "# Spec\n\n## Overview\n\nSkipped.\n\n## Data Model\n\n| Field | Type |\n|--|--|\n| id | uuid |\n\n### Keys\n\nUUIDv7.\n\n## API\n\nSkipped too.\n",
+		"shared.md":   "Shared **terms**.\n",
+	})
+	src := "# Plan\n\n## Overview\n\n![[spec.md#Data Model]]\n\n{{< include \"../shared.md\" >}}\n"
+	doc := ParseFile(root, "001/plan.md", []byte(src))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		"<div class=\"include\" data-include=\"001/spec.md\" data-source-line=\"5\" data-source-line-end=\"5\"",
+		"<h2 id=\"data-model\">Data Model</h2>\n<table>",
+		`<h3 id="keys">Keys</h3>`,
+		"<div class=\"include\" data-include=\"shared.md\" data-source-line=\"7\"",
+		"<p>Shared <strong>terms</strong>.</p>",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	for _, unwanted := range []string{"Skipped", `id="overview-1"`} {
+		if strings.Contains(out, unwanted) {
+			t.Errorf("expected output not to contain %q, got:\n%s", unwanted, out)
+		}
+	}
+
+	want := []TOCEntry{{1, "Plan", "plan"}, {2, "Overview", "overview"}, {2, "Data Model", "data-model"}, {3, "Keys", "keys"}}
+	if toc := doc.TOC(); !reflect.DeepEqual(toc, want) {
+		t.Errorf("expected %v, got %v", want, toc)
+	}
+	if blocks := doc.Blocks(); len(blocks) != 4 || blocks[2].Kind != "Include" || blocks[2].Text != "![[spec.md#Data Model]]" {
+		t.Errorf("expected the include as a block of its directive, got %+v", blocks)
+	}
+}
+
+func TestParseFile_IncludePrivate(t *testing.T) {
+	root := writeSpecs(t, map[string]string{
+		".spec-viewer.yaml": "auth:\n  tokens:\n    - token: secret\n",
+		"spec.md":           "# Spec\n",
+	})
+	if err := os.Symlink(filepath.Join(root, ".spec-viewer.yaml"), filepath.Join(root, "config.md")); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+	doc := ParseFile(root, "plan.md", []byte("![[.spec-viewer.yaml]]\n\n![[config.md]]\n"))
+
+	want := []Diagnostic{
+		{Line: 1, Language: "include", Message: `file ".spec-viewer.yaml" is private`},
+		{Line: 3, Language: "include", Message: `file "config.md" is private`},
+	}
+	if diags := doc.Diagnostics(); !reflect.DeepEqual(diags, want) {
+		t.Errorf("expected %v, got %v", want, diags)
+	}
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if strings.Contains(buf.String(), "secret") {
+		t.Errorf("expected the config file not to be included, got:\n%s", buf.String())
+	}
+}
+
+func TestParseFile_IncludeIDsStayUnique(t *testing.T) {
+	root := writeSpecs(t, map[string]string{"spec.md": "## Overview\n\nIncluded.\n"})
+	doc := ParseFile(root, "plan.md", []byte("## Overview\n\n![[spec.md]]\n"))
+
+	want := []TOCEntry{{2, "Overview", "overview"}, {2, "Overview", "overview-1"}}
+	if toc := doc.TOC(); !reflect.DeepEqual(toc, want) {
+		t.Errorf("expected %v, got %v", want, toc)
+	}
+}
+
+func TestParseFile_IncludeErrors(t *testing.T) {
+	root := writeSpecs(t, map[string]string{
+		"a.md":    "# A\n\n![[b.md]]\n",
+		"b.md":    "# B\n\n![[a.md]]\n",
+		"spec.md": "# Spec\n",
+	})
+	src := "![[a.md]]\n\n![[spec.md#Nowhere]]\n\n![[missing.md]]\n\n![[../outside.md]]\n"
+	doc := ParseFile(root, "plan.md", []byte(src))
+
+	want := []Diagnostic{
+		{Line: 1, Language: "include", Message: "include cycle: a.md → b.md → a.md"},
+		{Line: 3, Language: "include", Message: `section "Nowhere" not found in spec.md`},
+		{Line: 5, Language: "include", Message: `file "missing.md" not found`},
+		{Line: 7, Language: "include", Message: `"../outside.md" is outside the spec folder`},
+	}
+	diags := doc.Diagnostics()
+	if len(diags) != len(want) {
+		t.Fatalf("expected %d diagnostics, got %v", len(want), diags)
+	}
+	for i := range want {
+		if diags[i] != want[i] {
+			t.Errorf("expected %v, got %v", want[i], diags[i])
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	cycle := `<p class="include-error" role="alert" data-source-line="1" data-source-line-end="1" data-source-hash="` + doc.Blocks()[0].Hash + `"><code>![[a.md]]</code> include cycle: a.md → b.md → a.md</p>`
+	if !strings.Contains(buf.String(), cycle) {
+		t.Errorf("expected output to contain %q, got:\n%s", cycle, buf.String())
+	}
+
+	if diags := ParseFile(root, "a.md", []byte("# A\n\n![[b.md]]\n")).Diagnostics(); len(diags) != 1 || diags[0].Message != "include cycle: a.md → b.md → a.md" {
+		t.Errorf("expected the cycle to be reported in the specs along it, got %v", diags)
+	}
+	if diags := Parse([]byte("![[spec.md]]\n")).Diagnostics(); len(diags) != 1 {
+		t.Errorf("expected includes to fail outside a spec folder, got %v", diags)
+	}
+}
+
+func TestParseFile_Contract(t *testing.T) {
+	api := "openapi: 3.0.0\ninfo: {title: Payments, version: \"1\"}\npaths:\n  /payments:\n    post:\n      operationId: createPayment\n      responses:\n        \"201\": {description: Created.}\n    get:\n      responses:\n        \"200\": {description: OK.}\n"
+	root := writeSpecs(t, map[string]string{"contracts/api.yaml": api, "contracts/bad.yaml": "port: 8080\n"})
+
+	doc := ParseFile(root, "contracts/api.yaml", []byte(api))
+	want := []TOCEntry{{1, "Payments", "payments"}, {2, "Operations", "operations"}, {3, "GET /payments", "get-payments"}, {3, "POST /payments", "post-payments"}}
+	if toc := doc.TOC(); !reflect.DeepEqual(toc, want) {
+		t.Errorf("expected %v, got %v", want, toc)
+	}
+
+	var buf bytes.Buffer
+	if err := ParseFile(root, "contracts/bad.yaml", []byte("port: 8080\n")).Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, "cannot be read as a contract: not an OpenAPI document or a JSON Schema.") || !strings.Contains(out, `<code class="language-yaml"><span class="tok-key">port</span>`) {
+		t.Errorf("expected the error and the source, got:\n%s", out)
+	}
+
+	src := "# Plan\n\n![[contracts/api.yaml#createPayment]]\n\n![[contracts/api.yaml#deletePayment]]\n\n![[contracts/bad.yaml]]\n"
+	doc = ParseFile(root, "plan.md", []byte(src))
+	buf.Reset()
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if out := buf.String(); !strings.Contains(out, `<div class="include" data-include="contracts/api.yaml"`) || !strings.Contains(out, "Operation ID <code>createPayment</code>") || strings.Contains(out, "<code>GET</code>") {
+		t.Errorf("expected the createPayment operation alone, got:\n%s", out)
+	}
+	diags := []Diagnostic{
+		{Line: 5, Language: "include", Message: `operation or schema "deletePayment" not found in contracts/api.yaml`},
+		{Line: 7, Language: "include", Message: "contracts/bad.yaml: not an OpenAPI document or a JSON Schema"},
+	}
+	if got := doc.Diagnostics(); !reflect.DeepEqual(got, diags) {
+		t.Errorf("expected %v, got %v", diags, got)
+	}
+}
+
+func TestParseFile_Artifact(t *testing.T) {
+	root := writeSpecs(t, map[string]string{"samples/payment.json": `{"id": 7}`, "fixtures.csv": "id,name\n1,Jane\n"})
+
+	var buf bytes.Buffer
+	doc := ParseFile(root, "samples/payment.json", []byte(`{"id": 7}`))
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	for _, want := range []string{
+		`<h1 id="paymentjson" data-source-line="1" data-source-line-end="1" data-source-hash="`,
+		`<div class="data-view" data-source-line="3" data-source-line-end="5" data-source-hash="`,
+		`<ul class="data-tree"><li><details open><summary><span class="data-tree-meta">{ 1 key }</span></summary><ul><li><span class="tok-key">id</span>: <span class="tok-number">7</span></li>`,
+		`<details class="data-source"><summary>Source</summary><pre><code class="language-json">{<span class="tok-key">&#34;id&#34;</span>: <span class="tok-number">7</span>}`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+
+	src := "# Plan\n\n![[fixtures.csv]]\n\n![[fixtures.csv#users]]\n"
+	doc = ParseFile(root, "plan.md", []byte(src))
+	buf.Reset()
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	if want := `<div class="include" data-include="fixtures.csv" data-source-line="3" data-source-line-end="3" data-source-hash="` + doc.Blocks()[1].Hash + "\">\n<div class=\"data-view\"><table class=\"data-table\" data-sortable>"; !strings.Contains(buf.String(), want) {
+		t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+	}
+	want := []Diagnostic{{Line: 5, Language: "include", Message: "fixtures.csv has no sections"}}
+	if got := doc.Diagnostics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestRender_DataFences(t *testing.T) {
+	src := "```yaml\nid: 7\n```\n\n```json tree\n{\"a\": [1,\n```\n\n```csv\na,b\n```\n"
+	var buf bytes.Buffer
+	if err := Parse([]byte(src)).Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<code class="language-yaml"><span class="tok-key">id</span>: <span class="tok-number">7</span>`,
+		// Invalid data falls back to its source.
+		`<code class="language-json">{<span class="tok-key">&#34;a&#34;</span>: [<span class="tok-number">1</span>,`,
+		`<code class="language-csv">a,b`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "data-view") {
+		t.Errorf("expected only fences marked tree or table as data views, got:\n%s", out)
+	}
+}
+
+func TestParseFile_WikiLinks(t *testing.T) {
+	root := writeSpecs(t, map[string]string{
+		"001-user-authentication/spec.md": "# User Authentication\n\n## Token Strategy\n",
+		"001-user-authentication/plan.md": "# Auth Plan\n\n## Rollout\n",
+		"002-payments/spec.md":            "# Payments\n\n## Refunds\n",
+		"002-payments/fixtures.csv":       "id\n1\n",
+		"003-billing/spec.md":             "---\ntitle: Billing Engine\n---\n# Spec\n",
+		".hidden/spec.md":                 "# Hidden\n",
+	})
+	src := "# Payments\n\n## Refunds\n\n" +
+		"See [[001-user-authentication/spec]], [[user authentication|login]] and [[Token Strategy]].\n\n" +
+		"Also [[plan#Rollout]], [[#Refunds]], [[fixtures.csv]], [[ spec.md ]] and [[billing engine]].\n\n" +
+		"Broken: [[Nowhere]], [[spec#Nowhere]], [[Hidden]] and `[[code]]`.\n"
+	doc := ParseFile(root, "002-payments/spec.md", []byte(src))
+
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{
+		`<a class="wiki-link" href="/view?file=001-user-authentication%2Fspec.md">001-user-authentication/spec</a>`,
+		`<a class="wiki-link" href="/view?file=001-user-authentication%2Fspec.md">login</a>`,
+		`<a class="wiki-link" href="/view?file=001-user-authentication%2Fspec.md#token-strategy">Token Strategy</a>`,
+		`<a class="wiki-link" href="/view?file=001-user-authentication%2Fplan.md#rollout">plan#Rollout</a>`,
+		`<a class="wiki-link" href="#refunds">#Refunds</a>`,
+		`<a class="wiki-link" href="/view?file=002-payments%2Ffixtures.csv">fixtures.csv</a>`,
+		`<a class="wiki-link" href="/view?file=002-payments%2Fspec.md">spec.md</a>`,
+		`<a class="wiki-link" href="/view?file=003-billing%2Fspec.md">billing engine</a>`,
+		`<span class="wiki-link wiki-link-broken" title="no spec or heading named &quot;Nowhere&quot;">Nowhere</span>`,
+		`<code>[[code]]</code>`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, out)
+		}
+	}
+
+	want := []Diagnostic{
+		{Line: 9, Language: "link", Message: `no spec or heading named "Nowhere"`},
+		{Line: 9, Language: "link", Message: `heading "Nowhere" not found in 002-payments/spec.md`},
+		{Line: 9, Language: "link", Message: `no spec or heading named "Hidden"`},
+	}
+	if got := doc.Diagnostics(); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if diags := Parse([]byte("[[spec]]\n")).Diagnostics(); len(diags) != 1 || diags[0].Language != "link" {
+		t.Errorf("expected wiki links to fail outside a spec folder, got %v", diags)
+	}
+}
+
+func TestWikiLinksBasePath(t *testing.T) {
+	defer Configure(DefaultOptions())
+	Configure(Options{BasePath: "/specs"})
+	root := writeSpecs(t, map[string]string{"001/spec.md": "# Spec\n\n## Scope\n", "001/plan.md": "# Plan\n"})
+
+	var buf bytes.Buffer
+	if err := ParseFile(root, "001/plan.md", []byte("[[spec#Scope]] and [[#Plan]]\n")).Render(&buf); err != nil {
+		t.Fatalf("Render returned error: %v", err)
+	}
+	for _, want := range []string{`href="/specs/view?file=001%2Fspec.md#scope"`, `href="#plan"`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, buf.String())
+		}
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	src := "# Plan\n\n![[spec.md#Data Model]]\n  {{< include \"../shared.md\" >}}\n![[spec.md]]\n![[../../outside.md]]\nNot ![[inline.md]]\n"
+	want := []string{"001/spec.md", "shared.md"}
+	if got := Includes("001/plan.md", []byte(src)); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
@@ -0,0 +1,267 @@
+package markdown
+
+import (
+	"bytes"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/latex"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Kinds of the math nodes.
+var (
+	KindMath      = ast.NewNodeKind("Math")
+	KindMathBlock = ast.NewNodeKind("MathBlock")
+)
+
+// Math is a formula inside a paragraph, between $ delimiters, or $$ ones
+// for display style.
+type Math struct {
+	ast.BaseInline
+	// Segment holds the formula without its delimiters.
+	Segment text.Segment
+	Display bool
+}
+
+func (n *Math) Kind() ast.NodeKind { return KindMath }
+
+func (n *Math) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Formula": string(n.Segment.Value(source))}, nil)
+}
+
+// Formula returns the LaTeX source of the formula.
+func (n *Math) Formula(source []byte) string {
+	return string(n.Segment.Value(source))
+}
+
+// MathBlock is a display formula whose $$ delimiters start and end lines.
+// Its lines hold the formula without the delimiters.
+type MathBlock struct {
+	ast.BaseBlock
+	// Start is the offset of the opening delimiter.
+	Start  int
+	closed bool
+}
+
+func (n *MathBlock) Kind() ast.NodeKind { return KindMathBlock }
+
+func (n *MathBlock) IsRaw() bool { return true }
+
+func (n *MathBlock) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, nil, nil)
+}
+
+// Formula returns the LaTeX source of the formula.
+func (n *MathBlock) Formula(source []byte) string {
+	var b bytes.Buffer
+	for i := 0; i < n.Lines().Len(); i++ {
+		line := n.Lines().At(i)
+		b.Write(line.Value(source))
+	}
+	return b.String()
+}
+
+// mathExtension parses $ and $$ formulas and renders them as MathML. Fenced
+// code blocks with the math language are display formulas too.
+type mathExtension struct{}
+
+func (mathExtension) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(
+		parser.WithBlockParsers(util.Prioritized(mathBlockParser{}, 150)),
+		parser.WithInlineParsers(util.Prioritized(mathParser{}, 150)),
+	)
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(mathRenderer{}, 100),
+	))
+}
+
+type mathBlockParser struct{}
+
+func (mathBlockParser) Trigger() []byte { return []byte{'$'} }
+
+func (mathBlockParser) Open(parent ast.Node, reader text.Reader, pc parser.Context) (ast.Node, parser.State) {
+	line, segment := reader.PeekLine()
+	pos := pc.BlockOffset()
+	if pos < 0 || !bytes.HasPrefix(line[pos:], []byte("$$")) {
+		return nil, parser.NoChildren
+	}
+	node := &MathBlock{Start: segment.Start + pos}
+	rest := util.TrimRightSpace(line[pos+2:])
+	start := segment.Start + pos + 2
+
+	if i := bytes.Index(rest, []byte("$$")); i >= 0 {
+		// A formula on a single line must end it, otherwise the line is a
+		// paragraph starting with a display formula.
+		if i != len(rest)-2 {
+			return nil, parser.NoChildren
+		}
+		node.Lines().Append(text.NewSegment(start, start+i))
+		node.closed = true
+	} else if len(util.TrimLeftSpace(rest)) > 0 {
+		node.Lines().Append(text.NewSegment(start, segment.Stop))
+	}
+	reader.AdvanceToEOL()
+	return node, parser.NoChildren
+}
+
+func (mathBlockParser) Continue(node ast.Node, reader text.Reader, pc parser.Context) parser.State {
+	n := node.(*MathBlock)
+	if n.closed {
+		return parser.Close
+	}
+	line, segment := reader.PeekLine()
+	if rest := util.TrimRightSpace(line); bytes.HasSuffix(rest, []byte("$$")) {
+		if len(util.TrimLeftSpace(rest)) > 2 {
+			n.Lines().Append(text.NewSegment(segment.Start, segment.Start+len(rest)-2))
+		}
+		reader.AdvanceToEOL()
+		n.closed = true
+		return parser.Close
+	}
+	n.Lines().Append(segment)
+	reader.AdvanceToEOL()
+	return parser.Continue | parser.NoChildren
+}
+
+func (mathBlockParser) Close(node ast.Node, reader text.Reader, pc parser.Context) {}
+
+func (mathBlockParser) CanInterruptParagraph() bool { return true }
+
+func (mathBlockParser) CanAcceptIndentedLine() bool { return false }
+
+// mathParser parses inline formulas. Like Pandoc, it does not take a $
+// followed by a space as an opening delimiter, nor a $ preceded by a space
+// or followed by a digit as a closing one. A formula also ends at the first
+// $ that is not escaped, so amounts such as "$5 and $10" stay text.
+type mathParser struct{}
+
+func (mathParser) Trigger() []byte { return []byte{'$'} }
+
+func (mathParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	delim := 1
+	if len(line) > 1 && line[1] == '$' {
+		delim = 2
+	}
+	if len(line) <= delim || util.IsSpace(line[delim]) {
+		return nil
+	}
+
+	for i := delim; i < len(line); i++ {
+		switch {
+		case line[i] == '\\':
+			i++
+			continue
+		case line[i] != '$':
+			continue
+		}
+		if delim == 2 {
+			if i == delim || i+1 >= len(line) || line[i+1] != '$' {
+				return nil
+			}
+			block.Advance(i + 2)
+			return &Math{Segment: text.NewSegment(segment.Start+2, segment.Start+i), Display: true}
+		}
+		if util.IsSpace(line[i-1]) || i+1 < len(line) && line[i+1] >= '0' && line[i+1] <= '9' {
+			return nil
+		}
+		block.Advance(i + 1)
+		return &Math{Segment: text.NewSegment(segment.Start+1, segment.Start+i)}
+	}
+	return nil
+}
+
+type mathRenderer struct{}
+
+func (r mathRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindMath, r.renderMath)
+	reg.Register(KindMathBlock, r.renderMathBlock)
+}
+
+// renderMath writes an inline formula, or its source followed by the
+// syntax error when it does not parse.
+func (r mathRenderer) renderMath(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*Math)
+	src := n.Formula(source)
+	f, err := latex.Parse(src)
+	if err != nil {
+		delim := "$"
+		if n.Display {
+			delim = "$$"
+		}
+		_, _ = w.WriteString(`<span class="math-error" role="alert"><code>`)
+		_, _ = w.Write(util.EscapeHTML([]byte(delim + src + delim)))
+		_, _ = w.WriteString("</code> ")
+		_, _ = w.Write(util.EscapeHTML([]byte(err.(latex.Error).Message)))
+		_, _ = w.WriteString("</span>")
+		return ast.WalkSkipChildren, nil
+	}
+	_, _ = w.WriteString(f.MathML(n.Display))
+	return ast.WalkSkipChildren, nil
+}
+
+func (r mathRenderer) renderMathBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering {
+		return ast.WalkContinue, nil
+	}
+	n := node.(*MathBlock)
+	src := n.Formula(source)
+	if f, err := latex.Parse(src); err == nil {
+		writeDisplayMath(w, node, f)
+		return ast.WalkSkipChildren, nil
+	}
+
+	_, _ = w.WriteString("<pre")
+	html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+	_, _ = w.WriteString(`><code class="language-math">`)
+	_, _ = w.Write(util.EscapeHTML([]byte(src)))
+	_, _ = w.WriteString("</code></pre>\n")
+	renderDiagnostics(w, "Math syntax errors", mathDiagnostics(n, source, newLineIndex(source)))
+	return ast.WalkSkipChildren, nil
+}
+
+// writeDisplayMath writes a display formula with the attributes of node.
+func writeDisplayMath(w util.BufWriter, node ast.Node, f *latex.Formula) {
+	_, _ = w.WriteString(`<div class="math-display"`)
+	html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+	_ = w.WriteByte('>')
+	_, _ = w.WriteString(f.MathML(true))
+	_, _ = w.WriteString("</div>\n")
+}
+
+// mathDiagnostics returns the syntax error of a formula, if any, with its
+// line relative to the markdown source. n is a Math, a MathBlock or a math
+// fence.
+func mathDiagnostics(n ast.Node, source []byte, lines lineIndex) []Diagnostic {
+	var src string
+	var first int
+	switch n := n.(type) {
+	case *Math:
+		src, first = n.Formula(source), lines.lineOf(n.Segment.Start)
+	case *MathBlock:
+		src, first = n.Formula(source), lines.lineOf(n.Start)
+		if n.Lines().Len() > 0 {
+			first = lines.lineOf(n.Lines().At(0).Start)
+		}
+	case *ast.FencedCodeBlock:
+		src, first = fenceText(n, source), lines.lineOf(n.Info.Segment.Start)+1
+		if n.Lines().Len() > 0 {
+			first = lines.lineOf(n.Lines().At(0).Start)
+		}
+	}
+
+	_, err := latex.Parse(src)
+	if err == nil {
+		return nil
+	}
+	e := err.(latex.Error)
+	return []Diagnostic{{Line: first + e.Line - 1, Language: "math", Message: e.Message}}
+}
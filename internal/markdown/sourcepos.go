@@ -0,0 +1,214 @@
+package markdown
+
+import (
+	"bytes"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/diagram"
+	"github.com/SantiagoBobrik/spec-viewer/internal/highlight"
+	"github.com/SantiagoBobrik/spec-viewer/internal/latex"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/renderer/html"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// Attributes set on every rendered top-level block so the browser can map
+// elements back to their source.
+const (
+	AttrSourceLine    = "data-source-line"
+	AttrSourceLineEnd = "data-source-line-end"
+	AttrSourceHash    = "data-source-hash"
+)
+
+// sourcePositions annotates top-level blocks with their source line range
+// and content hash.
+type sourcePositions struct{}
+
+func (sourcePositions) Extend(m goldmark.Markdown) {
+	m.Parser().AddOptions(parser.WithASTTransformers(
+		util.Prioritized(sourcePositions{}, 1000),
+	))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(
+		util.Prioritized(&codeBlockRenderer{Config: html.NewConfig()}, 100),
+	))
+}
+
+func (sourcePositions) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
+	for _, b := range blocksOf(doc, reader.Source()) {
+		if b.StartLine == 0 {
+			continue
+		}
+		b.node.SetAttributeString(AttrSourceLine, strconv.Itoa(b.StartLine))
+		b.node.SetAttributeString(AttrSourceLineEnd, strconv.Itoa(b.EndLine))
+		b.node.SetAttributeString(AttrSourceHash, b.Hash)
+	}
+}
+
+// codeBlockRenderer renders code blocks like goldmark's HTML renderer, but
+// keeps the node's data attributes on the <pre> element and highlights
+// JSON, YAML and SQL. D2 and Graphviz fences that compile are rendered as
+// inline SVG instead, math fences that parse as MathML, and data fences
+// marked "tree" or "table" as collapsible trees and sortable tables.
+type codeBlockRenderer struct {
+	html.Config
+}
+
+func (r *codeBlockRenderer) SetOption(name renderer.OptionName, value any) {
+	r.Config.SetOption(name, value)
+}
+
+func (r *codeBlockRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(ast.KindCodeBlock, r.renderCodeBlock)
+	reg.Register(ast.KindFencedCodeBlock, r.renderCodeBlock)
+}
+
+func (r *codeBlockRenderer) renderCodeBlock(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if svg := compiledDiagram(node, source); svg != nil {
+		if entering {
+			_, _ = w.WriteString(`<figure class="diagram"`)
+			html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+			_ = w.WriteByte('>')
+			_, _ = w.Write(svg)
+			_, _ = w.WriteString("</figure>\n")
+		}
+		return ast.WalkContinue, nil
+	}
+	if f := fenceFormula(node, source); f != nil {
+		if entering {
+			writeDisplayMath(w, node, f)
+		}
+		return ast.WalkContinue, nil
+	}
+	if view := dataView(node, source); view != nil {
+		if entering {
+			_, _ = w.WriteString(`<div class="data-view"`)
+			html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+			_ = w.WriteByte('>')
+			_, _ = w.Write(view)
+			_, _ = w.WriteString("</div>\n")
+		}
+		return ast.WalkContinue, nil
+	}
+
+	if !entering {
+		_, _ = w.WriteString("</code></pre>\n")
+		if fc, ok := node.(*ast.FencedCodeBlock); ok {
+			title := "Diagram syntax errors"
+			if string(fc.Language(source)) == "math" {
+				title = "Math syntax errors"
+			}
+			renderDiagnostics(w, title, fenceDiagnostics(fc, source, newLineIndex(source)))
+		}
+		return ast.WalkContinue, nil
+	}
+
+	_, _ = w.WriteString("<pre")
+	if node.Attributes() != nil {
+		html.RenderAttributes(w, node, html.GlobalAttributeFilter)
+	}
+	_, _ = w.WriteString("><code")
+	fc, _ := node.(*ast.FencedCodeBlock)
+	var language []byte
+	if fc != nil {
+		if language = fc.Language(source); language != nil {
+			_, _ = w.WriteString(` class="language-`)
+			r.Writer.Write(w, language)
+			_ = w.WriteByte('"')
+		}
+	}
+	_ = w.WriteByte('>')
+
+	if fc != nil && highlight.Supported(string(language)) {
+		_, _ = w.Write(highlight.HTML(string(language), []byte(fenceText(fc, source))))
+		return ast.WalkContinue, nil
+	}
+	lines := node.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		r.Writer.RawWrite(w, line.Value(source))
+	}
+	return ast.WalkContinue, nil
+}
+
+// dataView returns the tree of a JSON or YAML fence marked "tree", with
+// its highlighted source below, or the table of a CSV fence marked
+// "table". It returns nil for other blocks and for data that does not
+// parse, which is shown as code instead.
+func dataView(node ast.Node, source []byte) []byte {
+	fc, ok := node.(*ast.FencedCodeBlock)
+	if !ok || fc.Info == nil {
+		return nil
+	}
+	info := strings.Fields(string(fc.Info.Segment.Value(source)))
+	if len(info) < 2 || !slices.Contains(info[1:], artifact.View(info[0])) {
+		return nil
+	}
+	lang, text := info[0], []byte(fenceText(fc, source))
+
+	if artifact.View(lang) == "table" {
+		table, err := artifact.Table(text, artifact.Comma(lang))
+		if err != nil {
+			return nil
+		}
+		return table
+	}
+	tree, err := artifact.Tree(text)
+	if err != nil {
+		return nil
+	}
+	var b bytes.Buffer
+	b.Write(tree)
+	b.WriteString(`<details class="data-source"><summary>Source</summary><pre><code class="language-`)
+	b.Write(util.EscapeHTML([]byte(lang)))
+	b.WriteString(`">`)
+	b.Write(highlight.HTML(lang, text))
+	b.WriteString("</code></pre></details>")
+	return b.Bytes()
+}
+
+// compiledDiagram returns the SVG of a D2 or Graphviz fence, or nil if
+// node is another block or does not compile.
+func compiledDiagram(node ast.Node, source []byte) []byte {
+	fc, ok := node.(*ast.FencedCodeBlock)
+	if !ok {
+		return nil
+	}
+	lang := string(fc.Language(source))
+	if !diagram.Supported(lang) {
+		return nil
+	}
+	return diagram.Compile(lang, fenceText(fc, source)).SVG
+}
+
+// fenceFormula returns the parsed formula of a math fence, or nil if node
+// is another block or does not parse.
+func fenceFormula(node ast.Node, source []byte) *latex.Formula {
+	fc, ok := node.(*ast.FencedCodeBlock)
+	if !ok || string(fc.Language(source)) != "math" {
+		return nil
+	}
+	f, _ := latex.Parse(fenceText(fc, source))
+	return f
+}
+
+// renderDiagnostics writes the syntax errors of a diagram or formula below
+// it, so they point at the offending lines even when it fails to render.
+func renderDiagnostics(w util.BufWriter, title string, diags []Diagnostic) {
+	if len(diags) == 0 {
+		return
+	}
+	_, _ = w.WriteString(`<div class="diagram-errors" role="alert"><p>` + title + `</p><ul>`)
+	for _, d := range diags {
+		_, _ = w.WriteString("<li>Line " + strconv.Itoa(d.Line) + ": ")
+		_, _ = w.Write(util.EscapeHTML([]byte(d.Message)))
+		_, _ = w.WriteString("</li>")
+	}
+	_, _ = w.WriteString("</ul></div>\n")
+}
@@ -0,0 +1,61 @@
+package markdown
+
+import (
+	"os"
+	"sync"
+	"time"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+)
+
+// Summary is what the sidebar and wiki links read of a spec without
+// rendering it.
+type Summary struct {
+	// Title is the title of the spec, see Document.Title.
+	Title string
+	// Order is the order of the spec's front matter, if any.
+	Order    *int
+	Headings []TOCEntry
+}
+
+// summaries holds the summaries of specs by path, kept while the file is
+// unchanged.
+var summaries = struct {
+	sync.Mutex
+	files map[string]cachedSummary
+}{files: make(map[string]cachedSummary)}
+
+type cachedSummary struct {
+	modTime time.Time
+	size    int64
+	summary Summary
+}
+
+// Summarize reads the title, order and headings of the markdown spec at
+// path, or returns the cached ones while the file is unchanged.
+func Summarize(path string) (Summary, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Summary{}, err
+	}
+	summaries.Lock()
+	cached, ok := summaries.files[path]
+	summaries.Unlock()
+	hit := ok && cached.modTime.Equal(info.ModTime()) && cached.size == info.Size()
+	metrics.CacheLookup("summary", hit)
+	if hit {
+		return cached.summary, nil
+	}
+
+	source, err := os.ReadFile(path)
+	if err != nil {
+		return Summary{}, err
+	}
+	doc := Parse(source)
+	s := Summary{Title: doc.Title(), Order: doc.Meta().Order, Headings: doc.TOC()}
+
+	summaries.Lock()
+	summaries.files[path] = cachedSummary{modTime: info.ModTime(), size: info.Size(), summary: s}
+	summaries.Unlock()
+	return s, nil
+}
@@ -0,0 +1,304 @@
+package markdown
+
+import (
+	"fmt"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/contract"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
+	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+)
+
+// KindWikiLink is the kind of WikiLink nodes.
+var KindWikiLink = ast.NewNodeKind("WikiLink")
+
+// WikiLink is an Obsidian-style link to another spec or to a heading, such
+// as [[001-user-authentication/spec]], [[Token Strategy]] or
+// [[spec#Data Model|the data model]]. The target is looked up by file
+// path, then by H1 title, then by heading text across the spec folder.
+// Its children are the label: the text after "|", or the target.
+type WikiLink struct {
+	ast.BaseInline
+	// Target is the link target as written, without the label.
+	Target string
+	// File is the linked spec, a slash-separated path relative to the
+	// spec folder, and ID the heading within it, if any.
+	File string
+	ID   string
+	// Err says why the target could not be resolved.
+	Err string
+
+	// self is set when the link points into the spec it is written in.
+	self bool
+	// offset is the position of the link in the source.
+	offset int
+}
+
+func (n *WikiLink) Kind() ast.NodeKind { return KindWikiLink }
+
+func (n *WikiLink) Dump(source []byte, level int) {
+	ast.DumpHelper(n, source, level, map[string]string{"Target": n.Target, "File": n.File, "ID": n.ID, "Err": n.Err}, nil)
+}
+
+// Href returns the URL of the resolved link: the heading alone for links
+// to headings of the same spec, or the viewer page of the linked spec.
+func (n *WikiLink) Href() string {
+	fragment := ""
+	if n.ID != "" {
+		fragment = "#" + n.ID
+		if n.self {
+			return fragment
+		}
+	}
+	return "/view?file=" + url.QueryEscape(n.File) + fragment
+}
+
+// wikiLinkExtension parses and renders wiki links.
+type wikiLinkExtension struct {
+	base string
+}
+
+func (e wikiLinkExtension) Extend(m goldmark.Markdown) {
+	// Before goldmark's link parser, which also starts at "[".
+	m.Parser().AddOptions(parser.WithInlineParsers(util.Prioritized(wikiLinkParser{}, 199)))
+	m.Renderer().AddOptions(renderer.WithNodeRenderers(util.Prioritized(wikiLinkRenderer{base: e.base}, 100)))
+}
+
+type wikiLinkParser struct{}
+
+func (wikiLinkParser) Trigger() []byte { return []byte{'['} }
+
+func (wikiLinkParser) Parse(parent ast.Node, block text.Reader, pc parser.Context) ast.Node {
+	line, segment := block.PeekLine()
+	if len(line) < 5 || line[0] != '[' || line[1] != '[' {
+		return nil
+	}
+	end := strings.Index(string(line[2:]), "]]")
+	if end <= 0 {
+		return nil
+	}
+	inner := line[2 : 2+end]
+	if strings.ContainsAny(string(inner), "[]\n") {
+		return nil
+	}
+
+	target, _, hasLabel := strings.Cut(string(inner), "|")
+	target = strings.TrimSpace(target)
+	if target == "" {
+		return nil
+	}
+	// The label is the text after "|", or else the target, as written.
+	start := 2 + len(inner) - len(strings.TrimLeft(string(inner), " "))
+	label := text.NewSegment(segment.Start+start, segment.Start+start+len(target))
+	if hasLabel {
+		l := text.NewSegment(segment.Start+2+strings.IndexByte(string(inner), '|')+1, segment.Start+2+end)
+		l = l.TrimLeftSpace(block.Source())
+		if l = l.TrimRightSpace(block.Source()); !l.IsEmpty() {
+			label = l
+		}
+	}
+
+	n := &WikiLink{Target: target, offset: segment.Start}
+	n.AppendChild(n, ast.NewTextSegment(label))
+	block.Advance(end + 4)
+
+	state, _ := pc.Get(includeKey).(*includeState)
+	if state == nil {
+		n.Err = "wiki links are only resolved in files of the spec folder"
+		return n
+	}
+	resolveWikiLink(n, state.root, state.stack[len(state.stack)-1], pc)
+	return n
+}
+
+// pagesKey caches the pages of the spec folder for a parse.
+var pagesKey = parser.NewContextKey()
+
+func resolveWikiLink(n *WikiLink, root, from string, pc parser.Context) {
+	pages, _ := pc.Get(pagesKey).([]page)
+	if pages == nil {
+		pages = folderPages(root)
+		pc.Set(pagesKey, pages)
+	}
+
+	name, heading, hasHeading := strings.Cut(n.Target, "#")
+	name, heading = strings.TrimSpace(name), strings.TrimSpace(heading)
+	var p *page
+	switch {
+	case name == "":
+		p = findPage(pages, from, func(p *page) bool { return p.File == from })
+	default:
+		p = findPage(pages, from, func(p *page) bool { return p.matchesPath(from, name) })
+		if p == nil {
+			p = findPage(pages, from, func(p *page) bool { return p.Title != "" && strings.EqualFold(p.Title, name) })
+		}
+		if p == nil && !hasHeading {
+			// A heading anywhere, in the linking spec first.
+			p = findPage(pages, from, func(p *page) bool { return p.heading(name) != "" })
+			if p != nil {
+				heading = name
+			}
+		}
+	}
+	if p == nil {
+		if name == "" {
+			n.Err = fmt.Sprintf(`heading "%s" not found`, heading)
+		} else {
+			n.Err = fmt.Sprintf(`no spec or heading named "%s"`, name)
+		}
+		return
+	}
+
+	n.File, n.self = p.File, p.File == from
+	if heading != "" {
+		if n.ID = p.heading(heading); n.ID == "" {
+			n.Err = fmt.Sprintf(`heading "%s" not found in %s`, heading, p.File)
+		}
+	}
+}
+
+// findPage returns the page matching match, preferring the linking spec,
+// then specs in its directory, then the first by path.
+func findPage(pages []page, from string, match func(*page) bool) *page {
+	var found *page
+	for i := range pages {
+		p := &pages[i]
+		if !match(p) {
+			continue
+		}
+		switch {
+		case p.File == from:
+			return p
+		case found == nil,
+			path.Dir(p.File) == path.Dir(from) && path.Dir(found.File) != path.Dir(from):
+			found = p
+		}
+	}
+	return found
+}
+
+// page is a file of the spec folder wiki links can point to.
+type page struct {
+	// File is the slash-separated path relative to the spec folder.
+	File string
+	// Title is the title of a markdown spec, see Document.Title.
+	Title    string
+	Headings []TOCEntry
+}
+
+// matchesPath reports whether name, with or without the .md extension,
+// names the page relative to the linking spec from, to the spec folder,
+// or as the trailing elements of its path.
+func (p *page) matchesPath(from, name string) bool {
+	file := strings.ToLower(p.File)
+	name = strings.ToLower(strings.TrimPrefix(name, "/"))
+	if strings.HasSuffix(file, ".md") && !strings.HasSuffix(name, ".md") {
+		name += ".md"
+	}
+	rel := path.Join(strings.ToLower(path.Dir(from)), name)
+	return file == path.Clean(name) || file == rel || strings.HasSuffix(file, "/"+path.Clean(name))
+}
+
+// heading returns the ID of the heading of p whose text or ID is name, or
+// "".
+func (p *page) heading(name string) string {
+	for _, h := range p.Headings {
+		if strings.EqualFold(h.Text, name) || h.ID == name {
+			return h.ID
+		}
+	}
+	return ""
+}
+
+// folderPages lists the specs, contracts and artifacts under root, in path
+// order, skipping hidden files and directories as the sidebar does.
+func folderPages(root string) []page {
+	var pages []page
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return nil
+		}
+		file := filepath.ToSlash(rel)
+		if !strings.HasSuffix(file, ".md") && !contract.IsContract(file) && !artifact.Supported(file) {
+			return nil
+		}
+		page := page{File: file}
+		if strings.HasSuffix(file, ".md") {
+			if s, err := Summarize(p); err == nil {
+				page.Title, page.Headings = s.Title, s.Headings
+			}
+		}
+		pages = append(pages, page)
+		return nil
+	})
+	slices.SortFunc(pages, func(a, b page) int { return strings.Compare(a.File, b.File) })
+	return pages
+}
+
+// wikiLinkRenderer writes the links to other specs under base.
+type wikiLinkRenderer struct {
+	base string
+}
+
+func (r wikiLinkRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
+	reg.Register(KindWikiLink, r.render)
+}
+
+// render writes resolved links as anchors and unresolved ones as spans
+// that say why.
+func (r wikiLinkRenderer) render(w util.BufWriter, source []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	n := node.(*WikiLink)
+	if n.Err != "" {
+		if entering {
+			_, _ = w.WriteString(`<span class="wiki-link wiki-link-broken" title="`)
+			_, _ = w.Write(util.EscapeHTML([]byte(n.Err)))
+			_, _ = w.WriteString(`">`)
+		} else {
+			_, _ = w.WriteString("</span>")
+		}
+		return ast.WalkContinue, nil
+	}
+	if entering {
+		_, _ = w.WriteString(`<a class="wiki-link" href="`)
+		href := n.Href()
+		if strings.HasPrefix(href, "/") {
+			href = r.base + href
+		}
+		_, _ = w.Write(util.EscapeHTML([]byte(href)))
+		_, _ = w.WriteString(`">`)
+	} else {
+		_, _ = w.WriteString("</a>")
+	}
+	return ast.WalkContinue, nil
+}
+
+// wikiLinkDiagnostics reports n if it could not be resolved.
+func wikiLinkDiagnostics(n *WikiLink, lines lineIndex) []Diagnostic {
+	if n.Err == "" {
+		return nil
+	}
+	return []Diagnostic{{Line: lines.lineOf(n.offset), Language: "link", Message: n.Err}}
+}
@@ -0,0 +1,78 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	classAnnotation = regexp.MustCompile(`^<<[^>]+>>\s*\S+$`)
+	classRelation   = regexp.MustCompile(`--|\.\.`)
+)
+
+func checkClass(header statement, body []statement) []Error {
+	var errs []Error
+	var blocks blockStack
+
+	for _, s := range body {
+		text := s.text
+		if blocks.top() == "class body" {
+			// Members are free-form until the body closes.
+			if text == "}" {
+				blocks.pop()
+			}
+			continue
+		}
+
+		switch {
+		case hasKeyword(text, "class") && strings.HasSuffix(text, "{"):
+			blocks.push(s.line, "class body")
+		case hasKeyword(text, "namespace") && strings.HasSuffix(text, "{"):
+			blocks.push(s.line, "namespace")
+		case text == "}":
+			if _, ok := blocks.pop(); !ok {
+				errs = append(errs, Error{Line: s.line, Message: "unexpected \"}\""})
+			}
+		case classAnnotation.MatchString(text):
+		default:
+			if _, ok := firstKeyword(text, "class", "classDef", "cssClass", "style", "direction", "link", "click", "callback", "note", "title"); ok {
+				continue
+			}
+			if err := checkClassStatement(s); err != nil {
+				errs = append(errs, *err)
+			}
+		}
+	}
+	return append(errs, blocks.unclosed()...)
+}
+
+// checkClassStatement checks a relation, a member or a bare class name.
+func checkClassStatement(s statement) *Error {
+	plain, ok := stripQuotes(s.text)
+	if !ok {
+		return &Error{Line: s.line, Message: "unterminated string"}
+	}
+
+	rel := classRelation.FindStringIndex(plain)
+	colon := strings.Index(plain, ":")
+	switch {
+	case rel != nil && (colon < 0 || rel[0] < colon):
+		from := strings.TrimRight(plain[:rel[0]], " \t<|*o()")
+		to, _, _ := strings.Cut(plain[rel[1]:], ":")
+		to = strings.TrimLeft(to, " \t|>*o()-.")
+		if from == "" {
+			return &Error{Line: s.line, Message: "relation has no source class"}
+		}
+		if strings.TrimSpace(to) == "" {
+			return &Error{Line: s.line, Message: "relation has no target class"}
+		}
+	case colon >= 0:
+		if strings.TrimSpace(plain[:colon]) == "" {
+			return &Error{Line: s.line, Message: "member has no class"}
+		}
+	case strings.ContainsAny(strings.TrimSpace(plain), " \t"):
+		return &Error{Line: s.line, Message: fmt.Sprintf("unrecognized statement %q", s.text)}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const erName = `("[^"]*"|[\w.\-]+)(\[[^\]]*\])?`
+
+var (
+	erEntity       = regexp.MustCompile(`^` + erName + `$`)
+	erEntityOpen   = regexp.MustCompile(`^` + erName + `\s*\{$`)
+	erEntityInline = regexp.MustCompile(`^` + erName + `\s*\{.*\}$`)
+	erAttribute    = regexp.MustCompile(`^\S+\s+\S+(\s+(PK|FK|UK)(\s*,\s*(PK|FK|UK))*)?(\s+"[^"]*")?$`)
+	erRelation     = regexp.MustCompile(`^` + erName + `\s*([|}o{]{2}[-.]{2}[|o{}]{2})\s*` + erName + `\s*(:\s*\S.*)?$`)
+	erCardinality  = regexp.MustCompile(`^(\|o|\|\||\}o|\}\|)(--|\.\.|\.-|-\.)(o\||\|\||o\{|\|\{)$`)
+)
+
+func checkER(header statement, body []statement) []Error {
+	var errs []Error
+	var blocks blockStack
+
+	for _, s := range body {
+		text := s.text
+		switch {
+		case blocks.top() != "":
+			if text == "}" {
+				blocks.pop()
+			} else if !erAttribute.MatchString(text) {
+				errs = append(errs, Error{Line: s.line, Message: fmt.Sprintf("invalid attribute %q, expected \"type name [PK|FK|UK] [\\\"comment\\\"]\"", text)})
+			}
+		case erEntityOpen.MatchString(text):
+			blocks.push(s.line, "entity block")
+		case text == "}":
+			errs = append(errs, Error{Line: s.line, Message: "unexpected \"}\""})
+		case erEntity.MatchString(text), erEntityInline.MatchString(text):
+		default:
+			if _, ok := firstKeyword(text, "title", "direction", "classDef", "class", "style"); ok {
+				continue
+			}
+			if err := checkERRelation(s); err != nil {
+				errs = append(errs, *err)
+			}
+		}
+	}
+	return append(errs, blocks.unclosed()...)
+}
+
+func checkERRelation(s statement) *Error {
+	m := erRelation.FindStringSubmatch(s.text)
+	switch {
+	case m == nil:
+		// Cardinalities may also be spelled out, as in "1 to zero or more".
+		if strings.Contains(s.text, " to ") && strings.Contains(s.text, ":") {
+			return nil
+		}
+		return &Error{Line: s.line, Message: fmt.Sprintf("unrecognized statement %q, expected \"ENTITY ||--o{ OTHER : label\"", s.text)}
+	case !erCardinality.MatchString(m[3]):
+		return &Error{Line: s.line, Message: fmt.Sprintf("invalid relationship %q", m[3])}
+	case m[6] == "":
+		return &Error{Line: s.line, Message: "relationship is missing \": label\""}
+	}
+	return nil
+}
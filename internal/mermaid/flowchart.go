@@ -0,0 +1,162 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+var flowDirections = map[string]bool{
+	"TB": true, "TD": true, "BT": true, "RL": true, "LR": true,
+	"<": true, ">": true, "^": true, "V": true,
+}
+
+var (
+	// Links left dangling at the end or start of a statement, after node
+	// labels have been removed.
+	flowTrailingLink = regexp.MustCompile(`(--+|==+|-\.+-|~~~)[>ox]?\s*$`)
+	flowLeadingLink  = regexp.MustCompile(`^\s*(<?(--|==|-\.)|~~~)`)
+)
+
+func checkFlowchart(header statement, body []statement) []Error {
+	var errs []Error
+
+	// The header may carry a direction and, after a semicolon, statements.
+	fields := strings.Fields(header.text)
+	rest := strings.TrimSpace(strings.TrimPrefix(header.text, fields[0]))
+	dir, tail, _ := strings.Cut(rest, ";")
+	dir = strings.TrimSpace(dir)
+	if word, more, _ := strings.Cut(dir, " "); word != "" {
+		if !flowDirections[strings.ToUpper(word)] {
+			errs = append(errs, Error{Line: header.line, Message: fmt.Sprintf("unknown direction %q, expected TB, TD, BT, RL or LR", word)})
+		}
+		tail = more + ";" + tail
+	}
+	if strings.Trim(tail, "; ") != "" {
+		body = append([]statement{{line: header.line, text: tail}}, body...)
+	}
+
+	var blocks blockStack
+	for _, s := range body {
+		for _, text := range splitStatements(s.text) {
+			if err := checkFlowStatement(statement{line: s.line, text: text}, &blocks); err != nil {
+				errs = append(errs, *err)
+			}
+		}
+	}
+	return append(errs, blocks.unclosed()...)
+}
+
+func checkFlowStatement(s statement, blocks *blockStack) *Error {
+	switch {
+	case hasKeyword(s.text, "subgraph"):
+		blocks.push(s.line, "subgraph")
+		return nil
+	case s.text == "end":
+		if _, ok := blocks.pop(); !ok {
+			return &Error{Line: s.line, Message: "end without a matching subgraph"}
+		}
+		return nil
+	}
+	if _, ok := firstKeyword(s.text, "classDef", "class", "style", "linkStyle", "click", "direction", "title"); ok {
+		return nil
+	}
+
+	skeleton, problem := flowSkeleton(s.text)
+	switch {
+	case problem != "":
+		return &Error{Line: s.line, Message: problem}
+	case flowTrailingLink.MatchString(skeleton):
+		return &Error{Line: s.line, Message: "link has no target node"}
+	case flowLeadingLink.MatchString(skeleton):
+		return &Error{Line: s.line, Message: "link has no source node"}
+	}
+	return nil
+}
+
+// splitStatements splits a line at semicolons outside strings and labels.
+func splitStatements(text string) []string {
+	var parts []string
+	depth, quoted, start := 0, false, 0
+	for i, r := range text {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case quoted:
+		case r == '(' || r == '[' || r == '{':
+			depth++
+		case r == ')' || r == ']' || r == '}':
+			depth--
+		case r == ';' && depth <= 0:
+			parts = append(parts, text[start:i])
+			start = i + 1
+		}
+	}
+	parts = append(parts, text[start:])
+
+	var stmts []string
+	for _, p := range parts {
+		if p = strings.TrimSpace(p); p != "" {
+			stmts = append(stmts, p)
+		}
+	}
+	return stmts
+}
+
+var flowClosing = map[rune]rune{'(': ')', '[': ']', '{': '}'}
+
+// flowSkeleton returns text with node labels, link labels and strings
+// removed. It reports unbalanced brackets and unterminated strings as a
+// problem.
+func flowSkeleton(text string) (string, string) {
+	var b strings.Builder
+	var stack []rune
+	quoted, piped := false, false
+	runes := []rune(text)
+
+	for i, r := range runes {
+		switch {
+		case quoted:
+			quoted = r != '"'
+			continue
+		case r == '"':
+			quoted = true
+			continue
+		case piped:
+			piped = r != '|'
+			continue
+		case len(stack) == 0 && r == '|':
+			piped = true
+			continue
+		case r == '(' || r == '[' || r == '{':
+			stack = append(stack, flowClosing[r])
+		case len(stack) == 0 && r == '>' && i > 0 && isIDChar(runes[i-1]):
+			// An asymmetric node shape: id>label]
+			stack = append(stack, ']')
+		case r == ')' || r == ']' || r == '}':
+			if len(stack) == 0 || stack[len(stack)-1] != r {
+				return "", fmt.Sprintf("unexpected %q", r)
+			}
+			stack = stack[:len(stack)-1]
+			continue
+		}
+		if len(stack) == 0 {
+			b.WriteRune(r)
+		}
+	}
+
+	switch {
+	case quoted:
+		return "", "unterminated string"
+	case len(stack) > 0:
+		return "", fmt.Sprintf("missing %q", stack[len(stack)-1])
+	case piped:
+		return "", "unterminated link label, missing \"|\""
+	}
+	return b.String(), ""
+}
+
+func isIDChar(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
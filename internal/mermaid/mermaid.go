@@ -0,0 +1,186 @@
+// Package mermaid checks the syntax of Mermaid diagrams without a browser.
+//
+// The checks are deliberately conservative: they catch the mistakes that
+// make Mermaid give up on a diagram (unknown statements, unbalanced
+// brackets, blocks that are never closed, links without an end) and leave
+// the finer points of each grammar to Mermaid itself.
+package mermaid
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Error is a syntax error at a 1-based line of a diagram's source.
+type Error struct {
+	Line    int
+	Message string
+}
+
+func (e Error) Error() string {
+	return fmt.Sprintf("line %d: %s", e.Line, e.Message)
+}
+
+// statement is a meaningful source line of a diagram.
+type statement struct {
+	line int
+	text string
+}
+
+// checkers validates the statements following the header of each checked
+// diagram type.
+var checkers = map[string]func(header statement, body []statement) []Error{
+	"graph":           checkFlowchart,
+	"flowchart":       checkFlowchart,
+	"flowchart-elk":   checkFlowchart,
+	"sequenceDiagram": checkSequence,
+	"erDiagram":       checkER,
+	"stateDiagram":    checkState,
+	"stateDiagram-v2": checkState,
+	"classDiagram":    checkClass,
+	"classDiagram-v2": checkClass,
+}
+
+// otherTypes are diagram types that are recognised but not checked.
+var otherTypes = map[string]bool{
+	"gantt": true, "pie": true, "journey": true, "gitGraph": true,
+	"mindmap": true, "timeline": true, "quadrantChart": true,
+	"requirementDiagram": true, "C4Context": true, "C4Container": true,
+	"C4Component": true, "C4Dynamic": true, "C4Deployment": true,
+	"sankey-beta": true, "xychart-beta": true, "block-beta": true,
+	"packet-beta": true, "architecture-beta": true, "kanban": true,
+	"radar-beta": true, "treemap-beta": true, "zenuml": true, "info": true,
+}
+
+// Validate checks the syntax of a Mermaid diagram and returns its errors
+// in line order. Flowcharts and sequence, ER, state and class diagrams are
+// checked statement by statement; other diagram types are only recognised.
+func Validate(src string) []Error {
+	stmts := statements(src)
+	if len(stmts) == 0 {
+		return []Error{{Line: 1, Message: "diagram is empty"}}
+	}
+
+	header := stmts[0]
+	kind := strings.TrimSuffix(strings.Fields(header.text)[0], ";")
+	if check, ok := checkers[kind]; ok {
+		errs := check(header, stmts[1:])
+		sort.SliceStable(errs, func(i, j int) bool { return errs[i].Line < errs[j].Line })
+		return errs
+	}
+	if otherTypes[kind] {
+		return nil
+	}
+	return []Error{{Line: header.line, Message: fmt.Sprintf("unknown diagram type %q", kind)}}
+}
+
+// statements returns the non-blank lines of src, skipping front matter,
+// comments, directives and accessibility descriptions, which every diagram
+// type accepts.
+func statements(src string) []statement {
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+
+	var stmts []statement
+	inFrontMatter, inAccDescr := false, false
+	for i, raw := range lines {
+		text := strings.TrimSpace(raw)
+		switch {
+		case len(stmts) == 0 && text == "---":
+			inFrontMatter = !inFrontMatter
+			continue
+		case inFrontMatter:
+			continue
+		case inAccDescr:
+			inAccDescr = !strings.HasSuffix(text, "}")
+			continue
+		case text == "" || strings.HasPrefix(text, "%%"):
+			continue
+		case hasKeyword(text, "accTitle") || hasKeyword(text, "accDescr"):
+			inAccDescr = strings.HasSuffix(text, "{")
+			continue
+		}
+		stmts = append(stmts, statement{line: i + 1, text: text})
+	}
+	return stmts
+}
+
+// hasKeyword reports whether text starts with keyword as a whole word,
+// ignoring case.
+func hasKeyword(text, keyword string) bool {
+	if len(text) < len(keyword) || !strings.EqualFold(text[:len(keyword)], keyword) {
+		return false
+	}
+	if len(text) == len(keyword) {
+		return true
+	}
+	switch text[len(keyword)] {
+	case ' ', '\t', ':', ';', '{':
+		return true
+	}
+	return false
+}
+
+// firstKeyword returns the first of keywords text starts with.
+func firstKeyword(text string, keywords ...string) (string, bool) {
+	for _, k := range keywords {
+		if hasKeyword(text, k) {
+			return k, true
+		}
+	}
+	return "", false
+}
+
+// block is an open block statement waiting for its closing statement.
+type block struct {
+	line int
+	kind string
+}
+
+type blockStack []block
+
+func (s *blockStack) push(line int, kind string) {
+	*s = append(*s, block{line: line, kind: kind})
+}
+
+func (s *blockStack) pop() (block, bool) {
+	if len(*s) == 0 {
+		return block{}, false
+	}
+	b := (*s)[len(*s)-1]
+	*s = (*s)[:len(*s)-1]
+	return b, true
+}
+
+func (s blockStack) top() string {
+	if len(s) == 0 {
+		return ""
+	}
+	return s[len(s)-1].kind
+}
+
+// unclosed reports every block still open at the end of the diagram.
+func (s blockStack) unclosed() []Error {
+	var errs []Error
+	for _, b := range s {
+		errs = append(errs, Error{Line: b.line, Message: fmt.Sprintf("%s is never closed", b.kind)})
+	}
+	return errs
+}
+
+// stripQuotes removes double-quoted strings from text, reporting whether
+// the last one is unterminated.
+func stripQuotes(text string) (string, bool) {
+	var b strings.Builder
+	quoted := false
+	for _, r := range text {
+		if r == '"' {
+			quoted = !quoted
+			continue
+		}
+		if !quoted {
+			b.WriteRune(r)
+		}
+	}
+	return b.String(), !quoted
+}
@@ -0,0 +1,118 @@
+package mermaid
+
+import "testing"
+
+func TestValidate_Valid(t *testing.T) {
+	tests := map[string]string{
+		"flowchart": `flowchart LR
+    A[Start] --> B{Is it?}
+    B -->|Yes| C(OK)
+    B -- No --> D>Flag]
+    subgraph one [Group]
+      C --> E[("Database")]
+    end
+    classDef hot fill:#f00
+    class C hot
+    %% a comment`,
+		"graph with semicolons": "graph TD; A-->B; B-->C;",
+		"sequence": `sequenceDiagram
+    participant A as Alice
+    actor B
+    A->>B: Hello
+    B-->>-A: Hi
+    loop Every minute
+        A-)B: Ping
+    end
+    alt ok
+        B->>A: yes
+    else not ok
+        B->>A: no
+    end
+    Note over A,B: done`,
+		"er": `erDiagram
+    CUSTOMER ||--o{ ORDER : places
+    ORDER ||--|{ LINE-ITEM : contains
+    CUSTOMER {
+        string name PK
+        string email "contact"
+    }
+    PRODUCT`,
+		"state": `stateDiagram-v2
+    [*] --> Still
+    Still --> Moving : push
+    state Moving {
+        [*] --> Fast
+    }
+    note right of Still
+        resting
+    end note
+    Moving --> [*]`,
+		"class": `classDiagram
+    class Animal {
+        +String name
+        +eat() void
+    }
+    <<interface>> Animal
+    Animal <|-- Duck
+    Animal "1" *-- "many" Leg : has
+    Duck : +swim()
+    Fish`,
+		"front matter": "---\ntitle: Demo\n---\nflowchart TD\n  A --> B",
+		"other type":   "pie title Pets\n  \"Dogs\" : 3",
+	}
+
+	for name, src := range tests {
+		if errs := Validate(src); len(errs) != 0 {
+			t.Errorf("%s: expected no errors, got %v", name, errs)
+		}
+	}
+}
+
+func TestValidate_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		src     string
+		line    int
+		message string
+	}{
+		{"empty", "%% nothing\n", 1, "diagram is empty"},
+		{"unknown type", "flowchat TD\nA-->B", 1, `unknown diagram type "flowchat"`},
+		{"direction", "flowchart XY\nA-->B", 1, `unknown direction "XY", expected TB, TD, BT, RL or LR`},
+		{"unclosed bracket", "flowchart TD\nA[Start --> B", 2, `missing ']'`},
+		{"dangling link", "flowchart TD\nA --> B\nB -->", 3, "link has no target node"},
+		{"unclosed subgraph", "flowchart TD\nsubgraph S\nA-->B", 2, "subgraph is never closed"},
+		{"stray end", "graph LR\nA-->B\nend", 3, "end without a matching subgraph"},
+		{"message text", "sequenceDiagram\nA->>B", 2, `message must be followed by ": text"`},
+		{"unclosed loop", "sequenceDiagram\nloop x\nA->>B: hi", 2, "loop block is never closed"},
+		{"stray else", "sequenceDiagram\nelse\nA->>B: hi", 2, "else outside of an alt block"},
+		{"er cardinality", "erDiagram\nA ||--|| B", 2, `relationship is missing ": label"`},
+		{"er attribute", "erDiagram\nA {\n  string\n}", 3, `invalid attribute "string", expected "type name [PK|FK|UK] [\"comment\"]"`},
+		{"state transition", "stateDiagram-v2\n[*] -->", 2, "transition has no target state"},
+		{"state note", "stateDiagram-v2\nnote left of A\ntext", 2, `note is never closed, expected "end note"`},
+		{"class body", "classDiagram\nclass A {\n+int x", 2, "class body is never closed"},
+		{"class relation", "classDiagram\nA <|--", 2, "relation has no target class"},
+	}
+
+	for _, tt := range tests {
+		errs := Validate(tt.src)
+		if len(errs) != 1 {
+			t.Errorf("%s: expected 1 error, got %v", tt.name, errs)
+			continue
+		}
+		if errs[0].Line != tt.line || errs[0].Message != tt.message {
+			t.Errorf("%s: expected line %d %q, got line %d %q", tt.name, tt.line, tt.message, errs[0].Line, errs[0].Message)
+		}
+	}
+}
+
+func TestValidate_ErrorsInLineOrder(t *testing.T) {
+	errs := Validate("sequenceDiagram\nloop x\nA->>B\nfoo bar")
+	if len(errs) != 3 {
+		t.Fatalf("expected 3 errors, got %v", errs)
+	}
+	for i, line := range []int{2, 3, 4} {
+		if errs[i].Line != line {
+			t.Errorf("expected error %d on line %d, got %d", i, line, errs[i].Line)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	seqArrow = regexp.MustCompile(`<<-->>|<<->>|-->>|->>|-->|->|--x|-x|--\)|-\)`)
+	seqNote  = regexp.MustCompile(`(?i)^note\s+(left\s+of|right\s+of|over)\s+[^:]+:`)
+)
+
+// seqBlocks are the statements that open a block closed by "end".
+var seqBlocks = []string{"loop", "alt", "opt", "par", "critical", "break", "rect", "box"}
+
+// seqBranches maps statements that start another branch of a block to the
+// block they belong to.
+var seqBranches = map[string]string{"else": "alt", "and": "par", "option": "critical"}
+
+// seqStatements are keywords of single-line statements.
+var seqStatements = []string{
+	"participant", "actor", "create", "destroy", "activate", "deactivate",
+	"autonumber", "title", "links", "link", "properties", "details",
+}
+
+func checkSequence(header statement, body []statement) []Error {
+	var errs []Error
+	var blocks blockStack
+
+	for _, s := range body {
+		if err := checkSeqStatement(s, &blocks); err != nil {
+			errs = append(errs, *err)
+		}
+	}
+	return append(errs, blocks.unclosed()...)
+}
+
+func checkSeqStatement(s statement, blocks *blockStack) *Error {
+	text := s.text
+
+	if k, ok := firstKeyword(text, seqBlocks...); ok {
+		blocks.push(s.line, k+" block")
+		return nil
+	}
+	if strings.EqualFold(text, "end") {
+		if _, ok := blocks.pop(); !ok {
+			return &Error{Line: s.line, Message: "end without a matching block"}
+		}
+		return nil
+	}
+	for branch, parent := range seqBranches {
+		if hasKeyword(text, branch) {
+			if blocks.top() != parent+" block" {
+				return &Error{Line: s.line, Message: fmt.Sprintf("%s outside of an %s block", branch, parent)}
+			}
+			return nil
+		}
+	}
+	if _, ok := firstKeyword(text, seqStatements...); ok {
+		return nil
+	}
+	if hasKeyword(text, "note") {
+		if !seqNote.MatchString(text) {
+			return &Error{Line: s.line, Message: "invalid note, expected \"Note left of|right of|over <participant>: text\""}
+		}
+		return nil
+	}
+
+	loc := seqArrow.FindStringIndex(text)
+	if loc == nil {
+		return &Error{Line: s.line, Message: fmt.Sprintf("unrecognized statement %q", text)}
+	}
+	from := strings.TrimSpace(text[:loc[0]])
+	to, message, hasText := strings.Cut(text[loc[1]:], ":")
+	to = strings.TrimSpace(strings.TrimLeft(to, "+- "))
+
+	switch {
+	case from == "":
+		return &Error{Line: s.line, Message: "message has no sender"}
+	case to == "":
+		return &Error{Line: s.line, Message: "message has no receiver"}
+	case !hasText || message == "":
+		return &Error{Line: s.line, Message: "message must be followed by \": text\""}
+	}
+	return nil
+}
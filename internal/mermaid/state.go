@@ -0,0 +1,83 @@
+package mermaid
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var (
+	stateNoteInline = regexp.MustCompile(`(?i)^note\s+(left|right)\s+of\s+\S+\s*:`)
+	stateNoteBlock  = regexp.MustCompile(`(?i)^note\s+(left|right)\s+of\s+\S+$`)
+	stateNoteAlias  = regexp.MustCompile(`(?i)^note\s+"[^"]*"\s+as\s+\S+$`)
+)
+
+func checkState(header statement, body []statement) []Error {
+	var errs []Error
+	var blocks blockStack
+	var note *statement
+
+	for i, s := range body {
+		text := s.text
+		if note != nil {
+			if strings.EqualFold(text, "end note") {
+				note = nil
+			}
+			continue
+		}
+
+		switch {
+		case hasKeyword(text, "state") && strings.HasSuffix(text, "{"):
+			blocks.push(s.line, "state block")
+		case text == "}":
+			if _, ok := blocks.pop(); !ok {
+				errs = append(errs, Error{Line: s.line, Message: "unexpected \"}\""})
+			}
+		case text == "--" || hasKeyword(text, "state"):
+		case hasKeyword(text, "note"):
+			switch {
+			case stateNoteBlock.MatchString(text):
+				note = &body[i]
+			case !stateNoteInline.MatchString(text) && !stateNoteAlias.MatchString(text):
+				errs = append(errs, Error{Line: s.line, Message: "invalid note, expected \"note left of|right of <state> : text\""})
+			}
+		default:
+			if _, ok := firstKeyword(text, "direction", "classDef", "class", "style", "hide", "scale", "title"); ok {
+				continue
+			}
+			if err := checkStateTransition(s); err != nil {
+				errs = append(errs, *err)
+			}
+		}
+	}
+
+	if note != nil {
+		errs = append(errs, Error{Line: note.line, Message: "note is never closed, expected \"end note\""})
+	}
+	return append(errs, blocks.unclosed()...)
+}
+
+// checkStateTransition checks a transition, a state description or a
+// bare state.
+func checkStateTransition(s statement) *Error {
+	arrow := strings.Index(s.text, "-->")
+	colon := strings.Index(s.text, ":")
+	switch {
+	case arrow >= 0 && (colon < 0 || arrow < colon):
+		from := strings.TrimSpace(s.text[:arrow])
+		to, _, _ := strings.Cut(s.text[arrow+3:], ":")
+		if from == "" {
+			return &Error{Line: s.line, Message: "transition has no source state"}
+		}
+		if strings.TrimSpace(to) == "" {
+			return &Error{Line: s.line, Message: "transition has no target state"}
+		}
+	case colon >= 0:
+		if strings.TrimSpace(s.text[:colon]) == "" {
+			return &Error{Line: s.line, Message: "description has no state"}
+		}
+	case strings.ContainsAny(s.text, " \t"):
+		return &Error{Line: s.line, Message: fmt.Sprintf("unrecognized statement %q", s.text)}
+	}
+	return nil
+}
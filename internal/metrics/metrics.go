@@ -0,0 +1,38 @@
+package metrics
+
+// renderBuckets are the upper bounds, in seconds, of render durations:
+// from a short spec to a large one full of diagrams.
+var renderBuckets = []float64{0.001, 0.0025, 0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5}
+
+var (
+	// Requests counts the HTTP requests by route template, method and
+	// status code.
+	Requests = Default.NewCounter("spec_viewer_http_requests_total",
+		"HTTP requests by route, method and status code.", "route", "method", "code")
+
+	// RenderDuration observes how long rendering a spec takes, by what it
+	// was rendered for: "view" for pages, "update" for changes pushed over
+	// the websocket, "pdf" and "html" for exports.
+	RenderDuration = Default.NewHistogram("spec_viewer_render_duration_seconds",
+		"Time spent rendering a spec, by purpose.", renderBuckets, "purpose")
+
+	// WatcherEvents counts the file system events seen by the watcher, by
+	// operation: "create", "write", "remove", "rename" or "chmod".
+	WatcherEvents = Default.NewCounter("spec_viewer_watcher_events_total",
+		"File system events seen by the watcher, by operation.", "op")
+
+	// CacheRequests counts the lookups of the in-memory caches, by cache
+	// and result, "hit" or "miss".
+	CacheRequests = Default.NewCounter("spec_viewer_cache_requests_total",
+		"Lookups of the in-memory caches, by cache and result.", "cache", "result")
+)
+
+// CacheLookup records a lookup of cache that found what it looked for
+// when hit is true.
+func CacheLookup(cache string, hit bool) {
+	if hit {
+		CacheRequests.Inc(cache, "hit")
+	} else {
+		CacheRequests.Inc(cache, "miss")
+	}
+}
@@ -0,0 +1,269 @@
+// Package metrics keeps counters, gauges and histograms and exposes them in
+// the Prometheus text format.
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Registry holds metrics by name and writes them in the Prometheus text
+// format.
+type Registry struct {
+	mu      sync.Mutex
+	metrics map[string]metric
+}
+
+// metric is a family of samples sharing a name, help and type.
+type metric interface {
+	// write writes the samples of the family, named name.
+	write(w io.Writer, name string)
+	help() string
+	kind() string
+}
+
+// NewRegistry returns an empty registry.
+func NewRegistry() *Registry {
+	return &Registry{metrics: make(map[string]metric)}
+}
+
+// Default is the registry of the metrics of this package and of those
+// registered by the server.
+var Default = NewRegistry()
+
+func (r *Registry) register(name string, m metric) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.metrics[name] = m
+}
+
+// NewCounter registers a counter named name, with a sample per
+// combination of values of labels.
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{desc: help, vec: newVec[*float64](labels)}
+	r.register(name, c)
+	return c
+}
+
+// NewHistogram registers a histogram named name that counts observations
+// in buckets, their upper bounds in increasing order.
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	h := &Histogram{desc: help, buckets: buckets, vec: newVec[*histogramSample](labels)}
+	r.register(name, h)
+	return h
+}
+
+// GaugeFunc registers a gauge named name whose value is read from value
+// when the metrics are written. Registering a name again replaces it.
+func (r *Registry) GaugeFunc(name, help string, value func() float64) {
+	r.register(name, gaugeFunc{desc: help, value: value})
+}
+
+// Write writes every metric to w, sorted by name.
+func (r *Registry) Write(w io.Writer) {
+	r.mu.Lock()
+	names := make([]string, 0, len(r.metrics))
+	for name := range r.metrics {
+		names = append(names, name)
+	}
+	metrics := make([]metric, len(names))
+	slices.Sort(names)
+	for i, name := range names {
+		metrics[i] = r.metrics[name]
+	}
+	r.mu.Unlock()
+
+	for i, name := range names {
+		m := metrics[i]
+		_, _ = fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, m.help(), name, m.kind())
+		m.write(w, name)
+	}
+}
+
+// Handler serves the metrics of r to Prometheus.
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// vec holds the samples of a metric by label values.
+type vec[S any] struct {
+	mu      sync.Mutex
+	labels  []string
+	samples map[string]S
+	values  map[string][]string
+}
+
+func newVec[S any](labels []string) vec[S] {
+	return vec[S]{labels: labels, samples: make(map[string]S), values: make(map[string][]string)}
+}
+
+// get returns the sample of values, creating it with create. v.mu must be
+// held.
+func (v *vec[S]) get(values []string, create func() S) S {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: got %d label values for labels %v", len(values), v.labels))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.samples[key]
+	if !ok {
+		s = create()
+		v.samples[key] = s
+		v.values[key] = slices.Clone(values)
+	}
+	return s
+}
+
+// each calls f with the label values and sample of every combination of
+// values, in a stable order. v.mu must be held.
+func (v *vec[S]) each(f func(values []string, s S)) {
+	keys := make([]string, 0, len(v.samples))
+	for key := range v.samples {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		f(v.values[key], v.samples[key])
+	}
+}
+
+// formatLabels returns the {name="value",…} suffix of a sample.
+func formatLabels(names, values []string, extra ...string) string {
+	var pairs []string
+	for i, name := range names {
+		pairs = append(pairs, name+`="`+escape(values[i])+`"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		pairs = append(pairs, extra[i]+`="`+extra[i+1]+`"`)
+	}
+	if len(pairs) == 0 {
+		return ""
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+var escaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+func escape(value string) string {
+	return escaper.Replace(value)
+}
+
+func formatFloat(f float64) string {
+	switch {
+	case math.IsInf(f, 1):
+		return "+Inf"
+	case math.IsInf(f, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// Counter is a value that only goes up, such as a number of requests.
+type Counter struct {
+	desc string
+	vec  vec[*float64]
+}
+
+// Inc adds one to the sample of values, given in the order of the labels
+// of c.
+func (c *Counter) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+// Add adds delta, which must not be negative, to the sample of values.
+func (c *Counter) Add(delta float64, values ...string) {
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+	*c.vec.get(values, func() *float64 { return new(float64) }) += delta
+}
+
+// Value returns the sample of values.
+func (c *Counter) Value(values ...string) float64 {
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+	return *c.vec.get(values, func() *float64 { return new(float64) })
+}
+
+func (c *Counter) help() string { return c.desc }
+func (c *Counter) kind() string { return "counter" }
+
+func (c *Counter) write(w io.Writer, name string) {
+	c.vec.mu.Lock()
+	defer c.vec.mu.Unlock()
+	c.vec.each(func(values []string, v *float64) {
+		_, _ = fmt.Fprintf(w, "%s%s %s\n", name, formatLabels(c.vec.labels, values), formatFloat(*v))
+	})
+}
+
+// Histogram counts observations, such as durations, in buckets.
+type Histogram struct {
+	desc    string
+	buckets []float64
+	vec     vec[*histogramSample]
+}
+
+type histogramSample struct {
+	// counts holds the observations per bucket, not cumulated, with the
+	// ones above the last bucket at the end.
+	counts []uint64
+	sum    float64
+}
+
+// Observe records v in the sample of values.
+func (h *Histogram) Observe(v float64, values ...string) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+	s := h.vec.get(values, func() *histogramSample {
+		return &histogramSample{counts: make([]uint64, len(h.buckets)+1)}
+	})
+	i, _ := slices.BinarySearch(h.buckets, v)
+	s.counts[i]++
+	s.sum += v
+}
+
+// Since observes the seconds elapsed from start in the sample of values.
+func (h *Histogram) Since(start time.Time, values ...string) {
+	h.Observe(time.Since(start).Seconds(), values...)
+}
+
+func (h *Histogram) help() string { return h.desc }
+func (h *Histogram) kind() string { return "histogram" }
+
+func (h *Histogram) write(w io.Writer, name string) {
+	h.vec.mu.Lock()
+	defer h.vec.mu.Unlock()
+	h.vec.each(func(values []string, s *histogramSample) {
+		var total uint64
+		for i, count := range s.counts {
+			total += count
+			le := math.Inf(1)
+			if i < len(h.buckets) {
+				le = h.buckets[i]
+			}
+			_, _ = fmt.Fprintf(w, "%s_bucket%s %d\n", name, formatLabels(h.vec.labels, values, "le", formatFloat(le)), total)
+		}
+		labels := formatLabels(h.vec.labels, values)
+		_, _ = fmt.Fprintf(w, "%s_sum%s %s\n%s_count%s %d\n", name, labels, formatFloat(s.sum), name, labels, total)
+	})
+}
+
+type gaugeFunc struct {
+	desc  string
+	value func() float64
+}
+
+func (g gaugeFunc) help() string { return g.desc }
+func (g gaugeFunc) kind() string { return "gauge" }
+
+func (g gaugeFunc) write(w io.Writer, name string) {
+	_, _ = fmt.Fprintf(w, "%s %s\n", name, formatFloat(g.value()))
+}
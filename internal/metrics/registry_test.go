@@ -0,0 +1,58 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistryWrite(t *testing.T) {
+	r := NewRegistry()
+	requests := r.NewCounter("requests_total", "Requests.", "route", "code")
+	requests.Inc("/view", "200")
+	requests.Add(2, "/view", "200")
+	requests.Inc(`/a"b`, "404")
+	durations := r.NewHistogram("render_seconds", "Render time.", []float64{0.1, 1}, "purpose")
+	durations.Observe(0.05, "view")
+	durations.Observe(0.1, "view")
+	durations.Observe(3, "view")
+	r.GaugeFunc("clients", "Clients.", func() float64 { return 2 })
+	r.GaugeFunc("clients", "Connected clients.", func() float64 { return 4 })
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	want := `# HELP clients Connected clients.
+# TYPE clients gauge
+clients 4
+# HELP render_seconds Render time.
+# TYPE render_seconds histogram
+render_seconds_bucket{purpose="view",le="0.1"} 2
+render_seconds_bucket{purpose="view",le="1"} 2
+render_seconds_bucket{purpose="view",le="+Inf"} 3
+render_seconds_sum{purpose="view"} 3.15
+render_seconds_count{purpose="view"} 3
+# HELP requests_total Requests.
+# TYPE requests_total counter
+requests_total{route="/a\"b",code="404"} 1
+requests_total{route="/view",code="200"} 3
+`
+	if got := rec.Body.String(); got != want {
+		t.Errorf("expected:\n%s\ngot:\n%s", want, got)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/plain; version=0.0.4") {
+		t.Errorf("unexpected content type %q", ct)
+	}
+	if v := requests.Value("/view", "200"); v != 3 {
+		t.Errorf("expected 3 requests, got %v", v)
+	}
+}
+
+func TestCounterPanicsOnWrongLabels(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Error("expected a panic for missing label values")
+		}
+	}()
+	NewRegistry().NewCounter("x_total", "X.", "a", "b").Inc("only one")
+}
@@ -0,0 +1,65 @@
+// Package private decides which files of the spec folder are kept private:
+// neither listed, nor served, nor included into a spec.
+package private
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPrivate is returned by ReadFile for a file that is private or outside
+// the spec folder.
+var ErrPrivate = errors.New("private file")
+
+// hidden holds the slash-separated paths, relative to the spec folder, of
+// the files that are neither listed nor served, such as a config file
+// given with --config.
+var hidden = make(map[string]bool)
+
+// Hide keeps the file at relPath, relative to the spec folder, out of the
+// sidebar and the server. It is meant to be called once at startup.
+func Hide(relPath string) {
+	hidden[filepath.ToSlash(filepath.Clean(relPath))] = true
+}
+
+// Hidden reports whether the file at relPath, relative to the spec folder,
+// is kept private: hidden files and folders, whose names start with a dot
+// like the config file's, and the files given to Hide.
+func Hidden(relPath string) bool {
+	relPath = filepath.ToSlash(filepath.Clean(relPath))
+	if hidden[relPath] {
+		return true
+	}
+	for _, segment := range strings.Split(relPath, "/") {
+		if strings.HasPrefix(segment, ".") && segment != "." {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFile reads the file at relPath in the spec folder root. It fails
+// with ErrPrivate when the file is hidden or outside root, including
+// through a symbolic link, so that a link to the config file is as private
+// as the file itself.
+func ReadFile(root, relPath string) ([]byte, error) {
+	if !filepath.IsLocal(relPath) || Hidden(relPath) {
+		return nil, fmt.Errorf("%w: %s", ErrPrivate, relPath)
+	}
+	realRoot, err := filepath.EvalSymlinks(root)
+	if err != nil {
+		return nil, err
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(root, relPath))
+	if err != nil {
+		return nil, err
+	}
+	rel, err := filepath.Rel(realRoot, realPath)
+	if err != nil || !filepath.IsLocal(rel) || Hidden(rel) {
+		return nil, fmt.Errorf("%w: %s", ErrPrivate, relPath)
+	}
+	return os.ReadFile(realPath)
+}
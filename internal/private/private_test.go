@@ -0,0 +1,67 @@
+package private
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestHidden(t *testing.T) {
+	Hide(filepath.Join("config", "viewer.yaml"))
+	defer delete(hidden, "config/viewer.yaml")
+
+	for path, want := range map[string]bool{
+		".spec-viewer.yaml":                    true,
+		"./.spec-viewer.yaml":                  true,
+		filepath.Join(".git", "notes.md"):      true,
+		filepath.Join("a", ".b", "c.md"):       true,
+		filepath.Join("config", "viewer.yaml"): true,
+		filepath.Join("config", "other.yaml"):  false,
+		filepath.Join("001-auth", "spec.md"):   false,
+		"./spec.md":                            false,
+	} {
+		if got := Hidden(path); got != want {
+			t.Errorf("Hidden(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(outside, "secret.txt"), "secret")
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "spec.md"), "# Spec")
+	writeFile(t, filepath.Join(root, ".spec-viewer.yaml"), "auth: {}")
+	symlink(t, filepath.Join(root, ".spec-viewer.yaml"), filepath.Join(root, "config.md"))
+	symlink(t, filepath.Join(outside, "secret.txt"), filepath.Join(root, "secret.md"))
+	symlink(t, filepath.Join(root, "spec.md"), filepath.Join(root, "alias.md"))
+
+	for _, name := range []string{"spec.md", "alias.md"} {
+		if content, err := ReadFile(root, name); err != nil || string(content) != "# Spec" {
+			t.Errorf("ReadFile(%q) = %q, %v, want the spec", name, content, err)
+		}
+	}
+	for _, name := range []string{".spec-viewer.yaml", "config.md", "secret.md", filepath.Join("..", "secret.txt")} {
+		if _, err := ReadFile(root, name); !errors.Is(err, ErrPrivate) {
+			t.Errorf("ReadFile(%q) error = %v, want ErrPrivate", name, err)
+		}
+	}
+	if _, err := ReadFile(root, "missing.md"); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("ReadFile(missing.md) error = %v, want not exist", err)
+	}
+}
+
+func writeFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symbolic links are not supported: %v", err)
+	}
+}
@@ -0,0 +1,365 @@
+package review
+
+import (
+	"bytes"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/internal/private"
+)
+
+//go:embed prompts/*.tmpl
+var prompts embed.FS
+
+// Export formats accepted by Exporter.Export.
+const (
+	FormatMarkdown = "markdown"
+	FormatJSON     = "json"
+	FormatPatch    = "patch"
+)
+
+// Names of the built-in prompt templates. User templates with the same name
+// replace them.
+const (
+	TemplateReview = "review"
+	TemplatePatch  = "patch"
+)
+
+var (
+	ErrUnknownFormat   = errors.New("unknown export format")
+	ErrUnknownTemplate = errors.New("unknown prompt template")
+	ErrInvalidPath     = errors.New("invalid file path")
+)
+
+// Thread is a review conversation as stored by the browser. Threads are
+// anchored to the source lines and content hash of a top-level block;
+// BlockIndex is only set by comments saved before line anchors existed.
+type Thread struct {
+	ID               string    `json:"id"`
+	StartLine        int       `json:"startLine,omitempty"`
+	EndLine          int       `json:"endLine,omitempty"`
+	Hash             string    `json:"hash,omitempty"`
+	BlockIndex       *int      `json:"blockIndex,omitempty"`
+	BlockTextPreview string    `json:"blockTextPreview"`
+	Resolved         bool      `json:"resolved,omitempty"`
+	ResolvedBy       string    `json:"resolvedBy,omitempty"`
+	ResolvedAt       string    `json:"resolvedAt,omitempty"`
+	Comments         []Comment `json:"comments"`
+}
+
+// Comment is a single message in a thread. The first comment opens the
+// thread and the rest are replies.
+type Comment struct {
+	ID        string `json:"id"`
+	Author    string `json:"author,omitempty"`
+	Text      string `json:"text"`
+	CreatedAt string `json:"createdAt"`
+}
+
+// FileThreads groups the threads left on one spec file.
+type FileThreads struct {
+	File    string   `json:"file"`
+	Threads []Thread `json:"threads"`
+}
+
+// Request describes what to export and how. Resolved threads are skipped
+// unless IncludeResolved is set.
+type Request struct {
+	Format          string        `json:"format"`
+	Template        string        `json:"template"`
+	IncludeResolved bool          `json:"includeResolved"`
+	Files           []FileThreads `json:"files"`
+}
+
+// Export is the data handed to prompt templates and the JSON output.
+type Export struct {
+	Files []File `json:"files"`
+}
+
+// File holds the commented sections of one spec file.
+type File struct {
+	Path     string    `json:"path"`
+	Sections []Section `json:"sections"`
+}
+
+// Section is a commented block with its source line range. StartLine and
+// EndLine are zero when the block no longer exists in the file.
+type Section struct {
+	StartLine int      `json:"startLine"`
+	EndLine   int      `json:"endLine"`
+	Preview   string   `json:"preview"`
+	Source    string   `json:"source"`
+	Threads   []Thread `json:"threads"`
+}
+
+// Exporter turns review comments into prompts for the spec folder.
+type Exporter struct {
+	folder          string
+	templates       map[string]string
+	defaultTemplate string
+}
+
+// NewExporter creates an Exporter for folder using the prompt templates
+// declared in cfg. Template files are read on every export so edits are
+// picked up without a restart.
+func NewExporter(folder string, cfg *config.Config) *Exporter {
+	if cfg == nil {
+		cfg = config.Default()
+	}
+
+	templates := make(map[string]string, len(cfg.Export.Templates))
+	for name, path := range cfg.Export.Templates {
+		templates[name] = cfg.Resolve(path)
+	}
+
+	defaultTemplate := cfg.Export.DefaultTemplate
+	if defaultTemplate == "" {
+		defaultTemplate = TemplateReview
+	}
+
+	return &Exporter{
+		folder:          folder,
+		templates:       templates,
+		defaultTemplate: defaultTemplate,
+	}
+}
+
+// Templates returns the names of all available prompt templates, with the
+// default template first.
+func (e *Exporter) Templates() []string {
+	seen := map[string]bool{e.defaultTemplate: true}
+	names := []string{e.defaultTemplate}
+
+	var rest []string
+	for _, name := range []string{TemplateReview, TemplatePatch} {
+		if !seen[name] {
+			seen[name] = true
+			rest = append(rest, name)
+		}
+	}
+	for name := range e.templates {
+		if !seen[name] {
+			seen[name] = true
+			rest = append(rest, name)
+		}
+	}
+	sort.Strings(rest)
+
+	return append(names, rest...)
+}
+
+// Export resolves the comments in req against the current spec sources and
+// renders them in the requested format. It returns the rendered output and
+// its content type.
+func (e *Exporter) Export(req Request) ([]byte, string, error) {
+	data, err := e.resolve(req.Files, req.IncludeResolved)
+	if err != nil {
+		return nil, "", err
+	}
+
+	switch req.Format {
+	case FormatJSON:
+		out, err := json.MarshalIndent(data, "", "  ")
+		if err != nil {
+			return nil, "", err
+		}
+		return out, "application/json", nil
+	case FormatMarkdown, "":
+		name := req.Template
+		if name == "" {
+			name = e.defaultTemplate
+		}
+		out, err := e.execute(name, data)
+		return out, "text/markdown; charset=utf-8", err
+	case FormatPatch:
+		out, err := e.execute(TemplatePatch, data)
+		return out, "text/plain; charset=utf-8", err
+	default:
+		return nil, "", fmt.Errorf("%w: %q", ErrUnknownFormat, req.Format)
+	}
+}
+
+// resolve attaches source line ranges to the threads and groups them by
+// block. Files that can no longer be read keep their threads without line
+// information.
+func (e *Exporter) resolve(files []FileThreads, includeResolved bool) (Export, error) {
+	var export Export
+
+	for _, fc := range files {
+		var threads []Thread
+		for _, t := range fc.Threads {
+			if len(t.Comments) > 0 && (includeResolved || !t.Resolved) {
+				threads = append(threads, t)
+			}
+		}
+		if len(threads) == 0 {
+			continue
+		}
+
+		// Hidden files, such as the config file, are as private here as in
+		// the viewer: read-only users may export comments too.
+		cleanPath := filepath.Clean(fc.File)
+		content, err := private.ReadFile(e.folder, cleanPath)
+		if !filepath.IsLocal(cleanPath) || errors.Is(err, private.ErrPrivate) {
+			return Export{}, fmt.Errorf("%w: %q", ErrInvalidPath, fc.File)
+		}
+
+		var doc *markdown.Document
+		var blocks []markdown.Block
+		if err == nil {
+			doc = markdown.ParseFile(e.folder, filepath.ToSlash(cleanPath), content)
+			blocks = doc.Blocks()
+		}
+
+		// Sections are keyed by block index; threads whose block cannot be
+		// found are keyed by their own (negated) position so they stay apart.
+		byBlock := make(map[int]*Section)
+		var keys []int
+		for i, t := range threads {
+			key := -1 - i
+			b, found := locate(blocks, t)
+			if found {
+				key = b.Index
+			}
+
+			s, ok := byBlock[key]
+			if !ok {
+				s = &Section{Preview: t.BlockTextPreview}
+				if found {
+					s.StartLine = b.StartLine
+					s.EndLine = b.EndLine
+					s.Preview = preview(b.Text)
+					s.Source = doc.SourceLines(b.StartLine, b.EndLine)
+				}
+				byBlock[key] = s
+				keys = append(keys, key)
+			}
+			s.Threads = append(s.Threads, t)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			// Anchored sections in source order, then unanchored ones.
+			if (keys[i] >= 0) != (keys[j] >= 0) {
+				return keys[i] >= 0
+			}
+			if keys[i] >= 0 {
+				return keys[i] < keys[j]
+			}
+			return keys[i] > keys[j]
+		})
+
+		file := File{Path: filepath.ToSlash(cleanPath)}
+		for _, key := range keys {
+			file.Sections = append(file.Sections, *byBlock[key])
+		}
+		export.Files = append(export.Files, file)
+	}
+
+	sort.SliceStable(export.Files, func(i, j int) bool {
+		return export.Files[i].Path < export.Files[j].Path
+	})
+	return export, nil
+}
+
+// locate finds the block a thread is anchored to: a block with the same
+// content hash (nearest to the stored lines if there are several), else the
+// block covering the stored start line, else the legacy block index.
+func locate(blocks []markdown.Block, c Thread) (markdown.Block, bool) {
+	if c.Hash != "" {
+		best, found := markdown.Block{}, false
+		for _, b := range blocks {
+			if b.Hash != c.Hash {
+				continue
+			}
+			if !found || abs(b.StartLine-c.StartLine) < abs(best.StartLine-c.StartLine) {
+				best, found = b, true
+			}
+		}
+		if found {
+			return best, true
+		}
+	}
+
+	if c.StartLine > 0 {
+		for _, b := range blocks {
+			if b.StartLine <= c.StartLine && c.StartLine <= b.EndLine {
+				return b, true
+			}
+		}
+		return markdown.Block{}, false
+	}
+
+	if c.BlockIndex != nil && *c.BlockIndex >= 0 && *c.BlockIndex < len(blocks) {
+		return blocks[*c.BlockIndex], true
+	}
+	return markdown.Block{}, false
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+// execute renders the named template, preferring a user-defined file over
+// the built-in template of the same name.
+func (e *Exporter) execute(name string, data Export) ([]byte, error) {
+	var src []byte
+	var err error
+	if path, ok := e.templates[name]; ok {
+		src, err = os.ReadFile(path)
+	} else {
+		if src, err = prompts.ReadFile("prompts/" + name + ".tmpl"); err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrUnknownTemplate, name)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading template %q: %w", name, err)
+	}
+
+	tmpl, err := template.New(name).Funcs(funcMap).Parse(string(src))
+	if err != nil {
+		return nil, fmt.Errorf("parsing template %q: %w", name, err)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, fmt.Errorf("executing template %q: %w", name, err)
+	}
+	return buf.Bytes(), nil
+}
+
+// funcMap provides helpers available in prompt templates.
+var funcMap = template.FuncMap{
+	// fence returns a backtick fence long enough to wrap s verbatim.
+	"fence": func(s string) string {
+		longest, run := 0, 0
+		for _, r := range s {
+			if r == '`' {
+				run++
+				longest = max(longest, run)
+			} else {
+				run = 0
+			}
+		}
+		return strings.Repeat("`", max(3, longest+1))
+	},
+}
+
+// preview truncates block text the same way the browser does.
+func preview(s string) string {
+	r := []rune(s)
+	if len(r) > 80 {
+		r = r[:80]
+	}
+	return string(r)
+}
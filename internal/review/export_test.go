@@ -0,0 +1,327 @@
+package review
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+)
+
+const sampleSpec = `# Payments
+
+Intro paragraph.
+
+## Retries
+
+Retry up to three times.
+`
+
+func newTestExporter(t *testing.T, cfg *config.Config) (*Exporter, string) {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "002-payments"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "002-payments", "spec.md"), []byte(sampleSpec), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if cfg == nil {
+		cfg = config.Default()
+	}
+	return NewExporter(dir, cfg), dir
+}
+
+func sampleRequest(format string) Request {
+	return Request{
+		Format: format,
+		Files: []FileThreads{{
+			File: "002-payments/spec.md",
+			Threads: []Thread{
+				{ID: "b", StartLine: 7, EndLine: 7, Comments: []Comment{
+					{ID: "b1", Author: "Ana", Text: "Make the retry count configurable"},
+					{ID: "b2", Author: "Ben", Text: "Agreed, default to three"},
+				}},
+				{ID: "a", StartLine: 3, EndLine: 3, Comments: []Comment{{ID: "a1", Text: "Clarify the scope"}}},
+				{ID: "c", StartLine: 7, EndLine: 7, Comments: []Comment{{ID: "c1", Text: "Mention backoff"}}},
+				{ID: "d", StartLine: 1, EndLine: 1, Resolved: true, Comments: []Comment{{ID: "d1", Text: "Rename"}}},
+			},
+		}},
+	}
+}
+
+func TestExport_Markdown(t *testing.T) {
+	e, _ := newTestExporter(t, nil)
+
+	out, contentType, err := e.Export(sampleRequest(FormatMarkdown))
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	if !strings.HasPrefix(contentType, "text/markdown") {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+
+	got := string(out)
+	for _, want := range []string{
+		"Apply each requested change to the specification file: 002-payments/spec.md",
+		`### Lines 3-3, section starting with: "Intro paragraph."`,
+		`### Lines 7-7, section starting with: "Retry up to three times."` +
+			"\n- Ana: Make the retry count configurable\n  - Ben: Agreed, default to three\n- Mention backoff",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+	if strings.Index(got, "Lines 3-3") > strings.Index(got, "Lines 7-7") {
+		t.Error("expected sections in source order")
+	}
+	if strings.Contains(got, "Rename") {
+		t.Error("expected resolved threads to be skipped")
+	}
+}
+
+func TestExport_IncludeResolved(t *testing.T) {
+	e, _ := newTestExporter(t, nil)
+
+	req := sampleRequest(FormatMarkdown)
+	req.IncludeResolved = true
+	out, _, err := e.Export(req)
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	if !strings.Contains(string(out), "Lines 1-1") {
+		t.Errorf("expected resolved thread to be included, got:\n%s", out)
+	}
+}
+
+func TestExport_JSON(t *testing.T) {
+	e, _ := newTestExporter(t, nil)
+
+	out, contentType, err := e.Export(sampleRequest(FormatJSON))
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	if contentType != "application/json" {
+		t.Errorf("unexpected content type %q", contentType)
+	}
+
+	var export Export
+	if err := json.Unmarshal(out, &export); err != nil {
+		t.Fatalf("invalid JSON: %v", err)
+	}
+	if len(export.Files) != 1 || len(export.Files[0].Sections) != 2 {
+		t.Fatalf("unexpected export shape: %+v", export)
+	}
+	s := export.Files[0].Sections[1]
+	if s.StartLine != 7 || s.EndLine != 7 || s.Source != "Retry up to three times." {
+		t.Errorf("unexpected section %+v", s)
+	}
+	if len(s.Threads) != 2 || len(s.Threads[0].Comments) != 2 {
+		t.Errorf("expected 2 threads with the first holding a reply, got %+v", s.Threads)
+	}
+}
+
+func TestExport_Patch(t *testing.T) {
+	e, _ := newTestExporter(t, nil)
+
+	out, _, err := e.Export(sampleRequest(FormatPatch))
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	got := string(out)
+	for _, want := range []string{
+		"unified diff",
+		"File: 002-payments/spec.md",
+		"Lines 7-7:\n```markdown\nRetry up to three times.\n```",
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("expected output to contain %q, got:\n%s", want, got)
+		}
+	}
+}
+
+func TestExport_MultipleFiles(t *testing.T) {
+	e, dir := newTestExporter(t, nil)
+	if err := os.WriteFile(filepath.Join(dir, "other.md"), []byte("Other text\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := sampleRequest(FormatMarkdown)
+	req.Files = append(req.Files, FileThreads{
+		File:    "other.md",
+		Threads: []Thread{{ID: "e", StartLine: 1, EndLine: 1, Comments: []Comment{{ID: "e1", Text: "Expand"}}}},
+	})
+
+	out, _, err := e.Export(req)
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	got := string(out)
+	if !strings.Contains(got, "## 002-payments/spec.md") || !strings.Contains(got, "## other.md") {
+		t.Errorf("expected a heading per file, got:\n%s", got)
+	}
+}
+
+func TestExport_MissingFileKeepsComments(t *testing.T) {
+	e, _ := newTestExporter(t, nil)
+
+	out, _, err := e.Export(Request{
+		Format: FormatJSON,
+		Files: []FileThreads{{
+			File: "gone.md",
+			Threads: []Thread{{
+				ID: "x", StartLine: 2, EndLine: 2, BlockTextPreview: "Old text",
+				Comments: []Comment{{ID: "x1", Text: "Still relevant"}},
+			}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+
+	var export Export
+	_ = json.Unmarshal(out, &export)
+	s := export.Files[0].Sections[0]
+	if s.StartLine != 0 || s.Preview != "Old text" || len(s.Threads) != 1 {
+		t.Errorf("unexpected section for missing file: %+v", s)
+	}
+}
+
+func TestExport_AnchorsByHashAfterEdit(t *testing.T) {
+	e, dir := newTestExporter(t, nil)
+
+	// Insert lines above the commented block so its line numbers shift.
+	edited := strings.Replace(sampleSpec, "Intro paragraph.\n", "Intro paragraph.\n\nA new paragraph\nspanning two lines.\n", 1)
+	if err := os.WriteFile(filepath.Join(dir, "002-payments", "spec.md"), []byte(edited), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := Request{
+		Format: FormatJSON,
+		Files: []FileThreads{{
+			File: "002-payments/spec.md",
+			Threads: []Thread{{
+				ID: "a", StartLine: 7, EndLine: 7, Hash: contentHashOf(t, "Retry up to three times."),
+				Comments: []Comment{{ID: "a1", Text: "Moved"}},
+			}},
+		}},
+	}
+	out, _, err := e.Export(req)
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+
+	var export Export
+	_ = json.Unmarshal(out, &export)
+	s := export.Files[0].Sections[0]
+	if s.StartLine != 10 || s.Source != "Retry up to three times." {
+		t.Errorf("expected comment to follow the block to line 10, got %+v", s)
+	}
+}
+
+func TestExport_LegacyBlockIndex(t *testing.T) {
+	e, _ := newTestExporter(t, nil)
+
+	idx := 3
+	out, _, err := e.Export(Request{
+		Format: FormatJSON,
+		Files: []FileThreads{{
+			File:    "002-payments/spec.md",
+			Threads: []Thread{{ID: "old", BlockIndex: &idx, Comments: []Comment{{ID: "old", Text: "From version 1"}}}},
+		}},
+	})
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+
+	var export Export
+	_ = json.Unmarshal(out, &export)
+	if s := export.Files[0].Sections[0]; s.StartLine != 7 {
+		t.Errorf("expected legacy index 3 to resolve to line 7, got %+v", s)
+	}
+}
+
+// contentHashOf returns the hash the renderer emits for a single-block
+// document containing src.
+func contentHashOf(t *testing.T, src string) string {
+	t.Helper()
+	blocks := markdown.Parse([]byte(src)).Blocks()
+	if len(blocks) != 1 {
+		t.Fatalf("expected one block, got %d", len(blocks))
+	}
+	return blocks[0].Hash
+}
+
+func TestExport_UserTemplate(t *testing.T) {
+	cfgDir := t.TempDir()
+	tmplPath := filepath.Join(cfgDir, "short.tmpl")
+	tmpl := `{{ range .Files }}{{ .Path }}:{{ range .Sections }} L{{ .StartLine }}{{ end }}{{ end }}`
+	if err := os.WriteFile(tmplPath, []byte(tmpl), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfgPath := filepath.Join(cfgDir, config.FileName)
+	cfgData := "export:\n  default_template: short\n  templates:\n    short: short.tmpl\n"
+	if err := os.WriteFile(cfgPath, []byte(cfgData), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(cfgPath, true)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+
+	e, _ := newTestExporter(t, cfg)
+
+	names := e.Templates()
+	if len(names) != 3 || names[0] != "short" {
+		t.Errorf("expected user default template first, got %v", names)
+	}
+
+	out, _, err := e.Export(sampleRequest(FormatMarkdown))
+	if err != nil {
+		t.Fatalf("Export returned error: %v", err)
+	}
+	if string(out) != "002-payments/spec.md: L3 L7" {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestExport_Errors(t *testing.T) {
+	e, dir := newTestExporter(t, nil)
+	if err := os.WriteFile(filepath.Join(dir, ".spec-viewer.yaml"), []byte("auth: {}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		req  Request
+		want error
+	}{
+		{"unknown format", Request{Format: "docx"}, ErrUnknownFormat},
+		{"unknown template", Request{Format: FormatMarkdown, Template: "nope"}, ErrUnknownTemplate},
+		{"traversal", Request{Files: []FileThreads{{File: "../secret.md", Threads: []Thread{{Comments: []Comment{{Text: "x"}}}}}}}, ErrInvalidPath},
+		{"absolute", Request{Files: []FileThreads{{File: "/etc/passwd", Threads: []Thread{{Comments: []Comment{{Text: "x"}}}}}}}, ErrInvalidPath},
+		{"hidden", Request{Files: []FileThreads{{File: ".spec-viewer.yaml", Threads: []Thread{{Comments: []Comment{{Text: "x"}}}}}}}, ErrInvalidPath},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, _, err := e.Export(tt.req)
+			if !errors.Is(err, tt.want) {
+				t.Errorf("expected %v, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestFence(t *testing.T) {
+	fence := funcMap["fence"].(func(string) string)
+	if got := fence("plain"); got != "```" {
+		t.Errorf("expected triple backticks, got %q", got)
+	}
+	if got := fence("```go\nx\n```"); got != "````" {
+		t.Errorf("expected four backticks, got %q", got)
+	}
+}
@@ -0,0 +1,25 @@
+package review
+
+import (
+	"os/exec"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+)
+
+// Author returns the name review comments are attributed to by default: the
+// configured author, else git's user.name as seen from the spec folder. It
+// returns an empty string when neither is set.
+func Author(folder string, cfg *config.Config) string {
+	if cfg != nil && cfg.Review.Author != "" {
+		return cfg.Review.Author
+	}
+
+	cmd := exec.Command("git", "config", "user.name")
+	cmd.Dir = folder
+	out, err := cmd.Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(out))
+}
@@ -0,0 +1,16 @@
+package review
+
+import (
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+)
+
+func TestAuthor_PrefersConfiguredName(t *testing.T) {
+	cfg := config.Default()
+	cfg.Review.Author = "Ana"
+
+	if got := Author(t.TempDir(), cfg); got != "Ana" {
+		t.Errorf("expected configured author, got %q", got)
+	}
+}
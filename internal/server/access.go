@@ -0,0 +1,26 @@
+package server
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+)
+
+// accessLog logs every request once it is served, with its status and how
+// long it took. Only the path is logged, so that a ?token= login does not
+// leak the token into the logs; websockets are logged when they close.
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		rec := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(rec, r)
+		logger.Info("Request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"status", rec.Status(),
+			"duration", time.Since(start),
+			"remote", r.RemoteAddr,
+		)
+	})
+}
@@ -0,0 +1,74 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+)
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	if err := logger.Configure(logger.Options{Level: slog.LevelInfo, Format: logger.FormatJSON, Output: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = logger.Configure(logger.Options{Output: os.Stdout}) }()
+
+	auth, err := NewAuth(config.AuthConfig{Tokens: []config.TokenConfig{{Token: "s3cret"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(socket.NewHub(), Config{Folder: t.TempDir(), Auth: auth, BasePath: "/specs"})
+
+	for _, path := range []string{"/specs/healthz", "/specs/?token=s3cret", "/specs/view"} {
+		srv.Handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	type entry struct {
+		Msg      string  `json:"msg"`
+		Method   string  `json:"method"`
+		Path     string  `json:"path"`
+		Status   int     `json:"status"`
+		Duration float64 `json:"duration"`
+	}
+	logged := buf.String()
+	if strings.Contains(logged, "s3cret") {
+		t.Error("expected the token to stay out of the access log")
+	}
+
+	var entries []entry
+	dec := json.NewDecoder(strings.NewReader(logged))
+	for dec.More() {
+		var e entry
+		if err := dec.Decode(&e); err != nil {
+			t.Fatal(err)
+		}
+		entries = append(entries, e)
+	}
+
+	want := []entry{
+		{Msg: "Request", Method: http.MethodGet, Path: "/specs/healthz", Status: http.StatusOK},
+		{Msg: "Request", Method: http.MethodGet, Path: "/specs/", Status: http.StatusSeeOther},
+		{Msg: "Request", Method: http.MethodGet, Path: "/specs/view", Status: http.StatusUnauthorized},
+	}
+	if len(entries) != len(want) {
+		t.Fatalf("expected %d entries, got %+v", len(want), entries)
+	}
+	for i, e := range entries {
+		if e.Duration <= 0 {
+			t.Errorf("expected the latency of %s, got %v", e.Path, e.Duration)
+		}
+		e.Duration = 0
+		if e != want[i] {
+			t.Errorf("entry %d = %+v, want %+v", i, e, want[i])
+		}
+	}
+}
@@ -0,0 +1,272 @@
+package server
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+)
+
+// Role is what an authenticated user may do.
+type Role int
+
+const (
+	// ReadOnly users can browse, export and review specs.
+	ReadOnly Role = iota + 1
+	// ReadWrite users can also make requests that change things on the
+	// server.
+	ReadWrite
+)
+
+// ParseRole parses a role name from the config file, "read-only" when
+// empty.
+func ParseRole(name string) (Role, error) {
+	switch name {
+	case "", "read-only":
+		return ReadOnly, nil
+	case "read-write":
+		return ReadWrite, nil
+	}
+	return 0, fmt.Errorf("unknown role %q, expected read-only or read-write", name)
+}
+
+// TokenCookie holds the session of a browser that logged in with a token.
+const TokenCookie = "spec_viewer_token"
+
+// publicPaths are served without authentication: the probes and metrics
+// of container platforms.
+var publicPaths = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+	"/metrics": true,
+}
+
+// readOnlyPosts are the POST endpoints read-only users may call, as they
+// change nothing on the server.
+var readOnlyPosts = map[string]bool{
+	"/api/comments/export": true,
+}
+
+type roleKey struct{}
+
+// RoleOf returns the role of the user that made r, ReadWrite when the
+// server requires no authentication.
+func RoleOf(r *http.Request) Role {
+	if role, ok := r.Context().Value(roleKey{}).(Role); ok {
+		return role
+	}
+	return ReadWrite
+}
+
+// Auth restricts the server to the holders of a shared token, given once
+// as ?token= in a link and then kept in a cookie, and to basic auth users.
+// An Auth without tokens or users lets everyone in.
+type Auth struct {
+	// tokens maps the hex SHA-256 of every token to its role.
+	tokens map[string]Role
+	// sessions maps the cookie of every token to its role. A cookie is
+	// signed with key, drawn when the server starts, so that neither a
+	// token nor the hash the config file may hold can be used as one.
+	sessions map[string]Role
+	key      []byte
+	users    map[string]user
+}
+
+type user struct {
+	// passwordHash is the hex SHA-256 of the password.
+	passwordHash string
+	role         Role
+}
+
+// NewAuth returns the Auth of the tokens and users in cfg.
+func NewAuth(cfg config.AuthConfig) (*Auth, error) {
+	a := &Auth{
+		tokens:   make(map[string]Role),
+		sessions: make(map[string]Role),
+		key:      make([]byte, sha256.Size),
+		users:    make(map[string]user),
+	}
+	rand.Read(a.key)
+	for _, t := range cfg.Tokens {
+		role, err := ParseRole(t.Role)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case t.Token != "" && t.TokenSHA256 != "":
+			return nil, fmt.Errorf("auth token with role %q has both a token and a token_sha256", t.Role)
+		case t.Token != "":
+			a.AddToken(t.Token, role)
+		case t.TokenSHA256 != "":
+			hash, err := parseHash(t.TokenSHA256)
+			if err != nil {
+				return nil, fmt.Errorf("auth token with role %q: %w", t.Role, err)
+			}
+			a.addHash(hash, role)
+		default:
+			return nil, fmt.Errorf("auth token with role %q is empty", t.Role)
+		}
+	}
+	for _, u := range cfg.Users {
+		role, err := ParseRole(u.Role)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case u.Name == "" || (u.Password == "" && u.PasswordSHA256 == ""):
+			return nil, fmt.Errorf("auth user %q needs a name and a password", u.Name)
+		case u.Password != "" && u.PasswordSHA256 != "":
+			return nil, fmt.Errorf("auth user %q has both a password and a password_sha256", u.Name)
+		case u.Password != "":
+			a.AddUser(u.Name, u.Password, role)
+		default:
+			hash, err := parseHash(u.PasswordSHA256)
+			if err != nil {
+				return nil, fmt.Errorf("auth user %q: %w", u.Name, err)
+			}
+			a.users[u.Name] = user{passwordHash: hash, role: role}
+		}
+	}
+	return a, nil
+}
+
+// AddToken lets in the holders of token with role.
+func (a *Auth) AddToken(token string, role Role) {
+	a.addHash(hashToken(token), role)
+}
+
+// addHash lets in the holders of the token whose hex SHA-256 is hash.
+func (a *Auth) addHash(hash string, role Role) {
+	a.tokens[hash] = role
+	a.sessions[a.session(hash)] = role
+}
+
+// session returns the cookie of the token whose hex SHA-256 is hash.
+func (a *Auth) session(hash string) string {
+	mac := hmac.New(sha256.New, a.key)
+	mac.Write([]byte(hash))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// AddUser lets in name, with password, as role.
+func (a *Auth) AddUser(name, password string, role Role) {
+	a.users[name] = user{passwordHash: hashToken(password), role: role}
+}
+
+// Enabled reports whether the server requires authentication.
+func (a *Auth) Enabled() bool {
+	return a != nil && (len(a.tokens) > 0 || len(a.users) > 0)
+}
+
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// parseHash checks that s is a hex SHA-256, as printed by sha256sum, and
+// returns it in lower case.
+func parseHash(s string) (string, error) {
+	b, err := hex.DecodeString(strings.TrimSpace(s))
+	if err != nil || len(b) != sha256.Size {
+		return "", fmt.Errorf("%q is not a hex SHA-256", s)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// Middleware lets through the requests of authenticated users allowed to
+// make them, and answers the others with 401 or 403. Static assets,
+// probes and metrics are public.
+func (a *Auth) Middleware(next http.Handler) http.Handler {
+	if !a.Enabled() {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasPrefix(r.URL.Path, "/public/") || publicPaths[r.URL.Path] {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// A login link: keep the token in a cookie and drop it from the
+		// address bar, so that it does not end up in history or shared links.
+		if token := r.URL.Query().Get("token"); token != "" {
+			if _, ok := a.tokens[hashToken(token)]; !ok {
+				http.Error(w, "Invalid token", http.StatusUnauthorized)
+				return
+			}
+			http.SetCookie(w, &http.Cookie{
+				Name:     TokenCookie,
+				Value:    a.session(hashToken(token)),
+				Path:     "/",
+				HttpOnly: true,
+				Secure:   isHTTPS(r),
+				SameSite: http.SameSiteLaxMode,
+			})
+			// RequestURI, unlike URL, still holds the base path.
+			if u, err := url.ParseRequestURI(r.RequestURI); err == nil && r.Method == http.MethodGet {
+				q := u.Query()
+				q.Del("token")
+				u.RawQuery = q.Encode()
+				http.Redirect(w, r, u.RequestURI(), http.StatusSeeOther)
+				return
+			}
+		}
+
+		role, ok := a.authenticate(r)
+		if !ok {
+			if len(a.users) > 0 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="Spec Viewer", charset="UTF-8"`)
+			}
+			http.Error(w, "Unauthorized: sign in or open the link shared with you", http.StatusUnauthorized)
+			return
+		}
+		if role < ReadWrite && !safeMethod(r.Method) && !readOnlyPosts[r.URL.Path] {
+			http.Error(w, "Forbidden: read-only access", http.StatusForbidden)
+			return
+		}
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), roleKey{}, role)))
+	})
+}
+
+// authenticate returns the role of the token in the query or cookie of r,
+// or of its basic auth user.
+func (a *Auth) authenticate(r *http.Request) (Role, bool) {
+	if token := r.URL.Query().Get("token"); token != "" {
+		role, ok := a.tokens[hashToken(token)]
+		return role, ok
+	}
+	if c, err := r.Cookie(TokenCookie); err == nil {
+		if role, ok := a.sessions[c.Value]; ok {
+			return role, true
+		}
+	}
+	if name, password, ok := r.BasicAuth(); ok {
+		u, found := a.users[name]
+		// Compare even for unknown users, so that timing does not tell
+		// which names exist.
+		match := subtle.ConstantTimeCompare([]byte(hashToken(password)), []byte(u.passwordHash)) == 1
+		if found && match {
+			return u.role, true
+		}
+	}
+	return 0, false
+}
+
+func safeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// LoginURL returns u with token added, so that opening it logs in.
+func LoginURL(u, token string) string {
+	if token == "" {
+		return u
+	}
+	return u + "/?token=" + url.QueryEscape(token)
+}
@@ -0,0 +1,202 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
+)
+
+func newTestAuth(t *testing.T) http.Handler {
+	t.Helper()
+	auth, err := NewAuth(config.AuthConfig{
+		Tokens: []config.TokenConfig{{Token: "viewer"}, {Token: "editor", Role: "read-write"}},
+		Users:  []config.UserConfig{{Name: "ana", Password: "s3cret"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if RoleOf(r) == ReadWrite {
+			_, _ = w.Write([]byte("read-write"))
+		} else {
+			_, _ = w.Write([]byte("read-only"))
+		}
+	}))
+}
+
+func TestAuthTokenLogin(t *testing.T) {
+	handler := newTestAuth(t)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/view?file=a.md&token=editor", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/view?file=a.md" {
+		t.Fatalf("expected a redirect without the token, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != TokenCookie || !cookies[0].HttpOnly || strings.Contains(cookies[0].Value, "editor") {
+		t.Fatalf("unexpected cookies %+v", cookies)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/api/anything", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK || rec.Body.String() != "read-write" {
+		t.Errorf("expected the cookie to log in as read-write, got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?token=wrong", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected a wrong token to be refused, got %d", rec.Code)
+	}
+}
+
+func TestAuthBasicAndRoles(t *testing.T) {
+	handler := newTestAuth(t)
+	for _, tt := range []struct {
+		name           string
+		method, path   string
+		user, password string
+		want           int
+		wantChallenge  bool
+	}{
+		{"anonymous", http.MethodGet, "/", "", "", http.StatusUnauthorized, true},
+		{"public assets", http.MethodGet, "/public/css/main.css", "", "", http.StatusOK, false},
+		{"wrong password", http.MethodGet, "/", "ana", "nope", http.StatusUnauthorized, true},
+		{"unknown user", http.MethodGet, "/", "bob", "s3cret", http.StatusUnauthorized, true},
+		{"read-only get", http.MethodGet, "/", "ana", "s3cret", http.StatusOK, false},
+		{"read-only post", http.MethodPost, "/api/anything", "ana", "s3cret", http.StatusForbidden, false},
+		{"read-only comment export", http.MethodPost, "/api/comments/export", "ana", "s3cret", http.StatusOK, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.user != "" {
+				req.SetBasicAuth(tt.user, tt.password)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != tt.want {
+				t.Errorf("expected %d, got %d", tt.want, rec.Code)
+			}
+			if got := rec.Header().Get("WWW-Authenticate") != ""; got != tt.wantChallenge {
+				t.Errorf("expected a basic auth challenge: %v, got %v", tt.wantChallenge, got)
+			}
+		})
+	}
+}
+
+func TestAuthDisabled(t *testing.T) {
+	var auth *Auth
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	if auth.Enabled() {
+		t.Error("expected a nil Auth to be disabled")
+	}
+	rec := httptest.NewRecorder()
+	auth.Middleware(next).ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected every request to pass, got %d", rec.Code)
+	}
+}
+
+func TestNewAuthRejectsInvalidSettings(t *testing.T) {
+	for _, cfg := range []config.AuthConfig{
+		{Tokens: []config.TokenConfig{{Token: "x", Role: "admin"}}},
+		{Tokens: []config.TokenConfig{{Role: "read-write"}}},
+		{Users: []config.UserConfig{{Name: "ana"}}},
+		{Tokens: []config.TokenConfig{{Token: "x", TokenSHA256: hashToken("x")}}},
+		{Tokens: []config.TokenConfig{{TokenSHA256: "not-a-hash"}}},
+		{Users: []config.UserConfig{{Name: "ana", PasswordSHA256: "abc"}}},
+	} {
+		if _, err := NewAuth(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
+
+func TestAuthHashedCredentials(t *testing.T) {
+	auth, err := NewAuth(config.AuthConfig{
+		Tokens: []config.TokenConfig{{TokenSHA256: strings.ToUpper(hashToken("editor")), Role: "read-write"}},
+		Users:  []config.UserConfig{{Name: "ana", PasswordSHA256: hashToken("s3cret")}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := auth.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/?token=editor", nil))
+	if rec.Code != http.StatusSeeOther {
+		t.Errorf("expected the hashed token to log in, got %d", rec.Code)
+	}
+
+	// The hash in the config file is not a cookie.
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(&http.Cookie{Name: TokenCookie, Value: hashToken("editor")})
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected the token hash to be refused as a cookie, got %d", rec.Code)
+	}
+
+	for password, want := range map[string]int{"s3cret": http.StatusOK, hashToken("s3cret"): http.StatusUnauthorized} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth("ana", password)
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != want {
+			t.Errorf("password %q: expected %d, got %d", password, want, rec.Code)
+		}
+	}
+}
+
+func TestAuthHidesConfigFile(t *testing.T) {
+	folder := t.TempDir()
+	settings := "auth:\n  tokens:\n    - token: viewer\n    - token: ADMINSECRET\n      role: read-write\n"
+	if err := os.WriteFile(filepath.Join(folder, config.FileName), []byte(settings), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.MkdirAll(filepath.Join(folder, ".git"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(folder, ".git", "notes.md"), []byte("ADMINSECRET"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	cfg, err := config.Load(filepath.Join(folder, config.FileName), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	auth, err := NewAuth(cfg.Auth)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(socket.NewHub(), Config{Folder: folder, Settings: cfg, Auth: auth})
+
+	for _, file := range []string{config.FileName, "./" + config.FileName, "sub/../" + config.FileName, ".git/notes.md"} {
+		for _, endpoint := range []string{"/view", "/api/view", "/api/export/pdf", "/api/export/html"} {
+			req := httptest.NewRequest(http.MethodGet, endpoint+"?file="+url.QueryEscape(file), nil)
+			req.AddCookie(&http.Cookie{Name: TokenCookie, Value: auth.session(hashToken("viewer"))})
+			rec := httptest.NewRecorder()
+			srv.Handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusSeeOther || strings.Contains(rec.Body.String(), "ADMINSECRET") {
+				t.Errorf("%s?file=%s: expected a redirect home, got %d %q", endpoint, file, rec.Code, rec.Body.String())
+			}
+		}
+	}
+}
+
+func TestLoginURL(t *testing.T) {
+	if got := LoginURL("http://192.168.1.20:9091", "a b"); got != "http://192.168.1.20:9091/?token=a+b" {
+		t.Errorf("unexpected login URL %q", got)
+	}
+	if got := LoginURL("http://localhost:9091", ""); got != "http://localhost:9091" {
+		t.Errorf("expected no token, got %q", got)
+	}
+}
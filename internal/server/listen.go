@@ -0,0 +1,49 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"syscall"
+)
+
+const (
+	// DefaultPort is the port the server listens on unless told otherwise.
+	DefaultPort = "9091"
+	// AutoPort picks the first free port from DefaultPort on.
+	AutoPort = "auto"
+	// autoPorts is how many ports AutoPort tries before letting the system
+	// choose one.
+	autoPorts = 20
+)
+
+// Listen opens the listener of the server on host and port, and returns
+// it with the port it got. Port "0" lets the system choose a free port and
+// AutoPort tries DefaultPort and the following ones first, so that the
+// port stays predictable when it can.
+func Listen(host, port string) (net.Listener, string, error) {
+	if port != AutoPort {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, port))
+		if errors.Is(err, syscall.EADDRINUSE) {
+			err = fmt.Errorf("port %s is already in use, try --port %s: %w", port, AutoPort, err)
+		}
+		return listened(ln, err)
+	}
+
+	first, _ := strconv.Atoi(DefaultPort)
+	for p := first; p < first+autoPorts; p++ {
+		ln, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(p)))
+		if err == nil {
+			return listened(ln, nil)
+		}
+	}
+	return listened(net.Listen("tcp", net.JoinHostPort(host, "0")))
+}
+
+func listened(ln net.Listener, err error) (net.Listener, string, error) {
+	if err != nil {
+		return nil, "", err
+	}
+	return ln, strconv.Itoa(ln.Addr().(*net.TCPAddr).Port), nil
+}
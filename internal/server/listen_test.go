@@ -0,0 +1,39 @@
+package server
+
+import (
+	"net"
+	"strings"
+	"testing"
+)
+
+func TestListen(t *testing.T) {
+	ln, port, err := Listen("127.0.0.1", "0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	if port == "0" || port == "" {
+		t.Fatalf("expected the port chosen by the system, got %q", port)
+	}
+
+	// The same port is taken now.
+	if _, _, err := Listen("127.0.0.1", port); err == nil || !strings.Contains(err.Error(), "--port auto") {
+		t.Errorf("expected a busy port to suggest --port auto, got %v", err)
+	}
+}
+
+func TestListenAuto(t *testing.T) {
+	// Keep the default port busy, unless something else already does.
+	if busy, err := net.Listen("tcp", "127.0.0.1:"+DefaultPort); err == nil {
+		defer func() { _ = busy.Close() }()
+	}
+
+	ln, port, err := Listen("127.0.0.1", AutoPort)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = ln.Close() }()
+	if port == DefaultPort {
+		t.Errorf("expected another port than the busy default one")
+	}
+}
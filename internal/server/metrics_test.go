@@ -0,0 +1,70 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
+)
+
+func TestProbesAndMetrics(t *testing.T) {
+	auth, err := NewAuth(config.AuthConfig{Tokens: []config.TokenConfig{{Token: "s3cret"}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := New(socket.NewHub(), Config{Folder: t.TempDir(), Auth: auth})
+	get := func(path string) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		return rec
+	}
+
+	before := metrics.Requests.Value("/healthz", http.MethodGet, "200")
+	notFound := metrics.Requests.Value(notFoundRoute, http.MethodGet, "404")
+
+	if rec := get("/healthz"); rec.Code != http.StatusOK {
+		t.Errorf("expected /healthz to be open, got %d", rec.Code)
+	}
+
+	// Neither the templates nor the watcher run in this test.
+	rec := get("/readyz")
+	var ready struct {
+		Status string          `json:"status"`
+		Checks map[string]bool `json:"checks"`
+	}
+	if err := json.NewDecoder(rec.Body).Decode(&ready); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Code != http.StatusServiceUnavailable || ready.Status != "unavailable" || len(ready.Checks) != 2 || ready.Checks["watcher"] {
+		t.Errorf("expected /readyz to report the watcher down, got %d %+v", rec.Code, ready)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/nowhere", nil)
+	req.AddCookie(&http.Cookie{Name: TokenCookie, Value: auth.session(hashToken("s3cret"))})
+	srv.Handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	rec = get("/metrics")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected /metrics to be open, got %d", rec.Code)
+	}
+	for _, want := range []string{
+		"# TYPE spec_viewer_http_requests_total counter",
+		"# TYPE spec_viewer_render_duration_seconds histogram",
+		"spec_viewer_websocket_clients 0",
+	} {
+		if !strings.Contains(rec.Body.String(), want) {
+			t.Errorf("expected the metrics to contain %q, got:\n%s", want, rec.Body.String())
+		}
+	}
+	if got := metrics.Requests.Value("/healthz", http.MethodGet, "200"); got != before+1 {
+		t.Errorf("expected one more /healthz request, got %v after %v", got, before)
+	}
+	if got := metrics.Requests.Value(notFoundRoute, http.MethodGet, "404"); got != notFound+1 {
+		t.Errorf("expected one more request to a missing page, got %v after %v", got, notFound)
+	}
+}
@@ -0,0 +1,127 @@
+package server
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"path"
+	"strings"
+)
+
+// BasePath normalizes p, the path the server is mounted under: "specs/"
+// and "/specs" both become "/specs", and "" or "/" the root, "".
+func BasePath(p string) string {
+	p = path.Clean("/" + strings.Trim(p, "/"))
+	if p == "/" {
+		return ""
+	}
+	return p
+}
+
+// mount serves h under base, with base stripped from the request path.
+// base itself redirects to base + "/", and other paths are not found.
+func mount(base string, h http.Handler) http.Handler {
+	if base == "" {
+		return h
+	}
+	strip := http.StripPrefix(base, h)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == base:
+			target := base + "/"
+			if r.URL.RawQuery != "" {
+				target += "?" + r.URL.RawQuery
+			}
+			http.Redirect(w, r, target, http.StatusMovedPermanently)
+		case strings.HasPrefix(r.URL.Path, base+"/"):
+			strip.ServeHTTP(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+// ParseProxies parses the addresses of trusted reverse proxies: IP
+// addresses, such as 127.0.0.1, or CIDR ranges, such as 10.0.0.0/8.
+func ParseProxies(addrs []string) ([]netip.Prefix, error) {
+	var proxies []netip.Prefix
+	for _, a := range addrs {
+		a = strings.TrimSpace(a)
+		if a == "" {
+			continue
+		}
+		if strings.Contains(a, "/") {
+			prefix, err := netip.ParsePrefix(a)
+			if err != nil {
+				return nil, fmt.Errorf("invalid proxy range %q: %w", a, err)
+			}
+			proxies = append(proxies, prefix.Masked())
+			continue
+		}
+		ip, err := netip.ParseAddr(a)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy address %q: %w", a, err)
+		}
+		ip = ip.Unmap()
+		proxies = append(proxies, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return proxies, nil
+}
+
+// forwarded applies the X-Forwarded-Host and X-Forwarded-Proto headers of a
+// reverse proxy to r, so that the same-origin check of /ws compares the
+// page's origin with the host the browser used, and cookies are marked
+// secure behind an HTTPS proxy. Only the headers of requests from trusted
+// proxies are applied, as any other client could set them to pass the
+// origin check.
+func forwarded(trusted []netip.Prefix, next http.Handler) http.Handler {
+	if len(trusted) == 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !fromProxy(trusted, r) {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if host := firstValue(r.Header.Get("X-Forwarded-Host")); host != "" {
+			r.Host = host
+		}
+		if proto := firstValue(r.Header.Get("X-Forwarded-Proto")); proto != "" {
+			r.URL.Scheme = strings.ToLower(proto)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// fromProxy reports whether r comes from one of the trusted proxies.
+func fromProxy(trusted []netip.Prefix, r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return false
+	}
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return false
+	}
+	ip = ip.Unmap()
+	for _, p := range trusted {
+		if p.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// firstValue returns the first of the comma-separated values of a header
+// that proxies append to, which is the one the browser's request set.
+func firstValue(header string) string {
+	v, _, _ := strings.Cut(header, ",")
+	return strings.TrimSpace(v)
+}
+
+// isHTTPS reports whether the browser reached the server over HTTPS,
+// directly or through a proxy.
+func isHTTPS(r *http.Request) bool {
+	return r.TLS != nil || r.URL.Scheme == "https"
+}
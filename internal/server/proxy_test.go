@@ -0,0 +1,106 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
+	"github.com/gorilla/websocket"
+)
+
+func TestBasePath(t *testing.T) {
+	for in, want := range map[string]string{"": "", "/": "", "specs": "/specs", "/specs/": "/specs", "/a//b/": "/a/b"} {
+		if got := BasePath(in); got != want {
+			t.Errorf("BasePath(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestNewWithBasePath(t *testing.T) {
+	srv := New(socket.NewHub(), Config{Folder: t.TempDir(), BasePath: "/specs"})
+	for _, tt := range []struct {
+		path     string
+		want     int
+		location string
+	}{
+		{"/specs/public/css/main.css", http.StatusOK, ""},
+		{"/specs?file=a.md", http.StatusMovedPermanently, "/specs/?file=a.md"},
+		{"/public/css/main.css", http.StatusNotFound, ""},
+		{"/specsx/public/css/main.css", http.StatusNotFound, ""},
+	} {
+		rec := httptest.NewRecorder()
+		srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != tt.want || rec.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s: expected %d %q, got %d %q", tt.path, tt.want, tt.location, rec.Code, rec.Header().Get("Location"))
+		}
+	}
+}
+
+func TestParseProxies(t *testing.T) {
+	proxies, err := ParseProxies([]string{"127.0.0.1", " 10.1.2.3/8", "::1", ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("127.0.0.1/32"),
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("::1/128"),
+	}
+	if !slices.Equal(proxies, want) {
+		t.Errorf("expected %v, got %v", want, proxies)
+	}
+	for _, bad := range []string{"proxy.local", "10.0.0.0/33"} {
+		if _, err := ParseProxies([]string{bad}); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
+
+func TestNewBehindProxy(t *testing.T) {
+	dial := func(proxies []string, header http.Header) error {
+		t.Helper()
+		trusted, err := ParseProxies(proxies)
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := httptest.NewServer(New(socket.NewHub(), Config{Folder: t.TempDir(), BasePath: "/specs", TrustedProxies: trusted}).Handler)
+		defer srv.Close()
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/specs/ws", header)
+		if err == nil {
+			_ = conn.Close()
+		}
+		return err
+	}
+
+	// The proxy forwards the browser's host, which the page's origin names.
+	header := http.Header{"Origin": {"https://docs.example.com"}, "X-Forwarded-Host": {"docs.example.com"}, "X-Forwarded-Proto": {"https"}}
+	if err := dial([]string{"127.0.0.1"}, header); err != nil {
+		t.Fatalf("expected the websocket to accept the forwarded host: %v", err)
+	}
+
+	// Clients other than the trusted proxies cannot forge the host to pass
+	// the origin check.
+	if err := dial(nil, header); err == nil {
+		t.Error("expected the forwarded host to be ignored without trusted proxies")
+	}
+	if err := dial([]string{"10.0.0.0/8"}, header); err == nil {
+		t.Error("expected the forwarded host of an untrusted client to be ignored")
+	}
+
+	header.Set("X-Forwarded-Host", "other.example.com")
+	if err := dial([]string{"127.0.0.1"}, header); err == nil {
+		t.Error("expected an origin that does not match the forwarded host to be refused")
+	}
+}
+
+func TestAuthTokenLoginUnderBasePath(t *testing.T) {
+	rec := httptest.NewRecorder()
+	mount("/specs", newTestAuth(t)).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/specs/view?file=a.md&token=viewer", nil))
+	if rec.Code != http.StatusSeeOther || rec.Header().Get("Location") != "/specs/view?file=a.md" {
+		t.Errorf("expected a redirect under the base path, got %d %q", rec.Code, rec.Header().Get("Location"))
+	}
+}
@@ -1,21 +1,46 @@
 package server
 
 import (
+	"crypto/tls"
 	"io/fs"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
+	"net/netip"
 	"strings"
 
+	"github.com/SantiagoBobrik/spec-viewer/internal/activity"
+	"github.com/SantiagoBobrik/spec-viewer/internal/config"
 	"github.com/SantiagoBobrik/spec-viewer/internal/handlers"
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+	"github.com/SantiagoBobrik/spec-viewer/internal/review"
 	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
+	"github.com/SantiagoBobrik/spec-viewer/internal/templates"
+	"github.com/SantiagoBobrik/spec-viewer/internal/watcher"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
 	"github.com/SantiagoBobrik/spec-viewer/web"
 
 	"github.com/gorilla/mux"
 )
 
 type Config struct {
-	Port   string
-	Folder string
+	// Host is the interface to listen on, "" or "0.0.0.0" for all of them.
+	Host     string
+	Port     string
+	Folder   string
+	Settings *config.Config
+	// Activity records the specs viewed, for the home page.
+	Activity *activity.Log
+	// TLS, when set, serves HTTPS instead of HTTP.
+	TLS *tls.Config
+	// Auth, when set, restricts who can use the server.
+	Auth *Auth
+	// BasePath is the path the server is mounted under, as returned by
+	// BasePath, for reverse proxies that forward a subpath.
+	BasePath string
+	// TrustedProxies are the reverse proxies whose X-Forwarded-Host and
+	// X-Forwarded-Proto headers are applied, as returned by ParseProxies.
+	TrustedProxies []netip.Prefix
 }
 
 func noDirectoryListing(next http.Handler) http.Handler {
@@ -28,26 +53,49 @@ func noDirectoryListing(next http.Handler) http.Handler {
 	})
 }
 
-func New(hub *socket.Hub, config Config) *http.Server {
+func New(hub *socket.Hub, cfg Config) *http.Server {
 	r := mux.NewRouter()
 
-	r.NotFoundHandler = handlers.NotFoundHandler()
+	r.NotFoundHandler = countRequests(handlers.NotFoundHandler())
+	r.Use(countRequests)
 
-	r.HandleFunc("/", handlers.HomeHandler())
-	r.HandleFunc("/view", handlers.ViewSpecHandler(config.Folder))
-	r.HandleFunc("/api/view", handlers.ViewContentHandler(config.Folder))
+	r.HandleFunc("/", handlers.HomeHandler(cfg.Folder, cfg.Activity))
+	r.HandleFunc("/view", handlers.ViewSpecHandler(cfg.Folder, cfg.Activity))
+	r.HandleFunc("/api/view", handlers.ViewContentHandler(cfg.Folder))
+	r.HandleFunc("/api/export/pdf", handlers.ExportPDFHandler(cfg.Folder)).Methods(http.MethodGet)
+	r.HandleFunc("/api/export/html", handlers.ExportHTMLHandler(cfg.Folder)).Methods(http.MethodGet)
+
+	exporter := review.NewExporter(cfg.Folder, cfg.Settings)
+	r.HandleFunc("/api/comments/export", handlers.CommentsExportHandler(exporter)).Methods(http.MethodPost)
+	r.HandleFunc("/api/comments/templates", handlers.CommentTemplatesHandler(exporter)).Methods(http.MethodGet)
+	r.HandleFunc("/api/identity", handlers.IdentityHandler(review.Author(cfg.Folder, cfg.Settings))).Methods(http.MethodGet)
 
 	publicFS, err := fs.Sub(web.Files, "public")
 	if err != nil {
-		log.Fatalf("Error creating public filesystem: %v", err)
+		logger.Fatal("Failed to create public filesystem", "error", err)
 	}
 
 	r.PathPrefix("/public/").Handler(http.StripPrefix("/public/", noDirectoryListing(http.FileServer(http.FS(publicFS)))))
 
 	r.HandleFunc("/ws", handlers.WebSocketHandler(hub))
 
+	// Probes and metrics for container platforms, open without auth.
+	r.HandleFunc("/healthz", handlers.HealthHandler()).Methods(http.MethodGet, http.MethodHead)
+	r.HandleFunc("/readyz", handlers.ReadyHandler(
+		handlers.Check{Name: "templates", Ready: templates.Ready},
+		handlers.Check{Name: "watcher", Ready: watcher.Running},
+	)).Methods(http.MethodGet, http.MethodHead)
+	r.Handle("/metrics", metrics.Default.Handler()).Methods(http.MethodGet)
+	metrics.Default.GaugeFunc("spec_viewer_websocket_clients", "Connected websocket clients.", func() float64 {
+		return float64(hub.Len())
+	})
+
 	return &http.Server{
-		Addr:    ":" + config.Port,
-		Handler: r,
+		Addr:      net.JoinHostPort(cfg.Host, cfg.Port),
+		Handler:   forwarded(cfg.TrustedProxies, accessLog(mount(cfg.BasePath, cfg.Auth.Middleware(r)))),
+		TLSConfig: cfg.TLS,
+		// net/http reports mostly clients that fail a TLS handshake or drop
+		// the connection, logged with the rest rather than by package log.
+		ErrorLog: logger.StdLogger(slog.LevelWarn),
 	}
 }
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"math/big"
+	"net"
+	"net/url"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
+)
+
+// Files of the self-signed certificate and its key, kept in the state
+// directory so that browsers that accepted it once keep trusting it.
+const (
+	selfSignedCert = "self-signed.pem"
+	selfSignedKey  = "self-signed-key.pem"
+)
+
+// renewBefore is how long before it expires a self-signed certificate is
+// replaced.
+const renewBefore = 7 * 24 * time.Hour
+
+// TLSConfig returns the TLS configuration of the server: the certificate
+// in certFile and keyFile, or a self-signed one valid for the hosts of
+// urls when both are empty. The self-signed certificate is kept in
+// stateDir, when given, and generated again only once it is about to
+// expire or the hosts change.
+func TLSConfig(certFile, keyFile string, urls []string, stateDir string) (*tls.Config, error) {
+	var cert tls.Certificate
+	var err error
+	switch {
+	case certFile != "" && keyFile != "":
+		cert, err = tls.LoadX509KeyPair(certFile, keyFile)
+	case certFile != "" || keyFile != "":
+		return nil, errors.New("a TLS certificate needs both a certificate and a key file")
+	default:
+		cert, err = selfSigned(urls, stateDir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &tls.Config{Certificates: []tls.Certificate{cert}, MinVersion: tls.VersionTLS12}, nil
+}
+
+// selfSigned returns the certificate kept in dir if it is still valid for
+// the hosts of urls, or else a new one, which it keeps in dir.
+func selfSigned(urls []string, dir string) (tls.Certificate, error) {
+	dnsNames, ips := hosts(urls)
+	if dir == "" {
+		return generate(dnsNames, ips)
+	}
+
+	certPath, keyPath := filepath.Join(dir, selfSignedCert), filepath.Join(dir, selfSignedKey)
+	if cert, err := tls.LoadX509KeyPair(certPath, keyPath); err == nil && reusable(cert, dnsNames, ips) {
+		return cert, nil
+	}
+	cert, err := generate(dnsNames, ips)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	if err := save(cert, certPath, keyPath); err != nil {
+		logger.Warn("Failed to keep the self-signed certificate, browsers will warn again next time", "error", err)
+	}
+	return cert, nil
+}
+
+// hosts returns the names and addresses a certificate for urls is valid
+// for: those of urls and of the loopback interface.
+func hosts(urls []string) ([]string, []net.IP) {
+	dnsNames := []string{"localhost"}
+	ips := []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback}
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		if ip := net.ParseIP(u.Hostname()); ip != nil {
+			ips = append(ips, ip)
+		} else if u.Hostname() != "localhost" {
+			dnsNames = append(dnsNames, u.Hostname())
+		}
+	}
+	return dnsNames, ips
+}
+
+// reusable reports whether cert is valid for exactly dnsNames and ips for
+// a while longer.
+func reusable(cert tls.Certificate, dnsNames []string, ips []net.IP) bool {
+	leaf, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil || time.Now().Add(renewBefore).After(leaf.NotAfter) {
+		return false
+	}
+	return slices.Equal(hostSet(leaf.DNSNames, leaf.IPAddresses), hostSet(dnsNames, ips))
+}
+
+// hostSet returns dnsNames and ips as sorted strings without duplicates.
+func hostSet(dnsNames []string, ips []net.IP) []string {
+	set := slices.Clone(dnsNames)
+	for _, ip := range ips {
+		set = append(set, ip.String())
+	}
+	slices.Sort(set)
+	return slices.Compact(set)
+}
+
+// generate creates a certificate for dnsNames and ips, valid for a year.
+// Browsers warn about it until it is accepted once.
+func generate(dnsNames []string, ips []net.IP) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      pkix.Name{Organization: []string{"Spec Viewer"}, CommonName: "localhost"},
+		NotBefore:    now.Add(-time.Hour),
+		NotAfter:     now.AddDate(1, 0, 0),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		DNSNames:     dnsNames,
+		IPAddresses:  ips,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
+
+// save writes cert to certPath and its private key, readable only by the
+// user, to keyPath.
+func save(cert tls.Certificate, certPath, keyPath string) error {
+	key, err := x509.MarshalECPrivateKey(cert.PrivateKey.(*ecdsa.PrivateKey))
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(certPath), 0o700); err != nil {
+		return err
+	}
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: key})
+	if err := os.WriteFile(keyPath, keyPEM, 0o600); err != nil {
+		return err
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Certificate[0]})
+	return os.WriteFile(certPath, certPEM, 0o644)
+}
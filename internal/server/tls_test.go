@@ -0,0 +1,99 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestTLSConfigSelfSigned(t *testing.T) {
+	cfg, err := TLSConfig("", "", []string{"https://localhost:9091", "https://192.168.1.20:9091", "https://specs.lan:9091"}, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, host := range []string{"localhost", "127.0.0.1", "192.168.1.20", "specs.lan"} {
+		if err := cert.VerifyHostname(host); err != nil {
+			t.Errorf("expected the certificate to be valid for %s: %v", host, err)
+		}
+	}
+	if !cert.IPAddresses[len(cert.IPAddresses)-1].Equal(net.IPv4(192, 168, 1, 20)) {
+		t.Errorf("unexpected IP addresses %v", cert.IPAddresses)
+	}
+}
+
+func TestTLSConfigKeepsSelfSigned(t *testing.T) {
+	dir := t.TempDir()
+	serial := func(urls ...string) string {
+		t.Helper()
+		cfg, err := TLSConfig("", "", urls, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		cert, err := x509.ParseCertificate(cfg.Certificates[0].Certificate[0])
+		if err != nil {
+			t.Fatal(err)
+		}
+		return cert.SerialNumber.String()
+	}
+
+	first := serial("https://localhost:9091", "https://192.168.1.20:9091")
+	if info, err := os.Stat(filepath.Join(dir, selfSignedKey)); err != nil || info.Mode().Perm()&0o077 != 0 {
+		t.Fatalf("expected a private key readable only by the user, got %v, %v", info, err)
+	}
+	if got := serial("https://localhost:9092", "https://192.168.1.20:9092"); got != first {
+		t.Error("expected the certificate to be kept across restarts on the same hosts")
+	}
+
+	// Another network address needs a new certificate, which is kept too.
+	second := serial("https://localhost:9091", "https://192.168.1.30:9091")
+	if second == first {
+		t.Error("expected a new certificate for other hosts")
+	}
+	if got := serial("https://localhost:9091", "https://192.168.1.30:9091"); got != second {
+		t.Error("expected the new certificate to be kept")
+	}
+
+	// A certificate about to expire is replaced.
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback, net.IPv4(192, 168, 1, 30)},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := save(tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, filepath.Join(dir, selfSignedCert), filepath.Join(dir, selfSignedKey)); err != nil {
+		t.Fatal(err)
+	}
+	if got := serial("https://localhost:9091", "https://192.168.1.30:9091"); got == "1" || got == second {
+		t.Error("expected a certificate about to expire to be replaced")
+	}
+}
+
+func TestTLSConfigNeedsCertAndKey(t *testing.T) {
+	if _, err := TLSConfig("cert.pem", "", nil, ""); err == nil {
+		t.Error("expected an error for a certificate without a key")
+	}
+	if _, err := TLSConfig("missing.pem", "missing-key.pem", nil, ""); err == nil {
+		t.Error("expected an error for missing files")
+	}
+}
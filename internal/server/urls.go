@@ -0,0 +1,52 @@
+package server
+
+import (
+	"net"
+	"net/url"
+)
+
+// DefaultHost is the interface the server binds to unless told otherwise,
+// so that specs are not shared with the network by accident.
+const DefaultHost = "127.0.0.1"
+
+// URLs returns the URL to open the server on this machine and, when it
+// listens on other interfaces, the URLs other machines can reach it at.
+func URLs(host, port string, secure bool) (local string, network []string) {
+	addrs, _ := net.InterfaceAddrs()
+	return urls(host, port, secure, addrs)
+}
+
+func urls(host, port string, secure bool, addrs []net.Addr) (string, []string) {
+	scheme := "http"
+	if secure {
+		scheme = "https"
+	}
+	link := func(h string) string {
+		return (&url.URL{Scheme: scheme, Host: net.JoinHostPort(h, port)}).String()
+	}
+
+	ip := net.ParseIP(host)
+	switch {
+	case host == "localhost" || ip != nil && ip.IsLoopback():
+		return link("localhost"), nil
+	case host != "" && !ip.IsUnspecified():
+		// Bound to a single interface, which is not reachable as localhost.
+		return link(host), []string{link(host)}
+	}
+
+	// Bound to every interface: list their addresses, IPv4 first as they
+	// are the easiest to type.
+	var v4, v6 []string
+	for _, addr := range addrs {
+		n, ok := addr.(*net.IPNet)
+		if !ok || n.IP.IsLoopback() || n.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		if n.IP.To4() != nil {
+			v4 = append(v4, link(n.IP.String()))
+		} else if host != "0.0.0.0" {
+			v6 = append(v6, link(n.IP.String()))
+		}
+	}
+	return link("localhost"), append(v4, v6...)
+}
@@ -0,0 +1,33 @@
+package server
+
+import (
+	"net"
+	"slices"
+	"testing"
+)
+
+func TestURLs(t *testing.T) {
+	addrs := []net.Addr{
+		&net.IPNet{IP: net.IPv4(127, 0, 0, 1)},
+		&net.IPNet{IP: net.ParseIP("fe80::1")},
+		&net.IPNet{IP: net.ParseIP("2001:db8::5")},
+		&net.IPNet{IP: net.IPv4(192, 168, 1, 20)},
+	}
+	for _, tt := range []struct {
+		host    string
+		secure  bool
+		local   string
+		network []string
+	}{
+		{"127.0.0.1", false, "http://localhost:9091", nil},
+		{"localhost", true, "https://localhost:9091", nil},
+		{"0.0.0.0", false, "http://localhost:9091", []string{"http://192.168.1.20:9091"}},
+		{"", true, "https://localhost:9091", []string{"https://192.168.1.20:9091", "https://[2001:db8::5]:9091"}},
+		{"192.168.1.20", false, "http://192.168.1.20:9091", []string{"http://192.168.1.20:9091"}},
+	} {
+		local, network := urls(tt.host, "9091", tt.secure, addrs)
+		if local != tt.local || !slices.Equal(network, tt.network) {
+			t.Errorf("urls(%q) = %q, %q, want %q, %q", tt.host, local, network, tt.local, tt.network)
+		}
+	}
+}
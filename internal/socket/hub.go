@@ -1,56 +1,334 @@
 package socket
 
 import (
+	"encoding/json"
+	"path"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
 	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
 
 	"github.com/gorilla/websocket"
 )
 
+// SlowClientPolicy says what Broadcast does for a client whose queue is
+// full because it does not keep up with the messages sent to it.
+type SlowClientPolicy int
+
+const (
+	// Disconnect closes the client. Browsers reconnect and refresh the
+	// spec they show once they catch up.
+	Disconnect SlowClientPolicy = iota
+	// DropMessage discards the message for that client only.
+	DropMessage
+)
+
+// Options tune how the hub writes to its clients.
+type Options struct {
+	// QueueSize is the number of messages waiting to be written to a
+	// client before it counts as slow.
+	QueueSize int
+	// SlowClients is what happens to a slow client.
+	SlowClients SlowClientPolicy
+	// WriteTimeout bounds every write; a client that takes longer to
+	// accept a message is closed.
+	WriteTimeout time.Duration
+	// PingInterval is how often clients are pinged, and PongTimeout how
+	// long the hub waits for a pong, or any other message, before it
+	// closes the client. PongTimeout must be longer than PingInterval.
+	PingInterval time.Duration
+	PongTimeout  time.Duration
+}
+
+// DefaultOptions returns the options of NewHub.
+func DefaultOptions() Options {
+	return Options{
+		QueueSize:    16,
+		SlowClients:  Disconnect,
+		WriteTimeout: 10 * time.Second,
+		PingInterval: 30 * time.Second,
+		PongTimeout:  60 * time.Second,
+	}
+}
+
+// maxMessageSize limits the messages read from clients.
+const maxMessageSize = 4096
+
+// Hub broadcasts messages to the connected browsers. Every client has its
+// own queue and writer goroutine, so a stalled client delays no one else.
 type Hub struct {
-	clients map[*websocket.Conn]bool
+	clients map[*websocket.Conn]*client
 	mu      sync.Mutex
+	opts    Options
+
+	// seq numbers the clients in the order they connect.
+	seq int
+	// presenter is the client followers track, and follow the last Follow
+	// message it caused, if any.
+	presenter *client
+	follow    []byte
+}
+
+// client is a connection and the queue of messages to write to it.
+type client struct {
+	conn *websocket.Conn
+	send chan []byte
+	// done is closed when the client is removed, to stop its writer.
+	done chan struct{}
+	// topic is the file or folder the client subscribed to, "" for the
+	// whole spec folder. Clients that never subscribed get every message.
+	topic      string
+	subscribed bool
+
+	// seq and id identify the client in presence messages, under name,
+	// once it has sent a hello message.
+	seq   int
+	id    string
+	name  string
+	hello bool
+}
+
+// wants reports whether c is interested in changes to file.
+func (c *client) wants(file string) bool {
+	return !c.subscribed || c.topic == "" || file == c.topic || strings.HasPrefix(file, c.topic+"/")
 }
 
 func NewHub() *Hub {
+	return NewHubWithOptions(DefaultOptions())
+}
+
+// NewHubWithOptions returns a hub that writes to its clients as opts say.
+func NewHubWithOptions(opts Options) *Hub {
 	return &Hub{
-		clients: make(map[*websocket.Conn]bool),
+		clients: make(map[*websocket.Conn]*client),
+		opts:    opts,
 	}
 }
 
+// Events names the messages exchanged with clients. Tree tells every client
+// that specs or folders were added, removed or renamed, which changes the
+// sidebar and the links between specs.
 var Events = struct {
-	Reload string
+	Reload    string
+	Tree      string
+	Subscribe string
+	Update    string
+	Hello     string
+	Presence  string
+	Present   string
+	Position  string
+	Follow    string
 }{
-	Reload: "reload",
+	Reload:    "reload",
+	Tree:      "tree",
+	Subscribe: "subscribe",
+	Update:    "update",
+	Hello:     "hello",
+	Presence:  "presence",
+	Present:   "present",
+	Position:  "position",
+	Follow:    "follow",
+}
+
+// FileReload returns the message that tells the clients showing file, a
+// slash-separated path relative to the spec folder, to reload it. A bare
+// Events.Reload reloads every client.
+func FileReload(file string) string {
+	return Events.Reload + ":" + file
 }
 
+// Update is the message that gives the clients showing a spec its content,
+// rendered once for all of them, and its table of contents.
+type Update struct {
+	Type string              `json:"type"`
+	File string              `json:"file"`
+	HTML string              `json:"html"`
+	TOC  []markdown.TOCEntry `json:"toc"`
+}
+
+// FileUpdate returns the Update message of file, a slash-separated path
+// relative to the spec folder, rendered as html with the entries of toc.
+func FileUpdate(file string, html []byte, toc []markdown.TOCEntry) string {
+	if toc == nil {
+		toc = []markdown.TOCEntry{}
+	}
+	data, _ := json.Marshal(Update{Type: Events.Update, File: file, HTML: string(html), TOC: toc})
+	return string(data)
+}
+
+// Subscribe returns the message a client sends to receive only the
+// changes to topic, a file or folder relative to the spec folder, or to
+// the whole folder when topic is "".
+func Subscribe(topic string) string {
+	return Events.Subscribe + ":" + topic
+}
+
+// Add registers conn and starts reading and writing to it until it is
+// removed or fails.
 func (h *Hub) Add(conn *websocket.Conn) {
+	c := &client{
+		conn: conn,
+		send: make(chan []byte, h.opts.QueueSize),
+		done: make(chan struct{}),
+	}
 	h.mu.Lock()
-	defer h.mu.Unlock()
-	h.clients[conn] = true
+	h.seq++
+	c.seq, c.id = h.seq, strconv.Itoa(h.seq)
+	h.clients[conn] = c
+	h.mu.Unlock()
+
+	go h.write(c)
+	go h.read(c)
 }
 
+// Remove closes conn and forgets it. Removing a client twice is harmless.
 func (h *Hub) Remove(conn *websocket.Conn) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
-	if _, ok := h.clients[conn]; ok {
-		delete(h.clients, conn)
-		_ = conn.Close()
+	if c, ok := h.clients[conn]; ok {
+		h.remove(c)
 	}
 }
 
+// remove closes c. h.mu must be held.
+func (h *Hub) remove(c *client) {
+	delete(h.clients, c.conn)
+	close(c.done)
+	_ = c.conn.Close()
+
+	if c == h.presenter {
+		h.presenter, h.follow = nil, nil
+	}
+	if c.hello {
+		// Not now, as the caller may be sending to the clients.
+		go h.reannounce()
+	}
+}
+
+// Broadcast queues message for every client without waiting for it to be
+// written. Clients whose queue is full are handled as Options.SlowClients
+// says.
 func (h *Hub) Broadcast(message string) {
 	h.mu.Lock()
 	defer h.mu.Unlock()
 
 	payload := []byte(message)
-	for conn := range h.clients {
-		err := conn.WriteMessage(websocket.TextMessage, payload)
+	for _, c := range h.clients {
+		h.queue(c, payload)
+	}
+}
+
+// Publish queues message, about a change to file, for the clients
+// subscribed to file or to a folder containing it, and for those that
+// did not subscribe.
+func (h *Hub) Publish(file, message string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	payload := []byte(message)
+	for _, c := range h.clients {
+		if c.wants(file) {
+			h.queue(c, payload)
+		}
+	}
+}
+
+// Len returns the number of connected clients.
+func (h *Hub) Len() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// Wants reports whether any client is interested in changes to file, so
+// that messages nobody receives are not prepared.
+func (h *Hub) Wants(file string) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, c := range h.clients {
+		if c.wants(file) {
+			return true
+		}
+	}
+	return false
+}
+
+// queue adds payload to the queue of c, or handles c as a slow client if
+// it is full. h.mu must be held.
+func (h *Hub) queue(c *client, payload []byte) {
+	select {
+	case c.send <- payload:
+	default:
+		if h.opts.SlowClients == DropMessage {
+			logger.Warn("Websocket client is too slow, dropping message", "client", c.conn.RemoteAddr())
+			return
+		}
+		logger.Warn("Websocket client is too slow, disconnecting", "client", c.conn.RemoteAddr())
+		h.remove(c)
+	}
+}
+
+// subscribe records the topic c subscribed to, which is also the spec it
+// shows in presence.
+func (h *Hub) subscribe(c *client, topic string) {
+	if topic = path.Clean("/" + topic)[1:]; topic == "." {
+		topic = ""
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	c.topic, c.subscribed = topic, true
+	if c.hello {
+		h.announce()
+	}
+}
+
+// write sends the queued messages and pings to c until it is removed or a
+// write fails or times out.
+func (h *Hub) write(c *client) {
+	ping := time.NewTicker(h.opts.PingInterval)
+	defer ping.Stop()
+
+	for {
+		var err error
+		select {
+		case <-c.done:
+			return
+		case payload := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(h.opts.WriteTimeout))
+			err = c.conn.WriteMessage(websocket.TextMessage, payload)
+		case <-ping.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(h.opts.WriteTimeout))
+			err = c.conn.WriteMessage(websocket.PingMessage, nil)
+		}
 		if err != nil {
 			logger.Error("Failed to write to websocket, removing client", "error", err)
-			delete(h.clients, conn)
-			_ = conn.Close()
+			h.Remove(c.conn)
+			return
+		}
+	}
+}
+
+// read handles the messages c sends and its pongs, and removes it
+// when it disconnects or stays silent past the pong timeout.
+func (h *Hub) read(c *client) {
+	defer h.Remove(c.conn)
+
+	alive := func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(h.opts.PongTimeout))
+	}
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = alive("")
+	c.conn.SetPongHandler(alive)
+	for {
+		// This is expected to fail when the client disconnects.
+		_, message, err := c.conn.ReadMessage()
+		if err != nil {
+			return
 		}
+		_ = alive("")
+		h.handle(c, message)
 	}
 }
@@ -8,6 +8,7 @@ import (
 	"testing"
 	"time"
 
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
 	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
 	"github.com/gorilla/websocket"
 )
@@ -64,6 +65,40 @@ func TestEvents(t *testing.T) {
 	if Events.Reload != "reload" {
 		t.Errorf("expected Events.Reload to be 'reload', got %q", Events.Reload)
 	}
+	if Events.Tree != "tree" {
+		t.Errorf("expected Events.Tree to be 'tree', got %q", Events.Tree)
+	}
+	if got := FileReload("001/spec.md"); got != "reload:001/spec.md" {
+		t.Errorf("expected file reload message, got %q", got)
+	}
+}
+
+func TestFileUpdate(t *testing.T) {
+	want := `{"type":"update","file":"001/spec.md","html":"\u003ch1 id=\"spec\"\u003eSpec\u003c/h1\u003e\n","toc":[{"level":1,"text":"Spec","id":"spec"}]}`
+	if got := FileUpdate("001/spec.md", []byte("<h1 id=\"spec\">Spec</h1>\n"), []markdown.TOCEntry{{Level: 1, Text: "Spec", ID: "spec"}}); got != want {
+		t.Errorf("expected %s, got %s", want, got)
+	}
+	if got := FileUpdate("empty.md", nil, nil); !strings.Contains(got, `"toc":[]`) {
+		t.Errorf("expected an empty TOC list, got %s", got)
+	}
+}
+
+func TestHub_Wants(t *testing.T) {
+	hub := NewHub()
+	server := newTestServer(t, hub)
+	defer server.Close()
+
+	if hub.Wants("spec.md") {
+		t.Error("expected no interest without clients")
+	}
+	conn := dial(t, server)
+	defer func() { _ = conn.Close() }()
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(Subscribe("001"))); err != nil {
+		t.Fatal(err)
+	}
+	if !eventually(func() bool { return hub.Wants("001/spec.md") && !hub.Wants("002/spec.md") }) {
+		t.Error("expected interest in the subscribed folder only")
+	}
 }
 
 func TestHub_AddClient(t *testing.T) {
@@ -240,14 +275,217 @@ func TestHub_BroadcastRemovesDisconnectedClients(t *testing.T) {
 
 	hub.Broadcast("reload")
 
-	// The hub should have removed the dead client.
-	hub.mu.Lock()
-	count := len(hub.clients)
-	hub.mu.Unlock()
-
-	if count != 0 {
-		t.Errorf("expected 0 clients after broadcast to dead conn, got %d", count)
+	// The hub should remove the dead client once the write fails.
+	if !eventually(func() bool { return clientCount(hub) == 0 }) {
+		t.Errorf("expected 0 clients after broadcast to dead conn, got %d", clientCount(hub))
 	}
 
 	_ = conn.Close()
 }
+
+func TestHub_Publish(t *testing.T) {
+	hub := NewHub()
+	server := newTestServer(t, hub)
+	defer server.Close()
+
+	subscribe := func(topic string) *websocket.Conn {
+		conn := dial(t, server)
+		t.Cleanup(func() { _ = conn.Close() })
+		if err := conn.WriteMessage(websocket.TextMessage, []byte(Subscribe(topic))); err != nil {
+			t.Fatalf("failed to subscribe: %v", err)
+		}
+		return conn
+	}
+	spec := subscribe("001-auth/spec.md")
+	folder := subscribe("001-auth/")
+	other := subscribe("002-payments/spec.md")
+	all := subscribe("")
+	unsubscribed := dial(t, server)
+	defer func() { _ = unsubscribed.Close() }()
+
+	if !eventually(func() bool {
+		hub.mu.Lock()
+		defer hub.mu.Unlock()
+		subscribed := 0
+		for _, c := range hub.clients {
+			if c.subscribed {
+				subscribed++
+			}
+		}
+		return len(hub.clients) == 5 && subscribed == 4
+	}) {
+		t.Fatal("expected 4 subscribed clients out of 5")
+	}
+
+	hub.Publish("001-auth/spec.md", FileReload("001-auth/spec.md"))
+	hub.Publish("001-auth/plan.md", FileReload("001-auth/plan.md"))
+	hub.Broadcast(Events.Reload)
+
+	tests := []struct {
+		name string
+		conn *websocket.Conn
+		want []string
+	}{
+		{"file", spec, []string{"reload:001-auth/spec.md", "reload"}},
+		{"folder", folder, []string{"reload:001-auth/spec.md", "reload:001-auth/plan.md", "reload"}},
+		{"other file", other, []string{"reload"}},
+		{"whole folder", all, []string{"reload:001-auth/spec.md", "reload:001-auth/plan.md", "reload"}},
+		{"unsubscribed", unsubscribed, []string{"reload:001-auth/spec.md", "reload:001-auth/plan.md", "reload"}},
+	}
+	for _, tt := range tests {
+		_ = tt.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+		for _, want := range tt.want {
+			_, msg, err := tt.conn.ReadMessage()
+			if err != nil {
+				t.Fatalf("%s: failed to read %q: %v", tt.name, want, err)
+			}
+			if string(msg) != want {
+				t.Errorf("%s: expected %q, got %q", tt.name, want, msg)
+			}
+		}
+	}
+}
+
+// stalledPayload is larger than the socket buffers of a loopback
+// connection, so writing it to a client that does not read blocks.
+var stalledPayload = strings.Repeat("x", 32<<20)
+
+// stalledHub returns a hub with small queues and a stalled and a healthy
+// client. The stalled client never reads; the healthy one is returned.
+func stalledHub(t *testing.T, opts Options) (*Hub, *websocket.Conn) {
+	t.Helper()
+	hub := NewHubWithOptions(opts)
+	server := newTestServer(t, hub)
+	t.Cleanup(server.Close)
+
+	stalled := dial(t, server)
+	t.Cleanup(func() { _ = stalled.Close() })
+	if !eventually(func() bool { return clientCount(hub) == 1 }) {
+		t.Fatal("expected the stalled client to connect")
+	}
+	healthy := dial(t, server)
+	t.Cleanup(func() { _ = healthy.Close() })
+	if !eventually(func() bool { return clientCount(hub) == 2 }) {
+		t.Fatal("expected the healthy client to connect")
+	}
+	return hub, healthy
+}
+
+func TestHub_StalledClientDoesNotBlockOthers(t *testing.T) {
+	opts := DefaultOptions()
+	opts.QueueSize = 2
+	opts.WriteTimeout = 300 * time.Millisecond
+	hub, healthy := stalledHub(t, opts)
+
+	start := time.Now()
+	hub.Broadcast(stalledPayload)
+	hub.Broadcast("reload")
+	if elapsed := time.Since(start); elapsed > 100*time.Millisecond {
+		t.Errorf("expected Broadcast not to wait for writes, took %v", elapsed)
+	}
+
+	// Add and Remove are not held up by the stalled client either.
+	server := newTestServer(t, hub)
+	defer server.Close()
+	extra := dial(t, server)
+	defer func() { _ = extra.Close() }()
+	if !eventually(func() bool { return clientCount(hub) == 3 }) {
+		t.Fatal("expected a new client to be added while another is stalled")
+	}
+
+	_ = healthy.SetReadDeadline(time.Now().Add(5 * time.Second))
+	for _, want := range []int{len(stalledPayload), len("reload")} {
+		_, msg, err := healthy.ReadMessage()
+		if err != nil {
+			t.Fatalf("healthy client failed to read: %v", err)
+		}
+		if len(msg) != want {
+			t.Errorf("expected a message of %d bytes, got %d", want, len(msg))
+		}
+	}
+
+	// The stalled client is closed once its write times out.
+	if !eventually(func() bool { return clientCount(hub) == 2 }) {
+		t.Errorf("expected the stalled client to be removed, got %d clients", clientCount(hub))
+	}
+}
+
+func TestHub_SlowClientPolicies(t *testing.T) {
+	tests := []struct {
+		policy  SlowClientPolicy
+		clients int
+	}{
+		{Disconnect, 1},
+		{DropMessage, 2},
+	}
+	for _, tt := range tests {
+		opts := DefaultOptions()
+		opts.QueueSize = 2
+		opts.SlowClients = tt.policy
+		hub, healthy := stalledHub(t, opts)
+
+		// The first message blocks the stalled client's writer, the next
+		// two fill its queue and the last overflows it. The healthy client
+		// keeps up by reading each message before the next is sent.
+		_ = healthy.SetReadDeadline(time.Now().Add(10 * time.Second))
+		for i := range 4 {
+			hub.Broadcast(stalledPayload)
+			if _, _, err := healthy.ReadMessage(); err != nil {
+				t.Fatalf("policy %d: healthy client failed to read message %d: %v", tt.policy, i, err)
+			}
+		}
+		if n := clientCount(hub); n != tt.clients {
+			t.Errorf("policy %d: expected %d clients after overflowing a queue, got %d", tt.policy, tt.clients, n)
+		}
+	}
+}
+
+func TestHub_PingPong(t *testing.T) {
+	opts := DefaultOptions()
+	opts.PingInterval = 20 * time.Millisecond
+	opts.PongTimeout = 100 * time.Millisecond
+	hub := NewHubWithOptions(opts)
+	server := newTestServer(t, hub)
+	defer server.Close()
+
+	// The responsive client reads, so it answers pings with pongs.
+	responsive := dial(t, server)
+	defer func() { _ = responsive.Close() }()
+	go func() {
+		for {
+			if _, _, err := responsive.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+	if !eventually(func() bool { return clientCount(hub) == 1 }) {
+		t.Fatal("expected the responsive client to connect")
+	}
+
+	// The dead client never reads, so it never answers.
+	dead := dial(t, server)
+	defer func() { _ = dead.Close() }()
+	if !eventually(func() bool { return clientCount(hub) == 2 }) {
+		t.Fatal("expected the dead client to connect")
+	}
+
+	time.Sleep(5 * opts.PongTimeout)
+	if n := clientCount(hub); n != 1 {
+		t.Errorf("expected only the responsive client to remain, got %d clients", n)
+	}
+}
+
+// clientCount returns the number of clients of hub.
+func clientCount(hub *Hub) int {
+	return hub.Len()
+}
+
+// eventually polls cond for up to five seconds.
+func eventually(cond func() bool) bool {
+	for deadline := time.Now().Add(5 * time.Second); time.Now().Before(deadline); time.Sleep(10 * time.Millisecond) {
+		if cond() {
+			return true
+		}
+	}
+	return cond()
+}
@@ -0,0 +1,146 @@
+package socket
+
+import (
+	"cmp"
+	"encoding/json"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxNameLength limits display names, in characters.
+const maxNameLength = 40
+
+// Presence is the message listing who is viewing which spec. Only the
+// clients that introduced themselves with a hello message are listed and
+// receive it.
+type Presence struct {
+	Type string `json:"type"`
+	// Self is the ID of the client receiving the message.
+	Self    string   `json:"self"`
+	Clients []Viewer `json:"clients"`
+}
+
+// Viewer is a client of a presence message.
+type Viewer struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	File      string `json:"file"`
+	Presenter bool   `json:"presenter"`
+}
+
+// Follow is the message that tells followers where the presenter is: the
+// spec they show and the source line at the top of their screen.
+type Follow struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+	Name string `json:"name"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// request is a JSON message sent by a client:
+//
+//	{"type": "hello", "name": "Ana"}             joins presence under a display name
+//	{"type": "present", "on": true}              becomes, or stops being, the presenter
+//	{"type": "position", "file": "…", "line": 12} moves the presenter's followers
+type request struct {
+	Type string `json:"type"`
+	Name string `json:"name"`
+	On   bool   `json:"on"`
+	File string `json:"file"`
+	Line int    `json:"line"`
+}
+
+// handle acts on a message from c: a subscription, or a presence request.
+// Anything else is ignored.
+func (h *Hub) handle(c *client, data []byte) {
+	if topic, ok := strings.CutPrefix(string(data), Events.Subscribe+":"); ok {
+		h.subscribe(c, topic)
+		return
+	}
+	var req request
+	if err := json.Unmarshal(data, &req); err != nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if _, ok := h.clients[c.conn]; !ok {
+		return
+	}
+	switch req.Type {
+	case Events.Hello:
+		c.hello, c.name = true, displayName(req.Name, c.id)
+		h.announce()
+		h.sendFollow(c)
+	case Events.Present:
+		switch {
+		case req.On && c.hello:
+			h.presenter, h.follow = c, nil
+		case !req.On && h.presenter == c:
+			h.presenter, h.follow = nil, nil
+		default:
+			return
+		}
+		h.announce()
+	case Events.Position:
+		if h.presenter != c {
+			return
+		}
+		h.follow, _ = json.Marshal(Follow{Type: Events.Follow, ID: c.id, Name: c.name, File: req.File, Line: req.Line})
+		for _, other := range h.clients {
+			h.sendFollow(other)
+		}
+	}
+}
+
+// displayName returns name, trimmed and shortened, or a guest name made
+// from the client ID.
+func displayName(name, id string) string {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "Guest " + id
+	}
+	if utf8.RuneCountInString(name) > maxNameLength {
+		name = string([]rune(name)[:maxNameLength])
+	}
+	return name
+}
+
+// sendFollow queues the presenter's last position for c, unless c is the
+// presenter or not in presence. h.mu must be held.
+func (h *Hub) sendFollow(c *client) {
+	if h.follow != nil && c.hello && c != h.presenter {
+		h.queue(c, h.follow)
+	}
+}
+
+// announce sends the list of viewers to the clients in presence. h.mu must
+// be held.
+func (h *Hub) announce() {
+	var present []*client
+	for _, c := range h.clients {
+		if c.hello {
+			present = append(present, c)
+		}
+	}
+	// Clients are listed in the order they connected.
+	slices.SortFunc(present, func(a, b *client) int { return cmp.Compare(a.seq, b.seq) })
+
+	viewers := make([]Viewer, len(present))
+	for i, c := range present {
+		viewers[i] = Viewer{ID: c.id, Name: c.name, File: c.topic, Presenter: c == h.presenter}
+	}
+	for _, c := range present {
+		payload, _ := json.Marshal(Presence{Type: Events.Presence, Self: c.id, Clients: viewers})
+		h.queue(c, payload)
+	}
+}
+
+// reannounce announces the viewers once a client in presence is gone.
+func (h *Hub) reannounce() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.announce()
+}
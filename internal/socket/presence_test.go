@@ -0,0 +1,120 @@
+package socket
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+// send writes a JSON or text message from a test client.
+func send(t *testing.T, conn *websocket.Conn, message any) {
+	t.Helper()
+	data, ok := message.(string)
+	if !ok {
+		b, err := json.Marshal(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		data = string(b)
+	}
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(data)); err != nil {
+		t.Fatalf("failed to send %s: %v", data, err)
+	}
+}
+
+// readUntil reads messages from conn until one decoded into v satisfies
+// done, and fails the test if none does within two seconds.
+func readUntil[T any](t *testing.T, conn *websocket.Conn, done func(T) bool) T {
+	t.Helper()
+	_ = conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("no expected message before: %v", err)
+		}
+		var v T
+		if json.Unmarshal(data, &v) == nil && done(v) {
+			return v
+		}
+	}
+}
+
+func TestPresence(t *testing.T) {
+	hub := NewHub()
+	server := newTestServer(t, hub)
+	defer server.Close()
+
+	ana := dial(t, server)
+	defer func() { _ = ana.Close() }()
+	guest := dial(t, server)
+	defer func() { _ = guest.Close() }()
+	silent := dial(t, server)
+	defer func() { _ = silent.Close() }()
+	if !eventually(func() bool { return clientCount(hub) == 3 }) {
+		t.Fatal("expected 3 clients")
+	}
+
+	send(t, ana, request{Type: "hello", Name: "  Ana  "})
+	send(t, ana, Subscribe("001/spec.md"))
+	send(t, guest, request{Type: "hello", Name: strings.Repeat("x", 50)})
+
+	p := readUntil(t, guest, func(p Presence) bool {
+		return p.Type == "presence" && len(p.Clients) == 2 && p.Clients[0].File != ""
+	})
+	if p.Self != p.Clients[1].ID {
+		t.Errorf("expected the guest to be told its ID, got %+v", p)
+	}
+	first := p.Clients[0]
+	if first.Name != "Ana" || first.File != "001/spec.md" || len(p.Clients[1].Name) != maxNameLength {
+		t.Errorf("unexpected viewers %+v", p.Clients)
+	}
+
+	// A position from anyone but the presenter is ignored.
+	send(t, guest, request{Type: "position", File: "002/spec.md", Line: 3})
+	send(t, ana, request{Type: "present", On: true})
+	readUntil(t, guest, func(p Presence) bool { return len(p.Clients) == 2 && p.Clients[0].Presenter })
+
+	send(t, ana, request{Type: "position", File: "001/spec.md", Line: 12})
+	f := readUntil(t, guest, func(f Follow) bool { return f.Type == "follow" })
+	if f.Name != "Ana" || f.File != "001/spec.md" || f.Line != 12 {
+		t.Errorf("unexpected follow message %+v", f)
+	}
+
+	// Late joiners are told where the presenter is.
+	late := dial(t, server)
+	defer func() { _ = late.Close() }()
+	send(t, late, request{Type: "hello", Name: "Late"})
+	if f := readUntil(t, late, func(f Follow) bool { return f.Type == "follow" }); f.Line != 12 {
+		t.Errorf("expected the presenter's last position, got %+v", f)
+	}
+
+	// Clients that never said hello only receive their subscriptions.
+	hub.Broadcast(Events.Reload)
+	_ = silent.SetReadDeadline(time.Now().Add(2 * time.Second))
+	if _, msg, err := silent.ReadMessage(); err != nil || string(msg) != "reload" {
+		t.Errorf("expected the silent client to receive only the reload, got %q, %v", msg, err)
+	}
+
+	_ = ana.Close()
+	p = readUntil(t, guest, func(p Presence) bool { return len(p.Clients) == 2 })
+	for _, v := range p.Clients {
+		if v.Name == "Ana" || v.Presenter {
+			t.Errorf("expected the presenter to be gone, got %+v", p.Clients)
+		}
+	}
+}
+
+func TestDisplayName(t *testing.T) {
+	for _, tt := range []struct{ name, want string }{
+		{" Ana ", "Ana"},
+		{"", "Guest 7"},
+		{strings.Repeat("é", 45), strings.Repeat("é", maxNameLength)},
+	} {
+		if got := displayName(tt.name, "7"); got != tt.want {
+			t.Errorf("displayName(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
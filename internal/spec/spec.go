@@ -1,19 +1,42 @@
 package spec
 
 import (
+	"cmp"
 	"os"
 	"path/filepath"
+	"slices"
 	"strings"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/contract"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/internal/private"
 )
 
+// IndexFile describes the folder it is in: its title and front matter
+// order are the folder's.
+const IndexFile = "_index.md"
+
+// OrderFile lists the names of the entries of the folder it is in, one per
+// line, in the order the sidebar shows them. The .md extension is optional.
+const OrderFile = ".order"
+
 type Spec struct {
-	Name     string
+	Name string
+	// Title is the title of a markdown spec or of the index of a folder,
+	// or else its name.
+	Title    string
 	Path     string
 	IsDir    bool
 	Active   bool
 	Children []Spec
+
+	order *int
 }
 
+// canonical is the order of the Spec Kit artifacts of a feature.
+var canonical = []string{"_index", "spec", "plan", "research", "data-model", "quickstart", "contracts", "tasks", "checklists"}
+
 func GetAll(root string) ([]Spec, error) {
 	return scanDir(root, "")
 }
@@ -28,19 +51,20 @@ func scanDir(root string, relBase string) ([]Spec, error) {
 	var specs []Spec
 	for _, entry := range entries {
 		name := entry.Name()
+		relPath := filepath.Join(relBase, name)
 		// Skip hidden files/dirs
-		if strings.HasPrefix(name, ".") {
+		if private.Hidden(relPath) {
 			continue
 		}
 
-		// Skip non-markdown files if it's a file
-		if !entry.IsDir() && !strings.HasSuffix(name, ".md") {
+		// Skip files other than markdown specs, contracts and artifacts
+		if !entry.IsDir() && !strings.HasSuffix(name, ".md") && !contract.IsContract(filepath.ToSlash(relPath)) && !artifact.Supported(name) {
 			continue
 		}
 
-		relPath := filepath.Join(relBase, name)
 		item := Spec{
 			Name:  name,
+			Title: name,
 			Path:  relPath,
 			IsDir: entry.IsDir(),
 		}
@@ -52,13 +76,166 @@ func scanDir(root string, relBase string) ([]Spec, error) {
 			}
 			item.Children = children
 		}
+		if s, ok := summarize(root, relPath, entry.IsDir()); ok {
+			item.order = s.Order
+			if s.Title != "" {
+				item.Title = s.Title
+			}
+		}
 
 		specs = append(specs, item)
 	}
 
+	sortSpecs(specs, readOrder(fullPath))
 	return specs, nil
 }
 
+// summarize reads the summary of the markdown spec at relPath, or of the
+// index of the folder at relPath.
+func summarize(root, relPath string, isDir bool) (markdown.Summary, bool) {
+	path := filepath.Join(root, relPath)
+	if isDir {
+		path = filepath.Join(path, IndexFile)
+	} else if !strings.HasSuffix(relPath, ".md") {
+		return markdown.Summary{}, false
+	}
+	s, err := markdown.Summarize(path)
+	return s, err == nil
+}
+
+// Title returns the title of the spec at relPath under root, as shown in
+// the sidebar.
+func Title(root, relPath string) string {
+	info, err := os.Stat(filepath.Join(root, relPath))
+	if err == nil {
+		if s, ok := summarize(root, relPath, info.IsDir()); ok && s.Title != "" {
+			return s.Title
+		}
+	}
+	return filepath.Base(relPath)
+}
+
+// readOrder returns the position of each name listed in the order file of
+// dir, without the .md extension.
+func readOrder(dir string) map[string]int {
+	data, err := os.ReadFile(filepath.Join(dir, OrderFile))
+	if err != nil {
+		return nil
+	}
+	positions := make(map[string]int)
+	for _, line := range strings.Split(string(data), "\n") {
+		name := strings.TrimSuffix(strings.TrimSpace(line), ".md")
+		if name == "" || strings.HasPrefix(name, "#") {
+			continue
+		}
+		if _, ok := positions[name]; !ok {
+			positions[name] = len(positions)
+		}
+	}
+	return positions
+}
+
+// sortSpecs sorts siblings by their position in the order file, then by
+// their front matter order, then in the canonical order of Spec Kit
+// artifacts, then by name, comparing numbers by value. Entries placed by
+// the order file or front matter come before those that are not.
+func sortSpecs(specs []Spec, positions map[string]int) {
+	listed := func(s Spec) rank {
+		p, ok := positions[strings.TrimSuffix(s.Name, ".md")]
+		return rank{p, ok}
+	}
+	slices.SortStableFunc(specs, func(a, b Spec) int {
+		for _, by := range []func(Spec) rank{listed, orderOf, canonicalOf} {
+			if c := compareRanks(by(a), by(b)); c != 0 {
+				return c
+			}
+		}
+		return naturalCompare(a.Name, b.Name)
+	})
+}
+
+// rank is an optional sort position.
+type rank struct {
+	n  int
+	ok bool
+}
+
+// compareRanks compares two ranks, with missing ones last.
+func compareRanks(a, b rank) int {
+	switch {
+	case a.ok && b.ok:
+		return cmp.Compare(a.n, b.n)
+	case a.ok:
+		return -1
+	case b.ok:
+		return 1
+	}
+	return 0
+}
+
+func orderOf(s Spec) rank {
+	if s.order == nil {
+		return rank{}
+	}
+	return rank{*s.order, true}
+}
+
+// canonicalOf returns the position of s among the Spec Kit artifacts:
+// markdown files named after one, or the contracts and checklists folders.
+func canonicalOf(s Spec) rank {
+	name := strings.ToLower(s.Name)
+	if !s.IsDir {
+		if !strings.HasSuffix(name, ".md") {
+			return rank{}
+		}
+		name = strings.TrimSuffix(name, ".md")
+	}
+	i := slices.Index(canonical, name)
+	if i < 0 || s.IsDir != (name == "contracts" || name == "checklists") {
+		return rank{}
+	}
+	return rank{i, true}
+}
+
+// naturalCompare compares names case-insensitively, with runs of digits
+// compared by value, so "2-auth" sorts before "10-billing".
+func naturalCompare(a, b string) int {
+	x, y := strings.ToLower(a), strings.ToLower(b)
+	for x != "" && y != "" {
+		if isDigit(x[0]) && isDigit(y[0]) {
+			i, j := digits(x), digits(y)
+			nx, ny := strings.TrimLeft(x[:i], "0"), strings.TrimLeft(y[:j], "0")
+			if c := cmp.Compare(len(nx), len(ny)); c != 0 {
+				return c
+			}
+			if c := strings.Compare(nx, ny); c != 0 {
+				return c
+			}
+			x, y = x[i:], y[j:]
+			continue
+		}
+		if x[0] != y[0] {
+			return cmp.Compare(x[0], y[0])
+		}
+		x, y = x[1:], y[1:]
+	}
+	if c := cmp.Compare(len(x), len(y)); c != 0 {
+		return c
+	}
+	return strings.Compare(a, b)
+}
+
+func isDigit(c byte) bool { return c >= '0' && c <= '9' }
+
+// digits returns the length of the run of digits s starts with.
+func digits(s string) int {
+	i := 0
+	for i < len(s) && isDigit(s[i]) {
+		i++
+	}
+	return i
+}
+
 func MarkActive(specs []Spec, activePath string) {
 	for i := range specs {
 		if specs[i].Path == activePath {
@@ -69,3 +246,75 @@ func MarkActive(specs []Spec, activePath string) {
 		}
 	}
 }
+
+// Nav places a spec in the sidebar tree.
+type Nav struct {
+	// Crumbs are the folders leading to the spec, then the spec itself.
+	Crumbs []Crumb
+	// Prev and Next are the specs before and after it in sidebar order.
+	Prev, Next *Spec
+}
+
+// Crumb is a step of the path to a spec. Path links to the spec, or to the
+// index of a folder, and is empty for folders without one.
+type Crumb struct {
+	Title string
+	Path  string
+}
+
+// Locate returns the breadcrumbs and neighbors of the spec at activePath.
+// Both are empty if specs has no such spec.
+func Locate(specs []Spec, activePath string) Nav {
+	var nav Nav
+	if crumbs, ok := crumbsTo(specs, activePath); ok {
+		nav.Crumbs = crumbs
+	}
+
+	files := flatten(specs, nil)
+	for i, f := range files {
+		if f.Path != activePath {
+			continue
+		}
+		if i > 0 {
+			nav.Prev = &files[i-1]
+		}
+		if i+1 < len(files) {
+			nav.Next = &files[i+1]
+		}
+		break
+	}
+	return nav
+}
+
+func crumbsTo(specs []Spec, activePath string) ([]Crumb, bool) {
+	for _, s := range specs {
+		if s.Path == activePath {
+			return []Crumb{{Title: s.Title, Path: s.Path}}, true
+		}
+		if !s.IsDir {
+			continue
+		}
+		if rest, ok := crumbsTo(s.Children, activePath); ok {
+			folder := Crumb{Title: s.Title}
+			for _, c := range s.Children {
+				if c.Name == IndexFile {
+					folder.Path = c.Path
+				}
+			}
+			return append([]Crumb{folder}, rest...), true
+		}
+	}
+	return nil, false
+}
+
+// flatten appends the files of specs to files, depth first.
+func flatten(specs []Spec, files []Spec) []Spec {
+	for _, s := range specs {
+		if s.IsDir {
+			files = flatten(s.Children, files)
+		} else {
+			files = append(files, s)
+		}
+	}
+	return files
+}
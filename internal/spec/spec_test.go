@@ -3,7 +3,11 @@ package spec
 import (
 	"os"
 	"path/filepath"
+	"slices"
 	"testing"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/private"
 )
 
 func TestGetAll_EmptyDirectory(t *testing.T) {
@@ -66,6 +70,22 @@ func TestGetAll_HiddenFilesExcluded(t *testing.T) {
 	}
 }
 
+func TestGetAll_HideExcluded(t *testing.T) {
+	private.Hide(filepath.Join("config", "viewer.yaml"))
+
+	dir := t.TempDir()
+	mkdir(t, dir, "config")
+	writeFile(t, filepath.Join(dir, "config"), "viewer.yaml", "auth: {}")
+	writeFile(t, filepath.Join(dir, "config"), "other.yaml", "a: 1")
+	specs, err := GetAll(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(specs) != 1 || len(specs[0].Children) != 1 || specs[0].Children[0].Name != "other.yaml" {
+		t.Errorf("expected only other.yaml to be listed, got %+v", specs)
+	}
+}
+
 func TestGetAll_RecursiveDirectoryScanning(t *testing.T) {
 	dir := t.TempDir()
 	writeFile(t, dir, "root.md", "# Root")
@@ -168,7 +188,7 @@ func TestGetAll_NonExistentDirectory(t *testing.T) {
 func TestGetAll_DirectoryWithOnlyNonMarkdownFiles(t *testing.T) {
 	dir := t.TempDir()
 	writeFile(t, dir, "readme.txt", "text")
-	writeFile(t, dir, "data.json", "{}")
+	writeFile(t, dir, "data.xml", "<data/>")
 	writeFile(t, dir, "script.sh", "#!/bin/bash")
 
 	specs, err := GetAll(dir)
@@ -180,6 +200,63 @@ func TestGetAll_DirectoryWithOnlyNonMarkdownFiles(t *testing.T) {
 	}
 }
 
+func TestGetAll_Contracts(t *testing.T) {
+	dir := t.TempDir()
+	mkdir(t, dir, "001-payments", "contracts")
+	writeFile(t, filepath.Join(dir, "001-payments"), "spec.md", "# Spec")
+	writeFile(t, filepath.Join(dir, "001-payments", "contracts"), "api.yaml", "openapi: 3.0.0")
+	writeFile(t, filepath.Join(dir, "001-payments", "contracts"), "event.schema.json", "{}")
+	writeFile(t, filepath.Join(dir, "001-payments", "contracts"), "notes.txt", "text")
+
+	specs, err := GetAll(dir)
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	if len(specs) != 1 || len(specs[0].Children) != 2 {
+		t.Fatalf("expected the feature with its contracts and spec, got %+v", specs)
+	}
+	if specs[0].Children[0].Name != "spec.md" {
+		t.Errorf("expected the spec before its contracts, got %+v", specs[0].Children)
+	}
+	contracts := specs[0].Children[1]
+	if contracts.Name != "contracts" || len(contracts.Children) != 2 {
+		t.Fatalf("expected the contracts folder with 2 contracts, got %+v", contracts)
+	}
+	if contracts.Children[0].Name != "api.yaml" || contracts.Children[1].Name != "event.schema.json" {
+		t.Errorf("unexpected contracts %+v", contracts.Children)
+	}
+}
+
+func TestGetAll_Artifacts(t *testing.T) {
+	defer artifact.Configure(artifact.DefaultExtensions)
+	dir := t.TempDir()
+	writeFile(t, dir, "spec.md", "# Spec")
+	writeFile(t, dir, "payload.json", "{}")
+	writeFile(t, dir, "fixtures.CSV", "id\n1")
+	writeFile(t, dir, "001_init.sql", "SELECT 1;")
+	writeFile(t, dir, "notes.txt", "text")
+
+	names := func() []string {
+		specs, err := GetAll(dir)
+		if err != nil {
+			t.Fatalf("GetAll returned error: %v", err)
+		}
+		var names []string
+		for _, s := range specs {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+	if got, want := names(), []string{"spec.md", "001_init.sql", "fixtures.CSV", "payload.json"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	artifact.Configure([]string{"csv", ".TXT"})
+	if got, want := names(), []string{"spec.md", "fixtures.CSV", "notes.txt"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v with the configured extensions, got %v", want, got)
+	}
+}
+
 func TestGetAll_RelativePaths(t *testing.T) {
 	dir := t.TempDir()
 	mkdir(t, dir, "docs")
@@ -205,6 +282,95 @@ func TestGetAll_RelativePaths(t *testing.T) {
 	}
 }
 
+func TestGetAll_Titles(t *testing.T) {
+	dir := t.TempDir()
+	mkdir(t, dir, "002-payments")
+	writeFile(t, dir, "notes.md", "Intro\n\n## Only a subsection")
+	writeFile(t, dir, "overview.md", "---\ntitle: Product Overview\n---\n# Overview")
+	writeFile(t, filepath.Join(dir, "002-payments"), "spec.md", "# Payment Processing\n\n# Second")
+	writeFile(t, filepath.Join(dir, "002-payments"), "_index.md", "# Payments")
+	writeFile(t, dir, "data.json", "{}")
+
+	specs, err := GetAll(dir)
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	titles := map[string]string{}
+	var walk func([]Spec)
+	walk = func(specs []Spec) {
+		for _, s := range specs {
+			titles[s.Path] = s.Title
+			walk(s.Children)
+		}
+	}
+	walk(specs)
+
+	for path, want := range map[string]string{
+		"notes.md":                               "notes.md",
+		"overview.md":                            "Product Overview",
+		"002-payments":                           "Payments",
+		filepath.Join("002-payments", "spec.md"): "Payment Processing",
+		"data.json":                              "data.json",
+	} {
+		if titles[path] != want {
+			t.Errorf("expected title %q for %s, got %q", want, path, titles[path])
+		}
+	}
+}
+
+func TestGetAll_Order(t *testing.T) {
+	dir := t.TempDir()
+	feature := filepath.Join(dir, "002-payments")
+	mkdir(t, dir, "002-payments", "contracts")
+	mkdir(t, dir, "002-payments", "checklists")
+	for _, name := range []string{"tasks.md", "research.md", "plan.md", "spec.md", "data-model.md", "quickstart.md", "_index.md", "zz-notes.md", "payload.json"} {
+		writeFile(t, feature, name, "# "+name)
+	}
+	mkdir(t, dir, "010-billing")
+	mkdir(t, dir, "1-auth")
+	mkdir(t, dir, "archive")
+	writeFile(t, filepath.Join(dir, "archive"), "_index.md", "---\norder: 1\n---\n# Archive")
+	writeFile(t, dir, "Glossary.md", "# Glossary")
+	writeFile(t, dir, "readme.md", "---\norder: 2\n---\n# Readme")
+
+	names := func(specs []Spec) []string {
+		var names []string
+		for _, s := range specs {
+			names = append(names, s.Name)
+		}
+		return names
+	}
+
+	specs, err := GetAll(dir)
+	if err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	if got, want := names(specs), []string{"archive", "readme.md", "1-auth", "002-payments", "010-billing", "Glossary.md"}; !slices.Equal(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+	want := []string{"_index.md", "spec.md", "plan.md", "research.md", "data-model.md", "quickstart.md", "contracts", "tasks.md", "checklists", "payload.json", "zz-notes.md"}
+	if got := names(specs[3].Children); !slices.Equal(got, want) {
+		t.Errorf("expected the canonical order %v, got %v", want, got)
+	}
+
+	writeFile(t, dir, ".order", "# Listed first\nGlossary\n010-billing\nmissing\n")
+	if specs, err = GetAll(dir); err != nil {
+		t.Fatalf("GetAll returned error: %v", err)
+	}
+	if got, want := names(specs), []string{"Glossary.md", "010-billing", "archive", "readme.md", "1-auth", "002-payments"}; !slices.Equal(got, want) {
+		t.Errorf("expected the order file to come first: %v, got %v", want, got)
+	}
+}
+
+func TestNaturalCompare(t *testing.T) {
+	sorted := []string{"001-a", "2-b", "10-c", "v1.2", "v1.10", "Z", "zz"}
+	for i := 1; i < len(sorted); i++ {
+		if naturalCompare(sorted[i-1], sorted[i]) >= 0 || naturalCompare(sorted[i], sorted[i-1]) <= 0 {
+			t.Errorf("expected %q before %q", sorted[i-1], sorted[i])
+		}
+	}
+}
+
 func TestMarkActive_TopLevelFile(t *testing.T) {
 	specs := []Spec{
 		{Name: "a.md", Path: "a.md"},
@@ -320,6 +486,64 @@ func TestMarkActive_DeeplyNested(t *testing.T) {
 	}
 }
 
+func TestLocate(t *testing.T) {
+	specs := []Spec{
+		{Name: "overview.md", Title: "Overview", Path: "overview.md"},
+		{
+			Name: "002-payments", Title: "Payments", Path: "002-payments", IsDir: true,
+			Children: []Spec{
+				{Name: "_index.md", Title: "Payments", Path: "002-payments/_index.md"},
+				{Name: "spec.md", Title: "Payment Spec", Path: "002-payments/spec.md"},
+				{
+					Name: "contracts", Title: "contracts", Path: "002-payments/contracts", IsDir: true,
+					Children: []Spec{{Name: "api.yaml", Title: "api.yaml", Path: "002-payments/contracts/api.yaml"}},
+				},
+			},
+		},
+	}
+
+	nav := Locate(specs, "002-payments/contracts/api.yaml")
+	want := []Crumb{
+		{Title: "Payments", Path: "002-payments/_index.md"},
+		{Title: "contracts"},
+		{Title: "api.yaml", Path: "002-payments/contracts/api.yaml"},
+	}
+	if !slices.Equal(nav.Crumbs, want) {
+		t.Errorf("expected crumbs %v, got %v", want, nav.Crumbs)
+	}
+	if nav.Prev == nil || nav.Prev.Path != "002-payments/spec.md" || nav.Next != nil {
+		t.Errorf("expected the spec before and nothing after, got %+v, %+v", nav.Prev, nav.Next)
+	}
+
+	nav = Locate(specs, "overview.md")
+	if len(nav.Crumbs) != 1 || nav.Prev != nil || nav.Next == nil || nav.Next.Path != "002-payments/_index.md" {
+		t.Errorf("unexpected nav for the first spec %+v", nav)
+	}
+
+	if nav := Locate(specs, "missing.md"); nav.Crumbs != nil || nav.Prev != nil || nav.Next != nil {
+		t.Errorf("expected no nav for a missing spec, got %+v", nav)
+	}
+}
+
+func TestTitle(t *testing.T) {
+	dir := t.TempDir()
+	mkdir(t, dir, "001-auth")
+	writeFile(t, filepath.Join(dir, "001-auth"), "_index.md", "# Authentication")
+	writeFile(t, filepath.Join(dir, "001-auth"), "spec.md", "---\ntitle: Login\n---\n")
+	writeFile(t, dir, "data.json", "{}")
+
+	for path, want := range map[string]string{
+		"001-auth":                           "Authentication",
+		filepath.Join("001-auth", "spec.md"): "Login",
+		"data.json":                          "data.json",
+		"missing.md":                         "missing.md",
+	} {
+		if got := Title(dir, path); got != want {
+			t.Errorf("Title(%q) = %q, want %q", path, got, want)
+		}
+	}
+}
+
 // Helper functions
 
 func writeFile(t *testing.T, dir, name, content string) {
@@ -1,14 +1,17 @@
 package templates
 
 import (
+	"fmt"
 	"html/template"
 	"io/fs"
-	"log"
 	"net/http"
 	"path"
 	"strings"
+	"sync/atomic"
+	"time"
 
 	"github.com/SantiagoBobrik/spec-viewer/internal/spec"
+	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
 	"github.com/SantiagoBobrik/spec-viewer/web"
 )
 
@@ -16,10 +19,51 @@ import (
 var cache = make(map[string]*template.Template)
 var specFolder string
 
+// ready is set once the templates are parsed.
+var ready atomic.Bool
+
+// basePath is the path the server is mounted under, "" at the root.
+var basePath string
+
 // funcMap provides custom template functions available in all templates.
 var funcMap = template.FuncMap{
 	"multiply": func(a, b int) int { return a * b },
 	"subtract": func(a, b int) int { return a - b },
+	"add":      func(a, b int) int { return a + b },
+	"ago":      func(t time.Time) string { return ago(time.Since(t)) },
+	"base":     func() string { return basePath },
+}
+
+// SetBasePath sets the path the server is mounted under, such as "/specs",
+// which prefixes the links of the pages. It is meant to be called once at
+// startup.
+func SetBasePath(p string) {
+	basePath = p
+}
+
+// URL returns the link to p, an absolute path of the server, under the
+// base path.
+func URL(p string) string {
+	return basePath + p
+}
+
+// ago describes how long ago something happened, d before now.
+func ago(d time.Duration) string {
+	unit := func(n int, name string) string {
+		if n == 1 {
+			return fmt.Sprintf("1 %s ago", name)
+		}
+		return fmt.Sprintf("%d %ss ago", n, name)
+	}
+	switch {
+	case d < time.Minute:
+		return "just now"
+	case d < time.Hour:
+		return unit(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		return unit(int(d/time.Hour), "hour")
+	}
+	return unit(int(d/(24*time.Hour)), "day")
 }
 
 // Init parses all templates and sets the spec folder.
@@ -29,20 +73,20 @@ func Init(folder string) {
 
 	baseTmpl, err := template.New("base.html").Funcs(funcMap).ParseFS(web.Files, "templates/layouts/base.html")
 	if err != nil {
-		log.Fatalf("Error parsing base layout: %v", err)
+		logger.Fatal("Failed to parse base layout", "error", err)
 	}
 
 	baseTmpl, err = baseTmpl.ParseFS(web.Files, "templates/components/*.html")
 	if err != nil {
-		log.Fatalf("Error parsing components: %v", err)
+		logger.Fatal("Failed to parse components", "error", err)
 	}
 
 	pages, err := fs.Glob(web.Files, "templates/*.html")
 	if err != nil {
-		log.Fatalf("Error globbing templates: %v", err)
+		logger.Fatal("Failed to glob templates", "error", err)
 	}
 	if len(pages) == 0 {
-		log.Fatalf("No page templates found under templates/*.html")
+		logger.Fatal("No page templates found", "pattern", "templates/*.html")
 	}
 
 	for _, page := range pages {
@@ -50,16 +94,23 @@ func Init(folder string) {
 
 		ts, err := baseTmpl.Clone()
 		if err != nil {
-			log.Fatalf("Error cloning base template for %s: %v", page, err)
+			logger.Fatal("Failed to clone base template", "page", page, "error", err)
 		}
 
 		ts, err = ts.ParseFS(web.Files, page)
 		if err != nil {
-			log.Fatalf("Error parsing page template %s: %v", page, err)
+			logger.Fatal("Failed to parse page template", "page", page, "error", err)
 		}
 
 		cache[tmplName] = ts
 	}
+	ready.Store(true)
+}
+
+// Ready reports whether the templates are parsed, so that pages can be
+// rendered.
+func Ready() bool {
+	return ready.Load()
 }
 
 // PageData wraps the content data with global layout data like Specs.
@@ -68,20 +119,26 @@ type PageData struct {
 	Specs []spec.Spec
 }
 
-// Render executes the cached template.
+// Render executes the cached template with the specs of the spec folder.
 func Render(w http.ResponseWriter, page string, data any, activePath ...string) {
+	specs, err := spec.GetAll(specFolder)
+	if err != nil {
+		logger.Error("Failed to list specs", "error", err)
+	}
+	RenderSpecs(w, page, data, specs, activePath...)
+}
+
+// RenderSpecs executes the cached template with specs, as listed by
+// spec.GetAll, for handlers that need them too, so that the spec folder is
+// walked once per page.
+func RenderSpecs(w http.ResponseWriter, page string, data any, specs []spec.Spec, activePath ...string) {
 	ts, ok := cache[page]
 	if !ok {
-		log.Printf("Template %s not found in cache", page)
+		logger.Error("Template not found in cache", "page", page)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 		return
 	}
 
-	specs, err := spec.GetAll(specFolder)
-	if err != nil {
-		log.Printf("Error fetching specs: %v", err)
-	}
-
 	if len(activePath) > 0 {
 		spec.MarkActive(specs, activePath[0])
 	}
@@ -94,9 +151,8 @@ func Render(w http.ResponseWriter, page string, data any, activePath ...string)
 	// Execute the "base.html" template.
 	w.Header().Set("Content-Type", "text/html; charset=utf-8")
 
-	err = ts.ExecuteTemplate(w, "base.html", pageData)
-	if err != nil {
-		log.Printf("Error executing template %s: %v", page, err)
+	if err := ts.ExecuteTemplate(w, "base.html", pageData); err != nil {
+		logger.Error("Failed to execute template", "page", page, "error", err)
 		http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 	}
 }
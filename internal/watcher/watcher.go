@@ -1,36 +1,67 @@
 package watcher
 
 import (
+	"bytes"
 	"context"
 	"os"
 	"path/filepath"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
 
+	"github.com/SantiagoBobrik/spec-viewer/internal/activity"
+	"github.com/SantiagoBobrik/spec-viewer/internal/artifact"
+	"github.com/SantiagoBobrik/spec-viewer/internal/contract"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+	"github.com/SantiagoBobrik/spec-viewer/internal/private"
 	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
+	"github.com/SantiagoBobrik/spec-viewer/internal/spec"
 	"github.com/SantiagoBobrik/spec-viewer/pkg/logger"
-	"github.com/SantiagoBobrik/spec-viewer/pkg/ui"
 
 	"github.com/fsnotify/fsnotify"
 )
 
-func Watch(ctx context.Context, root string, hub *socket.Hub) {
+// running counts the watchers that are watching their folder.
+var running atomic.Int32
+
+// Running reports whether a watcher is watching the spec folder, which
+// readiness checks require.
+func Running() bool {
+	return running.Load() > 0
+}
+
+// ops are the operations of the events counted in metrics.
+var ops = []fsnotify.Op{fsnotify.Create, fsnotify.Write, fsnotify.Remove, fsnotify.Rename, fsnotify.Chmod}
+
+func Watch(ctx context.Context, root string, hub *socket.Hub, recent *activity.Log) {
 	watcher, err := fsnotify.NewWatcher()
 	if err != nil {
 		logger.Fatal("Failed to create watcher", "error", err)
 	}
 	defer func() { _ = watcher.Close() }()
 
+	deps := newDependencies()
+	// dirs holds the watched folders, which cannot be told from files once
+	// removed.
+	dirs := make(map[string]bool)
 	err = filepath.WalkDir(root, func(path string, d os.DirEntry, err error) error {
 		if err != nil {
 			return err
 		}
 		if d.IsDir() {
+			dirs[path] = true
 			return watcher.Add(path)
 		}
+		deps.scan(root, path)
 		return nil
 	})
 	if err != nil {
 		logger.Fatal("Error walking directory", "error", err)
 	}
+	running.Add(1)
+	defer running.Add(-1)
 
 	for {
 		select {
@@ -40,18 +71,52 @@ func Watch(ctx context.Context, root string, hub *socket.Hub) {
 			if !ok {
 				return
 			}
+			for _, op := range ops {
+				if event.Has(op) {
+					metrics.WatcherEvents.Inc(strings.ToLower(op.String()))
+				}
+			}
+			isDir := dirs[event.Name]
 			if event.Has(fsnotify.Create) {
 				info, err := os.Stat(event.Name)
 				if err == nil && info.IsDir() {
 					logger.Info("Watching new directory", "path", event.Name)
 					_ = watcher.Add(event.Name)
+					dirs[event.Name], isDir = true, true
 				}
 			}
+			if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				delete(dirs, event.Name)
+			}
 
-			// Notify clients of changes
-			if event.Has(fsnotify.Write) || event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) {
-				ui.PrintFileChange(event.Name)
-				hub.Broadcast(socket.Events.Reload)
+			moved := event.Has(fsnotify.Create) || event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename)
+			if !event.Has(fsnotify.Write) && !moved {
+				continue
+			}
+			// Hidden files, such as the config file, are never sent to
+			// clients, and no page shows files other than specs: only the
+			// sidebar changes, when a folder's order or index file does.
+			private := hidden(root, event.Name)
+			folder := describesFolder(root, event.Name)
+			if (private || !(isDir || isSpec(event.Name))) && !folder {
+				logger.Debug("File changed", "file", event.Name, "op", strings.ToLower(event.Op.String()))
+				continue
+			}
+
+			logger.Info("File changed", "file", event.Name, "op", strings.ToLower(event.Op.String()))
+			if isSpec(event.Name) && !private {
+				record(recent, root, event)
+				// Specs that include the changed one show it too.
+				for _, file := range deps.scan(root, event.Name) {
+					if hub.Wants(file) {
+						hub.Publish(file, update(root, file))
+					}
+				}
+			}
+			// Every page lists the specs in its sidebar, whatever spec it
+			// shows.
+			if folder || (moved && !private) {
+				hub.Broadcast(socket.Events.Tree)
 			}
 		case err, ok := <-watcher.Errors:
 			if !ok {
@@ -61,3 +126,118 @@ func Watch(ctx context.Context, root string, hub *socket.Hub) {
 		}
 	}
 }
+
+// update renders the spec file once for all the clients showing it, or
+// returns the message telling them to reload it when it cannot be read.
+func update(root, file string) string {
+	source, err := os.ReadFile(filepath.Join(root, filepath.FromSlash(file)))
+	if err != nil {
+		return socket.FileReload(file)
+	}
+	start := time.Now()
+	doc := markdown.ParseFile(root, file, source)
+	var buf bytes.Buffer
+	if err := doc.Render(&buf); err != nil {
+		logger.Error("Failed to render markdown", "file", file, "error", err)
+		return socket.FileReload(file)
+	}
+	metrics.RenderDuration.Since(start, "update")
+	return socket.FileUpdate(file, buf.Bytes(), doc.TOC())
+}
+
+// record adds the spec changed by event to the recently changed specs, or
+// forgets it once removed or renamed, its new name being created. Hidden
+// files, such as editor backups, are skipped.
+func record(recent *activity.Log, root string, event fsnotify.Event) {
+	rel, err := filepath.Rel(root, event.Name)
+	if err != nil || strings.HasPrefix(filepath.Base(rel), ".") {
+		return
+	}
+	file := filepath.ToSlash(rel)
+	if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+		recent.Removed(file)
+	} else {
+		recent.Changed(file)
+	}
+}
+
+// hidden reports whether path, under root, is private to the server.
+func hidden(root, path string) bool {
+	rel, err := filepath.Rel(root, path)
+	return err != nil || private.Hidden(rel)
+}
+
+// describesFolder reports whether path, under root, is the order or index
+// file of a folder that is not private, which change how the sidebar
+// shows it.
+func describesFolder(root, path string) bool {
+	base := filepath.Base(path)
+	return (base == spec.OrderFile || base == spec.IndexFile) && !hidden(root, filepath.Dir(path))
+}
+
+// isSpec reports whether path is a markdown spec or a file specs can
+// include, such as a contract or another artifact.
+func isSpec(path string) bool {
+	return strings.HasSuffix(path, ".md") || contract.Supported(path) || artifact.Supported(path)
+}
+
+// dependencies records which specs include which, keyed by their
+// slash-separated paths relative to the spec folder.
+type dependencies struct {
+	includes map[string][]string
+}
+
+func newDependencies() *dependencies {
+	return &dependencies{includes: make(map[string][]string)}
+}
+
+// scan records the includes of the spec at path under root, or forgets
+// them when it no longer exists, and returns the specs affected by a
+// change to it.
+func (d *dependencies) scan(root, path string) []string {
+	if !isSpec(path) {
+		return nil
+	}
+	rel, err := filepath.Rel(root, path)
+	if err != nil {
+		return nil
+	}
+	file := filepath.ToSlash(rel)
+
+	if content, err := os.ReadFile(path); err == nil && strings.HasSuffix(path, ".md") {
+		d.includes[file] = markdown.Includes(file, content)
+	} else {
+		delete(d.includes, file)
+	}
+	return d.affected(file)
+}
+
+// affected returns file and every spec that includes it, directly or
+// through other specs, sorted.
+func (d *dependencies) affected(file string) []string {
+	seen := map[string]bool{file: true}
+	queue := []string{file}
+	for len(queue) > 0 {
+		target := queue[0]
+		queue = queue[1:]
+		for includer, files := range d.includes {
+			if seen[includer] {
+				continue
+			}
+			for _, f := range files {
+				if f == target {
+					seen[includer] = true
+					queue = append(queue, includer)
+					break
+				}
+			}
+		}
+	}
+
+	files := make([]string, 0, len(seen))
+	for f := range seen {
+		files = append(files, f)
+	}
+	sort.Strings(files)
+	return files
+}
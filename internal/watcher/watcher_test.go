@@ -0,0 +1,223 @@
+package watcher
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/SantiagoBobrik/spec-viewer/internal/activity"
+	"github.com/SantiagoBobrik/spec-viewer/internal/markdown"
+	"github.com/SantiagoBobrik/spec-viewer/internal/metrics"
+	"github.com/SantiagoBobrik/spec-viewer/internal/socket"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestDependencies(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+
+	deps := newDependencies()
+	spec := write("001/spec.md", "# Spec\n\n## Data Model\n")
+	deps.scan(root, spec)
+	deps.scan(root, write("001/plan.md", "# Plan\n\n![[spec.md#Data Model]]\n"))
+	deps.scan(root, write("001/tasks.md", "{{< include \"plan.md\" >}}\n"))
+	deps.scan(root, write("002/spec.md", "# Other\n"))
+
+	want := []string{"001/plan.md", "001/spec.md", "001/tasks.md"}
+	if got := deps.scan(root, spec); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	// Includers that drop the include no longer depend on the spec.
+	deps.scan(root, write("001/plan.md", "# Plan\n"))
+	if got := deps.affected("001/spec.md"); !reflect.DeepEqual(got, []string{"001/spec.md"}) {
+		t.Errorf("expected only the spec itself, got %v", got)
+	}
+
+	// Contracts are watched for the specs embedding them.
+	deps.scan(root, write("001/research.md", "![[contracts/api.yaml#createPayment]]\n"))
+	want = []string{"001/contracts/api.yaml", "001/research.md"}
+	if got := deps.scan(root, write("001/contracts/api.yaml", "openapi: 3.0.0\n")); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+
+	if got := deps.scan(root, filepath.Join(root, "notes.txt")); got != nil {
+		t.Errorf("expected no specs for a non-markdown file, got %v", got)
+	}
+}
+
+func TestUpdate(t *testing.T) {
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "spec.md"), []byte("# Spec\n\n## Data Model\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var got socket.Update
+	if err := json.Unmarshal([]byte(update(root, "spec.md")), &got); err != nil {
+		t.Fatalf("expected an update message: %v", err)
+	}
+	if got.Type != "update" || got.File != "spec.md" || !strings.Contains(got.HTML, `<h2 id="data-model"`) {
+		t.Errorf("unexpected update %+v", got)
+	}
+	want := []markdown.TOCEntry{{Level: 1, Text: "Spec", ID: "spec"}, {Level: 2, Text: "Data Model", ID: "data-model"}}
+	if !reflect.DeepEqual(got.TOC, want) {
+		t.Errorf("expected TOC %v, got %v", want, got.TOC)
+	}
+
+	if msg := update(root, "removed.md"); msg != socket.FileReload("removed.md") {
+		t.Errorf("expected a reload message for a missing spec, got %q", msg)
+	}
+}
+
+func TestWatchRunningAndEvents(t *testing.T) {
+	root := t.TempDir()
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Watch(ctx, root, socket.NewHub(), activity.NewLog(activity.DefaultLimit))
+		close(done)
+	}()
+
+	waitFor := func(what string, cond func() bool) {
+		t.Helper()
+		for deadline := time.Now().Add(5 * time.Second); !cond(); time.Sleep(10 * time.Millisecond) {
+			if time.Now().After(deadline) {
+				t.Fatalf("timed out waiting for %s", what)
+			}
+		}
+	}
+	waitFor("the watcher to run", Running)
+
+	creates := metrics.WatcherEvents.Value("create")
+	if err := os.WriteFile(filepath.Join(root, "spec.md"), []byte("# Spec\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("the create event to be counted", func() bool { return metrics.WatcherEvents.Value("create") > creates })
+
+	cancel()
+	<-done
+	if Running() {
+		t.Error("expected the watcher to stop running")
+	}
+}
+
+func TestWatchBroadcastsTreeChanges(t *testing.T) {
+	root := t.TempDir()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("other.md", "# Other\n")
+	write("_index.md", "# Specs\n")
+
+	hub := socket.NewHub()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		upgrader := websocket.Upgrader{CheckOrigin: func(r *http.Request) bool { return true }}
+		if conn, err := upgrader.Upgrade(w, r, nil); err == nil {
+			hub.Add(conn)
+		}
+	}))
+	defer server.Close()
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(server.URL, "http"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = conn.Close() }()
+	// A tab showing another spec.
+	if err := conn.WriteMessage(websocket.TextMessage, []byte(socket.Subscribe("other.md"))); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		Watch(ctx, root, hub, activity.NewLog(activity.DefaultLimit))
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	for deadline := time.Now().Add(5 * time.Second); !Running() || hub.Wants("new.md"); time.Sleep(10 * time.Millisecond) {
+		if time.Now().After(deadline) {
+			t.Fatal("timed out waiting for the watcher and the subscription")
+		}
+	}
+
+	next := func() string {
+		t.Helper()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		_, msg, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read a message: %v", err)
+		}
+		return string(msg)
+	}
+
+	steps := []struct {
+		name   string
+		change func() error
+		// trees is the number of tree messages: a rename is also the
+		// creation of the new name.
+		trees int
+	}{
+		{"create", func() error { return os.WriteFile(filepath.Join(root, "new.md"), []byte("# New\n"), 0644) }, 1},
+		{"rename", func() error { return os.Rename(filepath.Join(root, "new.md"), filepath.Join(root, "renamed.md")) }, 2},
+		{"remove", func() error { return os.Remove(filepath.Join(root, "renamed.md")) }, 1},
+		{"create folder", func() error { return os.Mkdir(filepath.Join(root, "002"), 0755) }, 1},
+	}
+	for _, step := range steps {
+		if err := step.change(); err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < step.trees; i++ {
+			if msg := next(); msg != socket.Events.Tree {
+				t.Fatalf("%s: expected a tree message, got %q", step.name, msg)
+			}
+		}
+	}
+
+	// Hidden files and files that are not specs change nothing shown, but
+	// order and index files change the sidebar: the messages up to the
+	// update of the spec shown are tree messages only.
+	for _, files := range [][]string{{".notes.md", ".spec-viewer.yaml", "diagram.png"}, {".order"}, {"_index.md"}} {
+		for _, file := range files {
+			write(file, "other.md\n")
+		}
+		edit := "Edited after " + files[0]
+		write("other.md", "# "+edit+"\n")
+		trees := 0
+		for msg := next(); !strings.Contains(msg, edit); msg = next() {
+			switch {
+			case msg == socket.Events.Tree:
+				trees++
+			case !strings.Contains(msg, `"file":"other.md"`):
+				// Writing a file may take more than one event, so updates
+				// of the previous edit may come first.
+				t.Fatalf("%v: expected a tree message, got %q", files, msg)
+			}
+		}
+		if want := files[0] != ".notes.md"; (trees > 0) != want {
+			t.Errorf("%v: expected tree messages: %v, got %d", files, want, trees)
+		}
+	}
+}
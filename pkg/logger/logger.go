@@ -1,30 +1,104 @@
 package logger
 
 import (
+	"fmt"
 	"io"
+	"log"
 	"log/slog"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/lmittmann/tint"
 )
 
-var defaultLogger *slog.Logger
+// Formats of the log lines.
+const (
+	// FormatText writes colored lines for people to read.
+	FormatText = "text"
+	// FormatJSON writes one JSON object per line for log collectors.
+	FormatJSON = "json"
+)
+
+// Options select what is logged and how.
+type Options struct {
+	// Level is the lowest level logged.
+	Level slog.Level
+	// Format is FormatText or FormatJSON, FormatText when empty.
+	Format string
+	// Output receives the log lines, os.Stdout when nil.
+	Output io.Writer
+}
+
+var (
+	defaultLogger *slog.Logger
+	options       = Options{Level: slog.LevelInfo, Format: FormatText, Output: os.Stdout}
+)
 
 func init() {
-	defaultLogger = slog.New(tint.NewHandler(os.Stdout, &tint.Options{
-		Level:      slog.LevelDebug,
-		TimeFormat: time.TimeOnly,
-	}))
+	defaultLogger = newLogger(options)
+}
+
+// Configure replaces the logger with one following opts. It is meant to be
+// called once at startup.
+func Configure(opts Options) error {
+	if opts.Format == "" {
+		opts.Format = FormatText
+	}
+	if opts.Format != FormatText && opts.Format != FormatJSON {
+		return fmt.Errorf("unknown log format %q, expected %s or %s", opts.Format, FormatText, FormatJSON)
+	}
+	if opts.Output == nil {
+		opts.Output = os.Stdout
+	}
+	options = opts
+	defaultLogger = newLogger(options)
+	return nil
 }
 
+// SetOutput sends the log lines to w, keeping the level and format.
 func SetOutput(w io.Writer) {
-	defaultLogger = slog.New(tint.NewHandler(w, &tint.Options{
-		Level:      slog.LevelDebug,
+	options.Output = w
+	defaultLogger = newLogger(options)
+}
+
+// ParseLevel returns the level named s: debug, info, warn or error.
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(strings.TrimSpace(s))); err != nil {
+		return 0, fmt.Errorf("unknown log level %q, expected debug, info, warn or error", s)
+	}
+	return level, nil
+}
+
+func newLogger(opts Options) *slog.Logger {
+	if opts.Format == FormatJSON {
+		return slog.New(slog.NewJSONHandler(opts.Output, &slog.HandlerOptions{Level: opts.Level}))
+	}
+	return slog.New(tint.NewHandler(opts.Output, &tint.Options{
+		Level:      opts.Level,
 		TimeFormat: time.TimeOnly,
+		// Colors only help on a terminal, not in a log file.
+		NoColor: !isTerminal(opts.Output),
 	}))
 }
 
+// isTerminal reports whether w is a character device such as a terminal.
+func isTerminal(w io.Writer) bool {
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// StdLogger returns a standard library logger that logs its lines at level,
+// for packages such as net/http that take one.
+func StdLogger(level slog.Level) *log.Logger {
+	return slog.NewLogLogger(defaultLogger.Handler(), level)
+}
+
 func Info(msg string, args ...any) {
 	defaultLogger.Info(msg, args...)
 }
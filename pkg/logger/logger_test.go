@@ -0,0 +1,75 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	for in, want := range map[string]slog.Level{"debug": slog.LevelDebug, "INFO": slog.LevelInfo, "warn": slog.LevelWarn, "error": slog.LevelError} {
+		got, err := ParseLevel(in)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v, want %v", in, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("loud"); err == nil {
+		t.Error("expected an error for an unknown level")
+	}
+}
+
+func TestConfigure(t *testing.T) {
+	defer func() { _ = Configure(Options{}) }()
+
+	if err := Configure(Options{Format: "xml"}); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+
+	var buf bytes.Buffer
+	if err := Configure(Options{Level: slog.LevelWarn, Format: FormatJSON, Output: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	Info("hidden")
+	Warn("shown", "file", "a.md")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected only the warning to be logged, got %q", buf.String())
+	}
+	var entry map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &entry); err != nil {
+		t.Fatal(err)
+	}
+	if entry["level"] != "WARN" || entry["msg"] != "shown" || entry["file"] != "a.md" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+
+	// SetOutput keeps the level and format.
+	buf.Reset()
+	var other bytes.Buffer
+	SetOutput(&other)
+	Error("moved")
+	if buf.Len() != 0 || !strings.HasPrefix(other.String(), "{") {
+		t.Errorf("expected a JSON line in the new output, got %q", other.String())
+	}
+}
+
+func TestStdLogger(t *testing.T) {
+	defer func() { _ = Configure(Options{}) }()
+
+	var buf bytes.Buffer
+	if err := Configure(Options{Format: FormatJSON, Output: &buf}); err != nil {
+		t.Fatal(err)
+	}
+	StdLogger(slog.LevelWarn).Printf("http: TLS handshake error from %s: EOF", "10.0.0.7:5000")
+
+	var entry map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &entry); err != nil {
+		t.Fatalf("expected a JSON line, got %q", buf.String())
+	}
+	if entry["level"] != "WARN" || entry["msg"] != "http: TLS handshake error from 10.0.0.7:5000: EOF" {
+		t.Errorf("unexpected entry %v", entry)
+	}
+}
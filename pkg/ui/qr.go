@@ -0,0 +1,46 @@
+package ui
+
+import (
+	"strings"
+
+	"rsc.io/qr"
+)
+
+// quietZone is the margin, in modules, that scanners need around a code.
+const quietZone = 2
+
+// QRCode renders text as a QR code drawn with half-block characters, two
+// rows of modules per line, light on dark so that it scans on terminals
+// with either background.
+func QRCode(text string) (string, error) {
+	code, err := qr.Encode(text, qr.L)
+	if err != nil {
+		return "", err
+	}
+
+	dark := func(x, y int) bool {
+		x, y = x-quietZone, y-quietZone
+		return x >= 0 && y >= 0 && x < code.Size && y < code.Size && code.Black(x, y)
+	}
+	size := code.Size + 2*quietZone
+
+	var b strings.Builder
+	for y := 0; y < size; y += 2 {
+		for x := 0; x < size; x++ {
+			// Light modules are drawn; dark ones are left blank.
+			top, bottom := !dark(x, y), !dark(x, y+1) && y+1 < size
+			switch {
+			case top && bottom:
+				b.WriteString("█")
+			case top:
+				b.WriteString("▀")
+			case bottom:
+				b.WriteString("▄")
+			default:
+				b.WriteString(" ")
+			}
+		}
+		b.WriteString("\n")
+	}
+	return b.String(), nil
+}
@@ -2,28 +2,35 @@ package ui
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"time"
 
 	"github.com/charmbracelet/lipgloss"
 )
 
 var (
-	timestampStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))              // Grey
-	eventStyle     = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))    // Indigo
-	fileStyle      = lipgloss.NewStyle().Italic(true).Foreground(lipgloss.Color("250")) // Light Grey
-	successStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63"))    // Indigo
+	timestampStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("240"))           // Grey
+	successStyle   = lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("63")) // Indigo
 )
 
-func PrintFileChange(path string) {
-	ts := timestampStyle.Render(time.Now().Format("15:04:05"))
-	icon := eventStyle.Render("⚡ Reload")
-	file := fileStyle.Render(path)
+// output receives the messages meant for people rather than log
+// collectors.
+var output io.Writer = os.Stdout
+
+// SetOutput sends the messages meant for people, such as the banner, to w,
+// so that they stay out of JSON logs printed to stdout.
+func SetOutput(w io.Writer) {
+	output = w
+}
 
-	fmt.Printf("%s  %s  %s\n", ts, icon, file)
+// Output returns where the messages meant for people go.
+func Output() io.Writer {
+	return output
 }
 
 func PrintSuccess(msg string) {
 	ts := timestampStyle.Render(time.Now().Format("15:04:05"))
 	icon := successStyle.Render("✔")
-	fmt.Printf("%s  %s  %s\n", ts, icon, msg)
+	_, _ = fmt.Fprintf(output, "%s  %s  %s\n", ts, icon, msg)
 }
@@ -9,21 +9,62 @@
     '<path d="M21 15a2 2 0 0 1-2 2H7l-4 4V5a2 2 0 0 1 2-2h14a2 2 0 0 1 2 2z"/>' +
     "</svg>";
 
+  const STORAGE_PREFIX = "specComments:";
+  const AUTHOR_KEY = "specReviewAuthor";
+
+  // Version 1 stored flat comments anchored by block index and version 2
+  // flat comments anchored to source lines. Version 3 stores threads of
+  // comments with a resolved state.
+  const STORAGE_VERSION = 3;
+
+  // Minimum similarity for a thread to follow an edited block.
+  const FUZZY_THRESHOLD = 0.5;
+
+  // Default author reported by the server (config or git user.name).
+  let serverAuthor = "";
+
   function currentFilePath() {
     return new URLSearchParams(window.location.search).get("file") || "";
   }
 
   function storageKey() {
-    return "specComments:" + currentFilePath();
+    return STORAGE_PREFIX + currentFilePath();
   }
 
-  function parseStoredComments(key) {
+  function newID() {
+    return Date.now().toString(36) + Math.random().toString(36).substring(2, 7);
+  }
+
+  function currentAuthor() {
+    return localStorage.getItem(AUTHOR_KEY) || serverAuthor;
+  }
+
+  // threadFromComment wraps a version 1 or 2 comment in a single-comment thread.
+  function threadFromComment(c) {
+    const thread = {
+      id: c.id,
+      startLine: c.startLine,
+      endLine: c.endLine,
+      hash: c.hash,
+      blockTextPreview: c.blockTextPreview,
+      resolved: false,
+      comments: [{ id: c.id, author: "", text: c.text, createdAt: c.createdAt }],
+    };
+    if (c.blockIndex !== undefined) thread.blockIndex = c.blockIndex;
+    if (c.stale) thread.stale = true;
+    return thread;
+  }
+
+  function parseStoredThreads(key) {
     try {
       const raw = localStorage.getItem(key);
       if (!raw) return [];
       const data = JSON.parse(raw);
-      if (data && data.version === 1 && Array.isArray(data.comments)) {
-        return data.comments;
+      if (data && data.version === STORAGE_VERSION && Array.isArray(data.threads)) {
+        return data.threads;
+      }
+      if (data && (data.version === 1 || data.version === 2) && Array.isArray(data.comments)) {
+        return data.comments.map(threadFromComment);
       }
     } catch (_) {
       // Corrupted data; fall through to empty array
@@ -31,25 +72,44 @@
     return [];
   }
 
-  function loadComments() {
-    return parseStoredComments(storageKey());
+  function loadThreads() {
+    return parseStoredThreads(storageKey());
   }
 
-  function saveComments(comments) {
-    if (comments.length === 0) {
-      localStorage.removeItem(storageKey());
+  function saveThreadsFor(file, threads) {
+    const key = STORAGE_PREFIX + file;
+    if (threads.length === 0) {
+      localStorage.removeItem(key);
     } else {
       localStorage.setItem(
-        storageKey(),
-        JSON.stringify({ version: 1, comments })
+        key,
+        JSON.stringify({ version: STORAGE_VERSION, threads })
       );
     }
     window.dispatchEvent(new CustomEvent("comments-changed"));
   }
 
-  function purgeComments() {
-    localStorage.removeItem(storageKey());
-    window.dispatchEvent(new CustomEvent("comments-changed"));
+  function saveThreads(threads) {
+    saveThreadsFor(currentFilePath(), threads);
+  }
+
+  // allFileThreads returns the stored threads of every spec file.
+  function allFileThreads() {
+    const files = [];
+    for (let i = 0; i < localStorage.length; i++) {
+      const key = localStorage.key(i);
+      if (!key || !key.startsWith(STORAGE_PREFIX)) continue;
+      const threads = parseStoredThreads(key);
+      if (threads.length > 0) {
+        files.push({ file: key.substring(STORAGE_PREFIX.length), threads });
+      }
+    }
+    files.sort((a, b) => a.file.localeCompare(b.file));
+    return files;
+  }
+
+  function openThreads(threads) {
+    return threads.filter((t) => !t.resolved);
   }
 
   // --- Block helpers ---
@@ -57,85 +117,149 @@
   function getBlocks() {
     const el = document.getElementById("spec-content");
     if (!el) return [];
-    return Array.from(el.children);
+    return Array.from(el.querySelectorAll(":scope > [data-source-line]"));
   }
 
   function blockPreview(block) {
     return (block.textContent || "").trim().substring(0, 80);
   }
 
+  function blockAnchor(block) {
+    return {
+      startLine: parseInt(block.getAttribute("data-source-line"), 10),
+      endLine: parseInt(block.getAttribute("data-source-line-end"), 10),
+      hash: block.getAttribute("data-source-hash") || "",
+      blockTextPreview: blockPreview(block),
+    };
+  }
+
+  function blockAt(line) {
+    return getBlocks().find(
+      (b) => parseInt(b.getAttribute("data-source-line"), 10) === line
+    );
+  }
+
   // --- Reconciliation ---
 
-  function reconcileComments() {
-    const comments = loadComments();
-    if (comments.length === 0) return;
+  // similarity returns the Dice coefficient of the character bigrams of a
+  // and b, between 0 (nothing shared) and 1 (identical).
+  function similarity(a, b) {
+    a = (a || "").toLowerCase().replace(/\s+/g, " ");
+    b = (b || "").toLowerCase().replace(/\s+/g, " ");
+    if (a === b) return 1;
+    if (a.length < 2 || b.length < 2) return 0;
+
+    const bigrams = new Map();
+    for (let i = 0; i < a.length - 1; i++) {
+      const bg = a.substring(i, i + 2);
+      bigrams.set(bg, (bigrams.get(bg) || 0) + 1);
+    }
+    let shared = 0;
+    for (let i = 0; i < b.length - 1; i++) {
+      const bg = b.substring(i, i + 2);
+      const n = bigrams.get(bg) || 0;
+      if (n > 0) {
+        bigrams.set(bg, n - 1);
+        shared++;
+      }
+    }
+    return (2 * shared) / (a.length + b.length - 2);
+  }
 
-    const blocks = getBlocks();
-    const previews = blocks.map(blockPreview);
-
-    for (const c of comments) {
-      // Try exact index first
-      if (
-        blocks[c.blockIndex] &&
-        blockPreview(blocks[c.blockIndex]) === c.blockTextPreview
-      ) {
-        continue;
+  // migrateAnchor converts a version 1 anchor, a block index, to a
+  // line-range anchor using the legacy element order.
+  function migrateAnchor(t) {
+    if (t.startLine || t.blockIndex === undefined) return;
+    const el = document.getElementById("spec-content");
+    const legacy = el ? el.children[t.blockIndex] : null;
+    if (legacy && legacy.hasAttribute("data-source-line")) {
+      Object.assign(t, blockAnchor(legacy));
+    }
+    delete t.blockIndex;
+  }
+
+  // findAnchor locates the block a thread belongs to: first a block with
+  // identical content (nearest to the old position if several), then the
+  // most similar block, preferring one that still overlaps the old lines.
+  function findAnchor(t, anchors) {
+    const distance = (a) => Math.abs(a.startLine - (t.startLine || 0));
+
+    const exact = anchors
+      .filter((a) => t.hash && a.hash === t.hash)
+      .sort((x, y) => distance(x) - distance(y));
+    if (exact.length > 0) return exact[0];
+
+    let best = null;
+    let bestScore = 0;
+    for (const a of anchors) {
+      let score = similarity(a.blockTextPreview, t.blockTextPreview);
+      if (t.startLine && a.startLine <= t.endLine && t.startLine <= a.endLine) {
+        score += 0.1;
       }
+      if (score > bestScore) {
+        best = a;
+        bestScore = score;
+      }
+    }
+    return bestScore >= FUZZY_THRESHOLD ? best : null;
+  }
 
-      // Search for best match
-      let bestIdx = -1;
-      let bestScore = 0;
-      previews.forEach((p, i) => {
-        if (p === c.blockTextPreview) {
-          bestIdx = i;
-          bestScore = 999;
-        } else if (
-          bestScore < 1 &&
-          p &&
-          c.blockTextPreview &&
-          p.substring(0, 40) === c.blockTextPreview.substring(0, 40)
-        ) {
-          bestIdx = i;
-          bestScore = 1;
-        }
-      });
+  function reconcileComments() {
+    const threads = loadThreads();
+    if (threads.length === 0) return;
+
+    const anchors = getBlocks().map(blockAnchor);
 
-      if (bestIdx >= 0) {
-        c.blockIndex = bestIdx;
-        c.blockTextPreview = previews[bestIdx];
+    for (const t of threads) {
+      migrateAnchor(t);
+
+      const anchor = findAnchor(t, anchors);
+      if (anchor) {
+        Object.assign(t, anchor);
+        delete t.stale;
+      } else {
+        // Keep the thread at its last known lines until the text returns.
+        t.stale = true;
       }
     }
 
-    saveComments(comments);
+    saveThreads(threads);
   }
 
   // --- Comment markers ---
 
+  // threadsForBlock returns the threads whose start line falls inside the
+  // block, which includes stale threads left at their last known lines.
+  function threadsForBlock(threads, block) {
+    const a = blockAnchor(block);
+    return threads.filter(
+      (t) => a.startLine <= t.startLine && t.startLine <= a.endLine
+    );
+  }
+
   function applyCommentMarkers() {
     const blocks = getBlocks();
-    const comments = loadComments();
+    const threads = loadThreads();
 
-    const countMap = {};
-    for (const c of comments) {
-      countMap[c.blockIndex] = (countMap[c.blockIndex] || 0) + 1;
-    }
-
-    blocks.forEach((block, idx) => {
+    blocks.forEach((block) => {
       if (getComputedStyle(block).position === "static") {
         block.style.position = "relative";
       }
 
-      const old = block.querySelector(".comment-indicator");
+      const old = block.querySelector(":scope > .comment-indicator");
       if (old) old.remove();
 
-      block.classList.toggle("has-comment", !!countMap[idx]);
+      const own = threadsForBlock(threads, block);
+      const count = openThreads(own).length;
+      block.classList.toggle("has-comment", count > 0);
 
-      const count = countMap[idx];
       const btn = document.createElement("button");
       btn.type = "button";
-      btn.className = "comment-indicator" + (count ? " has-comments" : "");
+      btn.className =
+        "comment-indicator" +
+        (count ? " has-comments" : own.length ? " has-resolved" : "");
       btn.setAttribute("aria-label", "Add comment");
-      btn.setAttribute("data-block-index", idx);
+      btn.setAttribute("data-source-line", block.getAttribute("data-source-line"));
       btn.innerHTML = count
         ? `${COMMENT_SVG}<span class="comment-count">${count}</span>`
         : COMMENT_SVG;
@@ -144,42 +268,54 @@
     });
   }
 
-  // --- LLM prompt formatting ---
-
-  function formatCommentsForLLM() {
-    const comments = loadComments();
-    if (comments.length === 0) return "";
-
-    const filePath = currentFilePath();
-
-    const grouped = {};
-    for (const c of comments) {
-      if (!grouped[c.blockIndex]) {
-        grouped[c.blockIndex] = { preview: c.blockTextPreview, items: [] };
-      }
-      grouped[c.blockIndex].items.push(c.text);
+  // openThread scrolls to a thread and opens its popover, navigating to
+  // the thread's spec first when it belongs to another file.
+  function openThread(file, thread) {
+    if (file !== currentFilePath()) {
+      window.location.href =
+        window.basePath + "/view?file=" + encodeURIComponent(file) + "#thread-" + thread.id;
+      return;
     }
+    const block = blockAt(thread.startLine);
+    if (block) block.scrollIntoView({ behavior: "smooth", block: "center" });
+    window.dispatchEvent(
+      new CustomEvent("open-comment-popover", {
+        detail: { startLine: thread.startLine },
+      })
+    );
+  }
 
-    const lines = [
-      "You are reviewing a technical specification document.",
-      "Apply each requested change to the specification file: " + filePath,
-      "Preserve the existing markdown formatting and style.",
-      "Only modify the sections mentioned. Do not change anything else.",
-      "",
-      "---",
-    ];
-
-    const sortedKeys = Object.keys(grouped).sort((a, b) => Number(a) - Number(b));
-    for (const idx of sortedKeys) {
-      const g = grouped[idx];
-      lines.push("");
-      lines.push(`### Section starting with: "${g.preview}"`);
-      for (const text of g.items) {
-        lines.push("- " + text);
+  // --- Export ---
+
+  // Prompt generation happens on the server, which resolves each thread to
+  // the exact source lines of its block.
+  function exportComments(files, format, template, includeResolved) {
+    return fetch(window.basePath + "/api/comments/export", {
+      method: "POST",
+      headers: { "Content-Type": "application/json" },
+      body: JSON.stringify({ format, template, includeResolved, files }),
+    }).then((resp) =>
+      resp.text().then((text) => {
+        if (!resp.ok) throw new Error(text.trim() || "Export failed");
+        return text;
+      })
+    );
+  }
+
+  function resolveExported(files) {
+    const now = new Date().toISOString();
+    for (const f of files) {
+      const exported = new Set(f.threads.map((t) => t.id));
+      const threads = parseStoredThreads(STORAGE_PREFIX + f.file);
+      for (const t of threads) {
+        if (exported.has(t.id) && !t.resolved) {
+          t.resolved = true;
+          t.resolvedBy = currentAuthor();
+          t.resolvedAt = now;
+        }
       }
+      saveThreadsFor(f.file, threads);
     }
-
-    return lines.join("\n");
   }
 
   // --- Alpine.js components ---
@@ -188,23 +324,24 @@
     Alpine.data("commentPopover", function () {
       return {
         open: false,
-        blockIndex: -1,
-        blockPreviewText: "",
-        comments: [],
+        startLine: 0,
+        threads: [],
         newComment: "",
+        replies: {},
+
+        show(line) {
+          const block = blockAt(line);
+          if (!block) return;
 
-        show(blockIdx) {
-          const blocks = getBlocks();
-          this.blockIndex = blockIdx;
-          this.blockPreviewText = blockPreview(blocks[blockIdx] || {});
-          this.comments = loadComments().filter((c) => c.blockIndex === blockIdx);
+          this.startLine = line;
+          this.threads = threadsForBlock(loadThreads(), block);
           this.newComment = "";
+          this.replies = {};
           this.open = true;
 
           this.$nextTick(() => {
-            const block = blocks[blockIdx];
             const popover = document.getElementById("comment-popover");
-            if (!block || !popover) return;
+            if (!popover) return;
 
             const rect = block.getBoundingClientRect();
             const popoverHeight = popover.offsetHeight || 300;
@@ -220,67 +357,224 @@
           });
         },
 
+        refresh(all) {
+          const block = blockAt(this.startLine);
+          this.threads = block ? threadsForBlock(all, block) : [];
+          applyCommentMarkers();
+        },
+
+        // update applies fn to a stored thread, dropping the thread once its
+        // last comment is deleted.
+        update(threadID, fn) {
+          const all = loadThreads();
+          const thread = all.find((t) => t.id === threadID);
+          if (!thread) return;
+          fn(thread);
+          const kept = all.filter((t) => t.comments.length > 0);
+          saveThreads(kept);
+          this.refresh(kept);
+        },
+
+        formatTime(iso) {
+          return iso ? new Date(iso).toLocaleString() : "";
+        },
+
         addComment() {
           const text = this.newComment.trim();
+          const block = blockAt(this.startLine);
+          if (!text || !block) return;
+
+          const all = loadThreads();
+          all.push(
+            Object.assign(
+              {
+                id: newID(),
+                resolved: false,
+                comments: [
+                  {
+                    id: newID(),
+                    author: currentAuthor(),
+                    text,
+                    createdAt: new Date().toISOString(),
+                  },
+                ],
+              },
+              blockAnchor(block)
+            )
+          );
+          saveThreads(all);
+
+          this.newComment = "";
+          this.refresh(all);
+        },
+
+        reply(threadID) {
+          const text = (this.replies[threadID] || "").trim();
           if (!text) return;
 
-          const all = loadComments();
-          all.push({
-            id: Date.now().toString(36) + Math.random().toString(36).substring(2, 7),
-            blockIndex: this.blockIndex,
-            blockTextPreview: this.blockPreviewText,
-            text,
-            createdAt: new Date().toISOString(),
+          this.update(threadID, (t) => {
+            t.comments.push({
+              id: newID(),
+              author: currentAuthor(),
+              text,
+              createdAt: new Date().toISOString(),
+            });
           });
-          saveComments(all);
-
-          this.newComment = "";
-          this.comments = all.filter((c) => c.blockIndex === this.blockIndex);
-          applyCommentMarkers();
+          this.replies[threadID] = "";
         },
 
-        deleteComment(id) {
-          const all = loadComments().filter((c) => c.id !== id);
-          saveComments(all);
+        setResolved(threadID, resolved) {
+          this.update(threadID, (t) => {
+            t.resolved = resolved;
+            if (resolved) {
+              t.resolvedBy = currentAuthor();
+              t.resolvedAt = new Date().toISOString();
+            } else {
+              delete t.resolvedBy;
+              delete t.resolvedAt;
+            }
+          });
+        },
 
-          this.comments = all.filter((c) => c.blockIndex === this.blockIndex);
-          applyCommentMarkers();
+        deleteComment(threadID, commentID) {
+          this.update(threadID, (t) => {
+            t.comments = t.comments.filter((c) => c.id !== commentID);
+          });
 
-          if (this.comments.length === 0 && !this.newComment) {
+          if (this.threads.length === 0 && !this.newComment) {
             this.open = false;
           }
         },
       };
     });
 
+    Alpine.data("reviewPanel", function () {
+      return {
+        open: false,
+        tab: "file",
+        showResolved: false,
+        author: "",
+        files: [],
+
+        init() {
+          this.author = currentAuthor();
+          this.load();
+          window.addEventListener("comments-changed", () => this.load());
+          window.addEventListener("review-author-loaded", () => {
+            if (!this.author) this.author = currentAuthor();
+          });
+        },
+
+        load() {
+          this.files = allFileThreads();
+        },
+
+        saveAuthor() {
+          const name = this.author.trim();
+          if (name && name !== serverAuthor) {
+            localStorage.setItem(AUTHOR_KEY, name);
+          } else {
+            localStorage.removeItem(AUTHOR_KEY);
+          }
+          this.author = currentAuthor();
+          window.dispatchEvent(new CustomEvent("review-author-loaded"));
+        },
+
+        // groups returns the files shown in the active tab, each with the
+        // threads that pass the resolved filter in source order.
+        groups() {
+          const current = currentFilePath();
+          return this.files
+            .filter((f) => this.tab === "all" || f.file === current)
+            .map((f) => ({
+              file: f.file,
+              threads: (this.showResolved ? f.threads : openThreads(f.threads))
+                .slice()
+                .sort((a, b) => (a.startLine || 0) - (b.startLine || 0)),
+            }))
+            .filter((f) => f.threads.length > 0);
+        },
+
+        openCount(scope) {
+          const current = currentFilePath();
+          return this.files
+            .filter((f) => scope === "all" || f.file === current)
+            .reduce((n, f) => n + openThreads(f.threads).length, 0);
+        },
+
+        go(file, thread) {
+          openThread(file, thread);
+        },
+      };
+    });
+
     Alpine.data("copyComments", function () {
       return {
         hasComments: false,
+        hasFileComments: false,
+        menuOpen: false,
         copied: false,
+        error: "",
+        format: "markdown",
+        scope: "file",
+        template: "",
+        templates: [],
+        includeResolved: false,
+        resolveAfterCopy: false,
 
         init() {
           this.checkComments();
           window.addEventListener("comments-changed", () => {
             this.checkComments();
           });
+          fetch(window.basePath + "/api/comments/templates")
+            .then((resp) => (resp.ok ? resp.json() : []))
+            .then((names) => {
+              this.templates = names || [];
+              if (!this.template && this.templates.length > 0) {
+                this.template = this.templates[0];
+              }
+            })
+            .catch(() => {});
         },
 
         checkComments() {
-          this.hasComments = loadComments().length > 0;
+          this.hasFileComments = loadThreads().length > 0;
+          this.hasComments = allFileThreads().length > 0;
+          if (!this.hasFileComments) this.scope = "all";
         },
 
-        copyAndPurge() {
-          const prompt = formatCommentsForLLM();
-          if (!prompt) return;
-
-          navigator.clipboard.writeText(prompt).then(() => {
-            this.copied = true;
-            setTimeout(() => {
-              purgeComments();
-              applyCommentMarkers();
-              this.copied = false;
-            }, 1500);
-          });
+        copy() {
+          const current = currentFilePath();
+          const files = allFileThreads()
+            .filter((f) => this.scope === "all" || f.file === current)
+            .map((f) => ({
+              file: f.file,
+              threads: this.includeResolved ? f.threads : openThreads(f.threads),
+            }))
+            .filter((f) => f.threads.length > 0);
+          if (files.length === 0) {
+            this.error = "No open threads to export";
+            return;
+          }
+
+          this.error = "";
+          exportComments(files, this.format, this.template, this.includeResolved)
+            .then((text) => navigator.clipboard.writeText(text))
+            .then(() => {
+              this.copied = true;
+              setTimeout(() => {
+                if (this.resolveAfterCopy) {
+                  resolveExported(files);
+                  applyCommentMarkers();
+                }
+                this.copied = false;
+                this.menuOpen = false;
+              }, 1500);
+            })
+            .catch((err) => {
+              this.error = err.message;
+            });
         },
       };
     });
@@ -299,7 +593,7 @@
       if (!match) continue;
 
       const filePath = decodeURIComponent(match[1]);
-      const count = parseStoredComments("specComments:" + filePath).length;
+      const count = openThreads(parseStoredThreads(STORAGE_PREFIX + filePath)).length;
       if (count > 0) {
         const badge = document.createElement("span");
         badge.className = "sidebar-comment-badge";
@@ -315,27 +609,43 @@
     const btn = e.target.closest(".comment-indicator");
     if (!btn) return;
 
-    const idx = parseInt(btn.getAttribute("data-block-index"), 10);
-    if (isNaN(idx)) return;
+    const line = parseInt(btn.getAttribute("data-source-line"), 10);
+    if (isNaN(line)) return;
 
     window.dispatchEvent(
-      new CustomEvent("open-comment-popover", { detail: { blockIndex: idx } })
+      new CustomEvent("open-comment-popover", { detail: { startLine: line } })
     );
   });
 
   // --- Init on page load ---
 
+  fetch(window.basePath + "/api/identity")
+    .then((resp) => (resp.ok ? resp.json() : {}))
+    .then((data) => {
+      serverAuthor = (data && data.name) || "";
+      window.dispatchEvent(new CustomEvent("review-author-loaded"));
+    })
+    .catch(() => {});
+
   document.addEventListener("DOMContentLoaded", () => {
     updateSidebarBadges();
     if (!currentFilePath()) return;
     reconcileComments();
     applyCommentMarkers();
+
+    // Links from the review panel of another spec point at a thread.
+    const match = window.location.hash.match(/^#thread-(.+)$/);
+    if (match) {
+      const thread = loadThreads().find((t) => t.id === match[1]);
+      if (thread) setTimeout(() => openThread(currentFilePath(), thread), 0);
+    }
   });
 
   window.addEventListener("comments-changed", updateSidebarBadges);
 
-  // --- Expose globally for smart reload ---
+  // --- Expose globally for smart reload and presence ---
 
   window.applyCommentMarkers = applyCommentMarkers;
   window.reconcileComments = reconcileComments;
+  window.currentAuthor = currentAuthor;
 })();
@@ -0,0 +1,48 @@
+// Data table module — sorts CSV tables by the column header clicked. Also
+// inlined into HTML exports, so it must not depend on the viewer page.
+(function () {
+  "use strict";
+
+  function cellValue(row, index) {
+    var cell = row.cells[index];
+    return cell ? cell.textContent.trim() : "";
+  }
+
+  // Empty cells sort last in either direction.
+  function compare(a, b, numeric) {
+    if (a === "" || b === "") return a === b ? 0 : a === "" ? 1 : -1;
+    if (numeric) return parseFloat(a) - parseFloat(b);
+    return a.localeCompare(b, undefined, { numeric: true, sensitivity: "base" });
+  }
+
+  function sort(th) {
+    var table = th.closest("table");
+    var body = table.tBodies[0];
+    if (!body) return;
+
+    var index = Array.prototype.indexOf.call(th.parentElement.children, th);
+    var ascending = th.getAttribute("aria-sort") !== "ascending";
+    var numeric = th.classList.contains("numeric");
+
+    table.querySelectorAll("thead th").forEach(function (other) {
+      other.setAttribute("aria-sort", "none");
+    });
+    th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+
+    var rows = Array.prototype.slice.call(body.rows);
+    rows.sort(function (a, b) {
+      var result = compare(cellValue(a, index), cellValue(b, index), numeric);
+      if (cellValue(a, index) === "" || cellValue(b, index) === "") return result;
+      return ascending ? result : -result;
+    });
+    rows.forEach(function (row) {
+      body.appendChild(row);
+    });
+  }
+
+  // Delegated, so tables replaced by live reload keep sorting.
+  document.addEventListener("click", function (event) {
+    var button = event.target.closest("table[data-sortable] thead th button");
+    if (button) sort(button.closest("th"));
+  });
+})();
@@ -11,3 +11,7 @@
         }
     } catch (e) { }
 })();
+
+// Path the viewer is served under, "" at the root of the host. Scripts
+// prefix the URLs they request with it.
+window.basePath = (document.querySelector('meta[name="base-path"]') || {}).content || "";
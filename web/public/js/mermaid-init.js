@@ -37,6 +37,13 @@
       div.classList.add("mermaid");
       div.textContent = diagramSource;
 
+      // Keep the source position so comments stay anchored to the diagram.
+      Array.prototype.forEach.call(preEl.attributes, function (attr) {
+        if (attr.name.indexOf("data-source-") === 0) {
+          div.setAttribute(attr.name, attr.value);
+        }
+      });
+
       preEl.parentNode.replaceChild(div, preEl);
       containers.push(div);
     });
@@ -59,7 +66,7 @@
 
     var containers = prepareMermaidBlocks();
     if (containers.length > 0) {
-      mermaid.run({ nodes: containers });
+      mermaid.run({ nodes: containers }).then(restoreCommentMarkers);
     }
   }
 
@@ -99,7 +106,17 @@
       }
     });
 
-    mermaid.run({ nodes: Array.from(containers) });
+    mermaid.run({ nodes: Array.from(containers) }).then(restoreCommentMarkers);
+  }
+
+  /**
+   * Rendering replaces the container contents, including any comment
+   * indicator, so put the indicators back once diagrams are drawn.
+   */
+  function restoreCommentMarkers() {
+    if (window.applyCommentMarkers) {
+      window.applyCommentMarkers();
+    }
   }
 
   // On first prepareMermaidBlocks, also stash the original source so we can
@@ -0,0 +1,178 @@
+// Presence module — who is viewing which spec, and a follow mode that keeps
+// followers on the presenter's spec and scroll position
+(function () {
+  "use strict";
+
+  // Set while this tab follows the presenter, so that it keeps following
+  // after navigating to the presenter's spec.
+  const FOLLOW_KEY = "specFollowPresenter";
+
+  // Set while this tab presents, so that it keeps presenting after
+  // navigating to another spec, which opens a new connection.
+  const PRESENT_KEY = "specPresenting";
+
+  // Minimum delay between two positions sent by the presenter, in ms.
+  const POSITION_INTERVAL = 150;
+
+  function currentFilePath() {
+    return new URLSearchParams(window.location.search).get("file") || "";
+  }
+
+  function send(message) {
+    const ws = window.specSocket;
+    if (ws && ws.readyState === WebSocket.OPEN) ws.send(JSON.stringify(message));
+  }
+
+  function hello() {
+    send({ type: "hello", name: window.currentAuthor ? window.currentAuthor() : "" });
+  }
+
+  function scrollContainer() {
+    const content = document.getElementById("spec-content");
+    return content ? content.closest(".overflow-y-auto") : null;
+  }
+
+  // topLine returns the source line of the first block at the top of the
+  // screen, or 0 when the page shows no spec.
+  function topLine() {
+    const container = scrollContainer();
+    const top = container ? container.getBoundingClientRect().top : 0;
+    const blocks = document.querySelectorAll("#spec-content > [data-source-line]");
+    let line = 0;
+    for (const block of blocks) {
+      if (block.getBoundingClientRect().bottom > top + 1) {
+        return parseInt(block.dataset.sourceLine, 10);
+      }
+      line = parseInt(block.dataset.sourceLine, 10);
+    }
+    return line;
+  }
+
+  // scrollToLine scrolls the spec to the last block starting at or before
+  // line.
+  function scrollToLine(line) {
+    const container = scrollContainer();
+    if (!container) return;
+    let target = null;
+    for (const block of document.querySelectorAll("#spec-content > [data-source-line]")) {
+      if (parseInt(block.dataset.sourceLine, 10) > line) break;
+      target = block;
+    }
+    if (line <= 1 || !target) {
+      container.scrollTop = 0;
+      return;
+    }
+    const offset = target.getBoundingClientRect().top - container.getBoundingClientRect().top;
+    container.scrollTop += offset;
+  }
+
+  document.addEventListener("alpine:init", () => {
+    Alpine.data("presence", function () {
+      return {
+        open: false,
+        self: "",
+        clients: [],
+        following: sessionStorage.getItem(FOLLOW_KEY) === "1",
+        lastSent: 0,
+        pending: null,
+
+        init() {
+          window.addEventListener("spec-socket-open", () => this.join());
+          window.addEventListener("review-author-loaded", () => hello());
+          window.addEventListener("spec-socket-message", (e) => this.receive(e.detail));
+          if (window.specSocket) this.join();
+
+          const container = scrollContainer();
+          if (container) container.addEventListener("scroll", () => this.scrolled(), { passive: true });
+        },
+
+        join() {
+          hello();
+          if (sessionStorage.getItem(PRESENT_KEY) === "1") send({ type: "present", on: true });
+        },
+
+        receive(message) {
+          if (message.type === "presence") {
+            this.self = message.self;
+            this.clients = message.clients;
+            const p = this.presenter();
+            if (p && p.id !== this.self) sessionStorage.removeItem(PRESENT_KEY);
+            // A presenter that reconnected tells its followers where it is.
+            if (this.presenting()) this.sendPosition();
+          } else if (message.type === "follow" && this.following) {
+            this.follow(message);
+          }
+        },
+
+        presenter() {
+          return this.clients.find((c) => c.presenter) || null;
+        },
+
+        presenting() {
+          const p = this.presenter();
+          return p !== null && p.id === this.self;
+        },
+
+        others() {
+          return this.clients.filter((c) => c.id !== this.self);
+        },
+
+        togglePresent() {
+          const on = !this.presenting();
+          if (on) {
+            this.setFollowing(false);
+            sessionStorage.setItem(PRESENT_KEY, "1");
+          } else {
+            sessionStorage.removeItem(PRESENT_KEY);
+          }
+          send({ type: "present", on: on });
+          if (on) this.sendPosition();
+        },
+
+        setFollowing(on) {
+          this.following = on;
+          if (on) {
+            sessionStorage.setItem(FOLLOW_KEY, "1");
+          } else {
+            sessionStorage.removeItem(FOLLOW_KEY);
+          }
+        },
+
+        // scrolled sends the presenter's position, at most once per
+        // POSITION_INTERVAL and always after the last scroll.
+        scrolled() {
+          if (!this.presenting()) return;
+          const wait = this.lastSent + POSITION_INTERVAL - Date.now();
+          if (wait <= 0) {
+            this.sendPosition();
+            return;
+          }
+          if (!this.pending) {
+            this.pending = setTimeout(() => {
+              this.pending = null;
+              this.sendPosition();
+            }, wait);
+          }
+        },
+
+        sendPosition() {
+          this.lastSent = Date.now();
+          send({ type: "position", file: currentFilePath(), line: topLine() });
+        },
+
+        follow(message) {
+          if (message.file !== currentFilePath()) {
+            window.location.href =
+              window.basePath + (message.file ? "/view?file=" + encodeURIComponent(message.file) : "/");
+            return;
+          }
+          scrollToLine(message.line);
+        },
+
+        label(c) {
+          return c.file || "Home";
+        },
+      };
+    });
+  });
+})();
@@ -13,49 +13,171 @@
     });
   });
 
-  function connect(scrollContainer) {
-    var ws = new WebSocket("ws://" + window.location.host + "/ws");
+  function currentFile() {
+    return new URLSearchParams(window.location.search).get("file");
+  }
+
+  function connect(scrollContainer, reconnecting) {
+    var scheme = window.location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(scheme + window.location.host + window.basePath + "/ws");
+
+    // Only changes to the spec shown concern this tab; pages without one
+    // follow the whole folder. Messages sent while the connection was down,
+    // or dropped because the tab fell behind, are lost, so catch up once it
+    // is back.
+    ws.onopen = function () {
+      ws.send("subscribe:" + (currentFile() || ""));
+      if (reconnecting) refresh(scrollContainer);
+      // Other modules, such as presence, share the connection.
+      window.specSocket = ws;
+      window.dispatchEvent(new CustomEvent("spec-socket-open"));
+    };
 
     ws.onmessage = function (event) {
-      if (event.data !== "reload") return;
+      var file = currentFile();
 
-      var file = new URLSearchParams(window.location.search).get("file");
-      if (!file) {
-        window.location.reload();
+      // "update" messages carry the spec rendered by the server; other JSON
+      // messages are for the modules sharing the connection.
+      if (event.data.charAt(0) === "{") {
+        var update = JSON.parse(event.data);
+        if (update.type !== "update") {
+          window.dispatchEvent(new CustomEvent("spec-socket-message", { detail: update }));
+          return;
+        }
+        if (!file) {
+          window.location.reload();
+        } else if (update.file === file) {
+          apply(scrollContainer, update.html, update.toc);
+        }
         return;
       }
 
-      var scrollTop = scrollContainer ? scrollContainer.scrollTop : 0;
+      // "tree" concerns every page, as they all list the specs.
+      if (event.data === "tree") {
+        refreshTree();
+        return;
+      }
+
+      // "reload" concerns every page, "reload:<file>" only the page of that
+      // spec, which includes the specs that include a changed one.
+      if (event.data !== "reload" && event.data.indexOf("reload:") !== 0) return;
+      if (file && event.data !== "reload" && event.data !== "reload:" + file) return;
+      refresh(scrollContainer);
+    };
+
+    ws.onclose = function () {
+      if (window.specSocket === ws) window.specSocket = null;
+      setTimeout(function () {
+        connect(scrollContainer, true);
+      }, 1000);
+    };
+  }
+
+  // refresh fetches the spec content and replaces it in place, or reloads
+  // pages that show no spec.
+  function refresh(scrollContainer) {
+    var file = currentFile();
+    if (!file) {
+      window.location.reload();
+      return;
+    }
+
+    fetch(window.basePath + "/api/view?file=" + encodeURIComponent(file))
+      .then(function (resp) {
+        if (!resp.ok) throw new Error("Failed to fetch content");
+        return resp.text();
+      })
+      .then(function (html) {
+        apply(scrollContainer, html);
+      })
+      .catch(function () {
+        window.location.reload();
+      });
+  }
 
-      fetch("/api/view?file=" + encodeURIComponent(file))
+  // Delay before refreshing the specs listed, in ms, so that the events of
+  // a rename or of a folder copied at once refresh them once.
+  var TREE_DELAY = 100;
+  var treeTimer = null;
+
+  // refreshTree replaces the parts of the page that list the specs, marked
+  // with data-spec-tree, such as the sidebar and the previous and next
+  // links, once specs were added, removed or renamed.
+  function refreshTree() {
+    clearTimeout(treeTimer);
+    treeTimer = setTimeout(function () {
+      fetch(window.location.href)
         .then(function (resp) {
-          if (!resp.ok) throw new Error("Failed to fetch content");
+          // A redirect means the spec shown is gone.
+          if (!resp.ok || resp.redirected) throw new Error("Failed to fetch page");
           return resp.text();
         })
         .then(function (html) {
-          var el = document.getElementById("spec-content");
-          if (!el) {
-            window.location.reload();
-            return;
-          }
-
-          el.innerHTML = html;
-
-          if (scrollContainer) {
-            scrollContainer.scrollTop = scrollTop;
-          }
-          if (window.reconcileComments) window.reconcileComments();
-          if (window.applyCommentMarkers) window.applyCommentMarkers();
+          var page = new DOMParser().parseFromString(html, "text/html");
+          var fresh = page.querySelectorAll("[data-spec-tree]");
+          var current = document.querySelectorAll("[data-spec-tree]");
+          if (fresh.length !== current.length) throw new Error("Page layout changed");
+          current.forEach(function (el, i) {
+            el.innerHTML = fresh[i].innerHTML;
+          });
+          // Keep the sidebar filtered by the search term.
+          document.querySelectorAll("[data-spec-filter]").forEach(function (input) {
+            input.dispatchEvent(new Event("input"));
+          });
         })
         .catch(function () {
           window.location.reload();
         });
-    };
+    }, TREE_DELAY);
+  }
 
-    ws.onclose = function () {
-      setTimeout(function () {
-        connect(scrollContainer);
-      }, 1000);
-    };
+  // apply replaces the spec content, keeping the scroll position, and the
+  // table of contents when toc is given.
+  function apply(scrollContainer, html, toc) {
+    var el = document.getElementById("spec-content");
+    if (!el) {
+      window.location.reload();
+      return;
+    }
+    if (toc && !updateTOC(toc)) {
+      window.location.reload();
+      return;
+    }
+
+    var scrollTop = scrollContainer ? scrollContainer.scrollTop : 0;
+    el.innerHTML = html;
+
+    if (scrollContainer) {
+      scrollContainer.scrollTop = scrollTop;
+    }
+    if (window.reconcileComments) window.reconcileComments();
+    if (window.applyCommentMarkers) window.applyCommentMarkers();
+  }
+
+  // updateTOC rebuilds the table of contents lists. It returns false when
+  // the page has to be reloaded to add or remove them.
+  function updateTOC(toc) {
+    var lists = document.querySelectorAll("[data-toc]");
+    if (lists.length === 0 || toc.length === 0) return lists.length === 0 && toc.length === 0;
+
+    lists.forEach(function (list) {
+      list.replaceChildren.apply(
+        list,
+        toc.map(function (entry) {
+          var li = document.createElement("li");
+          li.style.paddingLeft = (entry.level - 1) * 12 + "px";
+          var a = document.createElement("a");
+          a.href = "#" + entry.id;
+          a.className = "block py-1 text-muted-foreground hover:text-foreground transition-colors truncate";
+          a.textContent = entry.text;
+          if (list.getAttribute("data-toc") === "mobile") {
+            a.setAttribute("x-on:click", "mobileOpen = false");
+          }
+          li.appendChild(a);
+          return li;
+        })
+      );
+    });
+    return true;
   }
 })();